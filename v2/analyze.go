@@ -0,0 +1,158 @@
+package flac
+
+import (
+	"io"
+	"math"
+)
+
+// ChannelStats are the level statistics of one channel, with levels normalized to full scale (1.0)
+type ChannelStats struct {
+	// Clipped is the number of samples at the lowest or highest value of the bit depth
+	Clipped int64
+	// DCOffset is the mean sample value
+	DCOffset float64
+	// Peak is the highest absolute sample value
+	Peak float64
+	// TruePeak is the highest absolute value of the signal estimated by 4x oversampling, which can exceed full scale between samples
+	TruePeak float64
+}
+
+// TruePeakDB returns TruePeak in dBTP, -Inf for silence
+func (s ChannelStats) TruePeakDB() float64 {
+	return 20 * math.Log10(s.TruePeak)
+}
+
+// AudioStats are the level statistics of a stream for mastering quality control
+type AudioStats struct {
+	// Samples is the number of inter-channel samples analyzed
+	Samples int64
+	// Channels holds the statistics of each channel
+	Channels []ChannelStats
+}
+
+const (
+	// truePeakOversampling is the oversampling factor of the true-peak estimate, as recommended by ITU-R BS.1770
+	truePeakOversampling = 4
+	// truePeakTaps is the number of input samples each interpolated value is computed from
+	truePeakTaps = 16
+)
+
+// truePeakFilter holds the windowed-sinc interpolation coefficients of each oversampling phase between two samples
+var truePeakFilter = func() (res [truePeakOversampling][truePeakTaps]float64) {
+	half := float64(truePeakTaps / 2)
+	for p := 1; p < truePeakOversampling; p++ {
+		for k := range res[p] {
+			// distance of the tap from the interpolated position
+			x := float64(k) - (half - 1) - float64(p)/truePeakOversampling
+			sinc := math.Sin(math.Pi*x) / (math.Pi * x)
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/half)
+			res[p][k] = sinc * window
+		}
+	}
+	return
+}()
+
+// channelAnalyzer accumulates the statistics of one channel
+type channelAnalyzer struct {
+	stats   ChannelStats
+	sum     float64
+	history [truePeakTaps]float64
+	filled  int
+}
+
+func (a *channelAnalyzer) add(v float64) {
+	a.sum += v
+	if abs := math.Abs(v); abs > a.stats.Peak {
+		a.stats.Peak = abs
+	}
+	copy(a.history[:], a.history[1:])
+	a.history[truePeakTaps-1] = v
+	if a.filled < truePeakTaps {
+		a.filled++
+		return
+	}
+	for p := 1; p < truePeakOversampling; p++ {
+		var y float64
+		for k, h := range truePeakFilter[p] {
+			y += a.history[k] * h
+		}
+		if abs := math.Abs(y); abs > a.stats.TruePeak {
+			a.stats.TruePeak = abs
+		}
+	}
+}
+
+// PCMAnalyzer accumulates level statistics over decoded audio frames
+type PCMAnalyzer struct {
+	bitDepth int
+	samples  int64
+	channels []channelAnalyzer
+}
+
+// NewPCMAnalyzer returns a PCMAnalyzer of audio with the given number of channels and bits per sample
+func NewPCMAnalyzer(channels, bitDepth int) *PCMAnalyzer {
+	return &PCMAnalyzer{bitDepth: bitDepth, channels: make([]channelAnalyzer, channels)}
+}
+
+// Add accounts the samples of a decoded frame
+func (c *PCMAnalyzer) Add(frame *AudioFrame) error {
+	if len(frame.Samples) != len(c.channels) {
+		return ErrorInvalidFrameHeader
+	}
+	fullScale := math.Ldexp(1, c.bitDepth-1)
+	lowest, highest := -int32(fullScale), int32(fullScale-1)
+	for ch, samples := range frame.Samples {
+		a := &c.channels[ch]
+		for _, v := range samples {
+			if v <= lowest || v >= highest {
+				a.stats.Clipped++
+			}
+			a.add(float64(v) / fullScale)
+		}
+	}
+	c.samples += int64(frame.Header.BlockSize)
+	return nil
+}
+
+// Stats returns the statistics of the samples added so far
+func (c *PCMAnalyzer) Stats() *AudioStats {
+	res := &AudioStats{Samples: c.samples, Channels: make([]ChannelStats, len(c.channels))}
+	for i := range c.channels {
+		a := &c.channels[i]
+		stats := a.stats
+		if c.samples > 0 {
+			stats.DCOffset = a.sum / float64(c.samples)
+		}
+		if stats.TruePeak < stats.Peak {
+			stats.TruePeak = stats.Peak
+		}
+		res.Channels[i] = stats
+	}
+	return res
+}
+
+// AnalyzeAudio decodes the audio of the File and reports clipped samples, DC offset, sample peak and true-peak estimate per channel.
+// Decoding consumes Frames.
+func (c *File) AnalyzeAudio() (*AudioStats, error) {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	dec, err := c.Decoder()
+	if err != nil {
+		return nil, err
+	}
+	analyzer := NewPCMAnalyzer(info.ChannelCount, info.BitDepth)
+	for {
+		frame, err := dec.Next()
+		if err == io.EOF {
+			return analyzer.Stats(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := analyzer.Add(frame); err != nil {
+			return nil, err
+		}
+	}
+}
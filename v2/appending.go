@@ -0,0 +1,139 @@
+package flac
+
+import "io"
+
+// AppendingWriter writes a FLAC stream whose audio frames are produced while it is written, such as a live recording.
+// The header is written up front with an unknown sample count and MD5; Close patches the StreamInfo block in place when the output is seekable.
+type AppendingWriter struct {
+	w      io.Writer
+	start  int64
+	closed bool
+	stats  frameStats
+}
+
+// frameStats accumulates the StreamInfo values derived from the audio frames of a stream
+type frameStats struct {
+	info          StreamInfoBlock
+	frames        int
+	lastBlockSize int
+}
+
+// NewAppendingWriter writes the "fLaC" marker, a StreamInfo block built from info and the given metadata blocks to w, and returns a writer for the audio frames.
+// Only SampleRate, ChannelCount and BitDepth of info must be set; the sample count, block and frame sizes are tracked as frames are written.
+// BlockSizeMin and BlockSizeMax of info are written to the header until Close replaces them, so players reading the file while it grows see plausible values.
+func NewAppendingWriter(w io.Writer, info StreamInfoBlock, meta ...*MetaDataBlock) (*AppendingWriter, error) {
+	info.SampleCount = 0
+	info.FrameSizeMin = 0
+	info.FrameSizeMax = 0
+	info.AudioMD5 = make([]byte, 16)
+	res := &AppendingWriter{w: w, start: -1}
+
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			res.start = pos
+		}
+	}
+
+	head := &File{Meta: append([]*MetaDataBlock{{Type: StreamInfo, Data: info.encode()}}, meta...)}
+	if _, err := head.WriteTo(w); err != nil {
+		return nil, err
+	}
+	res.stats.reset(info)
+	return res, nil
+}
+
+// WriteFrame writes one complete encoded audio frame.
+// The frame header is decoded to account for its block size; the rest of the frame is written as is.
+func (c *AppendingWriter) WriteFrame(frame []byte) error {
+	if c.closed {
+		return ErrorWriterClosed
+	}
+	header, err := ParseFrameHeader(frame)
+	if err != nil {
+		return err
+	}
+	if _, err := c.w.Write(frame); err != nil {
+		return err
+	}
+
+	c.stats.account(header, len(frame))
+	return nil
+}
+
+// reset starts accumulating from info, clearing the values derived from frames
+func (c *frameStats) reset(info StreamInfoBlock) {
+	c.info = info
+	c.info.SampleCount = 0
+	c.info.BlockSizeMin, c.info.BlockSizeMax = 0, 0
+	c.info.FrameSizeMin, c.info.FrameSizeMax = 0, 0
+	c.frames = 0
+	c.lastBlockSize = 0
+}
+
+// account updates the StreamInfo values with a frame of the given header and size
+func (c *frameStats) account(header *FrameHeader, size int) {
+	// the last block may be shorter than the minimum block size, so the previous block is accounted only once another one follows
+	if c.frames > 0 && (c.info.BlockSizeMin == 0 || c.lastBlockSize < c.info.BlockSizeMin) {
+		c.info.BlockSizeMin = c.lastBlockSize
+	}
+	if header.BlockSize > c.info.BlockSizeMax {
+		c.info.BlockSizeMax = header.BlockSize
+	}
+	if c.info.FrameSizeMin == 0 || size < c.info.FrameSizeMin {
+		c.info.FrameSizeMin = size
+	}
+	if size > c.info.FrameSizeMax {
+		c.info.FrameSizeMax = size
+	}
+	c.info.SampleCount += int64(header.BlockSize)
+	c.lastBlockSize = header.BlockSize
+	c.frames++
+}
+
+// SetAudioMD5 sets the MD5 signature of the unencoded audio written to StreamInfo on Close, for producers hashing the samples they encode
+func (c *AppendingWriter) SetAudioMD5(sum []byte) {
+	c.stats.info.AudioMD5 = append([]byte(nil), sum...)
+}
+
+// StreamInfo returns the StreamInfo values accumulated from the frames written so far
+func (c *AppendingWriter) StreamInfo() StreamInfoBlock {
+	return c.stats.result()
+}
+
+// result returns the accumulated StreamInfo values
+func (c *frameStats) result() StreamInfoBlock {
+	res := c.info
+	if c.frames == 1 || res.BlockSizeMin == 0 {
+		res.BlockSizeMin = c.lastBlockSize
+	}
+	res.AudioMD5 = append([]byte(nil), c.info.AudioMD5...)
+	return res
+}
+
+// Close finalizes the stream. If the output implements io.Seeker, the StreamInfo block is rewritten in place with the
+// sample count, block and frame sizes of the frames written and the MD5 set by SetAudioMD5, and the position is restored to the end of the stream.
+// Non-seekable outputs keep the unknown values written by NewAppendingWriter. The underlying writer is not closed.
+func (c *AppendingWriter) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.start < 0 || c.stats.frames == 0 {
+		return nil
+	}
+	s := c.w.(io.WriteSeeker)
+	end, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	// StreamInfo data follows the "fLaC" marker and its 4 byte block header
+	if _, err := s.Seek(c.start+8, io.SeekStart); err != nil {
+		return err
+	}
+	info := c.StreamInfo()
+	if _, err := s.Write(info.encode()); err != nil {
+		return err
+	}
+	_, err = s.Seek(end, io.SeekStart)
+	return err
+}
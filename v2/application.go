@@ -0,0 +1,45 @@
+package flac
+
+import "bytes"
+
+// ApplicationBlock is the decoded form of an Application metadata block
+type ApplicationBlock struct {
+	// ID is the registered application ID, e.g. "riff" or "aiff"
+	ID [4]byte
+	// Data is the application defined payload following the ID
+	Data []byte
+}
+
+// ParseApplication decodes an Application metadata block, loading it first if it was parsed lazily.
+// The payload is not copied, so modifying Data of the result also modifies the block.
+func ParseApplication(meta *MetaDataBlock) (*ApplicationBlock, error) {
+	if meta.Type != Application {
+		return nil, ErrorUnexpectedBlockType
+	}
+	if err := meta.Load(); err != nil {
+		return nil, err
+	}
+	if len(meta.Data) < 4 {
+		return nil, ErrorApplicationIDMissing
+	}
+	res := new(ApplicationBlock)
+	copy(res.ID[:], meta.Data)
+	res.Data = meta.Data[4:]
+	return res, nil
+}
+
+// Marshal encodes the ApplicationBlock into a MetaDataBlock
+func (c *ApplicationBlock) Marshal() MetaDataBlock {
+	data := make([]byte, 0, 4+len(c.Data))
+	data = append(data, c.ID[:]...)
+	data = append(data, c.Data...)
+	return MetaDataBlock{
+		Type: Application,
+		Data: data,
+	}
+}
+
+// Equal reports whether both blocks have the same ID and payload
+func (c *ApplicationBlock) Equal(other *ApplicationBlock) bool {
+	return c.ID == other.ID && bytes.Equal(c.Data, other.Data)
+}
@@ -0,0 +1,115 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat formats backup timestamps so that backups sort by name in the order they were made
+const backupTimeFormat = "20060102-150405.000000000"
+
+// WithBackup makes Save copy the existing output file before modifying it, keeping the keepN most recent copies.
+// The last "*" in pattern is replaced by a timestamp, and a relative pattern is relative to the directory of the output,
+// for example ".backup/song-*.flac". Older backups matching the pattern are removed. A keepN below 1 keeps every backup.
+func WithBackup(pattern string, keepN int) SaveOption {
+	return func(c *saveConfig) {
+		c.backup = pattern
+		c.backupKeep = keepN
+		c.backupHeader = false
+	}
+}
+
+// WithHeaderBackup is WithBackup saving only the "fLaC" marker and the metadata blocks of the existing file, which is all
+// a metadata edit changes, instead of the whole file
+func WithHeaderBackup(pattern string, keepN int) SaveOption {
+	return func(c *saveConfig) {
+		c.backup = pattern
+		c.backupKeep = keepN
+		c.backupHeader = true
+	}
+}
+
+// backupFile copies fn, or its header, to a new backup named after cfg.backup and removes the backups beyond cfg.backupKeep.
+// Nothing is done if fn does not exist yet.
+func backupFile(fn string, cfg *saveConfig) error {
+	src, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open file to back up: %w", err)
+	}
+	defer src.Close()
+
+	pattern := cfg.backup
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(fn), pattern)
+	}
+	i := strings.LastIndex(pattern, "*")
+	if i < 0 {
+		return fmt.Errorf("backup pattern %q lacks a \"*\"", cfg.backup)
+	}
+	name := pattern[:i] + time.Now().UTC().Format(backupTimeFormat) + pattern[i+1:]
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	var r io.Reader = src
+	if cfg.backupHeader {
+		size, err := headerSize(src)
+		if err != nil {
+			return fmt.Errorf("failed to read header to back up: %w", err)
+		}
+		r = io.NewSectionReader(src, 0, size)
+	}
+	dst, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	if _, err := io.Copy(dst, r); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if cfg.backupKeep < 1 {
+		return nil
+	}
+	backups, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > cfg.backupKeep {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// headerSize returns the size of the "fLaC" marker and the metadata blocks of the FLAC stream in r
+func headerSize(r io.ReaderAt) (int64, error) {
+	if err := readFLACHead(io.NewSectionReader(r, 0, 4)); err != nil {
+		return 0, err
+	}
+	offset := int64(4)
+	header := make([]byte, 4)
+	for last := false; !last; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return 0, err
+		}
+		var length int
+		_, last, length = decodeBlockHeader(header)
+		offset += 4 + int64(length)
+	}
+	return offset, nil
+}
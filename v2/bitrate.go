@@ -0,0 +1,48 @@
+package flac
+
+// sizer is implemented by readers knowing the size of their data, such as *bytes.Reader and *io.SectionReader
+type sizer interface {
+	Size() int64
+}
+
+// AudioSize returns the size in bytes of the audio frames of the File, and whether it is exact.
+// It is exact for Files with a random access source, as returned by ParseFile, ParseReaderAt and ParseSeeker, and for Frames
+// that know their size, such as a *bytes.Reader. Otherwise it is estimated from StreamInfo, as the number of frames times
+// the average of the minimum and maximum frame sizes, and ErrorUnknownAudioSize is returned if StreamInfo does not record them.
+func (c *File) AudioSize() (int64, bool, error) {
+	switch {
+	case c.src != nil && c.audioOffset > 0:
+		end := c.srcSize
+		if c.audioEnd > 0 {
+			end = c.audioEnd
+		}
+		return end - c.audioOffset, true, nil
+	case c.Frames != nil:
+		if frames, ok := c.Frames.(sizer); ok {
+			return frames.Size(), true, nil
+		}
+	}
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return 0, false, err
+	}
+	if info.SampleCount <= 0 || info.BlockSizeMax <= 0 || info.FrameSizeMin <= 0 || info.FrameSizeMax <= 0 {
+		return 0, false, ErrorUnknownAudioSize
+	}
+	frames := (info.SampleCount + int64(info.BlockSizeMax) - 1) / int64(info.BlockSizeMax)
+	return frames * int64(info.FrameSizeMin+info.FrameSizeMax) / 2, false, nil
+}
+
+// Bitrate returns the average bitrate of the audio of the File in bits per second, its AudioSize over its Duration,
+// and whether the audio size it is computed from is exact. It fails with ErrorUnknownDuration or ErrorUnknownAudioSize if either is unknown.
+func (c *File) Bitrate() (int64, bool, error) {
+	duration, err := c.Duration()
+	if err != nil {
+		return 0, false, err
+	}
+	size, exact, err := c.AudioSize()
+	if err != nil {
+		return 0, false, err
+	}
+	return int64(float64(size) * 8 / duration.Seconds()), exact, nil
+}
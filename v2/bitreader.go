@@ -0,0 +1,75 @@
+package flac
+
+import "io"
+
+// bitReader reads MSB-first bit fields from an encoded frame
+type bitReader struct {
+	data []byte
+	// pos is the position of the next bit
+	pos int
+}
+
+// readBits reads an n-bit unsigned number, n <= 64
+func (b *bitReader) readBits(n int) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if b.pos+n > len(b.data)*8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var res uint64
+	for n > 0 {
+		byteIdx := b.pos >> 3
+		bitOff := b.pos & 7
+		avail := 8 - bitOff
+		take := avail
+		if take > n {
+			take = n
+		}
+		v := uint64(b.data[byteIdx]>>(avail-take)) & (1<<take - 1)
+		res = res<<take | v
+		b.pos += take
+		n -= take
+	}
+	return res, nil
+}
+
+// readSigned reads an n-bit two's complement number
+func (b *bitReader) readSigned(n int) (int64, error) {
+	v, err := b.readBits(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return int64(v<<(64-n)) >> (64 - n), nil
+}
+
+// readUnary counts zero bits up to the next one bit, which is consumed
+func (b *bitReader) readUnary() (int, error) {
+	n := 0
+	for {
+		byteIdx := b.pos >> 3
+		if byteIdx >= len(b.data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		bitOff := b.pos & 7
+		rest := b.data[byteIdx] << bitOff
+		if rest == 0 {
+			n += 8 - bitOff
+			b.pos += 8 - bitOff
+			continue
+		}
+		zeros := 0
+		for rest&0x80 == 0 {
+			rest <<= 1
+			zeros++
+		}
+		n += zeros
+		b.pos += zeros + 1
+		return n, nil
+	}
+}
+
+// alignByte skips to the next byte boundary
+func (b *bitReader) alignByte() {
+	b.pos = (b.pos + 7) &^ 7
+}
@@ -0,0 +1,45 @@
+package flac
+
+// bitWriter packs MSB-first bit fields of an encoded frame
+type bitWriter struct {
+	data []byte
+	// bits is the number of bits used in the last byte of data, 0 when it is full
+	bits uint
+}
+
+// writeBits writes the n low bits of v, n <= 64
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	for n > 0 {
+		if w.bits == 0 {
+			w.data = append(w.data, 0)
+		}
+		free := 8 - w.bits
+		take := free
+		if take > n {
+			take = n
+		}
+		chunk := byte(v>>(n-take)) & (1<<take - 1)
+		w.data[len(w.data)-1] |= chunk << (free - take)
+		w.bits = (w.bits + take) & 7
+		n -= take
+	}
+}
+
+// writeSigned writes v as an n-bit two's complement number
+func (w *bitWriter) writeSigned(v int64, n uint) {
+	w.writeBits(uint64(v)&(1<<n-1), n)
+}
+
+// writeUnary writes n zero bits followed by a one bit
+func (w *bitWriter) writeUnary(n uint64) {
+	for n >= 32 {
+		w.writeBits(0, 32)
+		n -= 32
+	}
+	w.writeBits(1, uint(n)+1)
+}
+
+// alignByte pads with zero bits to the next byte boundary
+func (w *bitWriter) alignByte() {
+	w.bits = 0
+}
@@ -0,0 +1,70 @@
+package flac
+
+import "io"
+
+// ByteRangeForSamples returns the byte range of the stream holding the frames that contain samples [start, end),
+// so an HTTP server can answer a time range request with a Range response without decoding.
+// Offsets are absolute positions in the parsed stream. An end beyond the last sample extends the range to the end of the stream.
+//
+// For Files parsed by ParseFile or ParseReaderAt, frames are scanned from the closest preceding seek point, and the range is exact.
+// Otherwise the SeekTable alone is used and the range is widened to the enclosing seek points; the end is -1 when no seek point follows end.
+// ErrorNoSeekInfo is returned if the File has neither a source nor a SeekTable.
+func (c *File) ByteRangeForSamples(start, end int64) (ByteRange, error) {
+	if start < 0 || end <= start {
+		return ByteRange{}, ErrorInvalidSampleRange
+	}
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return ByteRange{}, err
+	}
+	points, err := c.seekPoints()
+	if err != nil {
+		return ByteRange{}, err
+	}
+	if c.audioOffset == 0 || c.src == nil && len(points) == 0 {
+		return ByteRange{}, ErrorNoSeekInfo
+	}
+
+	// seek points are sorted by sample number
+	res := ByteRange{Start: c.audioOffset, End: -1}
+	for _, p := range points {
+		if int64(p.sample) <= start {
+			res.Start = c.audioOffset + int64(p.offset)
+		}
+		if int64(p.sample) >= end {
+			res.End = c.audioOffset + int64(p.offset)
+			break
+		}
+	}
+	if c.src == nil {
+		return res, nil
+	}
+
+	scanFrom := res.Start
+	res = ByteRange{Start: -1, End: c.srcSize}
+	s := &frameScanner{r: io.NewSectionReader(c.src, scanFrom, c.srcSize-scanFrom), offset: scanFrom}
+	for {
+		frame, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ByteRange{}, err
+		}
+		sample := int64(frame.Header.Number)
+		if !frame.Header.VariableBlockSize {
+			sample *= int64(info.BlockSizeMax)
+		}
+		if sample >= end {
+			res.End = frame.Offset
+			break
+		}
+		if res.Start < 0 && sample+int64(frame.Header.BlockSize) > start {
+			res.Start = frame.Offset
+		}
+	}
+	if res.Start < 0 {
+		return ByteRange{}, ErrorInvalidSampleRange
+	}
+	return res, nil
+}
@@ -0,0 +1,88 @@
+package flac
+
+import "math"
+
+// ChannelMap mixes the channels of decoded audio into a new set of channels: output channel o is the sum of input channel i scaled by ChannelMap[o][i]
+type ChannelMap [][]float64
+
+// speakerGains are the left and right downmix gains of each channel of the FLAC channel layouts, indexed by channel count.
+// Center channels are mixed at -3 dB, surround channels at -3 dB to their side and the LFE channel is dropped, following ITU-R BS.775.
+var speakerGains = [9][][2]float64{
+	1: {{1, 1}},
+	2: {{1, 0}, {0, 1}},
+	3: {{1, 0}, {0, 1}, {math.Sqrt2 / 2, math.Sqrt2 / 2}},
+	4: {{1, 0}, {0, 1}, {math.Sqrt2 / 2, 0}, {0, math.Sqrt2 / 2}},
+	5: {{1, 0}, {0, 1}, {math.Sqrt2 / 2, math.Sqrt2 / 2}, {math.Sqrt2 / 2, 0}, {0, math.Sqrt2 / 2}},
+	6: {{1, 0}, {0, 1}, {math.Sqrt2 / 2, math.Sqrt2 / 2}, {0, 0}, {math.Sqrt2 / 2, 0}, {0, math.Sqrt2 / 2}},
+	7: {{1, 0}, {0, 1}, {math.Sqrt2 / 2, math.Sqrt2 / 2}, {0, 0}, {0.5, 0.5}, {math.Sqrt2 / 2, 0}, {0, math.Sqrt2 / 2}},
+	8: {{1, 0}, {0, 1}, {math.Sqrt2 / 2, math.Sqrt2 / 2}, {0, 0}, {math.Sqrt2 / 2, 0}, {0, math.Sqrt2 / 2}, {math.Sqrt2 / 2, 0}, {0, math.Sqrt2 / 2}},
+}
+
+// normalize scales the gains of every output channel so they sum up to at most 1, which prevents clipping
+func (m ChannelMap) normalize() ChannelMap {
+	for _, row := range m {
+		var sum float64
+		for _, g := range row {
+			sum += math.Abs(g)
+		}
+		if sum > 1 {
+			for i := range row {
+				row[i] /= sum
+			}
+		}
+	}
+	return m
+}
+
+// StereoDownmix returns the ChannelMap folding the standard FLAC channel layout of the given channel count, such as 5.1, down to stereo
+func StereoDownmix(channels int) (ChannelMap, error) {
+	if channels < 1 || channels >= len(speakerGains) {
+		return nil, ErrorInvalidChannelMap
+	}
+	res := ChannelMap{make([]float64, channels), make([]float64, channels)}
+	for i, g := range speakerGains[channels] {
+		res[0][i], res[1][i] = g[0], g[1]
+	}
+	return res.normalize(), nil
+}
+
+// MonoFoldDown returns the ChannelMap folding the standard FLAC channel layout of the given channel count down to mono
+func MonoFoldDown(channels int) (ChannelMap, error) {
+	if channels < 1 || channels >= len(speakerGains) {
+		return nil, ErrorInvalidChannelMap
+	}
+	res := ChannelMap{make([]float64, channels)}
+	for i, g := range speakerGains[channels] {
+		res[0][i] = g[0] + g[1]
+	}
+	return res.normalize(), nil
+}
+
+// Apply returns a copy of frame with its channels mixed by the ChannelMap, rounding and clamping the samples to the bit depth of the frame
+func (m ChannelMap) Apply(frame *AudioFrame) (*AudioFrame, error) {
+	for _, row := range m {
+		if len(row) != len(frame.Samples) {
+			return nil, ErrorInvalidChannelMap
+		}
+	}
+	res := *frame
+	res.Header.Channels = len(m)
+	res.Header.ChannelAssignment = ChannelsIndependent
+	res.Samples = make([][]int32, len(m))
+	highest := math.Ldexp(1, frame.BitDepth-1) - 1
+	lowest := -highest - 1
+	for o, row := range m {
+		out := make([]int32, frame.Header.BlockSize)
+		for i := range out {
+			var v float64
+			for ch, g := range row {
+				if g != 0 {
+					v += g * float64(frame.Samples[ch][i])
+				}
+			}
+			out[i] = int32(math.Max(lowest, math.Min(highest, math.Round(v))))
+		}
+		res.Samples[o] = out
+	}
+	return &res, nil
+}
@@ -0,0 +1,279 @@
+package flac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chapters are stored with the Vorbis comment chapter convention, extended with per-chapter links and artwork:
+//
+//	CHAPTER001=00:00:00.000
+//	CHAPTER001NAME=Introduction
+//	CHAPTER001URL=https://example.com/intro
+//	CHAPTER001IMAGE=https://example.com/intro.jpg
+//
+// Chapter artwork embedded in the file is stored as a Picture block of type Other whose description is the chapter key, e.g. "CHAPTER001".
+const chapterPrefix = "CHAPTER"
+
+// Chapter is a chapter marker of a File
+type Chapter struct {
+	// Start is the offset of the chapter from the beginning of the stream
+	Start time.Duration
+	// Title is the name of the chapter
+	Title string
+	// URL is a link related to the chapter content
+	URL string
+	// ImageURL is the location of the chapter artwork when it is hosted outside the file
+	ImageURL string
+	// Image is the chapter artwork embedded in the file, nil if there is none
+	Image []byte
+	// ImageMIME is the MIME type of Image
+	ImageMIME string
+}
+
+// maxChapters is the number of chapters the 3 digits of the chapter keys can number
+const maxChapters = 999
+
+// chapterKey returns the Vorbis comment key of the chapter with the given zero-based index
+func chapterKey(i int) string {
+	return fmt.Sprintf("%s%03d", chapterPrefix, i+1)
+}
+
+// parseChapterField splits a Vorbis comment field name such as CHAPTER001NAME into the chapter number and the suffix
+func parseChapterField(name string) (number int, suffix string, ok bool) {
+	if !strings.HasPrefix(name, chapterPrefix) || len(name) < len(chapterPrefix)+3 {
+		return 0, "", false
+	}
+	digits := name[len(chapterPrefix) : len(chapterPrefix)+3]
+	number, err := strconv.Atoi(digits)
+	if err != nil || number < 0 {
+		return 0, "", false
+	}
+	return number, name[len(chapterPrefix)+3:], true
+}
+
+// parseChapterTime parses a HH:MM:SS.mmm chapter timestamp
+func parseChapterTime(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, ErrorInvalidChapter
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, ErrorInvalidChapter
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, ErrorInvalidChapter
+	}
+	// the fraction is parsed as an integer, as a float would turn 1.001 seconds into 1.000999999
+	whole, fraction, _ := strings.Cut(parts[2], ".")
+	seconds, err := strconv.Atoi(whole)
+	if err != nil {
+		return 0, ErrorInvalidChapter
+	}
+	var nanos int
+	if fraction != "" {
+		if strings.Trim(fraction, "0123456789") != "" {
+			return 0, ErrorInvalidChapter
+		}
+		if len(fraction) > 9 {
+			fraction = fraction[:9]
+		}
+		nanos, _ = strconv.Atoi(fraction + strings.Repeat("0", 9-len(fraction)))
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second + time.Duration(nanos), nil
+}
+
+// formatChapterTime formats a chapter start as HH:MM:SS.mmm
+func formatChapterTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Chapters decodes the chapter markers stored in the first VorbisComment block of the File, ordered by chapter number.
+// Embedded chapter artwork is resolved from Picture blocks described with the chapter key.
+func (c *File) Chapters() ([]Chapter, error) {
+	idx := c.vorbisCommentIndex()
+	if idx < 0 {
+		return nil, nil
+	}
+	_, comments, err := loadVorbisComment(c.Meta[idx])
+	if err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[int]*Chapter)
+	for _, comment := range comments {
+		name, value := splitVorbisComment(comment)
+		number, suffix, ok := parseChapterField(name)
+		if !ok {
+			continue
+		}
+		ch, ok := byNumber[number]
+		if !ok {
+			ch = new(Chapter)
+			byNumber[number] = ch
+		}
+		switch suffix {
+		case "":
+			if ch.Start, err = parseChapterTime(value); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		case "NAME":
+			ch.Title = value
+		case "URL":
+			ch.URL = value
+		case "IMAGE":
+			ch.ImageURL = value
+		}
+	}
+
+	numbers := make([]int, 0, len(byNumber))
+	for number := range byNumber {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	for _, meta := range c.Meta {
+		if meta.Type != Picture {
+			continue
+		}
+		data, err := pictureData(meta)
+		if err != nil {
+			return nil, err
+		}
+		header, image, err := parsePicture(data)
+		if err != nil {
+			continue
+		}
+		if number, suffix, ok := parseChapterField(header.description); ok && suffix == "" {
+			if ch, ok := byNumber[number]; ok {
+				ch.Image = image
+				ch.ImageMIME = header.mime
+			}
+		}
+	}
+
+	res := make([]Chapter, len(numbers))
+	for i, number := range numbers {
+		res[i] = *byNumber[number]
+	}
+	return res, nil
+}
+
+// SetChapters replaces the chapter markers of the File.
+// Existing CHAPTERxxx comments and chapter artwork Picture blocks are removed; a VorbisComment block is added when the File has none.
+// It returns ErrorTooManyChapters, leaving the File untouched, for more than 999 chapters.
+func (c *File) SetChapters(chapters []Chapter) error {
+	if len(chapters) > maxChapters {
+		return ErrorTooManyChapters
+	}
+	idx := c.vorbisCommentIndex()
+	var vendor string
+	var comments []string
+	if idx >= 0 {
+		var err error
+		if vendor, comments, err = loadVorbisComment(c.Meta[idx]); err != nil {
+			return err
+		}
+	}
+
+	kept := comments[:0]
+	for _, comment := range comments {
+		name, _ := splitVorbisComment(comment)
+		if _, _, ok := parseChapterField(name); !ok {
+			kept = append(kept, comment)
+		}
+	}
+	comments = kept
+	for i, ch := range chapters {
+		key := chapterKey(i)
+		comments = append(comments, key+"="+formatChapterTime(ch.Start))
+		if ch.Title != "" {
+			comments = append(comments, key+"NAME="+ch.Title)
+		}
+		if ch.URL != "" {
+			comments = append(comments, key+"URL="+ch.URL)
+		}
+		if ch.ImageURL != "" {
+			comments = append(comments, key+"IMAGE="+ch.ImageURL)
+		}
+	}
+	data := marshalVorbisComment(vendor, comments)
+
+	artwork := make(map[*MetaDataBlock]bool)
+	for _, block := range c.Meta {
+		if block.Type != Picture {
+			continue
+		}
+		picture, err := pictureData(block)
+		if err != nil {
+			return err
+		}
+		if header, _, err := parsePicture(picture); err == nil {
+			if _, suffix, ok := parseChapterField(header.description); ok && suffix == "" {
+				artwork[block] = true
+			}
+		}
+	}
+
+	if idx >= 0 {
+		c.Meta[idx].Data = data
+		c.modified(c.Meta[idx])
+	}
+	c.removeBlocks(func(block *MetaDataBlock) bool { return artwork[block] })
+	var added []*MetaDataBlock
+	if idx < 0 {
+		added = append(added, &MetaDataBlock{Type: VorbisComment, Data: data})
+	}
+	for i, ch := range chapters {
+		if ch.Image == nil {
+			continue
+		}
+		added = append(added, &MetaDataBlock{
+			Type: Picture,
+			Data: marshalPicture(pictureHeader{pictureType: PictureTypeOther, mime: ch.ImageMIME, description: chapterKey(i)}, ch.Image),
+		})
+	}
+	c.Meta = append(c.Meta, added...)
+	for _, block := range added {
+		c.modified(block)
+	}
+	return nil
+}
+
+// podcastChapters is the Podcasting 2.0 JSON chapters document
+type podcastChapters struct {
+	Version  string           `json:"version"`
+	Chapters []podcastChapter `json:"chapters"`
+}
+
+type podcastChapter struct {
+	StartTime float64 `json:"startTime"`
+	Title     string  `json:"title,omitempty"`
+	Img       string  `json:"img,omitempty"`
+	URL       string  `json:"url,omitempty"`
+}
+
+// WritePodcastChapters writes chapters as a Podcasting 2.0 JSON chapters document (version 1.2.0).
+// The JSON format can only reference artwork by URL, so embedded Image data is not exported; set ImageURL to the location the artwork is published at.
+func WritePodcastChapters(w io.Writer, chapters []Chapter) error {
+	doc := podcastChapters{Version: "1.2.0", Chapters: make([]podcastChapter, len(chapters))}
+	for i, ch := range chapters {
+		doc.Chapters[i] = podcastChapter{
+			StartTime: ch.Start.Seconds(),
+			Title:     ch.Title,
+			Img:       ch.ImageURL,
+			URL:       ch.URL,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
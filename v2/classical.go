@@ -0,0 +1,140 @@
+package flac
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Vorbis comment fields of classical works
+const (
+	workField           = "WORK"
+	movementField       = "MOVEMENT"
+	movementNumberField = "MOVEMENTNUMBER"
+	movementTotalField  = "MOVEMENTTOTAL"
+	conductorField      = "CONDUCTOR"
+	ensembleField       = "ENSEMBLE"
+)
+
+// iTunesFreeformPrefix prefixes the freeform MP4 atoms written by iTunes for tags without a dedicated atom
+const iTunesFreeformPrefix = "----:com.apple.iTunes:"
+
+// ClassicalTags holds the tags describing a track of a classical work
+type ClassicalTags struct {
+	// Work is the name of the work, e.g. "Symphony No. 5 in C minor, Op. 67"
+	Work string
+	// Movement is the name of the movement, e.g. "Allegro con brio"
+	Movement string
+	// MovementNumber and MovementTotal number the movement within the work, zero when unknown
+	MovementNumber int
+	MovementTotal  int
+	Conductor      string
+	// Ensemble is the orchestra, choir or chamber ensemble performing the work
+	Ensemble string
+}
+
+// ClassicalTags returns the WORK, MOVEMENT, MOVEMENTNUMBER, MOVEMENTTOTAL, CONDUCTOR and ENSEMBLE fields of the first VorbisComment block of the File.
+// A MOVEMENTNUMBER written as "n/total" also sets MovementTotal, and numbers that do not parse are left at zero.
+func (c *File) ClassicalTags() (*ClassicalTags, error) {
+	res := new(ClassicalTags)
+	i := c.vorbisCommentIndex()
+	if i < 0 {
+		return res, nil
+	}
+	_, comments, err := loadVorbisComment(c.Meta[i])
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		name, value := splitVorbisComment(comment)
+		switch name {
+		case workField:
+			res.Work = value
+		case movementField:
+			res.Movement = value
+		case movementNumberField:
+			number, total, _ := strings.Cut(value, "/")
+			res.MovementNumber, _ = strconv.Atoi(strings.TrimSpace(number))
+			if n, err := strconv.Atoi(strings.TrimSpace(total)); err == nil {
+				res.MovementTotal = n
+			}
+		case movementTotalField:
+			if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				res.MovementTotal = n
+			}
+		case conductorField:
+			res.Conductor = value
+		case ensembleField:
+			res.Ensemble = value
+		}
+	}
+	return res, nil
+}
+
+// SetClassicalTags replaces the WORK, MOVEMENT, MOVEMENTNUMBER, MOVEMENTTOTAL, CONDUCTOR and ENSEMBLE fields of the File by the non-zero values of tags,
+// or removes them if tags is nil. A VorbisComment block is added when the File has none.
+func (c *File) SetClassicalTags(tags *ClassicalTags) error {
+	return c.editVorbisComment(true, func(block *VorbisCommentBlock) error {
+		for _, field := range []string{workField, movementField, movementNumberField, movementTotalField, conductorField, ensembleField} {
+			block.Remove(field)
+		}
+		if tags == nil {
+			return nil
+		}
+		add := func(field, value string) {
+			if value != "" {
+				block.Comments = append(block.Comments, field+"="+value)
+			}
+		}
+		add(workField, tags.Work)
+		add(movementField, tags.Movement)
+		add(movementNumberField, formatPositive(tags.MovementNumber))
+		add(movementTotalField, formatPositive(tags.MovementTotal))
+		add(conductorField, tags.Conductor)
+		add(ensembleField, tags.Ensemble)
+		return nil
+	})
+}
+
+// formatPositive formats n in decimal, or returns "" if n is not positive
+func formatPositive(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// ITunesAtoms converts the tags to the MP4 atoms of the iTunes classical scheme, keyed by atom name:
+// ©wrk, ©mvn, ©mvi and ©mvc for the work and movement, with shwm set to "1" so iTunes shows them instead of the title.
+// Conductor and Ensemble have no dedicated atom and are keyed as the freeform atoms "----:com.apple.iTunes:CONDUCTOR" and "----:com.apple.iTunes:ENSEMBLE".
+// Zero values are omitted.
+func (c *ClassicalTags) ITunesAtoms() map[string]string {
+	res := make(map[string]string)
+	set := func(atom, value string) {
+		if value != "" {
+			res[atom] = value
+		}
+	}
+	set("©wrk", c.Work)
+	set("©mvn", c.Movement)
+	set("©mvi", formatPositive(c.MovementNumber))
+	set("©mvc", formatPositive(c.MovementTotal))
+	set(iTunesFreeformPrefix+conductorField, c.Conductor)
+	set(iTunesFreeformPrefix+ensembleField, c.Ensemble)
+	if c.Work != "" || c.Movement != "" {
+		res["shwm"] = "1"
+	}
+	return res
+}
+
+// ClassicalTagsFromITunes reads the tags of the iTunes classical scheme from MP4 atoms keyed by atom name, the reverse of ITunesAtoms
+func ClassicalTagsFromITunes(atoms map[string]string) *ClassicalTags {
+	res := &ClassicalTags{
+		Work:      atoms["©wrk"],
+		Movement:  atoms["©mvn"],
+		Conductor: atoms[iTunesFreeformPrefix+conductorField],
+		Ensemble:  atoms[iTunesFreeformPrefix+ensembleField],
+	}
+	res.MovementNumber, _ = strconv.Atoi(atoms["©mvi"])
+	res.MovementTotal, _ = strconv.Atoi(atoms["©mvc"])
+	return res
+}
@@ -0,0 +1,60 @@
+package flac
+
+import (
+	"sort"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/search"
+)
+
+// TagCollator compares and searches tag values following the collation rules of a language,
+// so that artist names with diacritics such as "Ólafur Arnalds" sort next to "Olafur" instead of after "Z".
+// It is safe for concurrent use.
+type TagCollator struct {
+	mu       sync.Mutex
+	collator *collate.Collator
+	matcher  *search.Matcher
+}
+
+// NewTagCollator returns a TagCollator for the language tag, e.g. language.German or language.Make("sv").
+// Sorting uses the collation order of the language, which ignores case and diacritics unless they are the only difference.
+// Searching ignores case, diacritics and character width.
+func NewTagCollator(tag language.Tag) *TagCollator {
+	return &TagCollator{
+		collator: collate.New(tag),
+		matcher:  search.New(tag, search.Loose),
+	}
+}
+
+// Compare returns -1, 0 or 1 as a sorts before, equal to or after b
+func (c *TagCollator) Compare(a, b string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.collator.CompareString(a, b)
+}
+
+// Sort sorts values in collation order, keeping the order of equal values
+func (c *TagCollator) Sort(values []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sort.SliceStable(values, func(i, j int) bool {
+		return c.collator.CompareString(values[i], values[j]) < 0
+	})
+}
+
+// Equal reports whether a and b are the same value when case, diacritics and width are ignored, e.g. "Bjork" and "Björk"
+func (c *TagCollator) Equal(a, b string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.matcher.EqualString(a, b)
+}
+
+// Contains reports whether value contains query when case, diacritics and width are ignored, for the search box of a library
+func (c *TagCollator) Contains(value, query string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	start, _ := c.matcher.IndexString(value, query)
+	return start >= 0
+}
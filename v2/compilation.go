@@ -0,0 +1,92 @@
+package flac
+
+import "strings"
+
+// VariousArtists is the ALBUMARTIST NormalizeCompilation gives to compilations
+const VariousArtists = "Various Artists"
+
+// variousArtistsNames are the lower-case spellings of VariousArtists recognized in ALBUMARTIST
+var variousArtistsNames = map[string]bool{
+	"various artists": true,
+	"various":         true,
+	"va":              true,
+	"v.a.":            true,
+	"v/a":             true,
+}
+
+// albumTrack holds the fields of a track that decide the album artist
+type albumTrack struct {
+	artist      string
+	albumArtist string
+}
+
+// trackArtists reads the ARTIST and ALBUMARTIST fields of the first VorbisComment block of the File, joining multiple values with "; "
+func (c *File) trackArtists() (albumTrack, error) {
+	var res albumTrack
+	i := c.vorbisCommentIndex()
+	if i < 0 {
+		return res, nil
+	}
+	block, err := ParseVorbisComment(c.Meta[i])
+	if err != nil {
+		return res, err
+	}
+	res.artist = strings.TrimSpace(strings.Join(block.Get("ARTIST"), "; "))
+	res.albumArtist = strings.TrimSpace(strings.Join(block.Get("ALBUMARTIST"), "; "))
+	return res, nil
+}
+
+// NormalizeCompilation decides the album artist of the tracks of an album and writes it consistently to every track, so players group them as one album.
+// The album artist is the ALBUMARTIST most tracks agree on, else the ARTIST shared by every track, else VariousArtists.
+// An album whose album artist is VariousArtists, or one of its usual spellings such as "VA", is a compilation: every track gets COMPILATION=1
+// and ALBUMARTIST=VariousArtists. Otherwise COMPILATION is removed. Artist names are compared case-insensitively.
+// A VorbisComment block is added to the tracks that have none.
+func NormalizeCompilation(tracks []*File) (albumArtist string, compilation bool, err error) {
+	found := make([]albumTrack, len(tracks))
+	for i, track := range tracks {
+		if found[i], err = track.trackArtists(); err != nil {
+			return "", false, err
+		}
+	}
+
+	counts := make(map[string]int)
+	best := 0
+	for _, track := range found {
+		if track.albumArtist == "" {
+			continue
+		}
+		key := strings.ToLower(track.albumArtist)
+		counts[key]++
+		if counts[key] > best {
+			best = counts[key]
+			albumArtist = track.albumArtist
+		}
+	}
+	if albumArtist == "" && len(found) > 0 {
+		albumArtist = found[0].artist
+		for _, track := range found[1:] {
+			if !strings.EqualFold(track.artist, albumArtist) {
+				albumArtist = ""
+				break
+			}
+		}
+	}
+	if albumArtist == "" || variousArtistsNames[strings.ToLower(albumArtist)] {
+		albumArtist, compilation = VariousArtists, true
+	}
+
+	for _, track := range tracks {
+		if err := track.editVorbisComment(true, func(block *VorbisCommentBlock) error {
+			block.Remove("ALBUMARTIST")
+			block.Remove("COMPILATION")
+			block.Comments = append(block.Comments, "ALBUMARTIST="+albumArtist)
+			if compilation {
+				block.Comments = append(block.Comments, "COMPILATION=1")
+			}
+			return nil
+		}); err != nil {
+			return "", false, err
+		}
+	}
+	return albumArtist, compilation, nil
+}
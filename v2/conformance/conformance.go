@@ -0,0 +1,183 @@
+// Package conformance verifies the decoder and parser of package flac against the IETF CELLAR FLAC conformance test files.
+//
+// The files are not bundled: Download fetches them once, and Run checks every file of a downloaded directory.
+// The test of this package is gated behind the conformance build tag, as it needs network access:
+//
+//	go test -tags conformance ./conformance
+package conformance
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	flac "github.com/go-flac/go-flac/v2"
+)
+
+// ArchiveURL is the location of the archive of the conformance test files
+const ArchiveURL = "https://github.com/ietf-wg-cellar/flac-test-files/archive/refs/heads/main.zip"
+
+// Category is the group of a conformance test file, named after its directory
+type Category string
+
+const (
+	// Subset files use only the streamable subset of the format
+	Subset Category = "subset"
+	// Uncommon files use valid features that decoders are not required to support, such as 32-bit audio
+	Uncommon Category = "uncommon"
+	// Faulty files are invalid and must be rejected
+	Faulty Category = "faulty"
+)
+
+// ErrorMD5Mismatch indicates that the decoded audio does not match the MD5 signature of its StreamInfo block.
+// It is flac.ErrorAudioMD5Mismatch, which the flac.AudioMD5Error returned by Check matches with errors.Is.
+var ErrorMD5Mismatch = flac.ErrorAudioMD5Mismatch
+
+// Result is the outcome of checking one conformance test file
+type Result struct {
+	// Name is the file name
+	Name string
+	// Category is the group of the file
+	Category Category
+	// Err is the error parsing or decoding the file, nil if the whole file decoded to its audio MD5
+	Err error
+}
+
+// Passed reports whether the file was handled as its category requires: decoded for Subset and Uncommon files, rejected for Faulty ones
+func (r Result) Passed() bool {
+	if r.Category == Faulty {
+		return r.Err != nil
+	}
+	return r.Err == nil
+}
+
+// Download fetches the conformance test files from ArchiveURL and extracts the FLAC files to dir, one subdirectory per Category
+func Download(dir string) error {
+	res, err := http.Get(ArchiveURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP status %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, file := range archive.File {
+		// archive entries are named <root>/<category>/<file>.flac
+		parts := strings.Split(file.Name, "/")
+		if len(parts) != 3 || path.Ext(parts[2]) != ".flac" {
+			continue
+		}
+		switch Category(parts[1]) {
+		case Subset, Uncommon, Faulty:
+		default:
+			continue
+		}
+		if err := extract(file, filepath.Join(dir, parts[1], parts[2])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extract writes an archive entry to target
+func extract(file *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Run checks every FLAC file in the category subdirectories of dir, as extracted by Download, and returns the results ordered by category and name
+func Run(dir string) ([]Result, error) {
+	var res []Result
+	for _, category := range []Category{Subset, Uncommon, Faulty} {
+		names, err := filepath.Glob(filepath.Join(dir, string(category), "*.flac"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res = append(res, Result{Name: filepath.Base(name), Category: category, Err: Check(name)})
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no conformance test files in %s", dir)
+	}
+	return res, nil
+}
+
+// Check parses the FLAC file at path, decodes all of its audio, checking that every frame has the channels of StreamInfo and that the
+// sample count matches, and verifies the audio with File.VerifyAudioMD5 when StreamInfo carries an MD5 signature.
+// A panic of the decoder is reported as an error.
+func Check(path string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.GetStreamInfo()
+	if err != nil {
+		return err
+	}
+	// the file has a source, so the MD5 is computed from it and Frames are left for the checks below
+	if err := f.VerifyAudioMD5(); err != nil && err != flac.ErrorUnknownAudioMD5 {
+		return err
+	}
+	dec, err := f.Decoder()
+	if err != nil {
+		return err
+	}
+
+	var samples int64
+	for {
+		frame, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(frame.Samples) != info.ChannelCount {
+			return fmt.Errorf("frame at sample %d: %d channels instead of %d", frame.Sample, len(frame.Samples), info.ChannelCount)
+		}
+		samples += int64(frame.Header.BlockSize)
+	}
+	if info.SampleCount != 0 && samples != info.SampleCount {
+		return fmt.Errorf("decoded %d samples instead of %d", samples, info.SampleCount)
+	}
+	return nil
+}
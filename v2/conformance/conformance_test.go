@@ -0,0 +1,30 @@
+//go:build conformance
+
+package conformance
+
+import (
+	"errors"
+	"testing"
+
+	flac "github.com/go-flac/go-flac/v2"
+)
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	if err := Download(dir); err != nil {
+		t.Fatalf("Failed to download conformance test files: %s", err)
+	}
+	results, err := Run(dir)
+	if err != nil {
+		t.Fatalf("Failed to run conformance tests: %s", err)
+	}
+	for _, r := range results {
+		switch {
+		case r.Passed():
+		case r.Category == Uncommon && errors.Is(r.Err, flac.ErrorUnsupportedFrame):
+			t.Logf("%s/%s: %v", r.Category, r.Name, r.Err)
+		default:
+			t.Errorf("%s/%s: %v", r.Category, r.Name, r.Err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package flac
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// contextReader fails once its context is done, so a parse stops between two reads of the source, even within a metadata block
+type contextReader struct {
+	r io.Reader
+	// ctx is nil once the parse is over, as it does not bound reading the audio frames afterwards
+	ctx context.Context
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if c.ctx == nil {
+		return c.r.Read(p)
+	}
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.r.Read(p)
+	if err != nil && isTimeout(err) && c.ctx.Err() != nil {
+		err = c.ctx.Err()
+	}
+	return n, err
+}
+
+func (c *contextReader) Close() error {
+	if closer, ok := c.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// interrupt unblocks a pending read of r once ctx is done, when r supports read deadlines as net.Conn and pipes do.
+// The returned function stops watching ctx and clears the deadline if it was set.
+func interrupt(ctx context.Context, r io.Reader) func() {
+	conn, ok := r.(ReadDeadliner)
+	if !ok {
+		return func() {}
+	}
+	done := make(chan struct{})
+	fired := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Unix(1, 0))
+			fired <- true
+		case <-done:
+			fired <- false
+		}
+	}()
+	return func() {
+		close(done)
+		if <-fired {
+			conn.SetReadDeadline(time.Time{})
+		}
+	}
+}
+
+// parseContext runs parse on r bounded by ctx, returning the error of ctx if it is done before parse completes
+func parseContext(ctx context.Context, r io.Reader, parse func(io.Reader) (*File, error)) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Done() == nil {
+		return parse(r)
+	}
+	cr := &contextReader{r: r, ctx: ctx}
+	stop := interrupt(ctx, r)
+	res, err := parse(cr)
+	stop()
+	cr.ctx = nil
+	if err != nil && ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	return res, err
+}
+
+// ParseContext is ParseBytes bounded by ctx: once ctx is done, the parse fails with the error of ctx.
+// The source is checked for cancellation before every read; a read blocked on a source supporting read deadlines,
+// such as a net.Conn, is interrupted. Reading Frames after ParseContext returns is not bound to ctx.
+func ParseContext(ctx context.Context, r io.Reader, opts ...ParseOption) (*File, error) {
+	return parseContext(ctx, r, func(r io.Reader) (*File, error) {
+		return ParseBytes(r, opts...)
+	})
+}
+
+// ParseFileContext is ParseFile bounded by ctx, as ParseContext is ParseBytes.
+// Reads of named pipes blocked waiting for a writer are interrupted as well.
+func ParseFileContext(ctx context.Context, filename string, opts ...ParseOption) (*File, error) {
+	start := time.Now()
+	f, err := os.Open(filename)
+	if err != nil {
+		recordParse(time.Since(start), err)
+		return nil, err
+	}
+	res, err := parseContext(ctx, f, func(r io.Reader) (*File, error) {
+		return ParseBytes(NewBufIOWithInner(r), append(opts[:len(opts):len(opts)], fromFile(filename))...)
+	})
+	if err != nil {
+		if stat, statErr := f.Stat(); res != nil && statErr == nil {
+			res.loadPartial(f, stat.Size())
+		}
+		f.Close()
+		return res, err
+	}
+	if stat, err := f.Stat(); err == nil && !res.wrapped {
+		res.attachSource(f, stat.Size())
+	}
+	return res, nil
+}
@@ -0,0 +1,126 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FrameCopyError reports that copying the audio frames failed after the metadata was written,
+// so callers can decide whether the output is salvageable. The output of a failed out-of-place Save can be completed with ResumeSave.
+type FrameCopyError struct {
+	// Op is "read" when reading the frames failed and "write" when writing the output failed
+	Op string
+	// Copied is the number of bytes of frames written before the failure
+	Copied int64
+	// Err is the underlying error, io.ErrUnexpectedEOF when the source ended before the end of the frames it held when parsed
+	Err error
+}
+
+func (e *FrameCopyError) Error() string {
+	return fmt.Sprintf("flac frame %s failed after %d bytes: %v", e.Op, e.Copied, e.Err)
+}
+
+func (e *FrameCopyError) Unwrap() error {
+	return e.Err
+}
+
+// Truncated reports whether the source ended early, as when the file was truncated while saving.
+// The output then holds the metadata and the first Copied bytes of frames, which players can usually decode up to the cut.
+func (e *FrameCopyError) Truncated() bool {
+	return e.Op == "read" && errors.Is(e.Err, io.ErrUnexpectedEOF)
+}
+
+// readRecorder records the error of the reader, other than io.EOF
+type readRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (c *readRecorder) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && err != io.EOF {
+		c.err = err
+	}
+	return n, err
+}
+
+// copyFrames copies the audio frames to w, hashing them into audit if it is not nil, and reports failures as FrameCopyError.
+// File to file copies are handed to the io_uring copier when it is built in.
+func (c *File) copyFrames(w io.Writer, audit *SaveAudit) (int64, error) {
+	if _, ok := c.Frames.(*ErrorReader); ok {
+		return io.Copy(w, c.Frames)
+	}
+	expected, known := c.framesLeft()
+	n, op, err := copyFramesTo(w, c.Frames, audit)
+	if err == nil && known && n < expected {
+		op, err = "read", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return n, &FrameCopyError{Op: op, Copied: n, Err: err}
+	}
+	return n, nil
+}
+
+// copyFramesTo copies frames to w and returns the operation that failed along with the error
+func copyFramesTo(w io.Writer, frames io.Reader, audit *SaveAudit) (int64, string, error) {
+	if dst, ok := w.(*os.File); ok && audit == nil {
+		if src, offset, ok := fileRemainder(frames); ok {
+			if n, handled, err := uringCopy(dst, src, offset); handled {
+				var pathErr *os.PathError
+				if errors.As(err, &pathErr) {
+					return n, pathErr.Op, err
+				}
+				return n, "write", err
+			}
+		}
+	}
+	src := &readRecorder{r: timeoutReader{frames}}
+	var r io.Reader = src
+	if audit != nil {
+		r = io.TeeReader(r, audit.hash)
+	}
+	n, err := io.Copy(timeoutWriter{w}, r)
+	if src.err != nil {
+		return n, "read", err
+	}
+	return n, "write", err
+}
+
+// framesLeft returns the number of bytes of frames left in the source the File was parsed from, false if it is unknown
+// or Frames no longer reads from that source
+func (c *File) framesLeft() (int64, bool) {
+	if f, offset, ok := fileRemainder(c.Frames); ok && c.src == io.ReaderAt(f) {
+		return c.srcSize - offset, true
+	}
+	if p, ok := c.Frames.(*PrefixReader); ok {
+		if section, ok := p.r.(*io.SectionReader); ok {
+			pos, err := section.Seek(0, io.SeekCurrent)
+			return section.Size() - pos + int64(len(p.prefix)), err == nil
+		}
+	}
+	return 0, false
+}
+
+// fileRemainder returns the file r reads from and the offset in it of the next byte r returns,
+// for readers that return the rest of the file unchanged
+func fileRemainder(r io.Reader) (*os.File, int64, bool) {
+	switch r := r.(type) {
+	case *os.File:
+		offset, err := r.Seek(0, io.SeekCurrent)
+		return r, offset, err == nil
+	case *PrefixReader:
+		// the prefix holds the bytes read from the file just before its position
+		f, offset, ok := fileRemainder(r.r)
+		return f, offset - int64(len(r.prefix)), ok
+	case *BufIOWithInner:
+		f, ok := r.inner.(*os.File)
+		if !ok {
+			return nil, 0, false
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		return f, offset - int64(r.Buf.Buffered()), err == nil
+	}
+	return nil, 0, false
+}
@@ -0,0 +1,102 @@
+package flac
+
+import (
+	"bytes"
+	"io"
+)
+
+// CorpusSink receives the head of a stream that failed to parse along with the parse error.
+// The sample must not be retained past the call without being copied.
+type CorpusSink func(sample []byte, err error)
+
+// WithErrorCorpus makes parsing failures report the first limit bytes of the offending stream to sink, to build corpora of
+// real-world malformed files for regression tests. Audio is redacted: the sample ends after the header of the first frame.
+// Streams that do not start with the FLAC marker are not recognizable audio and are captured as they are.
+// The stream is read beyond the failure until the sample is complete, so the reader must not be reused after a failed parse.
+func WithErrorCorpus(sink CorpusSink, limit int) ParseOption {
+	return func(c *parseConfig) {
+		c.corpus = sink
+		c.corpusLimit = limit
+	}
+}
+
+// captureReader records the first limit bytes read through it
+type captureReader struct {
+	r     io.Reader
+	buf   []byte
+	limit int
+}
+
+func (c *captureReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if room := c.limit - len(c.buf); room > 0 {
+		if room > n {
+			room = n
+		}
+		c.buf = append(c.buf, p[:room]...)
+	}
+	return n, err
+}
+
+// capture wraps r to record its head if an error corpus is configured
+func (c *parseConfig) capture(r io.Reader) io.Reader {
+	if c.corpus == nil || c.corpusLimit <= 0 {
+		return r
+	}
+	return &captureReader{r: r, limit: c.corpusLimit}
+}
+
+// release stops recording the frames of a successfully parsed File, which would otherwise read through the capturing reader
+func (c *parseConfig) release(r io.Reader, res *File) {
+	capture, ok := r.(*captureReader)
+	if !ok {
+		return
+	}
+	if frames, ok := res.Frames.(*PrefixReader); ok && frames.r == capture {
+		frames.r = capture.r
+	}
+}
+
+// collect completes the sample recorded by r up to the limit and reports it with err to the sink
+func (c *parseConfig) collect(r io.Reader, err error) {
+	capture, ok := r.(*captureReader)
+	if !ok {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(capture, int64(capture.limit-len(capture.buf))))
+	c.corpus(redactAudio(capture.buf), err)
+}
+
+// collectAt reports the first bytes of the size bytes stored in r with err to the sink
+func (c *parseConfig) collectAt(r io.ReaderAt, size int64, err error) {
+	if c.corpus == nil || c.corpusLimit <= 0 {
+		return
+	}
+	n := int64(c.corpusLimit)
+	if n > size {
+		n = size
+	}
+	sample := make([]byte, n)
+	read, _ := r.ReadAt(sample, 0)
+	c.corpus(redactAudio(sample[:read]), err)
+}
+
+// redactAudio cuts a sample of a FLAC stream after the header of the first frame, leaving the metadata
+func redactAudio(sample []byte) []byte {
+	if !bytes.HasPrefix(sample, []byte("fLaC")) {
+		return sample
+	}
+	pos := 4
+	for last := false; !last; {
+		if pos+4 > len(sample) {
+			return sample
+		}
+		var length int
+		_, last, length = decodeBlockHeader(sample[pos:])
+		pos += 4 + length
+	}
+	if end := pos + maxFrameHeaderSize; end < len(sample) {
+		return sample[:end]
+	}
+	return sample
+}
@@ -0,0 +1,163 @@
+package flac
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// CoverQuery describes the release whose cover is looked up, as found in the Vorbis comments of a File
+type CoverQuery struct {
+	// Artist is the ALBUMARTIST field, or the ARTIST field if there is none
+	Artist string
+	// Album is the ALBUM field
+	Album string
+	// ReleaseMBID is the MusicBrainz release ID, from the MUSICBRAINZ_ALBUMID field
+	ReleaseMBID string
+	// ReleaseGroupMBID is the MusicBrainz release group ID, from the MUSICBRAINZ_RELEASEGROUPID field
+	ReleaseGroupMBID string
+}
+
+// Cover is a cover image found by a CoverProvider
+type Cover struct {
+	// MIME is the MIME type of Image, e.g. "image/jpeg"
+	MIME string
+	// Image is the image data
+	Image []byte
+}
+
+// CoverProvider looks up the front cover of a release, for example in an online database.
+// It returns ErrorNoCover if it has no cover for the query.
+type CoverProvider interface {
+	FetchCover(ctx context.Context, query CoverQuery) (*Cover, error)
+}
+
+// CoverArtArchiveURL is the root of the Cover Art Archive API
+const CoverArtArchiveURL = "https://coverartarchive.org"
+
+// CoverArtArchive is a CoverProvider fetching front covers from the Cover Art Archive by MusicBrainz release ID, falling back to the release group ID.
+// Queries without MusicBrainz IDs cannot be looked up and return ErrorNoCover.
+type CoverArtArchive struct {
+	// Client is the HTTP client making the requests, http.DefaultClient if nil
+	Client *http.Client
+	// BaseURL is the root of the API, CoverArtArchiveURL if empty
+	BaseURL string
+	// Size is the size in pixels of the thumbnail to fetch, 250, 500 or 1200, or 0 for the original image
+	Size int
+}
+
+// FetchCover implements CoverProvider
+func (c *CoverArtArchive) FetchCover(ctx context.Context, query CoverQuery) (*Cover, error) {
+	for _, entity := range [][2]string{{"release", query.ReleaseMBID}, {"release-group", query.ReleaseGroupMBID}} {
+		if entity[1] == "" {
+			continue
+		}
+		res, err := c.fetch(ctx, entity[0], entity[1])
+		if err != ErrorNoCover {
+			return res, err
+		}
+	}
+	return nil, ErrorNoCover
+}
+
+func (c *CoverArtArchive) fetch(ctx context.Context, entity, mbid string) (*Cover, error) {
+	base := c.BaseURL
+	if base == "" {
+		base = CoverArtArchiveURL
+	}
+	url := base + "/" + entity + "/" + mbid + "/front"
+	if c.Size > 0 {
+		url += "-" + strconv.Itoa(c.Size)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, ErrorNoCover
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("cover art archive: %s", res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, MaxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxBlockSize {
+		return nil, ErrorBlockTooLarge
+	}
+	mime := res.Header.Get("Content-Type")
+	if mime == "" {
+		mime = http.DetectContentType(data)
+	}
+	return &Cover{MIME: mime, Image: data}, nil
+}
+
+// coverQuery builds the cover query from the Vorbis comments of the File
+func (c *File) coverQuery() (CoverQuery, error) {
+	var res CoverQuery
+	i := c.vorbisCommentIndex()
+	if i < 0 {
+		return res, nil
+	}
+	_, comments, err := loadVorbisComment(c.Meta[i])
+	if err != nil {
+		return res, err
+	}
+	var artist string
+	for _, comment := range comments {
+		name, value := splitVorbisComment(comment)
+		switch name {
+		case "ALBUMARTIST":
+			res.Artist = value
+		case "ARTIST":
+			artist = value
+		case "ALBUM":
+			res.Album = value
+		case "MUSICBRAINZ_ALBUMID":
+			res.ReleaseMBID = value
+		case "MUSICBRAINZ_RELEASEGROUPID":
+			res.ReleaseGroupMBID = value
+		}
+	}
+	if res.Artist == "" {
+		res.Artist = artist
+	}
+	return res, nil
+}
+
+// FetchAndEmbedCover looks up the front cover of the File with provider, using its Vorbis comments, and embeds it, replacing any front cover.
+// The image dimensions are recorded when its format is registered with the image package, and left unknown otherwise.
+func (c *File) FetchAndEmbedCover(ctx context.Context, provider CoverProvider) error {
+	query, err := c.coverQuery()
+	if err != nil {
+		return err
+	}
+	cover, err := provider.FetchCover(ctx, query)
+	if err != nil {
+		return err
+	}
+	header := pictureHeader{pictureType: PictureTypeFrontCover, mime: cover.MIME}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(cover.Image)); err == nil {
+		header.width, header.height = uint32(config.Width), uint32(config.Height)
+	}
+	data := marshalPicture(header, cover.Image)
+	if len(data) > MaxBlockSize {
+		return ErrorBlockTooLarge
+	}
+	src := &File{Meta: []*MetaDataBlock{{Type: Picture, Data: data}}}
+	return CopyPictures(c, src, PictureTypeFrontCover)
+}
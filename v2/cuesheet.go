@@ -0,0 +1,74 @@
+package flac
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// cueSheet is the decoded content of a CueSheet metadata block
+type cueSheet struct {
+	catalog string
+	leadIn  uint64
+	isCD    bool
+	tracks  []cueTrack
+}
+
+// cueTrack is a track of a CueSheet metadata block
+type cueTrack struct {
+	offset      uint64
+	number      uint8
+	isrc        string
+	isAudio     bool
+	preEmphasis bool
+	indices     []cueIndex
+}
+
+// cueIndex is an index point of a cue sheet track
+type cueIndex struct {
+	offset uint64
+	number uint8
+}
+
+const (
+	// cueSheetHeaderSize is the size of the fields of a CueSheet block preceding the tracks
+	cueSheetHeaderSize = 128 + 8 + 1 + 258 + 1
+	// cueTrackHeaderSize is the size of the fields of a track preceding its index points
+	cueTrackHeaderSize = 8 + 1 + 12 + 1 + 13 + 1
+	// cueIndexSize is the size of an index point
+	cueIndexSize = 8 + 1 + 3
+)
+
+// parseCueSheet decodes the data of a CueSheet metadata block
+func parseCueSheet(data []byte) (*cueSheet, error) {
+	if len(data) < cueSheetHeaderSize {
+		return nil, ErrorMalformedCueSheet
+	}
+	res := &cueSheet{
+		catalog: strings.TrimRight(string(data[:128]), "\x00"),
+		leadIn:  binary.BigEndian.Uint64(data[128:]),
+		isCD:    data[136]&0x80 != 0,
+		tracks:  make([]cueTrack, data[cueSheetHeaderSize-1]),
+	}
+	data = data[cueSheetHeaderSize:]
+	for i := range res.tracks {
+		if len(data) < cueTrackHeaderSize {
+			return nil, ErrorMalformedCueSheet
+		}
+		track := &res.tracks[i]
+		track.offset = binary.BigEndian.Uint64(data)
+		track.number = data[8]
+		track.isrc = strings.TrimRight(string(data[9:21]), "\x00")
+		track.isAudio = data[21]&0x80 == 0
+		track.preEmphasis = data[21]&0x40 != 0
+		track.indices = make([]cueIndex, data[cueTrackHeaderSize-1])
+		data = data[cueTrackHeaderSize:]
+		for j := range track.indices {
+			if len(data) < cueIndexSize {
+				return nil, ErrorMalformedCueSheet
+			}
+			track.indices[j] = cueIndex{offset: binary.BigEndian.Uint64(data), number: data[8]}
+			data = data[cueIndexSize:]
+		}
+	}
+	return res, nil
+}
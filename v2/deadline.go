@@ -0,0 +1,124 @@
+package flac
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// TimeoutError reports that reading the audio frames or writing the output did not complete before its deadline.
+// errors.Is(err, ErrorTimeout) reports whether an error is a TimeoutError, to tell slow peers apart from corrupted data.
+type TimeoutError struct {
+	// Op is "read" when reading the source timed out and "write" when writing the output timed out
+	Op string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return "flac " + e.Op + " timed out: " + e.Err.Error()
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrorTimeout
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrorTimeout
+}
+
+// Timeout reports true, so a TimeoutError satisfies net.Error style checks
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// isTimeout reports whether err is a deadline or timeout error
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// wrapTimeout converts timeout errors of op into TimeoutError
+func wrapTimeout(op string, err error) error {
+	var timeoutErr *TimeoutError
+	if err == nil || errors.As(err, &timeoutErr) || !isTimeout(err) {
+		return err
+	}
+	return &TimeoutError{Op: op, Err: err}
+}
+
+// timeoutReader tags timeout errors of the source
+type timeoutReader struct {
+	r io.Reader
+}
+
+func (c timeoutReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	return n, wrapTimeout("read", err)
+}
+
+// timeoutWriter tags timeout errors of the output
+type timeoutWriter struct {
+	w io.Writer
+}
+
+func (c timeoutWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	return n, wrapTimeout("write", err)
+}
+
+// ReadDeadliner is implemented by connections supporting read deadlines, such as net.Conn
+type ReadDeadliner interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+// WriteDeadliner is implemented by connections supporting write deadlines, such as net.Conn
+type WriteDeadliner interface {
+	io.Writer
+	SetWriteDeadline(t time.Time) error
+}
+
+type deadlineReader struct {
+	conn    ReadDeadliner
+	timeout time.Duration
+}
+
+// NewDeadlineReader returns a reader that extends the read deadline of conn by timeout before every Read,
+// so a File whose Frames is the returned reader fails with a TimeoutError instead of blocking when the source stalls.
+func NewDeadlineReader(conn ReadDeadliner, timeout time.Duration) io.Reader {
+	return &deadlineReader{conn: conn, timeout: timeout}
+}
+
+func (c *deadlineReader) Read(p []byte) (int, error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.conn.Read(p)
+	return n, wrapTimeout("read", err)
+}
+
+type deadlineWriter struct {
+	conn    WriteDeadliner
+	timeout time.Duration
+}
+
+// NewDeadlineWriter returns a writer that extends the write deadline of conn by timeout before every Write,
+// so WriteTo fails with a TimeoutError instead of blocking on a client that stopped reading.
+func NewDeadlineWriter(conn WriteDeadliner, timeout time.Duration) io.Writer {
+	return &deadlineWriter{conn: conn, timeout: timeout}
+}
+
+func (c *deadlineWriter) Write(p []byte) (int, error) {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.conn.Write(p)
+	return n, wrapTimeout("write", err)
+}
@@ -0,0 +1,304 @@
+package flac
+
+import "io"
+
+// AudioFrame holds the decoded samples of an audio frame
+type AudioFrame struct {
+	// Header is the decoded frame header
+	Header FrameHeader
+	// Sample is the number of the first inter-channel sample of the frame
+	Sample int64
+	// BitDepth is the number of bits per sample, resolved from StreamInfo if the frame header refers to it
+	BitDepth int
+	// Samples holds the samples of each channel, Samples[channel][i]
+	Samples [][]int32
+}
+
+// Decoder decodes audio frames into PCM samples
+type Decoder struct {
+	s    frameScanner
+	info StreamInfoBlock
+}
+
+// NewDecoder returns a Decoder of the audio frames read from r, such as File.Frames. info provides the bit depth and block size for frames referring to StreamInfo.
+func NewDecoder(r io.Reader, info *StreamInfoBlock) *Decoder {
+	return &Decoder{s: frameScanner{r: r}, info: *info}
+}
+
+// Decoder returns a Decoder of the audio frames of the File. Decoding consumes Frames.
+func (c *File) Decoder() (*Decoder, error) {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	if c.Frames == nil {
+		return nil, ErrorNoFrames
+	}
+	return NewDecoder(c.Frames, info), nil
+}
+
+// Next decodes the next frame, returning io.EOF at the end of the stream
+func (c *Decoder) Next() (*AudioFrame, error) {
+	frame, err := c.s.next()
+	if err != nil {
+		return nil, err
+	}
+	res, err := DecodeFrame(frame.Data, &c.info)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Header.VariableBlockSize {
+		blockSize := c.info.BlockSizeMax
+		if blockSize == 0 {
+			blockSize = res.Header.BlockSize
+		}
+		res.Sample = int64(res.Header.Number) * int64(blockSize)
+	}
+	return res, nil
+}
+
+// DecodeFrame decodes a whole encoded frame, such as Frame.Data reported by ScanFrames, and verifies its CRC-16.
+// info provides the bit depth for frames referring to StreamInfo and may be nil otherwise.
+// For fixed block size streams the Sample of the result is left 0, as it depends on the nominal block size of the stream.
+func DecodeFrame(data []byte, info *StreamInfoBlock) (*AudioFrame, error) {
+	res, _, err := decodeFrame(data, info)
+	return res, err
+}
+
+// decodeFrame implements DecodeFrame and also returns the length of the frame, which may be shorter than data
+func decodeFrame(data []byte, info *StreamInfoBlock) (*AudioFrame, int, error) {
+	header, err := ParseFrameHeader(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	res := &AudioFrame{Header: *header, BitDepth: header.BitDepth}
+	if res.BitDepth == 0 && info != nil {
+		res.BitDepth = info.BitDepth
+	}
+	if res.BitDepth <= 0 {
+		return nil, 0, ErrorInvalidFrameHeader
+	}
+	if header.VariableBlockSize {
+		res.Sample = int64(header.Number)
+	}
+
+	b := &bitReader{data: data, pos: header.Size * 8}
+	res.Samples = make([][]int32, header.Channels)
+	for ch := range res.Samples {
+		bps := res.BitDepth
+		switch {
+		case header.ChannelAssignment == ChannelsLeftSide && ch == 1,
+			header.ChannelAssignment == ChannelsRightSide && ch == 0,
+			header.ChannelAssignment == ChannelsMidSide && ch == 1:
+			// side channels carry one extra bit
+			bps++
+		}
+		if bps > 32 {
+			return nil, 0, ErrorUnsupportedFrame
+		}
+		res.Samples[ch] = make([]int32, header.BlockSize)
+		if err := decodeSubframe(b, bps, res.Samples[ch]); err != nil {
+			return nil, 0, err
+		}
+	}
+	b.alignByte()
+	end := b.pos / 8
+	if end+2 > len(data) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc16(data[:end+2]) != 0 {
+		return nil, 0, ErrorFrameCRC
+	}
+
+	decorrelate(header.ChannelAssignment, res.Samples)
+	return res, end + 2, nil
+}
+
+// decorrelate restores left and right channels from stereo decorrelated channels
+func decorrelate(assignment ChannelAssignment, samples [][]int32) {
+	switch assignment {
+	case ChannelsLeftSide:
+		left, side := samples[0], samples[1]
+		for i := range side {
+			side[i] = left[i] - side[i]
+		}
+	case ChannelsRightSide:
+		side, right := samples[0], samples[1]
+		for i := range side {
+			side[i] += right[i]
+		}
+	case ChannelsMidSide:
+		mid, side := samples[0], samples[1]
+		for i := range mid {
+			m := int64(mid[i])<<1 | int64(side[i])&1
+			s := int64(side[i])
+			mid[i] = int32((m + s) >> 1)
+			side[i] = int32((m - s) >> 1)
+		}
+	}
+}
+
+// decodeSubframe decodes one subframe of bps bits per sample into out
+func decodeSubframe(b *bitReader, bps int, out []int32) error {
+	header, err := b.readBits(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return ErrorInvalidSubframe
+	}
+	kind := int(header>>1) & 0x3F
+	wasted := 0
+	if header&1 != 0 {
+		n, err := b.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = n + 1
+		bps -= wasted
+		if bps <= 0 {
+			return ErrorInvalidSubframe
+		}
+	}
+
+	switch {
+	case kind == 0:
+		v, err := b.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		for i := range out {
+			out[i] = int32(v)
+		}
+	case kind == 1:
+		for i := range out {
+			v, err := b.readSigned(bps)
+			if err != nil {
+				return err
+			}
+			out[i] = int32(v)
+		}
+	case kind >= 8 && kind <= 12:
+		order := kind - 8
+		if err := decodeWarmup(b, bps, out, order); err != nil {
+			return err
+		}
+		if err := decodeResidual(b, out, order); err != nil {
+			return err
+		}
+		restoreFixed(out, order, bps)
+	case kind >= 32:
+		order := kind - 31
+		if err := decodeWarmup(b, bps, out, order); err != nil {
+			return err
+		}
+		precision, err := b.readBits(4)
+		if err != nil {
+			return err
+		}
+		if precision == 0x0F {
+			return ErrorInvalidSubframe
+		}
+		shift, err := b.readSigned(5)
+		if err != nil {
+			return err
+		}
+		if shift < 0 {
+			return ErrorInvalidSubframe
+		}
+		coefs := make([]int32, order)
+		for i := range coefs {
+			c, err := b.readSigned(int(precision) + 1)
+			if err != nil {
+				return err
+			}
+			coefs[i] = int32(c)
+		}
+		if err := decodeResidual(b, out, order); err != nil {
+			return err
+		}
+		restoreLPC(out, coefs, uint(shift), bps, int(precision)+1)
+	default:
+		return ErrorInvalidSubframe
+	}
+
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// decodeWarmup reads the unencoded warm-up samples of a predictive subframe
+func decodeWarmup(b *bitReader, bps int, out []int32, order int) error {
+	if order > len(out) {
+		return ErrorInvalidSubframe
+	}
+	for i := 0; i < order; i++ {
+		v, err := b.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		out[i] = int32(v)
+	}
+	return nil
+}
+
+// decodeResidual reads the Rice coded residual of a predictive subframe into out[order:]
+func decodeResidual(b *bitReader, out []int32, order int) error {
+	method, err := b.readBits(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return ErrorInvalidSubframe
+	}
+	paramBits := 4 + int(method)
+	escape := uint64(1)<<paramBits - 1
+	partitionOrder, err := b.readBits(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	partitionSize := len(out) >> partitionOrder
+	if partitionSize<<partitionOrder != len(out) || partitionSize < order {
+		return ErrorInvalidSubframe
+	}
+
+	pos := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * partitionSize
+		param, err := b.readBits(paramBits)
+		if err != nil {
+			return err
+		}
+		if param == escape {
+			n, err := b.readBits(5)
+			if err != nil {
+				return err
+			}
+			for ; pos < end; pos++ {
+				v, err := b.readSigned(int(n))
+				if err != nil {
+					return err
+				}
+				out[pos] = int32(v)
+			}
+			continue
+		}
+		for ; pos < end; pos++ {
+			q, err := b.readUnary()
+			if err != nil {
+				return err
+			}
+			r, err := b.readBits(int(param))
+			if err != nil {
+				return err
+			}
+			u := uint64(q)<<param | r
+			out[pos] = int32(int64(u>>1) ^ -int64(u&1))
+		}
+	}
+	return nil
+}
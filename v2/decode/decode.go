@@ -0,0 +1,134 @@
+// Package decode turns the audio frames of a FLAC stream into interleaved PCM samples, for playback and analysis.
+// Frames are decoded by package flac, which supports every subframe type and stereo decorrelation mode and verifies the CRC-16 of every frame.
+package decode
+
+import (
+	"errors"
+	"io"
+
+	flac "github.com/go-flac/go-flac/v2"
+)
+
+// ErrorFormatChanged indicates that a frame has a channel count or bit depth other than the one of StreamInfo, which a PCM stream cannot represent
+var ErrorFormatChanged = errors.New("frame format differs from StreamInfo")
+
+// Format describes the PCM samples produced by a Reader
+type Format struct {
+	// SampleRate is the number of inter-channel samples per second
+	SampleRate int
+	// Channels is the number of channels, interleaved in the order of the FLAC channel assignment
+	Channels int
+	// BitDepth is the number of significant bits of every sample
+	BitDepth int
+}
+
+// BytesPerSample returns the size of a sample of one channel in the byte stream of Reader.Read: the whole bytes needed by BitDepth
+func (f Format) BytesPerSample() int {
+	return (f.BitDepth + 7) / 8
+}
+
+// Reader reads the decoded audio of a FLAC stream as interleaved samples, or as bytes with Read
+type Reader struct {
+	dec    *flac.Decoder
+	format Format
+	// pending holds the interleaved samples of the last decoded frame not read yet, in samples
+	samples, pending []int32
+	// out holds the encoded samples Read has not returned yet, in buf
+	buf, out []byte
+	err      error
+}
+
+// New returns a Reader of the audio of f. Reading consumes the Frames of f.
+func New(f *flac.File) (*Reader, error) {
+	info, err := f.GetStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	if f.Frames == nil {
+		return nil, flac.ErrorNoFrames
+	}
+	return NewReader(f.Frames, info), nil
+}
+
+// NewReader returns a Reader of the audio frames read from r, described by info
+func NewReader(r io.Reader, info *flac.StreamInfoBlock) *Reader {
+	return &Reader{
+		dec:    flac.NewDecoder(r, info),
+		format: Format{SampleRate: info.SampleRate, Channels: info.ChannelCount, BitDepth: info.BitDepth},
+	}
+}
+
+// Format returns the format of the samples
+func (c *Reader) Format() Format {
+	return c.format
+}
+
+// fill decodes the next frame into pending, returning io.EOF at the end of the stream
+func (c *Reader) fill() error {
+	if c.err != nil {
+		return c.err
+	}
+	frame, err := c.dec.Next()
+	if err != nil {
+		c.err = err
+		return err
+	}
+	if len(frame.Samples) != c.format.Channels || frame.BitDepth != c.format.BitDepth {
+		c.err = ErrorFormatChanged
+		return c.err
+	}
+	c.samples = c.samples[:0]
+	for i := range frame.Samples[0] {
+		for _, channel := range frame.Samples {
+			c.samples = append(c.samples, channel[i])
+		}
+	}
+	c.pending = c.samples
+	return nil
+}
+
+// ReadSamples reads up to len(dst) interleaved samples into dst and returns the number of samples read, a multiple of the channel count
+// unless dst is too short to hold a sample of every channel. It returns io.EOF at the end of the stream,
+// and the decoding error if a frame is corrupted or does not match the format.
+func (c *Reader) ReadSamples(dst []int32) (int, error) {
+	if len(dst) == 0 {
+		return 0, nil
+	}
+	if len(dst) >= c.format.Channels {
+		dst = dst[:len(dst)-len(dst)%c.format.Channels]
+	}
+	for len(c.pending) == 0 {
+		if err := c.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(dst, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Read reads the samples as signed, right-justified little-endian integers of Format.BytesPerSample bytes, the layout the audio MD5
+// of StreamInfo is computed over, so hashing everything Read returns gives StreamInfo.AudioMD5. It is also the raw PCM most audio
+// outputs accept for bit depths that are a multiple of 8. WAV data differs for 8-bit samples, stored unsigned, and for other
+// bit depths, stored left-justified: use flac.WAVWriter to write WAV files.
+func (c *Reader) Read(p []byte) (int, error) {
+	width := c.format.BytesPerSample()
+	for len(c.out) == 0 {
+		for len(c.pending) == 0 {
+			if err := c.fill(); err != nil {
+				return 0, err
+			}
+		}
+		c.buf = c.buf[:0]
+		for _, v := range c.pending {
+			for b := 0; b < width; b++ {
+				c.buf = append(c.buf, byte(v>>(8*b)))
+			}
+		}
+		c.out = c.buf
+		c.pending = nil
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
@@ -0,0 +1,119 @@
+package decode
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	flac "github.com/go-flac/go-flac/v2"
+)
+
+// encode returns a FLAC stream of the stereo 16-bit samples, written to a file so the encoder records the audio MD5
+func encode(t *testing.T, level int, left, right []int32) []byte {
+	t.Helper()
+	opts, err := flac.CompressionLevel(level)
+	if err != nil {
+		t.Fatalf("Failed to get level %d: %s", level, err)
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "test.flac"))
+	if err != nil {
+		t.Fatalf("Failed to create test file: %s", err)
+	}
+	defer out.Close()
+	enc, err := flac.NewEncoder(out, flac.StreamInfoBlock{SampleRate: 44100, ChannelCount: 2, BitDepth: 16}, opts)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %s", err)
+	}
+	if err := enc.Write([][]int32{left, right}); err != nil {
+		t.Fatalf("Failed to encode: %s", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Failed to close encoder: %s", err)
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatalf("Failed to read test file: %s", err)
+	}
+	return data
+}
+
+func TestReader(t *testing.T) {
+	const n = 12000
+	left, right := make([]int32, n), make([]int32, n)
+	seed := uint32(7)
+	for i := range left {
+		seed = seed*1664525 + 1013904223
+		switch {
+		case i < 4096:
+			// silence, encoded as constant subframes
+		case i < 8192:
+			left[i] = int32(12000 * math.Sin(2*math.Pi*440*float64(i)/44100))
+			right[i] = left[i] / 2
+		default:
+			// noise, which prediction cannot compress
+			left[i] = int32(int16(seed >> 16))
+			right[i] = int32(int16(seed))
+		}
+	}
+	interleaved := make([]int32, 0, 2*n)
+	var pcm bytes.Buffer
+	for i := range left {
+		interleaved = append(interleaved, left[i], right[i])
+		binary.Write(&pcm, binary.LittleEndian, [2]int16{int16(left[i]), int16(right[i])})
+	}
+
+	for _, level := range []int{0, 5, 8} {
+		data := encode(t, level, left, right)
+		f, err := flac.ParseBytes(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Level %d: failed to parse: %s", level, err)
+		}
+		r, err := New(f)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %s", err)
+		}
+		if format := r.Format(); format != (Format{SampleRate: 44100, Channels: 2, BitDepth: 16}) || format.BytesPerSample() != 2 {
+			t.Errorf("Unexpected format: %+v", format)
+		}
+		var decoded []int32
+		buf := make([]int32, 1001)
+		for {
+			m, err := r.ReadSamples(buf)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Level %d: failed to decode: %s", level, err)
+			}
+			if m%2 != 0 {
+				t.Fatalf("Level %d: read %d samples, not whole inter-channel samples", level, m)
+			}
+			decoded = append(decoded, buf[:m]...)
+		}
+		if !reflect.DeepEqual(decoded, interleaved) {
+			t.Errorf("Level %d: decoded samples differ from the input", level)
+		}
+
+		if f, err = flac.ParseBytes(bytes.NewReader(data)); err != nil {
+			t.Fatalf("Level %d: failed to parse: %s", level, err)
+		}
+		info, err := f.GetStreamInfo()
+		if err != nil {
+			t.Fatalf("Failed to get StreamInfo: %s", err)
+		}
+		r = NewReader(f.Frames, info)
+		read, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(read, pcm.Bytes()) {
+			t.Errorf("Level %d: unexpected PCM bytes: %v", level, err)
+		}
+		if sum := md5.Sum(read); !bytes.Equal(sum[:], info.AudioMD5) {
+			t.Errorf("Level %d: the PCM bytes should hash to the audio MD5", level)
+		}
+	}
+}
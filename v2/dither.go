@@ -0,0 +1,52 @@
+package flac
+
+import "math"
+
+// Ditherer reduces the bit depth of decoded audio with triangular (TPDF) dither, which turns the truncation distortion into
+// benign white noise, as when making a 16-bit CD copy of a 24-bit master
+type Ditherer struct {
+	// BitDepth is the bit depth of the output
+	BitDepth int
+	state    uint64
+}
+
+// NewDitherer returns a Ditherer to the given bit depth. The dither noise is generated from seed, so the output is reproducible.
+func NewDitherer(bitDepth int, seed uint64) *Ditherer {
+	return &Ditherer{BitDepth: bitDepth, state: seed | 1}
+}
+
+// uniform returns a pseudo-random number in [-0.5, 0.5)
+func (c *Ditherer) uniform() float64 {
+	// xorshift64*
+	c.state ^= c.state >> 12
+	c.state ^= c.state << 25
+	c.state ^= c.state >> 27
+	return float64((c.state*0x2545F4914F6CDD1D)>>11)/(1<<53) - 0.5
+}
+
+// Apply returns a copy of frame reduced to the bit depth of the Ditherer. Frames already at or below it are returned unchanged.
+func (c *Ditherer) Apply(frame *AudioFrame) (*AudioFrame, error) {
+	if c.BitDepth < 1 || c.BitDepth > 32 {
+		return nil, ErrorInvalidBitDepth
+	}
+	shift := frame.BitDepth - c.BitDepth
+	if shift <= 0 {
+		return frame, nil
+	}
+	res := *frame
+	res.BitDepth = c.BitDepth
+	res.Header.BitDepth = c.BitDepth
+	res.Samples = make([][]int32, len(frame.Samples))
+	scale := math.Ldexp(1, -shift)
+	highest := math.Ldexp(1, c.BitDepth-1) - 1
+	lowest := -highest - 1
+	for ch, samples := range frame.Samples {
+		out := make([]int32, len(samples))
+		for i, v := range samples {
+			d := float64(v)*scale + c.uniform() + c.uniform()
+			out[i] = int32(math.Max(lowest, math.Min(highest, math.Round(d))))
+		}
+		res.Samples[ch] = out
+	}
+	return &res, nil
+}
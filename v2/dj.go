@@ -0,0 +1,73 @@
+package flac
+
+import "strings"
+
+// DJMetadata locates performance data (beatgrids, cue points, waveform overviews) a DJ application stored in a File.
+// This package keeps such data byte-exact when the File is saved; DJMetadata only reports where it is so retagging tools can warn before removing it.
+type DJMetadata struct {
+	// Software is the name of the application that wrote the data
+	Software string
+	// Block is the index in File.Meta of the block holding the data
+	Block int
+	// Field is the Vorbis comment field holding the data, empty for Application blocks
+	Field string
+}
+
+// djApplicationIDs maps Application block IDs to the DJ software using them.
+// Serato, Traktor and Rekordbox have no ID in the registry of Application IDs and none of them writes Application blocks,
+// so it only holds the IDs given to RegisterDJApplicationID.
+var djApplicationIDs = map[[4]byte]string{}
+
+// djCommentPrefixes maps Vorbis comment field name prefixes to the DJ software using them.
+// Rekordbox keeps its cues and beatgrids in its own database and ANLZ analysis files rather than in the FLAC files, so it has no entry.
+var djCommentPrefixes = map[string]string{
+	// Serato stores base64 encoded SERATO_MARKERS_V2, SERATO_BEATGRID, SERATO_OVERVIEW etc. in FLAC files
+	"SERATO_": "Serato",
+	// Traktor stores its cues, loops and beatgrid as a base64 encoded tree of chunks in a single TRAKTOR4 field
+	"TRAKTOR4": "Traktor",
+}
+
+// RegisterDJApplicationID makes DJMetadata report Application blocks with the given ID as data of software.
+// It is not safe to call concurrently with DJMetadata and is meant to be called from init functions.
+func RegisterDJApplicationID(id [4]byte, software string) {
+	djApplicationIDs[id] = software
+}
+
+// RegisterDJCommentPrefix makes DJMetadata report Vorbis comments whose field name starts with prefix as data of software.
+// The prefix is matched case-insensitively. It is not safe to call concurrently with DJMetadata and is meant to be called from init functions.
+func RegisterDJCommentPrefix(prefix, software string) {
+	djCommentPrefixes[strings.ToUpper(prefix)] = software
+}
+
+// DJMetadata lists the DJ performance data found in the metadata blocks of the File.
+// Blocks that fail to decode are skipped.
+func (c *File) DJMetadata() []DJMetadata {
+	var res []DJMetadata
+	for i, meta := range c.Meta {
+		switch meta.Type {
+		case Application:
+			app, err := ParseApplication(meta)
+			if err != nil {
+				continue
+			}
+			if software, ok := djApplicationIDs[app.ID]; ok {
+				res = append(res, DJMetadata{Software: software, Block: i})
+			}
+		case VorbisComment:
+			_, comments, err := loadVorbisComment(meta)
+			if err != nil {
+				continue
+			}
+			for _, comment := range comments {
+				name, _ := splitVorbisComment(comment)
+				for prefix, software := range djCommentPrefixes {
+					if strings.HasPrefix(name, prefix) {
+						res = append(res, DJMetadata{Software: software, Block: i, Field: name})
+						break
+					}
+				}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,329 @@
+package flac
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpVerbosity selects how much of each metadata block Dump reports
+type DumpVerbosity int
+
+const (
+	// DumpBlocks reports the type, last flag and length of each block
+	DumpBlocks DumpVerbosity = iota
+	// DumpFields also reports the decoded fields of known block types, like metaflac --list
+	DumpFields
+	// DumpData also reports the binary content of Application, Picture and unknown blocks
+	DumpData
+)
+
+// blockTypeNames are the names metaflac gives to the block types
+var blockTypeNames = [...]string{"STREAMINFO", "PADDING", "APPLICATION", "SEEKTABLE", "VORBIS_COMMENT", "CUESHEET", "PICTURE"}
+
+// blockTypeName returns the metaflac name of a block type
+func blockTypeName(t BlockType) string {
+	if t >= 0 && int(t) < len(blockTypeNames) {
+		return blockTypeNames[t]
+	}
+	if t == Invalid {
+		return "INVALID"
+	}
+	return "UNKNOWN"
+}
+
+// pictureTypeNames describe the picture types
+var pictureTypeNames = [...]string{"Other", "32x32 pixels 'file icon' (PNG only)", "Other file icon", "Cover (front)", "Cover (back)",
+	"Leaflet page", "Media (e.g. label side of CD)", "Lead artist/lead performer/soloist", "Artist/performer", "Conductor", "Band/Orchestra",
+	"Composer", "Lyricist/text writer", "Recording Location", "During recording", "During performance", "Movie/video screen capture",
+	"A bright coloured fish", "Illustration", "Band/artist logotype", "Publisher/Studio logotype"}
+
+// pictureTypeName returns the description of a picture type
+func pictureTypeName(t PictureType) string {
+	if int(t) < len(pictureTypeNames) {
+		return pictureTypeNames[t]
+	}
+	return "Unknown"
+}
+
+// dumpBlock is the report of one metadata block, rendered as text by Dump and as JSON by DumpJSON
+type dumpBlock struct {
+	Index         int                `json:"index"`
+	Type          BlockType          `json:"type"`
+	TypeName      string             `json:"type_name"`
+	IsLast        bool               `json:"is_last"`
+	Length        int                `json:"length"`
+	Error         string             `json:"error,omitempty"`
+	StreamInfo    *dumpStreamInfo    `json:"stream_info,omitempty"`
+	ApplicationID string             `json:"application_id,omitempty"`
+	SeekPoints    []dumpSeekPoint    `json:"seek_points,omitempty"`
+	VorbisComment *dumpVorbisComment `json:"vorbis_comment,omitempty"`
+	CueSheet      *dumpCueSheet      `json:"cue_sheet,omitempty"`
+	Picture       *dumpPicture       `json:"picture,omitempty"`
+	Data          []byte             `json:"data,omitempty"`
+}
+
+type dumpStreamInfo struct {
+	BlockSizeMin int    `json:"min_block_size"`
+	BlockSizeMax int    `json:"max_block_size"`
+	FrameSizeMin int    `json:"min_frame_size"`
+	FrameSizeMax int    `json:"max_frame_size"`
+	SampleRate   int    `json:"sample_rate"`
+	Channels     int    `json:"channels"`
+	BitDepth     int    `json:"bits_per_sample"`
+	SampleCount  int64  `json:"total_samples"`
+	AudioMD5     string `json:"md5"`
+}
+
+type dumpSeekPoint struct {
+	Placeholder bool   `json:"placeholder,omitempty"`
+	Sample      uint64 `json:"sample_number"`
+	Offset      uint64 `json:"stream_offset"`
+	Samples     uint16 `json:"frame_samples"`
+}
+
+type dumpVorbisComment struct {
+	Vendor   string   `json:"vendor"`
+	Comments []string `json:"comments"`
+}
+
+type dumpCueSheet struct {
+	Catalog string         `json:"media_catalog_number"`
+	LeadIn  uint64         `json:"lead_in"`
+	IsCD    bool           `json:"is_cd"`
+	Tracks  []dumpCueTrack `json:"tracks"`
+}
+
+type dumpCueTrack struct {
+	Offset      uint64         `json:"offset"`
+	Number      uint8          `json:"number"`
+	ISRC        string         `json:"isrc"`
+	Audio       bool           `json:"audio"`
+	PreEmphasis bool           `json:"pre_emphasis"`
+	Indices     []dumpCueIndex `json:"indices"`
+}
+
+type dumpCueIndex struct {
+	Offset uint64 `json:"offset"`
+	Number uint8  `json:"number"`
+}
+
+type dumpPicture struct {
+	Type        PictureType `json:"type"`
+	TypeName    string      `json:"type_name"`
+	MIME        string      `json:"mime_type"`
+	Description string      `json:"description"`
+	Width       uint32      `json:"width"`
+	Height      uint32      `json:"height"`
+	Depth       uint32      `json:"depth"`
+	Colors      uint32      `json:"colors"`
+	DataLength  int         `json:"data_length"`
+}
+
+// dumpBlocks decodes the metadata blocks of f for Dump and DumpJSON.
+// Blocks that fail to decode are reported with their error instead of failing the dump.
+func dumpBlocks(f *File, verbosity DumpVerbosity) []dumpBlock {
+	res := make([]dumpBlock, len(f.Meta))
+	for i, meta := range f.Meta {
+		block := &res[i]
+		*block = dumpBlock{Index: i, Type: meta.Type, TypeName: blockTypeName(meta.Type), IsLast: i == len(f.Meta)-1, Length: meta.Len()}
+		if verbosity < DumpFields || meta.Type == Padding {
+			continue
+		}
+		data := []byte(meta.Data)
+		if meta.pending() {
+			var err error
+			if data, err = io.ReadAll(meta.Reader()); err != nil {
+				block.Error = err.Error()
+				continue
+			}
+		}
+		if err := block.decode(data, verbosity); err != nil {
+			block.Error = err.Error()
+		}
+	}
+	return res
+}
+
+// decode fills the fields of the block type from the block data
+func (c *dumpBlock) decode(data []byte, verbosity DumpVerbosity) error {
+	switch c.Type {
+	case StreamInfo:
+		info, err := parseStreamInfo(data)
+		if err != nil {
+			return err
+		}
+		c.StreamInfo = &dumpStreamInfo{
+			BlockSizeMin: info.BlockSizeMin, BlockSizeMax: info.BlockSizeMax,
+			FrameSizeMin: info.FrameSizeMin, FrameSizeMax: info.FrameSizeMax,
+			SampleRate: info.SampleRate, Channels: info.ChannelCount, BitDepth: info.BitDepth,
+			SampleCount: info.SampleCount, AudioMD5: hex.EncodeToString(info.AudioMD5),
+		}
+	case Application:
+		if len(data) < 4 {
+			return ErrorApplicationIDMissing
+		}
+		c.ApplicationID = hex.EncodeToString(data[:4])
+		if verbosity >= DumpData {
+			c.Data = data[4:]
+		}
+	case SeekTable:
+		points, err := parseSeekTable(data)
+		if err != nil {
+			return err
+		}
+		c.SeekPoints = make([]dumpSeekPoint, len(points))
+		for i, p := range points {
+			if p.sample == SeekPointPlaceholder {
+				c.SeekPoints[i] = dumpSeekPoint{Placeholder: true}
+			} else {
+				c.SeekPoints[i] = dumpSeekPoint{Sample: p.sample, Offset: p.offset, Samples: p.samples}
+			}
+		}
+	case VorbisComment:
+		vendor, comments, err := parseVorbisComment(data)
+		if err != nil {
+			return err
+		}
+		c.VorbisComment = &dumpVorbisComment{Vendor: vendor, Comments: comments}
+	case CueSheet:
+		sheet, err := parseCueSheet(data)
+		if err != nil {
+			return err
+		}
+		c.CueSheet = &dumpCueSheet{Catalog: sheet.catalog, LeadIn: sheet.leadIn, IsCD: sheet.isCD, Tracks: make([]dumpCueTrack, len(sheet.tracks))}
+		for i, track := range sheet.tracks {
+			t := dumpCueTrack{Offset: track.offset, Number: track.number, ISRC: track.isrc, Audio: track.isAudio, PreEmphasis: track.preEmphasis}
+			t.Indices = make([]dumpCueIndex, len(track.indices))
+			for j, index := range track.indices {
+				t.Indices[j] = dumpCueIndex{Offset: index.offset, Number: index.number}
+			}
+			c.CueSheet.Tracks[i] = t
+		}
+	case Picture:
+		header, image, err := parsePicture(data)
+		if err != nil {
+			return err
+		}
+		c.Picture = &dumpPicture{
+			Type: header.pictureType, TypeName: pictureTypeName(header.pictureType), MIME: header.mime, Description: header.description,
+			Width: header.width, Height: header.height, Depth: header.depth, Colors: header.colors, DataLength: len(image),
+		}
+		if verbosity >= DumpData {
+			c.Data = image
+		}
+	default:
+		if verbosity >= DumpData {
+			c.Data = data
+		}
+	}
+	return nil
+}
+
+// Dump writes a human-readable listing of the metadata blocks of f in the style of metaflac --list, for command line tools and debug endpoints
+func Dump(w io.Writer, f *File, verbosity DumpVerbosity) error {
+	bw := bufio.NewWriter(w)
+	p := func(indent int, format string, args ...interface{}) {
+		bw.WriteString(strings.Repeat("  ", indent))
+		fmt.Fprintf(bw, format, args...)
+		bw.WriteByte('\n')
+	}
+	for _, block := range dumpBlocks(f, verbosity) {
+		p(0, "METADATA block #%d", block.Index)
+		p(1, "type: %d (%s)", block.Type, block.TypeName)
+		p(1, "is last: %t", block.IsLast)
+		p(1, "length: %d", block.Length)
+		if block.Error != "" {
+			p(1, "error: %s", block.Error)
+		}
+
+		if s := block.StreamInfo; s != nil {
+			p(1, "minimum blocksize: %d samples", s.BlockSizeMin)
+			p(1, "maximum blocksize: %d samples", s.BlockSizeMax)
+			p(1, "minimum framesize: %d bytes", s.FrameSizeMin)
+			p(1, "maximum framesize: %d bytes", s.FrameSizeMax)
+			p(1, "sample_rate: %d Hz", s.SampleRate)
+			p(1, "channels: %d", s.Channels)
+			p(1, "bits-per-sample: %d", s.BitDepth)
+			p(1, "total samples: %d", s.SampleCount)
+			p(1, "MD5 signature: %s", s.AudioMD5)
+		}
+		if block.ApplicationID != "" {
+			p(1, "application ID: %s", block.ApplicationID)
+		}
+		if block.Type == SeekTable && block.Error == "" && verbosity >= DumpFields {
+			p(1, "seek points: %d", len(block.SeekPoints))
+			for i, point := range block.SeekPoints {
+				if point.Placeholder {
+					p(2, "point %d: PLACEHOLDER", i)
+				} else {
+					p(2, "point %d: sample_number=%d, stream_offset=%d, frame_samples=%d", i, point.Sample, point.Offset, point.Samples)
+				}
+			}
+		}
+		if v := block.VorbisComment; v != nil {
+			p(1, "vendor string: %s", v.Vendor)
+			p(1, "comments: %d", len(v.Comments))
+			for i, comment := range v.Comments {
+				p(2, "comment[%d]: %s", i, comment)
+			}
+		}
+		if c := block.CueSheet; c != nil {
+			p(1, "media catalog number: %s", c.Catalog)
+			p(1, "lead-in: %d", c.LeadIn)
+			p(1, "is CD: %t", c.IsCD)
+			p(1, "number of tracks: %d", len(c.Tracks))
+			for i, track := range c.Tracks {
+				p(2, "track[%d]", i)
+				p(3, "offset: %d", track.Offset)
+				p(3, "number: %d", track.Number)
+				p(3, "ISRC: %s", track.ISRC)
+				if track.Audio {
+					p(3, "type: AUDIO")
+				} else {
+					p(3, "type: NON-AUDIO")
+				}
+				p(3, "pre-emphasis: %t", track.PreEmphasis)
+				p(3, "number of index points: %d", len(track.Indices))
+				for j, index := range track.Indices {
+					p(4, "index[%d]", j)
+					p(5, "offset: %d", index.Offset)
+					p(5, "number: %d", index.Number)
+				}
+			}
+		}
+		if pic := block.Picture; pic != nil {
+			p(1, "type: %d (%s)", pic.Type, pic.TypeName)
+			p(1, "MIME type: %s", pic.MIME)
+			p(1, "description: %s", pic.Description)
+			p(1, "width: %d", pic.Width)
+			p(1, "height: %d", pic.Height)
+			p(1, "depth: %d", pic.Depth)
+			if pic.Colors == 0 {
+				p(1, "colors: 0 (unindexed)")
+			} else {
+				p(1, "colors: %d", pic.Colors)
+			}
+			p(1, "data length: %d", pic.DataLength)
+		}
+		if block.Data != nil {
+			p(1, "data contents:")
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(hex.Dump(block.Data), "\n"), "\n") {
+				p(2, "%s", strings.TrimSuffix(line, "\n"))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// DumpJSON writes the listing of Dump as a JSON document with a "blocks" array, binary data being base64 encoded
+func DumpJSON(w io.Writer, f *File, verbosity DumpVerbosity) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Blocks []dumpBlock `json:"blocks"`
+	}{dumpBlocks(f, verbosity)})
+}
@@ -0,0 +1,367 @@
+package flac
+
+import (
+	"crypto/md5"
+	"hash"
+	"io"
+	"math/bits"
+)
+
+// EncoderOptions are the settings of an Encoder, trading encoding speed for size like the options of the reference encoder
+type EncoderOptions struct {
+	// BlockSize is the number of inter-channel samples per frame
+	BlockSize int
+	// MaxLPCOrder is the highest LPC order tried, 0 to use fixed predictors only (-l)
+	MaxLPCOrder int
+	// LPCPrecision is the precision of the quantized LPC coefficients in bits, 0 to pick it from the block size (-q)
+	LPCPrecision int
+	// MinPartitionOrder and MaxPartitionOrder bound the Rice partition orders tried (-r)
+	MinPartitionOrder, MaxPartitionOrder int
+	// MidSide tries stereo decorrelation and keeps the smallest channel assignment (-m)
+	MidSide bool
+	// ExhaustiveModelSearch tries every LPC order instead of the one estimated best (-e)
+	ExhaustiveModelSearch bool
+	// Apodizations are the windows tried for the LPC analysis, Tukey(0.5) if empty (-A)
+	Apodizations []Apodization
+	// Workers is the number of frames encoded concurrently (-j), 0 or 1 to encode on the calling goroutine.
+	// Frames are independent, so the output is identical whatever the number of workers.
+	Workers int
+}
+
+// CompressionLevel returns the EncoderOptions equivalent to the compression levels 0 to 8 of the reference encoder, 5 being its default.
+// Level 1 and 4 use adaptive mid-side stereo in the reference encoder; they try every stereo assignment here.
+func CompressionLevel(level int) (EncoderOptions, error) {
+	switch level {
+	case 0, 1, 2:
+		return EncoderOptions{BlockSize: 1152, MaxPartitionOrder: 3, MidSide: level > 0}, nil
+	case 3:
+		return EncoderOptions{BlockSize: 4096, MaxLPCOrder: 6, MaxPartitionOrder: 4}, nil
+	case 4:
+		return EncoderOptions{BlockSize: 4096, MaxLPCOrder: 8, MaxPartitionOrder: 4, MidSide: true}, nil
+	case 5:
+		return EncoderOptions{BlockSize: 4096, MaxLPCOrder: 8, MaxPartitionOrder: 5, MidSide: true}, nil
+	case 6:
+		return EncoderOptions{BlockSize: 4096, MaxLPCOrder: 8, MaxPartitionOrder: 6, MidSide: true, Apodizations: []Apodization{SubdivideTukey(2)}}, nil
+	case 7:
+		return EncoderOptions{BlockSize: 4096, MaxLPCOrder: 12, MaxPartitionOrder: 6, MidSide: true, Apodizations: []Apodization{SubdivideTukey(2)}}, nil
+	case 8:
+		return EncoderOptions{BlockSize: 4096, MaxLPCOrder: 12, MaxPartitionOrder: 6, MidSide: true, Apodizations: []Apodization{SubdivideTukey(3)}}, nil
+	}
+	return EncoderOptions{}, ErrorInvalidEncoderOptions
+}
+
+// validate checks the options against the limits of the format
+func (c *EncoderOptions) validate() error {
+	if c.BlockSize < 16 || c.BlockSize > 65535 || c.MaxLPCOrder < 0 || c.MaxLPCOrder > 32 ||
+		c.LPCPrecision < 0 || c.LPCPrecision > 15 || c.MinPartitionOrder < 0 || c.MaxPartitionOrder > 15 || c.MinPartitionOrder > c.MaxPartitionOrder ||
+		c.Workers < 0 {
+		return ErrorInvalidEncoderOptions
+	}
+	return nil
+}
+
+// Encoder encodes PCM samples into a FLAC stream
+type Encoder struct {
+	w      *AppendingWriter
+	opts   EncoderOptions
+	info   StreamInfoBlock
+	buf    [][]int32
+	frame  uint64
+	md5    hash.Hash
+	pcm    []byte
+	closed bool
+
+	// jobs feeds the worker pool; pending holds the results of the frames in flight in stream order
+	jobs    chan encodeJob
+	pending []chan []byte
+	err     error
+}
+
+// encodeJob is a block to encode on the worker pool
+type encodeJob struct {
+	number  uint64
+	samples [][]int32
+	result  chan<- []byte
+}
+
+// NewEncoder writes the header of a FLAC stream with the format of info and the given metadata blocks to w, and returns an Encoder of its audio.
+// Only SampleRate, ChannelCount and BitDepth of info must be set; the sample count, block and frame sizes and the audio MD5 are written on Close
+// when w is seekable.
+func NewEncoder(w io.Writer, info StreamInfoBlock, opts EncoderOptions, meta ...*MetaDataBlock) (*Encoder, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if info.ChannelCount < 1 || info.ChannelCount > 8 || info.BitDepth < 4 || info.BitDepth > 32 || info.SampleRate <= 0 {
+		return nil, ErrorInvalidEncoderOptions
+	}
+	info.BlockSizeMin, info.BlockSizeMax = opts.BlockSize, opts.BlockSize
+	aw, err := NewAppendingWriter(w, info, meta...)
+	if err != nil {
+		return nil, err
+	}
+	res := &Encoder{w: aw, opts: opts, info: info, buf: make([][]int32, info.ChannelCount), md5: md5.New()}
+	if opts.Workers > 1 {
+		res.jobs = make(chan encodeJob, opts.Workers)
+		for i := 0; i < opts.Workers; i++ {
+			go func() {
+				for job := range res.jobs {
+					job.result <- encodeFrame(&res.opts, &res.info, job.number, job.samples)
+				}
+			}()
+		}
+	}
+	return res, nil
+}
+
+// Write encodes the samples of each channel, samples[channel][i]. Every channel must hold the same number of samples.
+// Samples are buffered until a whole block is available. If a sample does not fit the bit depth of the stream,
+// Write returns ErrorSampleOutOfRange and none of the samples are encoded.
+func (c *Encoder) Write(samples [][]int32) error {
+	if c.closed {
+		return ErrorWriterClosed
+	}
+	if len(samples) != len(c.buf) {
+		return ErrorInvalidChannelMap
+	}
+	high := int64(1)<<(c.info.BitDepth-1) - 1
+	for ch := range samples {
+		if len(samples[ch]) != len(samples[0]) {
+			return ErrorInvalidChannelMap
+		}
+		for _, v := range samples[ch] {
+			if int64(v) < -high-1 || int64(v) > high {
+				return ErrorSampleOutOfRange
+			}
+		}
+	}
+	for ch := range samples {
+		c.buf[ch] = append(c.buf[ch], samples[ch]...)
+	}
+	c.hashSamples(samples)
+	for len(c.buf[0]) >= c.opts.BlockSize {
+		if err := c.flush(c.opts.BlockSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFrame encodes the samples of a decoded frame, which must have the channel count and bit depth of the Encoder
+func (c *Encoder) WriteFrame(frame *AudioFrame) error {
+	if frame.BitDepth != c.info.BitDepth {
+		return ErrorInvalidBitDepth
+	}
+	return c.Write(frame.Samples)
+}
+
+// hashSamples adds the samples to the audio MD5
+func (c *Encoder) hashSamples(samples [][]int32) {
+	c.pcm = appendPCM(c.pcm[:0], samples, c.info.BitDepth)
+	c.md5.Write(c.pcm)
+}
+
+// appendPCM appends the samples to buf as the audio MD5 hashes them, as in the reference encoder:
+// interleaved, little-endian, on the whole bytes needed by bitDepth
+func appendPCM(buf []byte, samples [][]int32, bitDepth int) []byte {
+	width := (bitDepth + 7) / 8
+	for i := range samples[0] {
+		for _, channel := range samples {
+			v := channel[i]
+			for b := 0; b < width; b++ {
+				buf = append(buf, byte(v>>(8*b)))
+			}
+		}
+	}
+	return buf
+}
+
+// flush encodes the first n buffered samples into a frame, or hands them to the worker pool
+func (c *Encoder) flush(n int) error {
+	block := make([][]int32, len(c.buf))
+	for ch := range c.buf {
+		block[ch] = append([]int32(nil), c.buf[ch][:n]...)
+		c.buf[ch] = append(c.buf[ch][:0], c.buf[ch][n:]...)
+	}
+	number := c.frame
+	c.frame++
+	if c.jobs == nil {
+		return c.w.WriteFrame(encodeFrame(&c.opts, &c.info, number, block))
+	}
+
+	result := make(chan []byte, 1)
+	c.jobs <- encodeJob{number: number, samples: block, result: result}
+	c.pending = append(c.pending, result)
+	// bound the frames held in memory while keeping every worker busy
+	for len(c.pending) > 2*c.opts.Workers {
+		if err := c.writePending(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePending waits for the oldest frame in flight and writes it
+func (c *Encoder) writePending() error {
+	frame := <-c.pending[0]
+	c.pending = c.pending[1:]
+	if c.err == nil {
+		c.err = c.w.WriteFrame(frame)
+	}
+	return c.err
+}
+
+// StreamInfo returns the StreamInfo values of the frames encoded so far
+func (c *Encoder) StreamInfo() StreamInfoBlock {
+	return c.w.StreamInfo()
+}
+
+// Close encodes the buffered samples and finalizes the stream as AppendingWriter.Close does. The underlying writer is not closed.
+func (c *Encoder) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	var err error
+	if n := len(c.buf[0]); n > 0 {
+		err = c.flush(n)
+	}
+	if c.jobs != nil {
+		close(c.jobs)
+		for len(c.pending) > 0 {
+			if err2 := c.writePending(); err == nil {
+				err = err2
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c.w.SetAudioMD5(c.md5.Sum(nil))
+	return c.w.Close()
+}
+
+// encodeFrame encodes a block of samples into a frame with the given frame number
+func encodeFrame(opts *EncoderOptions, info *StreamInfoBlock, number uint64, samples [][]int32) []byte {
+	blockSize := len(samples[0])
+	bps := uint(info.BitDepth)
+
+	assignment := ChannelsIndependent
+	subframes := make([]*subframe, len(samples))
+	for ch := range samples {
+		subframes[ch] = encodeSubframe(opts, samples[ch], bps)
+	}
+	if len(samples) == 2 && opts.MidSide && bps < 32 {
+		left, right := samples[0], samples[1]
+		mid, side := make([]int32, blockSize), make([]int32, blockSize)
+		for i := range left {
+			mid[i] = int32((int64(left[i]) + int64(right[i])) >> 1)
+			side[i] = left[i] - right[i]
+		}
+		midSub, sideSub := encodeSubframe(opts, mid, bps), encodeSubframe(opts, side, bps+1)
+		best := subframes[0].bits + subframes[1].bits
+		if n := subframes[0].bits + sideSub.bits; n < best {
+			best, assignment = n, ChannelsLeftSide
+		}
+		if n := sideSub.bits + subframes[1].bits; n < best {
+			best, assignment = n, ChannelsRightSide
+		}
+		if n := midSub.bits + sideSub.bits; n < best {
+			assignment = ChannelsMidSide
+		}
+		switch assignment {
+		case ChannelsLeftSide:
+			subframes[1] = sideSub
+		case ChannelsRightSide:
+			subframes[0] = sideSub
+		case ChannelsMidSide:
+			subframes[0], subframes[1] = midSub, sideSub
+		}
+	}
+
+	w := &bitWriter{data: appendFrameHeader(nil, info, number, blockSize, len(samples), assignment)}
+	for _, sub := range subframes {
+		sub.write(w)
+	}
+	w.alignByte()
+	crc := crc16(w.data)
+	return append(w.data, byte(crc>>8), byte(crc))
+}
+
+// appendFrameHeader appends the header of a fixed block size frame, including its CRC-8
+func appendFrameHeader(dst []byte, info *StreamInfoBlock, number uint64, blockSize, channels int, assignment ChannelAssignment) []byte {
+	start := len(dst)
+	var blockSizeCode, sampleRateCode, channelCode, bitDepthCode byte
+	var tail []byte
+
+	switch {
+	case blockSize == 192:
+		blockSizeCode = 1
+	case blockSize == 576 || blockSize == 1152 || blockSize == 2304 || blockSize == 4608:
+		blockSizeCode = byte(2 + bits.TrailingZeros(uint(blockSize/576)))
+	case blockSize >= 256 && blockSize <= 32768 && blockSize&(blockSize-1) == 0:
+		blockSizeCode = byte(8 + bits.TrailingZeros(uint(blockSize/256)))
+	case blockSize <= 256:
+		blockSizeCode = 6
+		tail = append(tail, byte(blockSize-1))
+	default:
+		blockSizeCode = 7
+		tail = append(tail, byte((blockSize-1)>>8), byte(blockSize-1))
+	}
+
+	rate := info.SampleRate
+	for code := 1; code < len(frameSampleRates); code++ {
+		if frameSampleRates[code] == rate {
+			sampleRateCode = byte(code)
+		}
+	}
+	if sampleRateCode == 0 {
+		switch {
+		case rate%1000 == 0 && rate/1000 < 256:
+			sampleRateCode = 12
+			tail = append(tail, byte(rate/1000))
+		case rate < 65536:
+			sampleRateCode = 13
+			tail = append(tail, byte(rate>>8), byte(rate))
+		case rate%10 == 0 && rate/10 < 65536:
+			sampleRateCode = 14
+			tail = append(tail, byte(rate/10>>8), byte(rate/10))
+		}
+	}
+
+	if assignment == ChannelsIndependent {
+		channelCode = byte(channels - 1)
+	} else {
+		channelCode = byte(7 + assignment)
+	}
+	for code, depth := range frameBitDepths {
+		if depth > 0 && depth == info.BitDepth {
+			bitDepthCode = byte(code)
+		}
+	}
+
+	dst = append(dst, 0xFF, 0xF8, blockSizeCode<<4|sampleRateCode, channelCode<<4|bitDepthCode<<1)
+	dst = appendFrameNumber(dst, number)
+	dst = append(dst, tail...)
+	return append(dst, crc8(dst[start:]))
+}
+
+// appendFrameNumber appends a frame or sample number with the extended UTF-8 coding of frame headers
+func appendFrameNumber(dst []byte, n uint64) []byte {
+	if n < 0x80 {
+		return append(dst, byte(n))
+	}
+	// number of continuation bytes, each holding 6 bits
+	extra := 1
+	for n >= 1<<(6*extra+6-extra) && extra < 6 {
+		extra++
+	}
+	lead := byte(0xFF << (7 - extra))
+	if extra == 6 {
+		lead = 0xFE
+	}
+	dst = append(dst, lead|byte(n>>(6*extra)))
+	for i := extra - 1; i >= 0; i-- {
+		dst = append(dst, 0x80|byte(n>>(6*i))&0x3F)
+	}
+	return dst
+}
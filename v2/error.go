@@ -1,6 +1,9 @@
 package flac
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrorNoFLACHeader indicates that "fLaC" marker not found at the beginning of the file
@@ -13,4 +16,135 @@ var (
 	ErrorNoSyncCode = errors.New("frames do not begin with sync code")
 	// ErrorAlreadyWritten indicates that the frames have already been written to the file
 	ErrorAlreadyWritten = errors.New("frames already written")
+	// ErrorInvalidSoundCheck indicates that an iTunNORM value is not made of ten hexadecimal numbers
+	ErrorInvalidSoundCheck = errors.New("invalid iTunNORM value")
+	// ErrorNoReplayGain indicates that the ReplayGain track gain is not present in the tags
+	ErrorNoReplayGain = errors.New("replay gain not present")
+	// ErrorUnexpectedBlockType indicates that a metadata block was passed to a decoder for a different block type
+	ErrorUnexpectedBlockType = errors.New("unexpected metadata block type")
+	// ErrorApplicationIDMissing indicates that an Application Metablock is too short to hold its application ID
+	ErrorApplicationIDMissing = errors.New("application id missing")
+	// ErrorMalformedVorbisComment indicates that the lengths in a VorbisComment Metablock exceed the block size
+	ErrorMalformedVorbisComment = errors.New("malformed vorbis comment")
+	// ErrorMalformedPicture indicates that the lengths in a Picture Metablock exceed the block size
+	ErrorMalformedPicture = errors.New("malformed picture")
+	// ErrorMalformedAPIC indicates that an ID3v2 APIC frame has an unknown text encoding or lacks the terminator of its MIME type or description
+	ErrorMalformedAPIC = errors.New("malformed APIC frame")
+	// ErrorInvalidChapter indicates that a CHAPTERxxx Vorbis comment does not hold a valid HH:MM:SS.mmm timestamp
+	ErrorInvalidChapter = errors.New("invalid chapter timestamp")
+	// ErrorTooManyChapters indicates that more chapters were given than the 3 digits of CHAPTERxxx Vorbis comments can number
+	ErrorTooManyChapters = errors.New("too many chapters")
+	// ErrorMalformedTranscript indicates that a transcript Application Metablock has an unknown version or inconsistent lengths
+	ErrorMalformedTranscript = errors.New("malformed transcript block")
+	// ErrorIncompleteTranscript indicates that some chunks of an embedded transcript are missing or duplicated
+	ErrorIncompleteTranscript = errors.New("incomplete transcript")
+	// ErrorTranscriptTooLarge indicates that a transcript needs more chunks than the block layout can number
+	ErrorTranscriptTooLarge = errors.New("transcript too large")
+	// ErrorBlockTooLarge indicates that the data of a metadata block exceeds the 16 MiB limit of the block header
+	ErrorBlockTooLarge = errors.New("metadata block too large")
+	// ErrorMalformedProvenance indicates that a provenance Application Metablock has an unknown version or inconsistent lengths
+	ErrorMalformedProvenance = errors.New("malformed provenance block")
+	// ErrorNoProvenance indicates that no provenance manifest is embedded in the file
+	ErrorNoProvenance = errors.New("provenance not present")
+	// ErrorProvenanceMismatch indicates that the audio MD5 a provenance manifest was bound to differs from the one in StreamInfo
+	ErrorProvenanceMismatch = errors.New("provenance manifest does not match audio")
+	// ErrorUnknownAudioMD5 indicates that the audio MD5 in StreamInfo is zero, meaning it was not computed by the encoder
+	ErrorUnknownAudioMD5 = errors.New("audio MD5 unknown")
+	// ErrorMalformedSignature indicates that a signature Application Metablock has an unknown version or inconsistent lengths
+	ErrorMalformedSignature = errors.New("malformed signature block")
+	// ErrorNoSignature indicates that the file carries no signature
+	ErrorNoSignature = errors.New("signature not present")
+	// ErrorSignatureInvalid indicates that the signature does not match the metadata or the public key
+	ErrorSignatureInvalid = errors.New("signature invalid")
+	// ErrorUnsupportedKey indicates that the public key type is not supported for signature verification
+	ErrorUnsupportedKey = errors.New("unsupported public key type")
+	// ErrorBlockIndex indicates that a metadata block index is out of range
+	ErrorBlockIndex = errors.New("metadata block index out of range")
+	// ErrorNoBlockSource indicates that the File was not parsed from a source supporting random access
+	ErrorNoBlockSource = errors.New("metadata block source not available")
+	// ErrorInvalidFrameHeader indicates that a frame header uses reserved or invalid values
+	ErrorInvalidFrameHeader = errors.New("invalid frame header")
+	// ErrorFrameCRC indicates that the checksum of a frame or frame header does not match its content
+	ErrorFrameCRC = errors.New("frame checksum mismatch")
+	// ErrorWriterClosed indicates that a frame was written to an AppendingWriter after Close
+	ErrorWriterClosed = errors.New("writer already closed")
+	// ErrorNoFrames indicates that the File holds no audio frames, as returned by ParseMetadata
+	ErrorNoFrames = errors.New("audio frames not present")
+	// ErrorTimeout matches every TimeoutError with errors.Is
+	ErrorTimeout = errors.New("timeout")
+	// ErrorQuotaTooSmall indicates that the byte limit given to WriteToN cannot hold the metadata
+	ErrorQuotaTooSmall = errors.New("byte limit smaller than metadata")
+	// ErrorMalformedSeekTable indicates that the size of a SeekTable Metablock is not a multiple of the seek point size
+	ErrorMalformedSeekTable = errors.New("malformed seek table")
+	// ErrorInvalidSampleRange indicates that a sample range is empty, negative or beyond the end of the stream
+	ErrorInvalidSampleRange = errors.New("invalid sample range")
+	// ErrorNoSeekInfo indicates that the File has neither a random access source nor a SeekTable to locate samples with
+	ErrorNoSeekInfo = errors.New("no seek information")
+	// ErrorInvalidSubframe indicates that a subframe uses a reserved type or coding, or its parameters do not fit the frame
+	ErrorInvalidSubframe = errors.New("invalid subframe")
+	// ErrorUnsupportedFrame indicates that a frame uses a valid coding the decoder does not support, such as 33-bit side channels
+	ErrorUnsupportedFrame = errors.New("unsupported frame")
+	// ErrorInvalidResolution indicates that the samples per peak or the bits per peak value requested are not supported
+	ErrorInvalidResolution = errors.New("invalid peak resolution")
+	// ErrorInvalidChannelMap indicates that a channel map or output format does not match the channels or bit depth of the audio
+	ErrorInvalidChannelMap = errors.New("invalid channel map")
+	// ErrorInvalidBitDepth indicates that a requested output bit depth is outside 1 to 32 bits
+	ErrorInvalidBitDepth = errors.New("invalid bit depth")
+	// ErrorInvalidSampleRate indicates that a requested sample rate is not positive
+	ErrorInvalidSampleRate = errors.New("invalid sample rate")
+	// ErrorInvalidEncoderOptions indicates that an encoder setting or stream format is outside the limits of the format or the encoder
+	ErrorInvalidEncoderOptions = errors.New("invalid encoder options")
+	// ErrorSampleOutOfRange indicates that a sample given to an Encoder does not fit the bit depth of the stream
+	ErrorSampleOutOfRange = errors.New("sample out of range of the bit depth")
+	// ErrorInvalidStreamInfo matches every StreamInfoError with errors.Is
+	ErrorInvalidStreamInfo = errors.New("invalid stream info")
+	// ErrorMalformedCueSheet indicates that a CueSheet Metablock is shorter than its track and index counts require
+	ErrorMalformedCueSheet = errors.New("malformed cue sheet")
+	// ErrorInvalidBlockType indicates that a metadata block uses the type 127, which is forbidden to avoid confusion with a frame sync code
+	ErrorInvalidBlockType = errors.New("invalid metadata block type")
+	// ErrorHeaderConsumed indicates that the stream starts with the StreamInfo block or an audio frame instead of the "fLaC" marker,
+	// as when the reader was already read past the marker, for example by an earlier parse. It matches ErrorNoFLACHeader with errors.Is.
+	ErrorHeaderConsumed = fmt.Errorf("%w: reader already positioned past the marker", ErrorNoFLACHeader)
+	// ErrorFileLocked indicates that another process held the lock of the file to save for longer than the timeout given to WithLock
+	ErrorFileLocked = errors.New("file locked by another process")
+	// ErrorHardLinked matches every HardLinkError with errors.Is
+	ErrorHardLinked = errors.New("file has several hard links")
+	// ErrorNoPictureChecksums indicates that the File has no picture checksums stored by SetPictureChecksums
+	ErrorNoPictureChecksums = errors.New("no picture checksums")
+	// ErrorMalformedPictureChecksums indicates that a picture checksum Application block is truncated or of an unknown version
+	ErrorMalformedPictureChecksums = errors.New("malformed picture checksums")
+	// ErrorHashUnavailable indicates that a hash function is not linked into the binary or is unknown
+	ErrorHashUnavailable = errors.New("hash function unavailable")
+	// ErrorPictureChecksumMismatch matches every PictureChecksumError with errors.Is
+	ErrorPictureChecksumMismatch = errors.New("picture checksum mismatch")
+	// ErrorNoCover indicates that a CoverProvider has no cover for the release
+	ErrorNoCover = errors.New("no cover found")
+	// ErrorNoLyrics indicates that a LyricsProvider has no lyrics for the track
+	ErrorNoLyrics = errors.New("no lyrics found")
+	// ErrorInvalidLRC indicates that lyrics are not in LRC format or have an invalid offset tag
+	ErrorInvalidLRC = errors.New("invalid LRC lyrics")
+	// ErrorNotResumable indicates that ResumeSave cannot complete a file, because the File has no seekable source or the file is not a partial copy of its frames
+	ErrorNotResumable = errors.New("save not resumable")
+	// ErrorInvalidFieldName indicates that a Vorbis comment field name is empty or holds characters outside 0x20 to 0x7D or '='
+	ErrorInvalidFieldName = errors.New("invalid Vorbis comment field name")
+	// ErrorMalformedTags indicates that a line of imported tags is not a NAME=value comment
+	ErrorMalformedTags = errors.New("malformed tag line")
+	// ErrorInvalidTemplate indicates that a RenamePolicy template has an unclosed field or an invalid padding width
+	ErrorInvalidTemplate = errors.New("invalid rename template")
+	// ErrorPathTooLong indicates that the directories of a destination path leave no room for its file name within the maximum path length
+	ErrorPathTooLong = errors.New("destination path too long")
+	// ErrorNoSourceFile indicates that the File was not parsed from a file that SaveMetadataInPlace could write to
+	ErrorNoSourceFile = errors.New("source file not available")
+	// ErrorFrameTooLarge indicates that no frame boundary was found within the largest size a frame can take
+	ErrorFrameTooLarge = errors.New("frame too large")
+	// ErrorMetadataInFrames matches every MisplacedMetadataError with errors.Is
+	ErrorMetadataInFrames = errors.New("metadata among audio frames")
+	// ErrorUnknownDuration indicates that StreamInfo does not record the sample count or the sample rate of the stream
+	ErrorUnknownDuration = errors.New("unknown stream duration")
+	// ErrorUnknownAudioSize indicates that the size of the audio frames can neither be measured nor estimated from StreamInfo
+	ErrorUnknownAudioSize = errors.New("unknown audio size")
+	// ErrorTooManyBlocks matches every BlockCountError with errors.Is
+	ErrorTooManyBlocks = errors.New("too many metadata blocks")
+	// ErrorAudioMD5Mismatch matches every AudioMD5Error with errors.Is
+	ErrorAudioMD5Mismatch = errors.New("audio MD5 mismatch")
 )
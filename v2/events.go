@@ -0,0 +1,145 @@
+package flac
+
+import "time"
+
+// EventKind identifies the step of an operation an Event reports
+type EventKind int
+
+const (
+	// EventParseStarted is sent when ParseMetadata, ParseBytes, ParseFile or ParseReaderAt starts reading a stream
+	EventParseStarted EventKind = iota
+	// EventParseFinished is sent when a parse returns, with its error if it failed
+	EventParseFinished
+	// EventBlockModified is sent when an editing method of the File, such as SetStreamInfo or SetLyrics, changes or adds a metadata block.
+	// Changes made to Meta directly are not reported.
+	EventBlockModified
+	// EventSaveStarted is sent when Save or SaveTo starts writing
+	EventSaveStarted
+	// EventSaveMetadataWritten is sent once the "fLaC" marker and the metadata blocks are written, before the audio frames
+	EventSaveMetadataWritten
+	// EventSaveFinished is sent when Save or SaveTo returns, with its error if it failed
+	EventSaveFinished
+	// EventBlockRemoved is sent when an editing method of the File, such as Scrub or RemoveTranscript, removes a metadata block.
+	// Changes made to Meta directly are not reported.
+	EventBlockRemoved
+)
+
+// String returns the name of the event kind, e.g. "parse started"
+func (k EventKind) String() string {
+	switch k {
+	case EventParseStarted:
+		return "parse started"
+	case EventParseFinished:
+		return "parse finished"
+	case EventBlockModified:
+		return "block modified"
+	case EventSaveStarted:
+		return "save started"
+	case EventSaveMetadataWritten:
+		return "save metadata written"
+	case EventSaveFinished:
+		return "save finished"
+	case EventBlockRemoved:
+		return "block removed"
+	}
+	return "unknown"
+}
+
+// Event reports a step of a parse, an edit or a save, for orchestration systems that track library operations without polling
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Path is the file parsed or saved, empty for streams
+	Path string
+	// Block is the index in Meta of the block an EventBlockModified reports, or the index the block an EventBlockRemoved reports had
+	// before the edit removing it, -1 for the other kinds
+	Block int
+	// BlockType is the type of the block an EventBlockModified or EventBlockRemoved reports
+	BlockType BlockType
+	// Bytes is the number of bytes written so far by a save
+	Bytes int64
+	// Err is the error an EventParseFinished or EventSaveFinished operation failed with, nil on success
+	Err error
+}
+
+// EventSink receives the events of a File. It is called synchronously from the goroutine running the operation, so it should return quickly.
+type EventSink func(Event)
+
+// EventChannel returns an EventSink sending the events to ch. Sends block until ch has room or a receiver,
+// so ch must be buffered or drained by another goroutine.
+func EventChannel(ch chan<- Event) EventSink {
+	return func(e Event) {
+		ch <- e
+	}
+}
+
+// WithEvents makes parsing report its start and end to sink. The parsed File keeps sink to report block modifications and saves, as SetEventSink does.
+func WithEvents(sink EventSink) ParseOption {
+	return func(c *parseConfig) {
+		c.events = sink
+	}
+}
+
+// SetEventSink makes the File report block modifications and saves to sink, or stops reporting them if sink is nil
+func (c *File) SetEventSink(sink EventSink) {
+	c.events = sink
+}
+
+// emit sends e to the sink of the File, if any, stamped with the current time
+func (c *File) emit(e Event) {
+	if c.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	if e.Kind != EventBlockModified && e.Kind != EventBlockRemoved {
+		e.Block = -1
+	}
+	c.events(e)
+}
+
+// modified reports the change of block, which must be in Meta
+func (c *File) modified(block *MetaDataBlock) {
+	if c.events == nil {
+		return
+	}
+	for i, meta := range c.Meta {
+		if meta == block {
+			c.emit(Event{Kind: EventBlockModified, Block: i, BlockType: block.Type})
+			return
+		}
+	}
+}
+
+// removeBlocks removes the blocks of Meta drop selects and reports each removal
+func (c *File) removeBlocks(drop func(*MetaDataBlock) bool) {
+	meta := c.Meta[:0]
+	for i, block := range c.Meta {
+		if drop(block) {
+			c.emit(Event{Kind: EventBlockRemoved, Block: i, BlockType: block.Type})
+			continue
+		}
+		meta = append(meta, block)
+	}
+	c.Meta = meta
+}
+
+// parseStarted reports the start of a parse to the sink given to WithEvents
+func (c *parseConfig) parseStarted() {
+	c.started = time.Now()
+	if c.events != nil {
+		c.events(Event{Kind: EventParseStarted, Time: c.started, Path: c.path, Block: -1})
+	}
+}
+
+// parseFinished reports the end of a parse to Instrumentation and to the sink given to WithEvents, and attaches the sink and WithRewindableFrames to the parsed File
+func (c *parseConfig) parseFinished(res *File, err error) {
+	recordParse(time.Since(c.started), err)
+	if res != nil && err == nil {
+		res.events = c.events
+		res.rewindable = c.rewindable
+	}
+	if c.events == nil {
+		return
+	}
+	c.events(Event{Kind: EventParseFinished, Time: time.Now(), Path: c.path, Block: -1, Err: err})
+}
@@ -0,0 +1,26 @@
+package flac
+
+import (
+	"bufio"
+	"io"
+)
+
+// Filter streams the FLAC stream read from in to out, replacing its metadata with the metadata edited by edit,
+// for pipelines such as `cat in.flac | mytagger > out.flac`. Only the metadata is held in memory, the audio frames are copied in constant memory.
+// Nothing is written to out unless the metadata parses and edit succeeds, so a failed edit leaves an empty output instead of a truncated file.
+// edit must not replace or read Frames.
+func Filter(in io.Reader, out io.Writer, edit func(*File) error) error {
+	f, err := ParseBytes(NewBufIOWithInner(in))
+	if err != nil {
+		return err
+	}
+	if err := edit(f); err != nil {
+		return err
+	}
+	// buffer the small writes of the metadata blocks
+	w := bufio.NewWriter(out)
+	if _, err := f.WriteTo(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
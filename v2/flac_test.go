@@ -3,12 +3,37 @@ package flac
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
+	"io/fs"
+	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"golang.org/x/text/language"
 )
 
 func httpGetBytes(url string) ([]byte, error) {
@@ -192,3 +217,4512 @@ func TestFLACDecode(t *testing.T) {
 	verify(f)
 
 }
+
+func TestID3v2VorbisConversion(t *testing.T) {
+	frames := map[string][]string{
+		"TIT2":               {"Bee Moved"},
+		"TPE1":               {"Blue Monday FM"},
+		"TRCK":               {"3/12"},
+		"TXXX:CATALOGNUMBER": {"BM-001"},
+		ID3v2PictureFrame:    {""},
+	}
+	fields, unmapped := ConvertID3v2ToVorbis(frames)
+	expected := map[string][]string{
+		"TITLE":         {"Bee Moved"},
+		"ARTIST":        {"Blue Monday FM"},
+		"TRACKNUMBER":   {"3"},
+		"TRACKTOTAL":    {"12"},
+		"CATALOGNUMBER": {"BM-001"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Unexpected Vorbis fields: %v", fields)
+	}
+	if !reflect.DeepEqual(unmapped, []string{ID3v2PictureFrame}) {
+		t.Errorf("Unexpected unmapped frames: %v", unmapped)
+	}
+
+	delete(frames, ID3v2PictureFrame)
+	if back := ConvertVorbisToID3v2(fields); !reflect.DeepEqual(back, frames) {
+		t.Errorf("Round trip mismatch: got %v expected %v", back, frames)
+	}
+
+	// TYER is only a fallback for TDRC
+	fields, _ = ConvertID3v2ToVorbis(map[string][]string{"TDRC": {"2004-03-01"}, "TYER": {"2004"}})
+	if !reflect.DeepEqual(fields["DATE"], []string{"2004-03-01"}) {
+		t.Errorf("TDRC should take precedence over TYER: %v", fields["DATE"])
+	}
+	fields, _ = ConvertID3v2ToVorbis(map[string][]string{"TYER": {"2004"}, "TORY": {"1999"}})
+	if !reflect.DeepEqual(fields, map[string][]string{"DATE": {"2004"}, "ORIGINALDATE": {"1999"}}) {
+		t.Errorf("TYER and TORY should be used without TDRC and TDOR: %v", fields)
+	}
+}
+
+func TestAPICConversion(t *testing.T) {
+	image := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10}
+	picture := &PictureBlock{PictureType: PictureTypeFrontCover, MIME: "image/jpeg", Description: "Pochette café", Data: image}
+	frame := ConvertPictureToAPIC(picture)
+	if expected := append([]byte("\x03image/jpeg\x00\x03Pochette café\x00"), image...); !bytes.Equal(frame, expected) {
+		t.Errorf("Unexpected APIC frame: %q", frame)
+	}
+	back, err := ConvertAPICToPicture(frame)
+	if err != nil || !back.Equal(picture) {
+		t.Errorf("Round trip mismatch: %+v %v", back, err)
+	}
+
+	// a frame written by an ID3v2.3 tagger, with a UTF-16 description holding a zero byte in its code units
+	frame = append([]byte("\x01image/png\x00\x04\xFF\xFEB\x00\x00\x01\x00\x00"), image...)
+	if back, err := ConvertAPICToPicture(frame); err != nil || back.PictureType != PictureTypeBackCover || back.MIME != "image/png" ||
+		back.Description != "B\u0100" || !bytes.Equal(back.Data, image) {
+		t.Errorf("Failed to convert UTF-16 frame: %+v %v", back, err)
+	}
+	frame = append([]byte("\x00image/gif\x00\x00Caf\xE9\x00"), image...)
+	if back, err := ConvertAPICToPicture(frame); err != nil || back.Description != "Café" || !bytes.Equal(back.Data, image) {
+		t.Errorf("Failed to convert Latin-1 frame: %+v %v", back, err)
+	}
+
+	for _, frame := range [][]byte{nil, []byte("\x04image/png\x00\x03\x00"), []byte("\x03image/png"), []byte("\x03image/png\x00\x03no terminator")} {
+		if _, err := ConvertAPICToPicture(frame); err != ErrorMalformedAPIC {
+			t.Errorf("Expected ErrorMalformedAPIC for %q, got %v", frame, err)
+		}
+	}
+
+	// the converted picture is stored as a Picture block
+	block := back.Marshal()
+	if parsed, err := ParsePicture(&block); err != nil || !parsed.Equal(picture) {
+		t.Errorf("Failed to store the picture: %+v %v", parsed, err)
+	}
+}
+
+func TestSoundCheck(t *testing.T) {
+	sc, err := SoundCheckFromTags(map[string][]string{
+		"replaygain_track_gain": {"-6.48 dB"},
+		"replaygain_track_peak": {"0.988553"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compute SoundCheck: %s", err)
+	}
+	if sc[0] != 4446 || sc[2] != 11116 || sc[6] != 32393 {
+		t.Errorf("Unexpected SoundCheck values: %v", sc)
+	}
+
+	parsed, err := ParseSoundCheck(sc.String())
+	if err != nil {
+		t.Fatalf("Failed to parse SoundCheck: %s", err)
+	}
+	if parsed != sc {
+		t.Errorf("SoundCheck round trip mismatch: got %v expected %v", parsed, sc)
+	}
+	tags := parsed.Tags()
+	if tags[ReplayGainTrackGain][0] != "-6.48 dB" {
+		t.Errorf("Unexpected track gain: %s", tags[ReplayGainTrackGain][0])
+	}
+
+	if _, err := ParseSoundCheck(" 00000318"); err != ErrorInvalidSoundCheck {
+		t.Errorf("Expected ErrorInvalidSoundCheck, got %v", err)
+	}
+}
+
+func testVorbisCommentData(vendor string, comments ...string) []byte {
+	buf := new(bytes.Buffer)
+	writeString := func(s string) {
+		binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+	writeString(vendor)
+	binary.Write(buf, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		writeString(comment)
+	}
+	return buf.Bytes()
+}
+
+func TestDJMetadata(t *testing.T) {
+	appData := []byte("TEST\x01\x02\x03")
+	// the TRAKTOR4 value starts with the header of the DMRT root chunk Traktor writes, cut after its child count
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: VorbisComment, Data: testVorbisCommentData("go-flac", "TITLE=x", "serato_markers_v2=AQFDT0xPUgAAAAAEAP",
+			"TRAKTOR4=RE1SVNAAAAABAAAAAAAAAA==")},
+		{Type: Application, Data: appData},
+	}}
+	RegisterDJApplicationID([4]byte{'T', 'E', 'S', 'T'}, "Test DJ")
+	defer delete(djApplicationIDs, [4]byte{'T', 'E', 'S', 'T'})
+
+	expected := []DJMetadata{
+		{Software: "Serato", Block: 1, Field: "SERATO_MARKERS_V2"},
+		{Software: "Traktor", Block: 1, Field: "TRAKTOR4"},
+		{Software: "Test DJ", Block: 2},
+	}
+	if res := f.DJMetadata(); !reflect.DeepEqual(res, expected) {
+		t.Errorf("Unexpected DJ metadata: %v", res)
+	}
+
+	app, err := ParseApplication(f.Meta[2])
+	if err != nil {
+		t.Fatalf("Failed to parse application block: %s", err)
+	}
+	if marshaled := app.Marshal(); !bytes.Equal(marshaled.Data, appData) {
+		t.Errorf("Application block round trip mismatch: %v", marshaled.Data)
+	}
+}
+
+func TestChapters(t *testing.T) {
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: VorbisComment, Data: testVorbisCommentData("go-flac", "TITLE=Episode 1", "CHAPTER001=00:00:00.000")},
+	}}
+	chapters := []Chapter{
+		{Start: 0, Title: "Intro", ImageURL: "https://example.com/intro.jpg"},
+		{Start: 83*time.Second + 250*time.Millisecond, Title: "Interview", URL: "https://example.com", Image: []byte{0xFF, 0xD8}, ImageMIME: "image/jpeg"},
+	}
+	if err := f.SetChapters(chapters); err != nil {
+		t.Fatalf("Failed to set chapters: %s", err)
+	}
+	if len(f.Meta) != 3 || f.Meta[2].Type != Picture {
+		t.Fatalf("Expected chapter artwork to be added as a picture block")
+	}
+	res, err := f.Chapters()
+	if err != nil {
+		t.Fatalf("Failed to read chapters: %s", err)
+	}
+	if !reflect.DeepEqual(res, chapters) {
+		t.Errorf("Chapter round trip mismatch: got %v expected %v", res, chapters)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WritePodcastChapters(buf, res); err != nil {
+		t.Fatalf("Failed to export chapters: %s", err)
+	}
+	var doc podcastChapters
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to decode exported chapters: %s", err)
+	}
+	if len(doc.Chapters) != 2 || doc.Chapters[1].StartTime != 83.25 || doc.Chapters[0].Img != chapters[0].ImageURL {
+		t.Errorf("Unexpected exported chapters: %s", buf.String())
+	}
+
+	// timestamps round-trip to the millisecond
+	f.Meta[1].Data = testVorbisCommentData("go-flac", "CHAPTER001=00:00:01.001", "CHAPTER002=01:02:03.5")
+	if res, err = f.Chapters(); err != nil || len(res) != 2 || res[0].Start != 1001*time.Millisecond || res[1].Start != time.Hour+2*time.Minute+3500*time.Millisecond {
+		t.Fatalf("Unexpected chapter starts %v: %v", res, err)
+	}
+	if err := f.SetChapters(res); err != nil {
+		t.Fatalf("Failed to set chapters: %s", err)
+	}
+	if vc, err := ParseVorbisComment(f.Meta[1]); err != nil || !reflect.DeepEqual(vc.Get("CHAPTER001"), []string{"00:00:01.001"}) {
+		t.Errorf("Unexpected written chapter start %v: %v", vc, err)
+	}
+	f.Meta[1].Data = testVorbisCommentData("go-flac", "CHAPTER001=00:00:01.-5")
+	if _, err := f.Chapters(); !errors.Is(err, ErrorInvalidChapter) {
+		t.Errorf("Expected ErrorInvalidChapter, got %v", err)
+	}
+
+	// the keys have 3 digits: chapter 1000 would read back as chapter 100
+	if err := f.SetChapters(make([]Chapter, 1000)); err != ErrorTooManyChapters {
+		t.Errorf("Expected ErrorTooManyChapters, got %v", err)
+	}
+	if err := f.SetChapters(make([]Chapter, 999)); err != nil {
+		t.Fatalf("Failed to set 999 chapters: %s", err)
+	}
+	if res, err := f.Chapters(); err != nil || len(res) != 999 {
+		t.Errorf("Expected 999 chapters, got %d: %v", len(res), err)
+	}
+
+	if err := f.SetChapters(nil); err != nil {
+		t.Fatalf("Failed to clear chapters: %s", err)
+	}
+	if len(f.Meta) != 2 {
+		t.Errorf("Chapter artwork should be removed with the chapters")
+	}
+}
+
+func TestTranscripts(t *testing.T) {
+	defer func(size int) { transcriptChunkSize = size }(transcriptChunkSize)
+	transcriptChunkSize = 64
+
+	vtt := Transcript{
+		MIME:     TranscriptWebVTT,
+		Language: "en",
+		Data:     []byte("WEBVTT\n\n00:00.000 --> 00:04.000\nHello and welcome to the show.\n\n00:04.000 --> 00:09.000\nToday we talk about lossless audio.\n"),
+	}
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: make([]byte, 34)}}}
+	if err := f.SetTranscript(vtt); err != nil {
+		t.Fatalf("Failed to embed transcript: %s", err)
+	}
+	if len(f.Meta) < 4 {
+		t.Fatalf("Transcript should have been split across blocks, got %d blocks", len(f.Meta))
+	}
+	// chunks must be reassembled regardless of block order
+	f.Meta[1], f.Meta[2] = f.Meta[2], f.Meta[1]
+
+	res, err := f.Transcripts()
+	if err != nil {
+		t.Fatalf("Failed to read transcripts: %s", err)
+	}
+	if !reflect.DeepEqual(res, []Transcript{vtt}) {
+		t.Errorf("Transcript round trip mismatch: %v", res)
+	}
+
+	f.Meta = f.Meta[:len(f.Meta)-1]
+	if _, err := f.Transcripts(); err != ErrorIncompleteTranscript {
+		t.Errorf("Expected ErrorIncompleteTranscript, got %v", err)
+	}
+	f.RemoveTranscript(TranscriptWebVTT, "en")
+	if len(f.Meta) != 1 {
+		t.Errorf("Transcript blocks should have been removed")
+	}
+}
+
+func testStreamInfoData(sampleRate, channels, bitDepth int, sampleCount int64, md5 []byte) []byte {
+	data := make([]byte, 34)
+	binary.BigEndian.PutUint16(data[0:], 4096)
+	binary.BigEndian.PutUint16(data[2:], 4096)
+	packed := uint64(sampleRate)<<44 | uint64(channels-1)<<41 | uint64(bitDepth-1)<<36 | uint64(sampleCount)
+	binary.BigEndian.PutUint64(data[10:], packed)
+	copy(data[18:], md5)
+	return data
+}
+
+func TestProvenance(t *testing.T) {
+	md5 := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, md5)}}}
+	if _, err := f.Provenance(); err != ErrorNoProvenance {
+		t.Errorf("Expected ErrorNoProvenance, got %v", err)
+	}
+	manifest := []byte(`{"@context":"https://schema.org","@type":"AudioObject"}`)
+	if err := f.SetProvenance(ProvenanceJSONLD, manifest); err != nil {
+		t.Fatalf("Failed to embed provenance: %s", err)
+	}
+	if err := f.SetProvenance(ProvenanceJSONLD, manifest); err != nil {
+		t.Fatalf("Failed to replace provenance: %s", err)
+	}
+	if len(f.Meta) != 2 {
+		t.Errorf("Provenance should replace the existing manifest")
+	}
+	res, err := f.Provenance()
+	if err != nil {
+		t.Fatalf("Failed to read provenance: %s", err)
+	}
+	if res.MIME != ProvenanceJSONLD || !bytes.Equal(res.Manifest, manifest) {
+		t.Errorf("Provenance round trip mismatch: %v", res)
+	}
+
+	f.Meta[0].Data = testStreamInfoData(44100, 2, 16, 1000, make([]byte, 16))
+	if _, err := f.Provenance(); err != ErrorProvenanceMismatch {
+		t.Errorf("Expected ErrorProvenanceMismatch, got %v", err)
+	}
+	if err := f.SetProvenance(ProvenanceJSONLD, manifest); err != ErrorUnknownAudioMD5 {
+		t.Errorf("Expected ErrorUnknownAudioMD5, got %v", err)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+
+	md5 := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	for _, key := range []crypto.Signer{priv, ecKey} {
+		f := &File{Meta: []*MetaDataBlock{
+			{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, md5)},
+			{Type: VorbisComment, Data: testVorbisCommentData("go-flac", "TITLE=x", "artist=y")},
+			{Type: Padding, Data: make([]byte, 10)},
+		}}
+		if err := f.Verify(key.Public()); err != ErrorNoSignature {
+			t.Errorf("Expected ErrorNoSignature, got %v", err)
+		}
+		if err := f.Sign(key); err != nil {
+			t.Fatalf("Failed to sign: %s", err)
+		}
+		if err := f.Verify(key.Public()); err != nil {
+			t.Errorf("Failed to verify: %s", err)
+		}
+
+		// reordering comments and resizing padding keep the signature valid
+		f.Meta[1].Data = testVorbisCommentData("other", "ARTIST=y", "TITLE=x")
+		f.Meta[2].Data = nil
+		if err := f.Verify(key.Public()); err != nil {
+			t.Errorf("Failed to verify after canonical changes: %s", err)
+		}
+
+		f.Meta[1].Data = testVorbisCommentData("other", "ARTIST=z", "TITLE=x")
+		if err := f.Verify(key.Public()); err != ErrorSignatureInvalid {
+			t.Errorf("Expected ErrorSignatureInvalid, got %v", err)
+		}
+	}
+
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, md5)}}}
+	if err := f.Sign(priv); err != nil {
+		t.Fatalf("Failed to sign: %s", err)
+	}
+	if err := f.Verify(&ecKey.PublicKey); err != ErrorSignatureInvalid {
+		t.Errorf("Expected ErrorSignatureInvalid with the wrong key, got %v", err)
+	}
+
+	// without an audio MD5 the signature would not cover the audio
+	f.Meta[0].Data = testStreamInfoData(44100, 2, 16, 1000, nil)
+	if err := f.Verify(priv.Public()); err != ErrorUnknownAudioMD5 {
+		t.Errorf("Expected ErrorUnknownAudioMD5 when verifying, got %v", err)
+	}
+	if err := f.Sign(priv); err != ErrorUnknownAudioMD5 {
+		t.Errorf("Expected ErrorUnknownAudioMD5 when signing, got %v", err)
+	}
+
+	// the values of a field are ordered, as for VorbisCommentBlock.Equal
+	f = &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, md5)},
+		{Type: VorbisComment, Data: testVorbisCommentData("go-flac", "ARTIST=a", "ARTIST=b")},
+	}}
+	if err := f.Sign(priv); err != nil {
+		t.Fatalf("Failed to sign: %s", err)
+	}
+	f.Meta[1].Data = testVorbisCommentData("go-flac", "ARTIST=b", "ARTIST=a")
+	if err := f.Verify(priv.Public()); err != ErrorSignatureInvalid {
+		t.Errorf("Expected ErrorSignatureInvalid after reordering values, got %v", err)
+	}
+
+	// blocks that are not loaded are covered
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, md5)},
+		{Type: VorbisComment, Data: testVorbisCommentData("go-flac", "TITLE=x")},
+		{Type: Picture, Data: bytes.Repeat([]byte{7}, 100)},
+	}, testFrame(0, 4096, 1, 2))
+	if f, err = ParseReaderAt(bytes.NewReader(stream), int64(len(stream))); err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if err := f.Sign(priv); err != nil {
+		t.Fatalf("Failed to sign lazily parsed file: %s", err)
+	}
+	if f.Meta[2].Loaded() {
+		t.Error("Signing should not load pictures")
+	}
+	var signed bytes.Buffer
+	if _, err := f.WriteTo(&signed); err != nil {
+		t.Fatalf("Failed to write: %s", err)
+	}
+	if f, err = ParseBytes(bytes.NewReader(signed.Bytes())); err != nil || f.Verify(priv.Public()) != nil {
+		t.Errorf("Failed to verify the signature of a lazily parsed file: %v", err)
+	}
+	tampered := bytes.Replace(signed.Bytes(), bytes.Repeat([]byte{7}, 100), bytes.Repeat([]byte{8}, 100), 1)
+	if f, err = ParseReaderAt(bytes.NewReader(tampered), int64(len(tampered))); err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if err := f.Verify(priv.Public()); err != ErrorSignatureInvalid {
+		t.Errorf("Expected ErrorSignatureInvalid for a tampered lazily parsed file, got %v", err)
+	}
+}
+
+func testFLACStream(meta []*MetaDataBlock, frames []byte) []byte {
+	f := &File{Meta: meta, Frames: bytes.NewReader(frames)}
+	buf := new(bytes.Buffer)
+	if _, err := f.WriteTo(buf); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSaveAudit(t *testing.T) {
+	frames := append([]byte{0xFF, 0xF8}, bytes.Repeat([]byte{0x42}, 1000)...)
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: Padding, Data: make([]byte, 100)},
+	}, frames)
+
+	f, err := ParseBytes(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Failed to parse flac stream: %s", err)
+	}
+	f.Meta = f.Meta[:1]
+
+	var audit SaveAudit
+	out := filepath.Join(t.TempDir(), "out.flac")
+	if err := f.Save(out, WithAudit(&audit)); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	digest := sha256.Sum256(frames)
+	expected := SaveAudit{
+		Header:            ByteRange{0, 42},
+		Audio:             ByteRange{42, 42 + int64(len(frames))},
+		SourceAudioOffset: 146,
+		AudioShifted:      true,
+		AudioSHA256:       digest[:],
+	}
+	if !reflect.DeepEqual(audit, expected) {
+		t.Errorf("Unexpected audit: got %+v expected %+v", audit, expected)
+	}
+
+	// auditing a save over the source keeps the metadata-only write
+	fn := filepath.Join(t.TempDir(), "source.flac")
+	if err := os.WriteFile(fn, stream, 0644); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	if f, err = ParseFile(fn); err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	comment := &MetaDataBlock{Type: VorbisComment, Data: testVorbisCommentData("go-flac", "TITLE=Audited")}
+	f.Meta = []*MetaDataBlock{f.Meta[0], comment, f.Meta[1]}
+	var report SaveReport
+	if err := f.Save(fn, WithInPlace(), WithAudit(&audit), WithReport(&report)); err != nil {
+		t.Fatalf("Failed to save in place: %s", err)
+	}
+	expected = SaveAudit{
+		Header:            ByteRange{0, 146},
+		Audio:             ByteRange{146, 146},
+		SourceAudioOffset: 146,
+	}
+	if !reflect.DeepEqual(audit, expected) || report.Strategy != SavePaddingPatch {
+		t.Errorf("Unexpected audit of an in-place save: got %+v expected %+v, strategy %v", audit, expected, report.Strategy)
+	}
+	if saved, _ := os.ReadFile(fn); len(saved) != len(stream) || !bytes.Equal(saved[146:], frames) {
+		t.Errorf("The audio should be left untouched")
+	}
+}
+
+func TestNewPadding(t *testing.T) {
+	padding := NewPadding(5 << 20)
+	if padding.Data != nil || padding.Len() != 5<<20 {
+		t.Fatalf("Padding should be lazy with length %d, got %d", 5<<20, padding.Len())
+	}
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		padding,
+	}}
+	buf := new(bytes.Buffer)
+	n, err := f.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("Failed to write: %s", err)
+	}
+	if n != int64(buf.Len()) || n != 4+38+4+5<<20 {
+		t.Errorf("Unexpected output size %d", n)
+	}
+	if !bytes.Equal(buf.Bytes()[42:], padding.Marshal(true)) {
+		t.Errorf("Streamed padding does not match Marshal")
+	}
+
+	parsed, err := ParseMetadata(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if parsed.Meta[1].Type != Padding || parsed.Meta[1].Len() != 5<<20 {
+		t.Errorf("Padding block not parsed back")
+	}
+
+	f.Meta[1] = NewPadding(MaxBlockSize + 1)
+	if _, err := f.WriteTo(io.Discard); err != ErrorBlockTooLarge {
+		t.Errorf("Expected ErrorBlockTooLarge, got %v", err)
+	}
+}
+
+func TestParseReaderAt(t *testing.T) {
+	picture := bytes.Repeat([]byte{0xAB}, 1<<20)
+	frames := append([]byte{0xFF, 0xF8}, bytes.Repeat([]byte{0x42}, 1000)...)
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: Picture, Data: picture},
+		{Type: Padding, Data: make([]byte, 100)},
+	}, frames)
+
+	f, err := ParseReaderAt(bytes.NewReader(stream), int64(len(stream)))
+	if err != nil {
+		t.Fatalf("Failed to parse flac stream: %s", err)
+	}
+	if !f.Meta[0].Loaded() || f.Meta[1].Loaded() || f.Meta[1].Data != nil {
+		t.Errorf("Only StreamInfo should be loaded")
+	}
+	if f.Meta[1].Len() != len(picture) {
+		t.Errorf("Unexpected picture length %d", f.Meta[1].Len())
+	}
+	if _, err := f.GetStreamInfo(); err != nil {
+		t.Errorf("Failed to get stream info: %s", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := f.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write: %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), stream) {
+		t.Errorf("Lazy round trip does not match original")
+	}
+	if f.Meta[1].Loaded() {
+		t.Errorf("WriteTo should not load lazy blocks")
+	}
+	if err := f.Meta[1].Load(); err != nil || !bytes.Equal(f.Meta[1].Data, picture) {
+		t.Errorf("Failed to load picture: %v", err)
+	}
+
+	if _, err := ParseReaderAt(bytes.NewReader(stream[:50]), 50); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a truncated stream, got %v", err)
+	}
+}
+
+func TestBlockSectionReader(t *testing.T) {
+	picture := bytes.Repeat([]byte{0xAB}, 4096)
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: Picture, Data: picture},
+	}, []byte{0xFF, 0xF8, 0x00})
+	fn := filepath.Join(t.TempDir(), "in.flac")
+	if err := os.WriteFile(fn, stream, 0644); err != nil {
+		t.Fatalf("Failed to write flac file: %s", err)
+	}
+
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse flac file: %s", err)
+	}
+	defer f.Close()
+	sr, err := f.BlockSectionReader(1)
+	if err != nil {
+		t.Fatalf("Failed to get section reader: %s", err)
+	}
+	data, err := io.ReadAll(sr)
+	if err != nil || !bytes.Equal(data, picture) {
+		t.Errorf("Section reader does not match block data: %v", err)
+	}
+	if _, err := f.BlockSectionReader(2); err != ErrorBlockIndex {
+		t.Errorf("Expected ErrorBlockIndex, got %v", err)
+	}
+
+	f, err = ParseBytes(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Failed to parse flac stream: %s", err)
+	}
+	if _, err := f.BlockSectionReader(1); err != ErrorNoBlockSource {
+		t.Errorf("Expected ErrorNoBlockSource, got %v", err)
+	}
+}
+
+func TestCopyPictures(t *testing.T) {
+	front := marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/jpeg"}, []byte{1})
+	back := marshalPicture(pictureHeader{pictureType: PictureTypeBackCover, mime: "image/jpeg"}, []byte{2})
+	oldFront := marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/png"}, []byte{3})
+	artist := marshalPicture(pictureHeader{pictureType: PictureTypeArtist, mime: "image/png"}, []byte{4})
+
+	src := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: Picture, Data: front},
+		{Type: Picture, Data: back},
+	}}
+	dst := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: Picture, Data: oldFront},
+		{Type: Picture, Data: artist},
+	}}
+	if err := CopyPictures(dst, src, PictureTypeFrontCover); err != nil {
+		t.Fatalf("Failed to copy pictures: %s", err)
+	}
+	if len(dst.Meta) != 3 || !bytes.Equal(dst.Meta[1].Data, artist) || !bytes.Equal(dst.Meta[2].Data, front) {
+		t.Errorf("Front cover should replace the old one and keep other pictures")
+	}
+
+	if err := CopyPictures(dst, src); err != nil {
+		t.Fatalf("Failed to copy pictures: %s", err)
+	}
+	if len(dst.Meta) != 3 || !bytes.Equal(dst.Meta[1].Data, front) || !bytes.Equal(dst.Meta[2].Data, back) {
+		t.Errorf("All pictures should be replaced when no types are given")
+	}
+}
+
+// testFrame encodes a fixed block size, 44.1 kHz 16-bit frame whose channels are constant subframes holding values
+func testFrame(number uint64, blockSize int, values ...int16) []byte {
+	frame := []byte{0xFF, 0xF8, 0x79, byte(len(values)-1)<<4 | 0x08}
+	if number < 0x80 {
+		frame = append(frame, byte(number))
+	} else {
+		frame = append(frame, 0xC0|byte(number>>6), 0x80|byte(number&0x3F))
+	}
+	frame = append(frame, byte((blockSize-1)>>8), byte(blockSize-1))
+	frame = append(frame, crc8(frame))
+	for _, v := range values {
+		frame = append(frame, 0x00, byte(uint16(v)>>8), byte(v))
+	}
+	crc := crc16(frame)
+	return append(frame, byte(crc>>8), byte(crc))
+}
+
+func TestParseFrameHeader(t *testing.T) {
+	header, err := ParseFrameHeader(testFrame(200, 4096, 1, -1))
+	if err != nil {
+		t.Fatalf("Failed to parse frame header: %s", err)
+	}
+	expected := &FrameHeader{BlockSize: 4096, SampleRate: 44100, Channels: 2, BitDepth: 16, Number: 200, Size: 9}
+	if !reflect.DeepEqual(header, expected) {
+		t.Errorf("Unexpected frame header: got %+v expected %+v", header, expected)
+	}
+	frame := testFrame(1, 4096, 0)
+	frame[5]++
+	if _, err := ParseFrameHeader(frame); err != ErrorFrameCRC {
+		t.Errorf("Expected ErrorFrameCRC, got %v", err)
+	}
+}
+
+func TestAppendingWriter(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "live.flac"))
+	if err != nil {
+		t.Fatalf("Failed to create output: %s", err)
+	}
+	defer out.Close()
+
+	info := StreamInfoBlock{BlockSizeMin: 4096, BlockSizeMax: 4096, SampleRate: 44100, ChannelCount: 2, BitDepth: 16}
+	w, err := NewAppendingWriter(out, info, NewPadding(64))
+	if err != nil {
+		t.Fatalf("Failed to create writer: %s", err)
+	}
+	for i, size := range []int{4096, 4096, 1000} {
+		if err := w.WriteFrame(testFrame(uint64(i), size, 0, 0)); err != nil {
+			t.Fatalf("Failed to write frame: %s", err)
+		}
+	}
+	md5 := bytes.Repeat([]byte{7}, 16)
+	w.SetAudioMD5(md5)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %s", err)
+	}
+	if err := w.WriteFrame(testFrame(3, 4096, 0, 0)); err != ErrorWriterClosed {
+		t.Errorf("Expected ErrorWriterClosed, got %v", err)
+	}
+
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Failed to rewind: %s", err)
+	}
+	f, err := ParseBytes(out)
+	if err != nil {
+		t.Fatalf("Failed to parse written stream: %s", err)
+	}
+	res, err := f.GetStreamInfo()
+	if err != nil {
+		t.Fatalf("Failed to get stream info: %s", err)
+	}
+	frameSize := len(testFrame(0, 4096, 0, 0))
+	expected := &StreamInfoBlock{4096, 4096, frameSize, frameSize, 44100, 2, 16, 9192, md5}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("Unexpected stream info: got %+v expected %+v", res, expected)
+	}
+}
+
+func TestFinalizeStreamInfo(t *testing.T) {
+	var frames []byte
+	for i, size := range []int{4096, 4096, 100} {
+		frames = append(frames, testFrame(uint64(i), size, int16(i), 0)...)
+	}
+	// frames are delimited by CRC, so a payload byte that looks like a sync code must not split a frame
+	frames = append(frames, testFrame(3, 4096, -2, 0xFF)...)
+	complete := len(frames)
+	frames = append(frames, testFrame(4, 4096, 0, 0)[:7]...)
+
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 0, nil)},
+		{Type: VorbisComment, Data: testVorbisCommentData("go-flac")},
+	}, frames)
+	fn := filepath.Join(t.TempDir(), "interrupted.flac")
+	if err := os.WriteFile(fn, stream, 0644); err != nil {
+		t.Fatalf("Failed to write flac file: %s", err)
+	}
+
+	var offsets []int64
+	err := ScanFrames(bytes.NewReader(frames), func(frame *Frame) error {
+		offsets = append(offsets, frame.Offset)
+		return nil
+	})
+	if err != io.ErrUnexpectedEOF || len(offsets) != 4 {
+		t.Errorf("Expected 4 frames and io.ErrUnexpectedEOF, got %d frames and %v", len(offsets), err)
+	}
+	if err := ScanFrames(bytes.NewReader(frames[:complete]), func(*Frame) error { return nil }); err != nil {
+		t.Errorf("Failed to scan complete frames: %s", err)
+	}
+
+	res, err := FinalizeStreamInfo(fn)
+	if err != nil {
+		t.Fatalf("Failed to finalize stream info: %s", err)
+	}
+	frameSize := len(testFrame(0, 4096, 0, 0))
+	expected := &StreamInfoBlock{100, 4096, frameSize, frameSize, 44100, 2, 16, 3*4096 + 100, make([]byte, 16)}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("Unexpected stream info: got %+v expected %+v", res, expected)
+	}
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse finalized file: %s", err)
+	}
+	defer f.Close()
+	if info, err := f.GetStreamInfo(); err != nil || !reflect.DeepEqual(info, expected) {
+		t.Errorf("Stream info not patched in place: %+v %v", info, err)
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	var frames []byte
+	for i := 0; i < 3; i++ {
+		frames = append(frames, testFrame(uint64(i), 4096, 0, 0)...)
+	}
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 3*4096, nil)},
+	}, frames)
+	f, err := ParseBytes(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Failed to parse flac stream: %s", err)
+	}
+	r, err := f.FrameReader()
+	if err != nil {
+		t.Fatalf("Failed to create frame reader: %s", err)
+	}
+	for i := 0; ; i++ {
+		frame, err := r.ReadFrame()
+		if err == io.EOF {
+			if i != 3 {
+				t.Errorf("Expected 3 frames, got %d", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read frame: %s", err)
+		}
+		if frame.Sample != int64(i)*4096 || frame.Timestamp != samplesToDuration(int64(i)*4096, 44100) || frame.Duration != 92879818*time.Nanosecond {
+			t.Errorf("Unexpected timing of frame %d: %s %s", i, frame.Timestamp, frame.Duration)
+		}
+	}
+}
+
+func TestDeadlineTimeouts(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	f := &File{
+		Meta:   []*MetaDataBlock{{Type: StreamInfo, Data: make([]byte, 34)}},
+		Frames: NewDeadlineReader(server, 10*time.Millisecond),
+	}
+	go io.Copy(io.Discard, client)
+	_, err := f.WriteTo(NewDeadlineWriter(server, time.Second))
+	var timeoutErr *TimeoutError
+	if !errors.Is(err, ErrorTimeout) || !errors.As(err, &timeoutErr) || timeoutErr.Op != "read" {
+		t.Errorf("Expected a read TimeoutError, got %v", err)
+	}
+
+	stalled, other := net.Pipe()
+	defer stalled.Close()
+	defer other.Close()
+	f = &File{
+		Meta:   []*MetaDataBlock{{Type: StreamInfo, Data: make([]byte, 34)}},
+		Frames: bytes.NewReader([]byte{0xFF, 0xF8}),
+	}
+	_, err = f.WriteTo(NewDeadlineWriter(stalled, 10*time.Millisecond))
+	if !errors.As(err, &timeoutErr) || timeoutErr.Op != "write" {
+		t.Errorf("Expected a write TimeoutError, got %v", err)
+	}
+}
+
+func TestWriteToN(t *testing.T) {
+	frame := testFrame(0, 4096, 0, 0)
+	frames := bytes.Repeat(frame, 10)
+	meta := []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 10*4096, nil)}}
+	header := int64(4 + 4 + 34)
+
+	f := &File{Meta: meta, Frames: bytes.NewReader(frames)}
+	buf := new(bytes.Buffer)
+	n, err := f.WriteToN(buf, header+int64(len(frame))*3+5)
+	if err != nil {
+		t.Fatalf("Failed to write: %s", err)
+	}
+	if n != header+int64(len(frame))*3 || int64(buf.Len()) != n {
+		t.Errorf("Expected 3 whole frames, wrote %d bytes", n)
+	}
+
+	garbage := append(append([]byte{}, frame...), bytes.Repeat([]byte{0xFF, 0xF8, 0x00}, 20)...)
+	f = &File{Meta: meta, Frames: bytes.NewReader(garbage)}
+	if n, err := f.WriteToN(io.Discard, header+30); err != nil || n != header+30 {
+		t.Errorf("Expected a byte exact cut for undelimited frames, got %d %v", n, err)
+	}
+
+	f = &File{Meta: meta, Frames: bytes.NewReader(frames)}
+	if _, err := f.WriteToN(io.Discard, 10); err != ErrorQuotaTooSmall {
+		t.Errorf("Expected ErrorQuotaTooSmall, got %v", err)
+	}
+}
+
+func TestByteRangeForSamples(t *testing.T) {
+	frame := testFrame(0, 4096, 0, 0)
+	var frames []byte
+	for i := 0; i < 10; i++ {
+		frames = append(frames, testFrame(uint64(i), 4096, 0, 0)...)
+	}
+	size := int64(len(frame))
+	seekTable := make([]byte, 0, 3*seekPointSize)
+	for _, p := range []seekPoint{{0, 0, 4096}, {5 * 4096, uint64(5 * size), 4096}, {SeekPointPlaceholder, 0, 0}} {
+		seekTable = binary.BigEndian.AppendUint64(seekTable, p.sample)
+		seekTable = binary.BigEndian.AppendUint64(seekTable, p.offset)
+		seekTable = binary.BigEndian.AppendUint16(seekTable, p.samples)
+	}
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 10*4096, nil)},
+		{Type: SeekTable, Data: seekTable},
+	}, frames)
+	audio := int64(len(stream) - len(frames))
+
+	f, err := ParseReaderAt(bytes.NewReader(stream), int64(len(stream)))
+	if err != nil {
+		t.Fatalf("Failed to parse flac stream: %s", err)
+	}
+	for _, c := range []struct {
+		start, end int64
+		expected   ByteRange
+	}{
+		{0, 1, ByteRange{audio, audio + size}},
+		{4096*6 + 10, 4096 * 8, ByteRange{audio + 6*size, audio + 8*size}},
+		{4096*9 + 1, 1 << 40, ByteRange{audio + 9*size, int64(len(stream))}},
+	} {
+		res, err := f.ByteRangeForSamples(c.start, c.end)
+		if err != nil || res != c.expected {
+			t.Errorf("Unexpected range for [%d, %d): got %v %v expected %v", c.start, c.end, res, err, c.expected)
+		}
+	}
+	if _, err := f.ByteRangeForSamples(1<<40, 1<<41); err != ErrorInvalidSampleRange {
+		t.Errorf("Expected ErrorInvalidSampleRange, got %v", err)
+	}
+
+	f, err = ParseBytes(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Failed to parse flac stream: %s", err)
+	}
+	res, err := f.ByteRangeForSamples(4096*6, 4096*7)
+	if expected := (ByteRange{audio + 5*size, -1}); err != nil || res != expected {
+		t.Errorf("Unexpected seek table range: got %v %v expected %v", res, err, expected)
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	// left/side stereo, 4 samples, 44.1 kHz, 16 bits
+	frame := []byte{0xFF, 0xF8, 0x79, 0x88, 0x00, 0x00, 0x03}
+	frame = append(frame, crc8(frame))
+	w := &bitWriter{data: frame}
+	// left: fixed order 1 with warm-up 100 and Rice coded residuals 1, -2, 3 (parameter 2)
+	w.writeBits(0x12, 8)
+	w.writeBits(100, 16)
+	w.writeBits(0, 2)
+	w.writeBits(0, 4)
+	w.writeBits(2, 4)
+	w.writeBits(0x6, 3)
+	w.writeBits(0x7, 3)
+	w.writeBits(0x6, 4)
+	// side: verbatim with one extra bit
+	w.writeBits(0x02, 8)
+	for _, v := range []int64{10, -10, 0, 5} {
+		w.writeSigned(v, 17)
+	}
+	frame = w.data
+	crc := crc16(frame)
+	frame = append(frame, byte(crc>>8), byte(crc))
+
+	res, err := DecodeFrame(frame, nil)
+	if err != nil {
+		t.Fatalf("Failed to decode frame: %s", err)
+	}
+	expected := [][]int32{{100, 101, 99, 102}, {90, 111, 99, 97}}
+	if !reflect.DeepEqual(res.Samples, expected) {
+		t.Errorf("Unexpected samples: got %v expected %v", res.Samples, expected)
+	}
+	frame[len(frame)-3] ^= 1
+	if _, err := DecodeFrame(frame, nil); err != ErrorFrameCRC {
+		t.Errorf("Expected ErrorFrameCRC, got %v", err)
+	}
+}
+
+func TestComputePeaks(t *testing.T) {
+	frames := append(testFrame(0, 4096, 1000, -2000), testFrame(1, 4096, -3000, 500)...)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 8192, nil)}}, frames)
+	f, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	peaks, err := f.ComputePeaks(6144)
+	if err != nil {
+		t.Fatalf("Failed to compute peaks: %s", err)
+	}
+	if peaks.Len() != 2 {
+		t.Fatalf("Expected 2 peaks, got %d", peaks.Len())
+	}
+	if !reflect.DeepEqual(peaks.Min, [][]int32{{-3000, -3000}, {-2000, 500}}) || !reflect.DeepEqual(peaks.Max, [][]int32{{1000, -3000}, {500, 500}}) {
+		t.Errorf("Unexpected peaks: min %v max %v", peaks.Min, peaks.Max)
+	}
+	if rms := peaks.RMS[0][1] * 32768; rms < 2999.9 || rms > 3000.1 {
+		t.Errorf("Unexpected RMS: %f", rms)
+	}
+
+	var buf bytes.Buffer
+	if err := peaks.WriteBBC(&buf, 8); err != nil {
+		t.Fatalf("Failed to write peaks: %s", err)
+	}
+	out := buf.Bytes()
+	if len(out) != 24+8 || binary.LittleEndian.Uint32(out[12:]) != 6144 || int8(out[24]) != -12 || int8(out[25]) != 3 {
+		t.Errorf("Unexpected BBC peaks data: %v", out)
+	}
+	if _, err := f.ComputePeaks(0); err != ErrorInvalidResolution {
+		t.Errorf("Expected ErrorInvalidResolution, got %v", err)
+	}
+}
+
+func TestPCMAnalyzer(t *testing.T) {
+	// a quarter sample rate sine sampled 45 degrees off its peaks, with a DC offset and two clipped samples
+	samples := make([]int32, 4096)
+	for i := range samples {
+		samples[i] = int32(math.Round(16000*math.Sin(math.Pi/2*float64(i)+math.Pi/4))) + 1000
+	}
+	samples[100], samples[200] = 32767, -32768
+	a := NewPCMAnalyzer(1, 16)
+	if err := a.Add(&AudioFrame{Header: FrameHeader{BlockSize: len(samples)}, Samples: [][]int32{samples}}); err != nil {
+		t.Fatalf("Failed to analyze frame: %s", err)
+	}
+	stats := a.Stats().Channels[0]
+	if stats.Clipped != 2 || stats.Peak != 1 {
+		t.Errorf("Unexpected clipping statistics: %+v", stats)
+	}
+	if math.Abs(stats.DCOffset-1000.0/32768) > 0.001 {
+		t.Errorf("Unexpected DC offset: %f", stats.DCOffset)
+	}
+
+	samples[100], samples[200] = samples[104], samples[204]
+	a = NewPCMAnalyzer(1, 16)
+	a.Add(&AudioFrame{Header: FrameHeader{BlockSize: len(samples)}, Samples: [][]int32{samples}})
+	stats = a.Stats().Channels[0]
+	if math.Abs(stats.Peak-(11314+1000)/32768.0) > 0.001 || math.Abs(stats.TruePeak-17000/32768.0) > 0.01 {
+		t.Errorf("Unexpected peaks: sample %f true %f", stats.Peak, stats.TruePeak)
+	}
+}
+
+func TestExportWAV(t *testing.T) {
+	// 5.1 with front left, center and left surround signals
+	frames := testFrame(0, 4096, 1000, 0, 2000, 5000, 3000, 0)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 6, 16, 4096, nil)}}, frames)
+	f, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	m, err := StereoDownmix(6)
+	if err != nil {
+		t.Fatalf("Failed to build downmix: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := f.ExportWAV(&buf, m); err != nil {
+		t.Fatalf("Failed to export WAV: %s", err)
+	}
+	out := buf.Bytes()
+	if len(out) != wavHeaderSize+4096*4 || string(out[:4]) != "RIFF" || binary.LittleEndian.Uint32(out[4:]) != uint32(len(out)-8) {
+		t.Fatalf("Unexpected RIFF header: %v", out[:wavHeaderSize])
+	}
+	if channels := binary.LittleEndian.Uint16(out[22:]); channels != 2 {
+		t.Errorf("Expected 2 channels, got %d", channels)
+	}
+	// left = (1000 + 0.707*2000 + 0.707*3000) / (1 + 2*0.707), right = 0.707*2000 / (1 + 2*0.707)
+	left, right := int16(binary.LittleEndian.Uint16(out[wavHeaderSize:])), int16(binary.LittleEndian.Uint16(out[wavHeaderSize+2:]))
+	if left != 1879 || right != 586 {
+		t.Errorf("Unexpected downmix: got %d, %d", left, right)
+	}
+
+	mono, _ := MonoFoldDown(2)
+	frame, err := mono.Apply(&AudioFrame{Header: FrameHeader{BlockSize: 1}, BitDepth: 16, Samples: [][]int32{{32767}, {32767}}})
+	if err != nil || frame.Samples[0][0] != 32767 {
+		t.Errorf("Unexpected mono fold-down: %v %v", frame, err)
+	}
+}
+
+func TestDitherAndResample(t *testing.T) {
+	samples := make([]int32, 4800)
+	for i := range samples {
+		samples[i] = int32(4000000 * math.Sin(2*math.Pi*1000*float64(i)/48000))
+	}
+	frame := &AudioFrame{Header: FrameHeader{BlockSize: len(samples), SampleRate: 48000, Channels: 1, BitDepth: 24}, BitDepth: 24, Samples: [][]int32{samples}}
+
+	d := NewDitherer(16, 1)
+	reduced, err := d.Apply(frame)
+	if err != nil {
+		t.Fatalf("Failed to dither: %s", err)
+	}
+	if reduced.BitDepth != 16 {
+		t.Errorf("Expected 16-bit output, got %d", reduced.BitDepth)
+	}
+	for i, v := range reduced.Samples[0] {
+		if diff := float64(v) - float64(samples[i])/256; math.Abs(diff) > 1.5 {
+			t.Fatalf("Sample %d: dithered %d too far from %d", i, v, samples[i])
+		}
+	}
+
+	r, err := NewResampler(48000, 44100)
+	if err != nil {
+		t.Fatalf("Failed to create resampler: %s", err)
+	}
+	var out []int32
+	for _, part := range []int{1000, 3800} {
+		f := *reduced
+		f.Header.BlockSize = part
+		f.Samples = [][]int32{reduced.Samples[0][:part]}
+		reduced.Samples[0] = reduced.Samples[0][part:]
+		res, err := r.Resample(&f)
+		if err != nil {
+			t.Fatalf("Failed to resample: %s", err)
+		}
+		out = append(out, res.Samples[0]...)
+	}
+	res, err := r.Flush()
+	if err != nil {
+		t.Fatalf("Failed to flush: %s", err)
+	}
+	out = append(out, res.Samples[0]...)
+	if len(out) != 4410 || res.Header.SampleRate != 44100 {
+		t.Fatalf("Expected 4410 samples at 44100 Hz, got %d at %d", len(out), res.Header.SampleRate)
+	}
+	for i := 100; i < 4300; i++ {
+		expected := 4000000.0 / 256 * math.Sin(2*math.Pi*1000*float64(i)/44100)
+		if math.Abs(float64(out[i])-expected) > 20 {
+			t.Fatalf("Sample %d: resampled %d, expected %.0f", i, out[i], expected)
+		}
+	}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	const n = 10000
+	left, right := make([]int32, n), make([]int32, n)
+	seed := uint32(1)
+	for i := range left {
+		seed = seed*1664525 + 1013904223
+		noise := int32(seed>>24) - 128
+		left[i] = int32(12000*math.Sin(2*math.Pi*440*float64(i)/44100)) + noise
+		right[i] = left[i]/2 + noise
+	}
+	left[5000] = 32767
+	right[5001] = -32768
+
+	var sizes [9]int
+	for level := 0; level <= 8; level++ {
+		opts, err := CompressionLevel(level)
+		if err != nil {
+			t.Fatalf("Failed to get level %d: %s", level, err)
+		}
+		var buf bytes.Buffer
+		enc, err := NewEncoder(&buf, StreamInfoBlock{SampleRate: 44100, ChannelCount: 2, BitDepth: 16}, opts)
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %s", err)
+		}
+		for _, part := range [][2]int{{0, 3000}, {3000, n}} {
+			if err := enc.Write([][]int32{left[part[0]:part[1]], right[part[0]:part[1]]}); err != nil {
+				t.Fatalf("Failed to encode: %s", err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("Failed to close encoder: %s", err)
+		}
+		sizes[level] = buf.Len()
+
+		f, err := ParseBytes(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Level %d: failed to parse: %s", level, err)
+		}
+		dec, err := f.Decoder()
+		if err != nil {
+			t.Fatalf("Failed to create decoder: %s", err)
+		}
+		var decoded [2][]int32
+		for {
+			frame, err := dec.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Level %d: failed to decode: %s", level, err)
+			}
+			decoded[0] = append(decoded[0], frame.Samples[0]...)
+			decoded[1] = append(decoded[1], frame.Samples[1]...)
+		}
+		if !reflect.DeepEqual(decoded[0], left) || !reflect.DeepEqual(decoded[1], right) {
+			t.Fatalf("Level %d: decoded samples differ from the input", level)
+		}
+	}
+	if sizes[8] >= sizes[0] || sizes[0] >= n*4 {
+		t.Errorf("Unexpected compressed sizes: %v", sizes)
+	}
+
+	// 24-bit mono with wasted bits and a custom configuration
+	samples := make([]int32, 5000)
+	for i := range samples {
+		samples[i] = int32(3000000*math.Sin(float64(i)/20)) &^ 0xFF
+	}
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, StreamInfoBlock{SampleRate: 96000, ChannelCount: 1, BitDepth: 24},
+		EncoderOptions{BlockSize: 1000, MaxLPCOrder: 32, MaxPartitionOrder: 8, ExhaustiveModelSearch: true, Apodizations: []Apodization{Hann(), Welch(), Rectangle()}})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %s", err)
+	}
+	if err := enc.Write([][]int32{samples}); err != nil {
+		t.Fatalf("Failed to encode: %s", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Failed to close encoder: %s", err)
+	}
+	f, err := ParseBytes(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	// the output is not seekable, so the accumulated StreamInfo is only available from the encoder
+	info := enc.StreamInfo()
+	pcm := md5.New()
+	for _, v := range samples {
+		pcm.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16)})
+	}
+	if info.SampleCount != 5000 || info.BlockSizeMax != 1000 || !bytes.Equal(info.AudioMD5, pcm.Sum(nil)) {
+		t.Errorf("Unexpected stream info: %+v", info)
+	}
+	dec, _ := f.Decoder()
+	var decoded []int32
+	for {
+		frame, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to decode: %s", err)
+		}
+		decoded = append(decoded, frame.Samples[0]...)
+	}
+	if !reflect.DeepEqual(decoded, samples) {
+		t.Errorf("Decoded 24-bit samples differ from the input")
+	}
+
+	// a final block of odd length no allowed partition order divides falls back to order 0
+	samples = left[:1001]
+	buf.Reset()
+	enc, err = NewEncoder(&buf, StreamInfoBlock{SampleRate: 44100, ChannelCount: 1, BitDepth: 16},
+		EncoderOptions{BlockSize: 1024, MinPartitionOrder: 1, MaxPartitionOrder: 4})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %s", err)
+	}
+	if err := enc.Write([][]int32{samples}); err != nil {
+		t.Fatalf("Failed to encode: %s", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Failed to close encoder: %s", err)
+	}
+	if f, err = ParseBytes(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	dec, _ = f.Decoder()
+	decoded = nil
+	for {
+		frame, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to decode the odd final block: %s", err)
+		}
+		decoded = append(decoded, frame.Samples[0]...)
+	}
+	if !reflect.DeepEqual(decoded, samples) {
+		t.Errorf("Decoded samples of the odd final block differ from the input")
+	}
+	// samples beyond the bit depth would be truncated and no longer match the audio MD5
+	buf.Reset()
+	enc, err = NewEncoder(&buf, StreamInfoBlock{SampleRate: 44100, ChannelCount: 2, BitDepth: 16}, EncoderOptions{BlockSize: 1024})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %s", err)
+	}
+	for _, v := range []int32{32768, -32769} {
+		if err := enc.Write([][]int32{{0, v}, {0, 0}}); err != ErrorSampleOutOfRange {
+			t.Errorf("Expected ErrorSampleOutOfRange for %d, got %v", v, err)
+		}
+	}
+	if err := enc.Write([][]int32{{32767}, {-32768}}); err != nil {
+		t.Errorf("Failed to encode samples at the limits of the bit depth: %s", err)
+	}
+	if err := enc.Close(); err != nil || enc.StreamInfo().SampleCount != 1 {
+		t.Errorf("Rejected samples should not be encoded: %v %d", err, enc.StreamInfo().SampleCount)
+	}
+	if _, err := CompressionLevel(9); err != ErrorInvalidEncoderOptions {
+		t.Errorf("Expected ErrorInvalidEncoderOptions, got %v", err)
+	}
+}
+
+func TestEncoderWorkers(t *testing.T) {
+	samples := make([]int32, 50000)
+	for i := range samples {
+		samples[i] = int32(8000*math.Sin(float64(i)/7) + 3000*math.Sin(float64(i)/3.1))
+	}
+	encode := func(workers int) []byte {
+		opts, _ := CompressionLevel(5)
+		opts.Workers = workers
+		var buf bytes.Buffer
+		enc, err := NewEncoder(&buf, StreamInfoBlock{SampleRate: 44100, ChannelCount: 1, BitDepth: 16}, opts)
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %s", err)
+		}
+		for i := 0; i < len(samples); i += 1000 {
+			if err := enc.Write([][]int32{samples[i : i+1000]}); err != nil {
+				t.Fatalf("Failed to encode: %s", err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("Failed to close encoder: %s", err)
+		}
+		return buf.Bytes()
+	}
+	if !bytes.Equal(encode(0), encode(4)) {
+		t.Errorf("Output of the worker pool differs from sequential encoding")
+	}
+}
+
+func TestPredictionKernels(t *testing.T) {
+	seed := uint32(7)
+	random := func(bits uint) int32 {
+		seed = seed*1664525 + 1013904223
+		return int32(seed) >> (32 - bits)
+	}
+	restored := func(residual []int32, restore func([]int32)) []int32 {
+		out := append([]int32(nil), residual...)
+		restore(out)
+		return out
+	}
+	residual := make([]int32, 4096)
+	for order := 1; order <= 32; order++ {
+		coefs := make([]int32, order)
+		for i := range coefs {
+			coefs[i] = random(15)
+		}
+		// lengths around the whole groups of 4 samples the vector kernels restore after the warm-up window
+		window := (order + 3) &^ 3
+		for _, n := range []int{order, order + 1, window + 3, window + 4, window + 5, window + 11, 1000, 4096} {
+			for i := range residual[:n] {
+				residual[i] = random(24)
+			}
+			shift := uint(random(32)) % 16
+			fast := restored(residual[:n], func(out []int32) { restoreLPC(out, coefs, shift, 16, 10) })
+			generic := restored(residual[:n], func(out []int32) { restoreLPC32(out, coefs, shift) })
+			if !reflect.DeepEqual(fast, generic) {
+				t.Fatalf("LPC order %d, %d samples: kernel output differs from the generic 32-bit prediction", order, n)
+			}
+			fast = restored(residual[:n], func(out []int32) { restoreLPC(out, coefs, shift, 24, 15) })
+			generic = restored(residual[:n], func(out []int32) { restoreLPCWide(out, coefs, shift) })
+			if !reflect.DeepEqual(fast, generic) {
+				t.Fatalf("LPC order %d, %d samples: kernel output differs from the generic 64-bit prediction", order, n)
+			}
+		}
+	}
+	for order := 0; order <= 4; order++ {
+		for _, n := range []int{order, order + 3, order + 4, order + 7, 1000, 4096} {
+			for i := range residual[:n] {
+				residual[i] = random(32)
+			}
+			for _, bps := range []int{16, 32} {
+				fast := restored(residual[:n], func(out []int32) { restoreFixed(out, order, bps) })
+				generic := restored(residual[:n], func(out []int32) { restoreFixedWide(out, order) })
+				if !reflect.DeepEqual(fast, generic) {
+					t.Fatalf("Fixed order %d, %d samples of %d bits: kernel output differs from the generic prediction", order, n, bps)
+				}
+			}
+		}
+	}
+}
+
+// benchmarkResidual returns a block of small pseudo-random residuals, which a decaying predictor keeps within 16 bits
+func benchmarkResidual() []int32 {
+	seed := uint32(7)
+	res := make([]int32, 4096)
+	for i := range res {
+		seed = seed*1664525 + 1013904223
+		res[i] = int32(seed) >> 28
+	}
+	return res
+}
+
+// BenchmarkRestoreLPC compares the prediction kernels used for 16-bit and 24-bit audio with the generic loops they replace.
+// Run it with and without -tags purego to compare the vector kernels with the unrolled Go loops.
+func BenchmarkRestoreLPC(b *testing.B) {
+	residual := benchmarkResidual()
+	out := make([]int32, len(residual))
+	for _, order := range []int{4, 8, 12, 32} {
+		coefs := make([]int32, order)
+		for i := range coefs {
+			coefs[i] = int32(300 - 20*i)
+		}
+		kernels := []struct {
+			name    string
+			restore func()
+		}{
+			{"kernel", func() { restoreLPC(out, coefs, 15, 16, 10) }},
+			{"kernel64", func() { restoreLPC(out, coefs, 15, 24, 15) }},
+			{"generic32", func() { restoreLPC32(out, coefs, 15) }},
+			{"generic64", func() { restoreLPCWide(out, coefs, 15) }},
+		}
+		for _, k := range kernels {
+			b.Run(fmt.Sprintf("order%d/%s", order, k.name), func(b *testing.B) {
+				b.SetBytes(int64(4 * len(out)))
+				for i := 0; i < b.N; i++ {
+					copy(out, residual)
+					k.restore()
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkRestoreFixed compares the fixed prediction kernels with the generic 64-bit loop. Run it with and without -tags purego
+// to compare the vector kernel with the 32-bit Go loops.
+func BenchmarkRestoreFixed(b *testing.B) {
+	residual := benchmarkResidual()
+	out := make([]int32, len(residual))
+	for order := 1; order <= 4; order++ {
+		order := order
+		b.Run(fmt.Sprintf("order%d/kernel", order), func(b *testing.B) {
+			b.SetBytes(int64(4 * len(out)))
+			for i := 0; i < b.N; i++ {
+				copy(out, residual)
+				restoreFixed(out, order, 16)
+			}
+		})
+		b.Run(fmt.Sprintf("order%d/generic64", order), func(b *testing.B) {
+			b.SetBytes(int64(4 * len(out)))
+			for i := 0; i < b.N; i++ {
+				copy(out, residual)
+				restoreFixedWide(out, order)
+			}
+		})
+	}
+}
+
+func TestInvalidStreamInfo(t *testing.T) {
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(0, 2, 16, 1000, nil)}}}
+	_, err := f.GetStreamInfo()
+	var infoErr *StreamInfoError
+	if !errors.Is(err, ErrorInvalidStreamInfo) || !errors.As(err, &infoErr) || infoErr.Field != "SampleRate" {
+		t.Fatalf("Expected a SampleRate StreamInfoError, got %v", err)
+	}
+
+	f.Meta[0].Data = testStreamInfoData(44100, 2, 16, 1000, nil)
+	info, err := f.GetStreamInfo()
+	if err != nil {
+		t.Fatalf("Failed to get stream info: %s", err)
+	}
+	if err := info.Validate(); err != nil {
+		t.Errorf("Unexpected validation error: %s", err)
+	}
+	// zero block sizes are unknown, as some encoders write them
+	info.BlockSizeMin, info.BlockSizeMax = 0, 0
+	if err := f.SetStreamInfo(info); err != nil {
+		t.Errorf("Unknown block sizes should be valid: %s", err)
+	}
+	info.BlockSizeMin = 8
+	if err := info.Validate(); !errors.As(err, &infoErr) || infoErr.Field != "BlockSizeMin" {
+		t.Errorf("Expected a BlockSizeMin StreamInfoError, got %v", err)
+	}
+	info.BlockSizeMin, info.BlockSizeMax = 4096, 1024
+	if err := info.Validate(); !errors.As(err, &infoErr) || infoErr.Field != "BlockSizeMax" {
+		t.Errorf("Expected a BlockSizeMax StreamInfoError, got %v", err)
+	}
+	info.BitDepth = 2
+	if err := info.Validate(); !errors.As(err, &infoErr) || infoErr.Field != "BitDepth" || infoErr.Error() != "invalid stream info: BitDepth 2" {
+		t.Errorf("Expected a BitDepth StreamInfoError, got %v", err)
+	}
+}
+
+func TestRecomputeStreamInfoBounds(t *testing.T) {
+	info := testStreamInfoData(44100, 2, 16, 9192, nil)
+	copy(info, make([]byte, 10))
+	frames := append(append(testFrame(0, 4096, 1, 2), testFrame(1, 4096, 1, 2)...), testFrame(2, 1000, 3, 4)...)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: info}}, frames)
+
+	for _, random := range []bool{false, true} {
+		var f *File
+		var err error
+		if random {
+			f, err = ParseReaderAt(bytes.NewReader(data), int64(len(data)))
+		} else {
+			f, err = ParseBytes(bytes.NewReader(data))
+		}
+		if err != nil {
+			t.Fatalf("Failed to parse stream: %s", err)
+		}
+		var events []Event
+		f.SetEventSink(func(e Event) { events = append(events, e) })
+		res, err := f.RecomputeStreamInfoBounds()
+		if err != nil {
+			t.Fatalf("Failed to recompute bounds: %s", err)
+		}
+		if len(events) != 1 || events[0].Kind != EventBlockModified || events[0].Block != 0 || events[0].BlockType != StreamInfo {
+			t.Errorf("Expected a StreamInfo block modified event, got %+v", events)
+		}
+		if res.BlockSizeMin != 4096 || res.BlockSizeMax != 4096 || res.FrameSizeMin != 16 || res.FrameSizeMax != 16 || res.SampleCount != 9192 {
+			t.Errorf("Unexpected stream info: %+v", res)
+		}
+		if err := res.Validate(); err != nil {
+			t.Errorf("Repaired stream info is invalid: %s", err)
+		}
+		var out bytes.Buffer
+		if _, err := f.WriteTo(&out); err != nil {
+			t.Fatalf("Failed to write stream: %s", err)
+		}
+		if !bytes.HasSuffix(out.Bytes(), frames) || !bytes.Contains(out.Bytes(), res.encode()) {
+			t.Errorf("Written stream lacks the frames or the repaired stream info")
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Test", "ARTIST=Someone"})},
+		{Type: Picture, Data: marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/png", width: 32, height: 16, depth: 24}, []byte("\x89PNG"))},
+		{Type: CueSheet, Data: []byte{1, 2, 3}},
+		{Type: Padding, Data: make([]byte, 16)},
+	}}
+
+	var out bytes.Buffer
+	if err := Dump(&out, f, DumpBlocks); err != nil {
+		t.Fatalf("Failed to dump: %s", err)
+	}
+	if s := out.String(); !strings.Contains(s, "METADATA block #4\n  type: 1 (PADDING)\n  is last: true\n  length: 16\n") || strings.Contains(s, "sample_rate") {
+		t.Errorf("Unexpected block dump:\n%s", s)
+	}
+
+	out.Reset()
+	if err := Dump(&out, f, DumpData); err != nil {
+		t.Fatalf("Failed to dump: %s", err)
+	}
+	for _, line := range []string{
+		"  sample_rate: 44100 Hz\n", "  channels: 2\n", "  total samples: 1000\n",
+		"  vendor string: go-flac\n", "    comment[1]: ARTIST=Someone\n",
+		"  type: 3 (Cover (front))\n", "  MIME type: image/png\n", "  width: 32\n", "  data length: 4\n", "89 50 4e 47",
+		"  error: " + ErrorMalformedCueSheet.Error() + "\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Dump lacks %q:\n%s", line, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := DumpJSON(&out, f, DumpFields); err != nil {
+		t.Fatalf("Failed to dump JSON: %s", err)
+	}
+	var res struct {
+		Blocks []dumpBlock `json:"blocks"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("Failed to decode JSON dump: %s", err)
+	}
+	if len(res.Blocks) != 5 || res.Blocks[0].StreamInfo.SampleRate != 44100 || res.Blocks[1].VorbisComment.Comments[0] != "TITLE=Test" ||
+		res.Blocks[2].Picture.Height != 16 || res.Blocks[2].Data != nil || res.Blocks[3].Error == "" {
+		t.Errorf("Unexpected JSON dump: %s", out.String())
+	}
+}
+
+func TestDumpHex(t *testing.T) {
+	picture := marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/png"}, []byte("\x89PNG"))
+	meta := []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Test"})},
+		{Type: Picture, Data: picture},
+	}
+	frame := testFrame(0, 4096, 1, 2)
+	data := testFLACStream(meta, frame)
+
+	var out bytes.Buffer
+	if err := DumpHex(&out, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Failed to dump valid stream: %s\n%s", err, out.String())
+	}
+	for _, line := range []string{
+		"00000000  66 4c 61 43" + strings.Repeat(" ", 16) + "stream marker \"fLaC\"\n",
+		"00000004  00" + strings.Repeat(" ", 25) + "block #0: last false, type 0 (STREAMINFO)\n",
+		"00000005  00 00 22" + strings.Repeat(" ", 19) + "block #0: length 34\n",
+		"sample_rate: 44100 Hz, channels: 2, bits-per-sample: 16, total samples: 4096\n",
+		"comment[0]: TITLE=Test\n", "picture type: 3 (Cover (front))\n", "MIME type: image/png\n",
+		"ff f8" + strings.Repeat(" ", 22) + "frame sync, fixed blocksize\n",
+		"frame number: 0, blocksize: 4096 samples, sample rate: 44100 Hz, bits-per-sample: 16\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Dump lacks %q:\n%s", line, out.String())
+		}
+	}
+
+	// the picture block starts after the marker, the StreamInfo and the VorbisComment block
+	pictureOffset := 4 + 4 + 34 + 4 + len(meta[1].Data) + 4
+	for _, c := range []struct {
+		name   string
+		modify func(data []byte) []byte
+		err    error
+		marker string
+	}{
+		{"marker", func(data []byte) []byte { data[2] = 'X'; return data }, ErrorNoFLACHeader, "      ^^ parse failed at offset 0x00000002"},
+		{"truncated", func(data []byte) []byte { return data[:20] }, io.ErrUnexpectedEOF, "^^ unexpected end at offset 0x00000014"},
+		{"sample rate", func(data []byte) []byte { data[8+10], data[8+11], data[8+12] = 0, 0, data[8+12]&0x0F; return data }, ErrorInvalidStreamInfo, "^^ parse failed at offset 0x00000012"},
+		{"picture", func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[pictureOffset+4:], 1000)
+			return data
+		}, nil, fmt.Sprintf("^^ parse failed at offset 0x%08x: MIME type length 1000", pictureOffset+4)},
+		{"frame", func(data []byte) []byte { data[len(data)-len(frame)+1] = 0xF0; return data }, ErrorNoSyncCode, fmt.Sprintf("^^ parse failed at offset 0x%08x", len(data)-len(frame)+1)},
+		{"frame crc", func(data []byte) []byte { data[len(data)-len(frame)+5]++; return data }, ErrorFrameCRC, fmt.Sprintf("^^ parse failed at offset 0x%08x", len(data)-len(frame)+7)},
+	} {
+		out.Reset()
+		err := DumpHex(&out, bytes.NewReader(c.modify(append([]byte(nil), data...))))
+		if err == nil || (c.err != nil && !errors.Is(err, c.err)) {
+			t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+		}
+		if !strings.Contains(out.String(), c.marker) {
+			t.Errorf("%s: dump lacks %q:\n%s", c.name, c.marker, out.String())
+		}
+	}
+}
+
+func TestErrorCorpus(t *testing.T) {
+	frames := append(testFrame(0, 4096, 1, 2), make([]byte, 64)...)
+	valid := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, frames)
+	metaEnd := len(valid) - len(frames)
+
+	var samples [][]byte
+	var errs []error
+	sink := func(sample []byte, err error) {
+		samples = append(samples, append([]byte(nil), sample...))
+		errs = append(errs, err)
+	}
+
+	if _, err := ParseBytes(bytes.NewReader(valid), WithErrorCorpus(sink, 1024)); err != nil {
+		t.Fatalf("Failed to parse valid stream: %s", err)
+	}
+	if len(samples) != 0 {
+		t.Fatalf("Valid stream was collected")
+	}
+
+	// a broken sync code fails after the metadata; the audio past the frame header is redacted
+	broken := append([]byte(nil), valid...)
+	broken[metaEnd] = 0
+	if _, err := ParseBytes(bytes.NewReader(broken), WithErrorCorpus(sink, 1024)); err != ErrorNoSyncCode {
+		t.Fatalf("Expected ErrorNoSyncCode, got %v", err)
+	}
+	if len(samples) != 1 || errs[0] != ErrorNoSyncCode || !bytes.Equal(samples[0], broken[:metaEnd+maxFrameHeaderSize]) {
+		t.Errorf("Unexpected sample %x (%v)", samples, errs)
+	}
+
+	// the limit applies to the sample, and every parse entry point reports failures
+	if _, err := ParseReaderAt(bytes.NewReader(broken), int64(len(broken)), WithErrorCorpus(sink, 10)); err != ErrorNoSyncCode {
+		t.Fatalf("Expected ErrorNoSyncCode, got %v", err)
+	}
+	if len(samples) != 2 || !bytes.Equal(samples[1], broken[:10]) {
+		t.Errorf("Unexpected sample %x", samples[1:])
+	}
+	if _, err := ParseMetadata(bytes.NewReader([]byte("ID3\x04junk")), WithErrorCorpus(sink, 1024)); err != ErrorNoFLACHeader {
+		t.Fatalf("Expected ErrorNoFLACHeader, got %v", err)
+	}
+	if len(samples) != 3 || string(samples[2]) != "ID3\x04junk" {
+		t.Errorf("Unexpected sample %q", samples[2:])
+	}
+}
+
+func TestOptimizeForStreaming(t *testing.T) {
+	meta := func() []*MetaDataBlock {
+		return []*MetaDataBlock{
+			{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+			{Type: Picture, Data: make([]byte, 100)},
+			NewPadding(1024),
+			{Type: Application, Data: make([]byte, StreamingBlockLimit+1)},
+			{Type: VorbisComment, Data: marshalVorbisComment("go-flac", nil)},
+			{Type: SeekTable, Data: make([]byte, seekPointSize)},
+			{Type: Application, Data: []byte("test")},
+		}
+	}
+	f := &File{Meta: meta()}
+	f.OptimizeForStreaming(false)
+	var order []BlockType
+	for _, block := range f.Meta {
+		order = append(order, block.Type)
+	}
+	if !reflect.DeepEqual(order, []BlockType{StreamInfo, SeekTable, VorbisComment, Application, Padding, Application, Picture}) || f.Meta[3].Len() != 4 {
+		t.Errorf("Unexpected block order %v", order)
+	}
+
+	f = &File{Meta: meta()}
+	f.OptimizeForStreaming(true)
+	if len(f.Meta) != 6 || f.Meta[5].Type != Application {
+		t.Errorf("Pictures were not stripped")
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	meta := []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Private"})},
+		{Type: SeekTable, Data: make([]byte, seekPointSize)},
+		{Type: Picture, Data: make([]byte, 100)},
+		NewPadding(100),
+	}
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream(meta, frames)
+
+	f, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	f.StripMetadata(SeekTable)
+	if len(f.Meta) != 2 || f.Meta[0].Type != StreamInfo || f.Meta[1].Type != SeekTable {
+		t.Errorf("Unexpected blocks after stripping: %v", f.Meta)
+	}
+
+	f, err = ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	fn := filepath.Join(t.TempDir(), "stripped.flac")
+	if err := f.SaveStripped(fn); err != nil {
+		t.Fatalf("Failed to save stripped file: %s", err)
+	}
+	if len(f.Meta) != 5 {
+		t.Errorf("SaveStripped modified the File metadata")
+	}
+	saved, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to read stripped file: %s", err)
+	}
+	if want := testFLACStream(meta[:1], frames); !bytes.Equal(saved, want) {
+		t.Errorf("Unexpected stripped file %x, expected %x", saved, want)
+	}
+	if _, err := f.WriteTo(io.Discard); err != ErrorAlreadyWritten {
+		t.Errorf("Expected ErrorAlreadyWritten once the frames were saved, got %v", err)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	meta := func() []*MetaDataBlock {
+		return []*MetaDataBlock{
+			{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+			{Type: VorbisComment, Data: marshalVorbisComment("reference libFLAC 1.4.3", []string{
+				"TITLE=Song", "artist=Band", "COMMENT=ripped at home", "GEO_LOCATION=52.5,13.4", "Encoder_Settings=-8", "ENCODED-BY=me",
+			})},
+			{Type: Picture, Data: marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/jpeg"}, []byte{0xFF, 0xD8})},
+			{Type: Application, Data: []byte("testdata")},
+			NewPadding(10),
+		}
+	}
+
+	f := &File{Meta: meta()}
+	if err := f.Scrub(ScrubIdentifying); err != nil {
+		t.Fatalf("Failed to scrub: %s", err)
+	}
+	vendor, comments, err := parseVorbisComment(f.Meta[1].Data)
+	if err != nil {
+		t.Fatalf("Failed to parse scrubbed comments: %s", err)
+	}
+	if vendor != "" || !reflect.DeepEqual(comments, []string{"TITLE=Song", "artist=Band"}) {
+		t.Errorf("Unexpected scrubbed comments %q %q", vendor, comments)
+	}
+	if len(f.Meta) != 4 || f.Meta[2].Type != Picture || f.Meta[3].Type != Padding {
+		t.Errorf("Unexpected scrubbed blocks %v", f.Meta)
+	}
+
+	f = &File{Meta: meta()}
+	if err := f.Scrub(ScrubAllPersonal); err != nil {
+		t.Fatalf("Failed to scrub: %s", err)
+	}
+	if _, comments, _ := parseVorbisComment(f.Meta[1].Data); len(f.Meta) != 3 || len(comments) != 0 {
+		t.Errorf("Unexpected scrubbed blocks %v, comments %q", f.Meta, comments)
+	}
+}
+
+func TestInvalidBlockType(t *testing.T) {
+	meta := []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: Invalid, Data: []byte{1, 2, 3}},
+	}
+	data := testFLACStream(meta, testFrame(0, 4096, 1, 2))
+
+	f, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream leniently: %s", err)
+	}
+	if f.Meta[1].Type != Invalid {
+		t.Errorf("Expected an Invalid block, got type %d", f.Meta[1].Type)
+	}
+	var out bytes.Buffer
+	if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("Invalid block was not preserved: %v", err)
+	}
+
+	if _, err := ParseBytes(bytes.NewReader(data), WithStrict()); err != ErrorInvalidBlockType {
+		t.Errorf("Expected ErrorInvalidBlockType, got %v", err)
+	}
+	if _, err := ParseReaderAt(bytes.NewReader(data), int64(len(data)), WithStrict()); err != ErrorInvalidBlockType {
+		t.Errorf("Expected ErrorInvalidBlockType, got %v", err)
+	}
+}
+
+func TestHeaderConsumed(t *testing.T) {
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, testFrame(0, 4096, 1, 2))
+
+	r := bytes.NewReader(data)
+	if _, err := ParseMetadata(r); err != nil {
+		t.Fatalf("Failed to parse metadata: %s", err)
+	}
+	if _, err := ParseBytes(r); err != ErrorHeaderConsumed || !errors.Is(err, ErrorNoFLACHeader) {
+		t.Errorf("Expected ErrorHeaderConsumed at the audio frames, got %v", err)
+	}
+	if _, err := ParseBytes(bytes.NewReader(data[4:])); err != ErrorHeaderConsumed {
+		t.Errorf("Expected ErrorHeaderConsumed at the StreamInfo block, got %v", err)
+	}
+	if _, err := ParseBytes(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE"))); err != ErrorNoFLACHeader {
+		t.Errorf("Expected ErrorNoFLACHeader, got %v", err)
+	}
+}
+
+func TestSaveTo(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, frames)
+	f, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	var out bytes.Buffer
+	var audit SaveAudit
+	if err := f.SaveTo(&out, WithAudit(&audit)); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) || audit.Audio.Len() != int64(len(frames)) {
+		t.Errorf("Unexpected output %x, audit %+v", out.Bytes(), audit)
+	}
+}
+
+func TestSaveReport(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", nil)},
+		NewPadding(100),
+	}, frames)
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	setTitle := func(title string) *File {
+		f, err := ParseFile(fn)
+		if err != nil {
+			t.Fatalf("Failed to parse file: %s", err)
+		}
+		f.Meta[1].Data = marshalVorbisComment("go-flac", []string{"TITLE=" + title})
+		return f
+	}
+
+	var report SaveReport
+	if err := setTitle("Song").SaveTo(io.Discard, WithReport(&report)); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	if report.Strategy != SaveRewrite || report.BytesMoved != int64(len(frames)) || report.BytesWritten != int64(len(data))+14 {
+		t.Errorf("Unexpected rewrite report %+v", report)
+	}
+
+	// the new comment takes 14 bytes of the padding
+	if err := setTitle("Song").Save(fn, WithInPlace(), WithReport(&report)); err != nil {
+		t.Fatalf("Failed to save in place: %s", err)
+	}
+	saved, _ := os.ReadFile(fn)
+	if report.Strategy != SavePaddingPatch || report.BytesMoved != 0 || len(saved) != len(data) || !bytes.HasSuffix(saved, frames) {
+		t.Errorf("Unexpected padding patch report %+v", report)
+	}
+	if err := setTitle("Tune").Save(fn, WithInPlace(), WithReport(&report)); err != nil || report.Strategy != SaveInPlace {
+		t.Errorf("Unexpected in place report %+v: %v", report, err)
+	}
+
+	// a comment larger than the padding needs a rewrite
+	if err := setTitle(strings.Repeat("x", 200)).Save(fn, WithInPlace(), WithReport(&report)); err != nil {
+		t.Fatalf("Failed to rewrite: %s", err)
+	}
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse rewritten file: %s", err)
+	}
+	defer f.Close()
+	if report.Strategy != SaveRewrite || report.BytesMoved != int64(len(frames)) || f.Meta[1].Len() != 4+7+4+4+206 {
+		t.Errorf("Unexpected rewrite report %+v", report)
+	}
+}
+
+func TestPaddingPolicy(t *testing.T) {
+	meta := []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		NewPadding(10),
+		{Type: VorbisComment, Data: make([]byte, 958)},
+		NewPadding(20),
+	}
+	f := &File{Meta: meta}
+	if err := f.SaveTo(io.Discard, WithPadding(DefaultPadding)); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	// the metadata takes 4+38+962 bytes without padding
+	if len(f.Meta) != 3 || f.Meta[2].Type != Padding || f.Meta[2].Len() != 100+4096 {
+		t.Errorf("Unexpected padding %v", f.Meta)
+	}
+
+	f.SaveTo(io.Discard, WithPadding(PaddingFunc(func(int64) int { return 0 })))
+	if len(f.Meta) != 2 {
+		t.Errorf("Padding was not removed")
+	}
+}
+
+func TestSaveLock(t *testing.T) {
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, testFrame(0, 4096, 1, 2))
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	save := func() error {
+		f, err := ParseBytes(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to parse stream: %s", err)
+		}
+		return f.Save(fn, WithLock(100*time.Millisecond))
+	}
+
+	if err := save(); err != nil {
+		t.Fatalf("Failed to save with lock: %s", err)
+	}
+	if _, err := os.Stat(fn + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Lock file was not removed")
+	}
+
+	if err := os.WriteFile(fn+".lock", nil, 0o644); err != nil {
+		t.Fatalf("Failed to create lock file: %s", err)
+	}
+	if err := save(); err != ErrorFileLocked {
+		t.Errorf("Expected ErrorFileLocked, got %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.Remove(fn + ".lock")
+	}()
+	if err := save(); err != nil {
+		t.Errorf("Failed to save after the lock was released: %s", err)
+	}
+}
+
+func TestSaveAtomic(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, frames)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.flac")
+	if err := os.WriteFile(fn, data, 0o640); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	f.Meta = append(f.Meta, &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("go-flac", nil)})
+	if err := f.Save(fn, WithAtomic()); err != nil {
+		t.Fatalf("Failed to save atomically over the source: %s", err)
+	}
+
+	saved, err := os.ReadFile(fn)
+	if err != nil || !bytes.Equal(saved, testFLACStream(f.Meta, frames)) {
+		t.Errorf("Unexpected saved file %x: %v", saved, err)
+	}
+	if info, err := os.Stat(fn); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("Permissions were not kept: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Temporary file left behind: %v", entries)
+	}
+}
+
+func TestSaveHardLinks(t *testing.T) {
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, testFrame(0, 4096, 1, 2))
+	dir := t.TempDir()
+	fn, link := filepath.Join(dir, "test.flac"), filepath.Join(dir, "link.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	if err := os.Link(fn, link); err != nil {
+		t.Skipf("Hard links not supported: %s", err)
+	}
+	save := func(opts ...SaveOption) error {
+		f, err := ParseFile(fn)
+		if err != nil {
+			t.Fatalf("Failed to parse file: %s", err)
+		}
+		defer f.Close()
+		f.Meta = append(f.Meta, NewPadding(len(opts)))
+		return f.Save(fn, append(opts, WithAtomic())...)
+	}
+
+	var linkErr *HardLinkError
+	if err := save(); !errors.As(err, &linkErr) || linkErr.Links != 2 || !errors.Is(err, ErrorHardLinked) {
+		t.Fatalf("Expected a HardLinkError, got %v", err)
+	}
+	if err := save(WithHardLinks(HardLinkShare)); err != nil {
+		t.Fatalf("Failed to save shared: %s", err)
+	}
+	if shared, _ := os.ReadFile(link); len(shared) != len(data)+4+1 {
+		t.Errorf("Link does not share the saved content")
+	}
+	if err := save(WithHardLinks(HardLinkBreak), WithReport(nil)); err != nil {
+		t.Fatalf("Failed to save breaking the link: %s", err)
+	}
+	saved, _ := os.ReadFile(fn)
+	shared, _ := os.ReadFile(link)
+	if len(saved) != len(data)+4+1+4+2 || len(shared) != len(data)+4+1 {
+		t.Errorf("Unexpected sizes %d and %d after breaking the link", len(saved), len(shared))
+	}
+}
+
+func TestSaveBackup(t *testing.T) {
+	meta := []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}
+	data := testFLACStream(meta, testFrame(0, 4096, 1, 2))
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.flac")
+	save := func(opts ...SaveOption) {
+		f, err := ParseBytes(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to parse stream: %s", err)
+		}
+		if err := f.Save(fn, opts...); err != nil {
+			t.Fatalf("Failed to save: %s", err)
+		}
+	}
+
+	// there is nothing to back up before the file exists
+	for i := 0; i < 4; i++ {
+		save(WithBackup("backup/test-*.flac", 2))
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "backup", "test-*.flac"))
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
+	if backup, _ := os.ReadFile(backups[1]); !bytes.Equal(backup, data) {
+		t.Errorf("Unexpected backup content %x", backup)
+	}
+
+	save(WithHeaderBackup("test-*.header", 0))
+	headers, _ := filepath.Glob(filepath.Join(dir, "test-*.header"))
+	if len(headers) != 1 {
+		t.Fatalf("Expected 1 header backup, got %v", headers)
+	}
+	if header, _ := os.ReadFile(headers[0]); !bytes.Equal(header, data[:4+4+34]) {
+		t.Errorf("Unexpected header backup %x", header)
+	}
+}
+
+type failingWriter struct {
+	room int
+}
+
+func (c *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > c.room {
+		n := c.room
+		c.room = 0
+		return n, io.ErrShortWrite
+	}
+	c.room -= len(p)
+	return len(p), nil
+}
+
+func TestWriteToFailingWriter(t *testing.T) {
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: VorbisComment, Data: bytes.Repeat([]byte{1}, 100)},
+	}, []byte{0xFF, 0xF8, 1, 2, 3})
+	for room := 0; room < len(stream); room++ {
+		f, err := ParseBytes(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatalf("Failed to parse stream: %s", err)
+		}
+		n, err := f.WriteTo(&failingWriter{room: room})
+		if !errors.Is(err, io.ErrShortWrite) {
+			t.Fatalf("Expected io.ErrShortWrite with %d bytes of room, got %v", room, err)
+		}
+		if n != int64(room) {
+			t.Errorf("Expected %d bytes written, got %d", room, n)
+		}
+	}
+}
+
+func TestAppendMarshal(t *testing.T) {
+	blocks := []*MetaDataBlock{
+		{Type: VorbisComment, Data: []byte{1, 2, 3}},
+		NewPadding(10),
+	}
+	for _, block := range blocks {
+		for _, last := range []bool{false, true} {
+			res, err := block.AppendMarshal([]byte("prefix"), last)
+			if err != nil || !bytes.Equal(res, append([]byte("prefix"), block.Marshal(last)...)) {
+				t.Errorf("AppendMarshal %x does not match Marshal: %v", res, err)
+			}
+		}
+	}
+
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, block := range blocks {
+			buf, _ = block.AppendMarshal(buf[:0], false)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+
+	// stale bytes of a reused buffer do not leak into padding
+	buf = append(buf[:0], bytes.Repeat([]byte{0xFF}, 64)...)
+	if res, err := NewPadding(10).AppendMarshal(buf[:0], true); err != nil || !bytes.Equal(res[4:], make([]byte, 10)) {
+		t.Errorf("Unexpected padding %x: %v", res, err)
+	}
+
+	// blocks that cannot be read from their source are reported instead of written as zeros
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: Application, Data: []byte("test0123456789")},
+	}, testFrame(0, 4096, 1, 2))
+	f, err := ParseReaderAt(bytes.NewReader(stream), int64(len(stream)))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	f.Meta[1].src = bytes.NewReader(stream[:len(stream)-30])
+	if res, err := f.Meta[1].AppendMarshal([]byte("prefix"), true); err == nil || string(res) != "prefix" {
+		t.Errorf("Expected a read error and dst unchanged, got %x %v", res, err)
+	}
+	if res := f.Meta[1].Marshal(true); res != nil {
+		t.Errorf("Marshal should return nil when the block cannot be read, got %x", res)
+	}
+	if _, err := f.WriteTo(io.Discard); err == nil {
+		t.Error("Writing a block that cannot be read should fail")
+	}
+	if f, err = ParseBytes(bytes.NewReader(stream), WithBlockTypes()); err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if _, err := f.Meta[1].AppendMarshal(nil, true); err != ErrorNoBlockSource {
+		t.Errorf("Expected ErrorNoBlockSource for a skipped block, got %v", err)
+	}
+}
+
+func testLargeFLACFile(t testing.TB, frameSize int) (string, []byte) {
+	frames := append([]byte{0xFF, 0xF8}, bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7}, frameSize/7)...)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+	}, frames)
+	fn := filepath.Join(t.TempDir(), "source.flac")
+	if err := os.WriteFile(fn, data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+	return fn, data
+}
+
+func TestSaveCopiesFrames(t *testing.T) {
+	fn, data := testLargeFLACFile(t, 3<<20+5)
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	out := filepath.Join(t.TempDir(), "out.flac")
+	if err := f.Save(out); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	if saved, _ := os.ReadFile(out); !bytes.Equal(saved, data) {
+		t.Errorf("Saved file does not match the source")
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	fn, data := testLargeFLACFile(b, 64<<20)
+	out := filepath.Join(b.TempDir(), "out.flac")
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := ParseFile(fn)
+		if err != nil {
+			b.Fatalf("Failed to parse file: %s", err)
+		}
+		if err := f.Save(out); err != nil {
+			b.Fatalf("Failed to save: %s", err)
+		}
+	}
+}
+
+// BenchmarkFrameCopy and BenchmarkSaveInPlace are meant to be run in pairs, with and without the iouring build tag:
+//
+//	go test -run '^$' -bench 'FrameCopy|SaveInPlace' .
+//	go test -run '^$' -bench 'FrameCopy|SaveInPlace' -tags iouring .
+//
+// Without the tag, the copier sub-benchmark measures the io.Copy fallback as well.
+func BenchmarkFrameCopy(b *testing.B) {
+	fn, data := testLargeFLACFile(b, 64<<20)
+	offset := int64(4 + 4 + 34)
+	out := filepath.Join(b.TempDir(), "out.flac")
+	for _, bench := range []struct {
+		name string
+		wrap func(*os.File) io.Writer
+	}{
+		{"copier", func(f *os.File) io.Writer { return f }},
+		// hiding the *os.File forces the io.Copy fallback
+		{"io.Copy", func(f *os.File) io.Writer { return struct{ io.Writer }{f} }},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)) - offset)
+			for i := 0; i < b.N; i++ {
+				src, err := os.Open(fn)
+				if err != nil {
+					b.Fatalf("Failed to open source: %s", err)
+				}
+				dst, err := os.Create(out)
+				if err != nil {
+					b.Fatalf("Failed to create output: %s", err)
+				}
+				if _, err := src.Seek(offset, io.SeekStart); err != nil {
+					b.Fatalf("Failed to seek: %s", err)
+				}
+				if n, _, err := copyFramesTo(bench.wrap(dst), src, nil); err != nil || n != int64(len(data))-offset {
+					b.Fatalf("Failed to copy frames: %d %v", n, err)
+				}
+				src.Close()
+				dst.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkSaveInPlace(b *testing.B) {
+	fn, data := testLargeFLACFile(b, 64<<20)
+	comment := &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Benchmark"})}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		if err := os.WriteFile(fn, data, 0644); err != nil {
+			b.Fatalf("Failed to restore source: %s", err)
+		}
+		b.StartTimer()
+		f, err := ParseFile(fn)
+		if err != nil {
+			b.Fatalf("Failed to parse file: %s", err)
+		}
+		// the source has no padding, so the added block moves the whole frame section
+		f.Meta = append(f.Meta, comment)
+		if err := f.Save(fn, WithInPlace()); err != nil {
+			b.Fatalf("Failed to save: %s", err)
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestBoundedMemory(t *testing.T) {
+	comment := bytes.Repeat([]byte{1}, 1000)
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: VorbisComment, Data: comment},
+		{Type: Picture, Data: make([]byte, 5000)},
+	}, append([]byte{0xFF, 0xF8}, make([]byte, 1<<20)...))
+	metadataSize := int64(4 + 4 + 34 + 4 + 1000 + 4 + 5000)
+
+	var stats []AllocStats
+	DebugAllocStats = func(s AllocStats) {
+		stats = append(stats, s)
+	}
+	defer func() {
+		DebugAllocStats = nil
+	}()
+
+	r := &countingReader{r: bytes.NewReader(stream)}
+	if _, err := ParseBytes(r); err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	if r.n != metadataSize+FrameReadAhead {
+		t.Errorf("Expected %d bytes read, got %d", metadataSize+FrameReadAhead, r.n)
+	}
+	if _, err := ParseReaderAt(bytes.NewReader(stream), int64(len(stream))); err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	expected := []AllocStats{
+		{Blocks: 3, BlockBytes: 34 + 1000 + 5000, LargestBlock: 5000, FrameBytes: FrameReadAhead},
+		{Blocks: 1, BlockBytes: 34, LargestBlock: 34, FrameBytes: FrameReadAhead},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestPictureChecksums(t *testing.T) {
+	front := marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/jpeg"}, []byte{1, 2, 3})
+	back := marshalPicture(pictureHeader{pictureType: PictureTypeBackCover, mime: "image/jpeg"}, []byte{4, 5, 6})
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: Picture, Data: front},
+		{Type: Picture, Data: back},
+	}}
+	if err := f.VerifyPictureChecksums(); err != ErrorNoPictureChecksums {
+		t.Errorf("Expected ErrorNoPictureChecksums, got %v", err)
+	}
+	if err := f.SetPictureChecksums(crypto.SHA256); err != nil {
+		t.Fatalf("Failed to set checksums: %s", err)
+	}
+	if len(f.Meta) != 4 || f.Meta[3].Len() != 4+2+len("SHA-256")+2*32 {
+		t.Fatalf("Unexpected checksum block")
+	}
+	if err := f.VerifyPictureChecksums(); err != nil {
+		t.Errorf("Failed to verify checksums: %s", err)
+	}
+
+	// a changed description keeps the checksum valid, a substituted image does not
+	f.Meta[1].Data = marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/jpeg", description: "Cover"}, []byte{1, 2, 3})
+	f.Meta[2].Data = marshalPicture(pictureHeader{pictureType: PictureTypeBackCover, mime: "image/jpeg"}, []byte{7})
+	err := f.VerifyPictureChecksums()
+	var mismatch *PictureChecksumError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrorPictureChecksumMismatch) || !reflect.DeepEqual(mismatch.Indices, []int{2}) {
+		t.Errorf("Expected mismatch of block 2, got %v", err)
+	}
+
+	f.Meta = append(f.Meta[:2], f.Meta[3])
+	if err := f.VerifyPictureChecksums(); !errors.As(err, &mismatch) || mismatch.Missing != 1 || len(mismatch.Indices) != 0 {
+		t.Errorf("Expected a missing picture, got %v", err)
+	}
+
+	// checksums survive a round trip through a lazily parsed stream
+	if err := f.SetPictureChecksums(crypto.SHA256); err != nil {
+		t.Fatalf("Failed to set checksums: %s", err)
+	}
+	stream := testFLACStream(f.Meta, []byte{0xFF, 0xF8})
+	parsed, err := ParseReaderAt(bytes.NewReader(stream), int64(len(stream)))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	if err := parsed.VerifyPictureChecksums(); err != nil {
+		t.Errorf("Failed to verify checksums: %s", err)
+	}
+	if err := f.SetPictureChecksums(crypto.Hash(0)); err != ErrorHashUnavailable {
+		t.Errorf("Expected ErrorHashUnavailable, got %v", err)
+	}
+}
+
+func TestFetchAndEmbedCover(t *testing.T) {
+	var cover bytes.Buffer
+	png.Encode(&cover, image.NewGray(image.Rect(0, 0, 3, 2)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/release-group/rg-id/front-500" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(cover.Bytes())
+	}))
+	defer server.Close()
+	provider := &CoverArtArchive{BaseURL: server.URL, Size: 500}
+
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("", []string{"ALBUM=Album", "MUSICBRAINZ_ALBUMID=release-id", "MUSICBRAINZ_RELEASEGROUPID=rg-id"})},
+		{Type: Picture, Data: marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/jpeg"}, []byte{1})},
+	}}
+	if err := f.FetchAndEmbedCover(context.Background(), provider); err != nil {
+		t.Fatalf("Failed to embed cover: %s", err)
+	}
+	if len(f.Meta) != 3 {
+		t.Fatalf("Expected the front cover to be replaced, got %d blocks", len(f.Meta))
+	}
+	header, image, err := parsePicture(f.Meta[2].Data)
+	if err != nil {
+		t.Fatalf("Failed to parse picture: %s", err)
+	}
+	if header.mime != "image/png" || header.width != 3 || header.height != 2 || !bytes.Equal(image, cover.Bytes()) {
+		t.Errorf("Unexpected picture %+v", header)
+	}
+
+	f.Meta[1].Data = marshalVorbisComment("", []string{"ALBUM=Album"})
+	if err := f.FetchAndEmbedCover(context.Background(), provider); err != ErrorNoCover {
+		t.Errorf("Expected ErrorNoCover, got %v", err)
+	}
+}
+
+type testLyricsProvider func(LyricsQuery) (*Lyrics, error)
+
+func (c testLyricsProvider) FetchLyrics(ctx context.Context, query LyricsQuery) (*Lyrics, error) {
+	return c(query)
+}
+
+func TestLyrics(t *testing.T) {
+	lines, err := ParseLRC("[ar:Artist]\n[offset:500]\n[00:12.00][01:02.50]Chorus\r\n[00:05.10] First line\n")
+	if err != nil {
+		t.Fatalf("Failed to parse LRC: %s", err)
+	}
+	expected := []LyricLine{{4600 * time.Millisecond, "First line"}, {11500 * time.Millisecond, "Chorus"}, {62 * time.Second, "Chorus"}}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %v, got %v", expected, lines)
+	}
+	if _, err := ParseLRC("[Chorus]\nno timestamps"); err != ErrorInvalidLRC {
+		t.Errorf("Expected ErrorInvalidLRC, got %v", err)
+	}
+
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 441000, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("", []string{"ARTIST=Artist", "TITLE=Title", "LYRICS=old"})},
+	}}
+	provider := testLyricsProvider(func(query LyricsQuery) (*Lyrics, error) {
+		if query != (LyricsQuery{Artist: "Artist", Title: "Title", Duration: 10 * time.Second}) {
+			t.Errorf("Unexpected query %+v", query)
+		}
+		return &Lyrics{Plain: "First line\nChorus", Synced: lines[:2]}, nil
+	})
+	if err := f.FetchAndEmbedLyrics(context.Background(), provider); err != nil {
+		t.Fatalf("Failed to embed lyrics: %s", err)
+	}
+	_, comments, _ := parseVorbisComment(f.Meta[1].Data)
+	if comments[2] != "LYRICS=[00:04.60]First line\n[00:11.50]Chorus\n" || comments[3] != "UNSYNCEDLYRICS=First line\nChorus" {
+		t.Errorf("Unexpected comments %q", comments)
+	}
+	lyrics, err := f.Lyrics()
+	if err != nil {
+		t.Fatalf("Failed to read lyrics: %s", err)
+	}
+	if !reflect.DeepEqual(lyrics, &Lyrics{Plain: "First line\nChorus", Synced: lines[:2]}) {
+		t.Errorf("Unexpected lyrics %+v", lyrics)
+	}
+
+	f.SetLyrics(nil)
+	if lyrics, err := f.Lyrics(); lyrics != nil || err != nil {
+		t.Errorf("Expected no lyrics, got %+v, %v", lyrics, err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	frames := append([]byte{0xFF, 0xF8}, bytes.Repeat([]byte{1, 2, 3}, 100000)...)
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: Padding, Data: make([]byte, 100)},
+	}, frames)
+
+	var out bytes.Buffer
+	err := Filter(bytes.NewReader(stream), &out, func(f *File) error {
+		f.Meta[1] = &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("", []string{"TITLE=Title"})}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to filter: %s", err)
+	}
+	f, err := ParseBytes(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to parse output: %s", err)
+	}
+	if f.Meta[1].Type != VorbisComment || !bytes.HasSuffix(out.Bytes(), frames) {
+		t.Errorf("Unexpected output")
+	}
+
+	out.Reset()
+	failure := errors.New("edit failed")
+	if err := Filter(bytes.NewReader(stream), &out, func(f *File) error { return failure }); err != failure || out.Len() != 0 {
+		t.Errorf("Expected the edit error and no output, got %v and %d bytes", err, out.Len())
+	}
+}
+
+func TestStreams(t *testing.T) {
+	first := append([]byte{0xFF, 0xF8}, bytes.Repeat([]byte("fLa"), 5000)...)
+	second := append([]byte{0xFF, 0xF8}, bytes.Repeat([]byte{1, 2, 3}, 5000)...)
+	// an Application block embedding what looks like a stream start is not mistaken for one
+	embedded := append([]byte("test"), testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(8000, 1, 8, 0, nil)}}, nil)...)
+	input := append(testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)},
+		{Type: Application, Data: embedded},
+	}, first), testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(48000, 2, 24, 1000, nil)},
+	}, second)...)
+
+	streams := NewStreams(bytes.NewReader(input))
+	for i, frames := range [][]byte{first, second} {
+		f, err := streams.Next()
+		if err != nil {
+			t.Fatalf("Failed to parse stream %d: %s", i, err)
+		}
+		if i == 0 && len(f.Meta) != 2 {
+			t.Errorf("Expected 2 blocks, got %d", len(f.Meta))
+		}
+		read, err := io.ReadAll(f.Frames)
+		if err != nil || !bytes.Equal(read, frames) {
+			t.Errorf("Unexpected frames of stream %d: %d bytes, %v", i, len(read), err)
+		}
+	}
+	if _, err := streams.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	// unread frames are skipped
+	streams = NewStreams(bytes.NewReader(input))
+	streams.Next()
+	f, err := streams.Next()
+	if err != nil {
+		t.Fatalf("Failed to parse second stream: %s", err)
+	}
+	if info, _ := f.GetStreamInfo(); info.SampleRate != 48000 {
+		t.Errorf("Unexpected stream info %+v", info)
+	}
+}
+
+func TestFrameCopyError(t *testing.T) {
+	fn, data := testLargeFLACFile(t, 100000)
+	metadataSize := len(data) - 100000/7*7 - 2
+
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	_, err = f.WriteTo(&failingWriter{room: metadataSize + 1000})
+	var copyErr *FrameCopyError
+	if !errors.As(err, &copyErr) || copyErr.Op != "write" || copyErr.Copied != 1000 || copyErr.Truncated() || !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Expected a write FrameCopyError after 1000 bytes, got %v", err)
+	}
+
+	// the source shrinks while saving
+	f, err = ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if err := os.Truncate(fn, int64(len(data)-500)); err != nil {
+		t.Fatalf("Failed to truncate: %s", err)
+	}
+	err = f.Save(filepath.Join(t.TempDir(), "out.flac"))
+	if !errors.As(err, &copyErr) || !copyErr.Truncated() || copyErr.Copied != int64(len(data)-500-metadataSize) {
+		t.Errorf("Expected a truncated source, got %v", err)
+	}
+
+	stream := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 1000, nil)}}, []byte{0xFF, 0xF8, 1})
+	f, _ = ParseBytes(bytes.NewReader(stream))
+	if _, err := f.WriteTo(io.Discard); err != nil {
+		t.Errorf("Failed to write: %s", err)
+	}
+	if _, err := f.WriteTo(io.Discard); err != ErrorAlreadyWritten {
+		t.Errorf("Expected ErrorAlreadyWritten, got %v", err)
+	}
+}
+
+func TestResumeSave(t *testing.T) {
+	fn, data := testLargeFLACFile(t, 100000)
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	f.Meta = append(f.Meta, &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("", []string{"TITLE=Title"})})
+	out := filepath.Join(t.TempDir(), "out.flac")
+	if err := f.Save(out); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	expected, _ := os.ReadFile(out)
+
+	// the save failed after 10000 bytes of frames
+	metadataSize := len(expected) - (len(data) - int(f.audioOffset))
+	os.Truncate(out, int64(metadataSize+10000))
+	f, err = ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if err := f.ResumeSave(out); err != nil {
+		t.Fatalf("Failed to resume: %s", err)
+	}
+	if saved, _ := os.ReadFile(out); !bytes.Equal(saved, expected) {
+		t.Errorf("Resumed file does not match")
+	}
+
+	// frames that differ from the source are not resumed
+	os.Truncate(out, int64(metadataSize+10000))
+	corrupted, _ := os.ReadFile(out)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	os.WriteFile(out, corrupted, 0644)
+	f, _ = ParseFile(fn)
+	if err := f.ResumeSave(out); err != ErrorNotResumable {
+		t.Errorf("Expected ErrorNotResumable, got %v", err)
+	}
+	f.Close()
+
+	f, _ = ParseBytes(bytes.NewReader(data))
+	if err := f.ResumeSave(out); err != ErrorNotResumable {
+		t.Errorf("Expected ErrorNotResumable without a seekable source, got %v", err)
+	}
+}
+
+func TestSaveScheduler(t *testing.T) {
+	fn, data := testLargeFLACFile(t, 100000)
+	dir := t.TempDir()
+	scheduler := NewSaveScheduler(2, 1<<20)
+	start := time.Now()
+	var results []<-chan error
+	for i := 0; i < 4; i++ {
+		f, err := ParseFile(fn)
+		if err != nil {
+			t.Fatalf("Failed to parse file: %s", err)
+		}
+		results = append(results, scheduler.Schedule(f, filepath.Join(dir, fmt.Sprintf("%d.flac", i))))
+	}
+	scheduler.Wait()
+	// 400 KB at 1 MiB/s, less the initial burst
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("Saves were not throttled, took %s", elapsed)
+	}
+	for i, result := range results {
+		if err := <-result; err != nil {
+			t.Errorf("Failed to save %d: %s", i, err)
+		}
+		if saved, _ := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.flac", i))); !bytes.Equal(saved, data) {
+			t.Errorf("Saved file %d does not match", i)
+		}
+	}
+}
+
+func TestWatchMetadata(t *testing.T) {
+	fn, data := testLargeFLACFile(t, 1000)
+	schema, err := ReadMetadataSchema(fn)
+	if err != nil {
+		t.Fatalf("Failed to read schema: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	drifts := make(chan *SchemaDrift, 1)
+	done := make(chan error)
+	go func() {
+		done <- WatchMetadata(ctx, fn, func(drift *SchemaDrift, err error) {
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			select {
+			case drifts <- drift:
+			default:
+			}
+		})
+	}()
+
+	// another tagger replaces the file, adding a comment and padding
+	comment := &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("", []string{"TITLE=Title"})}
+	f, _ := ParseBytes(bytes.NewReader(data))
+	f.Meta = append(f.Meta, comment, NewPadding(100))
+	var tagged bytes.Buffer
+	f.WriteTo(&tagged)
+	// the watch may not be set up before the first replacement, so the file alternates between both versions until a drift is seen
+	deadline := time.After(5 * time.Second)
+	var drift *SchemaDrift
+	for i := 0; drift == nil; i++ {
+		content := tagged.Bytes()
+		if i%2 == 1 {
+			content = data
+		}
+		tmp := fn + ".tmp"
+		os.WriteFile(tmp, content, 0644)
+		os.Rename(tmp, fn)
+		select {
+		case drift = <-drifts:
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("No drift reported")
+		}
+	}
+	added, removed := drift.Added, drift.Removed
+	if len(added) == 0 {
+		added, removed = removed, added
+	}
+	if len(added) != 1 || added[0].Type != VorbisComment || added[0].Size != len(comment.Data) || len(removed) != 0 {
+		t.Errorf("Unexpected drift %+v", drift)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	os.WriteFile(fn, tagged.Bytes(), 0644)
+	current, _ := ReadMetadataSchema(fn)
+	if drift := current.Drift(schema); len(drift.Removed) != 1 || len(drift.Added) != 0 {
+		t.Errorf("Unexpected drift %+v", drift)
+	}
+}
+
+func TestBlockHashes(t *testing.T) {
+	_, data := testLargeFLACFile(t, 1000)
+	f, _ := ParseBytes(bytes.NewReader(data))
+	f.Meta = append(f.Meta, &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("", []string{"TITLE=Title"})})
+	var stream bytes.Buffer
+	f.WriteTo(&stream)
+
+	parsed, err := ParseReaderAt(bytes.NewReader(stream.Bytes()), int64(stream.Len()), WithBlockHashes())
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	comment := parsed.Meta[len(parsed.Meta)-1]
+	if comment.Loaded() {
+		t.Error("Hashing at parse time loaded the block")
+	}
+	hash, err := comment.Hash()
+	if err != nil {
+		t.Fatalf("Failed to hash block: %s", err)
+	}
+	if hash != sha256.Sum256(f.Meta[len(f.Meta)-1].Data) {
+		t.Error("Block hash does not match the block data")
+	}
+	if err := comment.Load(); err != nil {
+		t.Fatalf("Failed to load block: %s", err)
+	}
+	if again, _ := comment.Hash(); again != hash {
+		t.Error("Block hash changed after loading the block")
+	}
+
+	comment.Data = marshalVorbisComment("", []string{"TITLE=Other"})
+	if changed, _ := comment.Hash(); changed != sha256.Sum256(comment.Data) {
+		t.Error("Block hash was not updated after Data was replaced")
+	}
+}
+
+func TestVorbisCommentBlock(t *testing.T) {
+	data := append(marshalVorbisComment("reference libFLAC 1.4.3", []string{"ARTIST=Artist", "title=Title", "ARTIST=Other"}), 1)
+	meta := &MetaDataBlock{Type: VorbisComment, Data: data}
+	block, err := ParseVorbisComment(meta)
+	if err != nil {
+		t.Fatalf("Failed to parse VorbisComment block: %s", err)
+	}
+	if block.Vendor != "reference libFLAC 1.4.3" {
+		t.Errorf("Unexpected vendor %q", block.Vendor)
+	}
+	if res := block.Get("artist"); !reflect.DeepEqual(res, []string{"Artist", "Other"}) {
+		t.Errorf("Unexpected ARTIST values %q", res)
+	}
+	if res := block.Marshal(); !bytes.Equal(res.Data, data) {
+		t.Error("VorbisComment block did not round-trip byte-exact")
+	}
+
+	if n := block.Remove("Artist"); n != 2 {
+		t.Errorf("Removed %d comments, want 2", n)
+	}
+	if err := block.Add("ALBUM=", "Album"); err != ErrorInvalidFieldName {
+		t.Errorf("Unexpected error for an invalid field name: %v", err)
+	}
+	if err := block.Add("ALBUM", "Album"); err != nil {
+		t.Fatalf("Failed to add comment: %s", err)
+	}
+	res := block.Marshal()
+	parsed, err := ParseVorbisComment(&res)
+	if err != nil {
+		t.Fatalf("Failed to parse marshaled block: %s", err)
+	}
+	if !reflect.DeepEqual(parsed.Comments, []string{"title=Title", "ALBUM=Album"}) {
+		t.Errorf("Unexpected comments %q", parsed.Comments)
+	}
+
+	if _, err := ParseVorbisComment(&MetaDataBlock{Type: Picture}); err != ErrorUnexpectedBlockType {
+		t.Errorf("Unexpected error for a Picture block: %v", err)
+	}
+	if _, err := ParseVorbisComment(&MetaDataBlock{Type: VorbisComment, Data: data[:10]}); err != ErrorMalformedVorbisComment {
+		t.Errorf("Unexpected error for a truncated block: %v", err)
+	}
+}
+
+func TestBlockEqual(t *testing.T) {
+	info := StreamInfoBlock{BlockSizeMin: 4096, BlockSizeMax: 4096, SampleRate: 44100, ChannelCount: 2, BitDepth: 16, SampleCount: 4096}
+	other := info
+	other.AudioMD5 = make([]byte, 16)
+	if !info.Equal(&other) {
+		t.Error("A missing MD5 signature should equal an all-zero one")
+	}
+	other.SampleCount++
+	if info.Equal(&other) {
+		t.Error("StreamInfo blocks with different sample counts are equal")
+	}
+
+	app := ApplicationBlock{ID: [4]byte{'t', 'e', 's', 't'}, Data: []byte{1, 2}}
+	if !app.Equal(&ApplicationBlock{ID: app.ID, Data: []byte{1, 2}}) || app.Equal(&ApplicationBlock{ID: app.ID, Data: []byte{1}}) {
+		t.Error("Unexpected Application block equality")
+	}
+
+	comments := VorbisCommentBlock{Vendor: "test", Comments: []string{"ARTIST=A", "TITLE=Title", "ARTIST=B"}}
+	if !comments.Equal(&VorbisCommentBlock{Vendor: "test", Comments: []string{"title=Title", "ARTIST=A", "artist=B"}}) {
+		t.Error("Reordered fields should be equal")
+	}
+	if comments.Equal(&VorbisCommentBlock{Vendor: "test", Comments: []string{"ARTIST=B", "TITLE=Title", "ARTIST=A"}}) {
+		t.Error("Reordered values of a field should not be equal")
+	}
+	if comments.Equal(&VorbisCommentBlock{Vendor: "other", Comments: comments.Comments}) {
+		t.Error("Blocks with different vendors are equal")
+	}
+}
+
+func TestPictureBlock(t *testing.T) {
+	picture := &PictureBlock{PictureType: PictureTypeFrontCover, MIME: "image/png", Description: "Cover", Width: 32, Height: 16, ColorDepth: 24, Data: []byte("\x89PNG")}
+	meta := picture.Marshal()
+	parsed, err := ParsePicture(&meta)
+	if err != nil {
+		t.Fatalf("Failed to parse Picture block: %s", err)
+	}
+	if !parsed.Equal(picture) {
+		t.Errorf("Unexpected Picture block %+v", parsed)
+	}
+
+	data := append(meta.Data, 0, 0)
+	parsed, err = ParsePicture(&MetaDataBlock{Type: Picture, Data: data})
+	if err != nil {
+		t.Fatalf("Failed to parse Picture block with trailing bytes: %s", err)
+	}
+	if res := parsed.Marshal(); !bytes.Equal(res.Data, data) {
+		t.Error("Picture block did not round-trip byte-exact")
+	}
+	parsed.Description = "Other"
+	if parsed.Equal(picture) {
+		t.Error("Pictures with different descriptions are equal")
+	}
+
+	if _, err := ParsePicture(&MetaDataBlock{Type: Picture, Data: data[:20]}); err != ErrorMalformedPicture {
+		t.Errorf("Unexpected error for a truncated block: %v", err)
+	}
+}
+
+func TestExportImportTags(t *testing.T) {
+	block := &VorbisCommentBlock{Vendor: "test", Comments: []string{"ARTIST=Artist", "title=Title", "COMMENT=a=b"}}
+	var exported bytes.Buffer
+	if err := block.ExportTags(&exported); err != nil {
+		t.Fatalf("Failed to export tags: %s", err)
+	}
+	if exported.String() != "ARTIST=Artist\ntitle=Title\nCOMMENT=a=b\n" {
+		t.Errorf("Unexpected exported tags %q", exported.String())
+	}
+
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: make([]byte, 34)}}}
+	if err := f.ImportTags(bytes.NewReader(exported.Bytes())); err != nil {
+		t.Fatalf("Failed to import tags: %s", err)
+	}
+	if err := f.ImportTags(strings.NewReader("ALBUM=Album")); err != nil {
+		t.Fatalf("Failed to import a last line without newline: %s", err)
+	}
+	var again bytes.Buffer
+	if err := f.ExportTags(&again); err != nil {
+		t.Fatalf("Failed to export tags: %s", err)
+	}
+	if again.String() != exported.String()+"ALBUM=Album\n" {
+		t.Errorf("Tags did not round-trip: %q", again.String())
+	}
+
+	if err := block.ImportTags(strings.NewReader("GENRE=Rock\nno separator\n")); err != ErrorMalformedTags {
+		t.Errorf("Unexpected error for a line without '=': %v", err)
+	}
+	if err := block.ImportTags(strings.NewReader("=value\n")); err != ErrorInvalidFieldName {
+		t.Errorf("Unexpected error for an empty field name: %v", err)
+	}
+	if len(block.Comments) != 3 {
+		t.Errorf("Failed imports appended comments: %q", block.Comments)
+	}
+}
+
+func TestSeekTableBlock(t *testing.T) {
+	table := &SeekTableBlock{Points: []SeekPoint{
+		{SampleNumber: 0, Offset: 0, FrameSamples: 4096},
+		{SampleNumber: 40960, Offset: 123456, FrameSamples: 4096},
+		{SampleNumber: SeekPointPlaceholder},
+	}}
+	meta := table.Marshal()
+	if len(meta.Data) != 3*seekPointSize {
+		t.Fatalf("Unexpected SeekTable block size %d", len(meta.Data))
+	}
+	parsed, err := ParseSeekTable(&meta)
+	if err != nil {
+		t.Fatalf("Failed to parse SeekTable block: %s", err)
+	}
+	if !parsed.Equal(table) {
+		t.Errorf("Unexpected seek points %+v", parsed.Points)
+	}
+	if parsed.Points[1].IsPlaceholder() || !parsed.Points[2].IsPlaceholder() {
+		t.Error("Unexpected placeholder detection")
+	}
+	if res := parsed.Marshal(); !bytes.Equal(res.Data, meta.Data) {
+		t.Error("SeekTable block did not round-trip byte-exact")
+	}
+
+	if _, err := ParseSeekTable(&MetaDataBlock{Type: SeekTable, Data: meta.Data[:20]}); err != ErrorMalformedSeekTable {
+		t.Errorf("Unexpected error for a truncated block: %v", err)
+	}
+}
+
+func TestTagCollator(t *testing.T) {
+	collator := NewTagCollator(language.English)
+	artists := []string{"Zoë Keating", "Ólafur Arnalds", "björk", "Beach House", "Olafur Eliasson"}
+	collator.Sort(artists)
+	if want := []string{"Beach House", "björk", "Ólafur Arnalds", "Olafur Eliasson", "Zoë Keating"}; !reflect.DeepEqual(artists, want) {
+		t.Errorf("Unexpected order %q", artists)
+	}
+	if collator.Compare("Ólafur", "Olafur") <= 0 {
+		t.Error("Diacritics should only break ties")
+	}
+	if !collator.Equal("Bjork", "Björk") || collator.Equal("Bjork", "Bjorn") {
+		t.Error("Unexpected loose equality")
+	}
+	if !collator.Contains("Ólafur Arnalds", "olafur") || collator.Contains("Ólafur Arnalds", "Eliasson") {
+		t.Error("Unexpected search result")
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	for in, want := range map[string]string{
+		"Björk":              "Bjork",
+		"Ólafur Arnalds":     "Olafur Arnalds",
+		"Straße – Live":      "Strasse - Live",
+		"Борис Гребенщиков":  "Boris Grebenshchikov",
+		"Μίκης Θεοδωράκης":   "Mikis Theodorakis",
+		"ﬁnal":               "final",
+		"坂本龍一":               "????",
+		"Plain ASCII Artist": "Plain ASCII Artist",
+	} {
+		if res := Transliterate(in); res != want {
+			t.Errorf("Transliterate(%q) = %q, want %q", in, res, want)
+		}
+	}
+
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: VorbisComment, Data: marshalVorbisComment("", []string{"ARTIST=Sigur Rós", "ALBUM=Takk...", "TITLE=Hoppípolla", "TITLESORT=Hoppipolla (sort)"})},
+	}}
+	if err := f.Transliterate(nil); err != nil {
+		t.Fatalf("Failed to transliterate: %s", err)
+	}
+	block, _ := ParseVorbisComment(f.Meta[1])
+	if !reflect.DeepEqual(block.Get("ARTISTSORT"), []string{"Sigur Ros"}) {
+		t.Errorf("Unexpected ARTISTSORT %q", block.Get("ARTISTSORT"))
+	}
+	if len(block.Get("ALBUMSORT")) != 0 {
+		t.Error("ASCII values should not be transliterated")
+	}
+	if !reflect.DeepEqual(block.Get("TITLESORT"), []string{"Hoppipolla (sort)"}) || !reflect.DeepEqual(block.Get("ARTIST"), []string{"Sigur Rós"}) {
+		t.Error("Existing values were modified")
+	}
+}
+
+func TestPaddingHelpers(t *testing.T) {
+	comment := &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("", nil)}
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: make([]byte, 34)}, NewPadding(100), comment, NewPadding(50)}}
+	if size := f.PaddingSize(); size != 150 {
+		t.Errorf("Unexpected padding size %d", size)
+	}
+
+	check := func(want int) {
+		t.Helper()
+		last := f.Meta[len(f.Meta)-1]
+		switch {
+		case want == 0 && (len(f.Meta) != 2 || last != comment):
+			t.Errorf("Padding was not removed: %d blocks", len(f.Meta))
+		case want > 0 && (len(f.Meta) != 3 || last.Type != Padding || last.Len() != want):
+			t.Errorf("Expected a single Padding block of %d bytes at the end, got %d blocks", want, len(f.Meta))
+		}
+	}
+	if err := f.EnsurePadding(100); err != nil {
+		t.Fatalf("Failed to ensure padding: %s", err)
+	}
+	check(150)
+	f.EnsurePadding(4096)
+	check(4096)
+	f.ShrinkPadding(8192)
+	check(4096)
+	f.ShrinkPadding(10)
+	check(10)
+	f.SetPadding(0)
+	check(0)
+	if err := f.SetPadding(MaxBlockSize + 1); err != ErrorBlockTooLarge {
+		t.Errorf("Unexpected error for an oversized padding: %v", err)
+	}
+}
+
+func TestGenerateSortTags(t *testing.T) {
+	block := &VorbisCommentBlock{Comments: []string{
+		"ARTIST=The Beatles", "ALBUM=Abbey Road", "TITLE=A Day in the Life",
+		"COMPOSER=Ludwig van Beethoven", "COMPOSER=Bach, Johann Sebastian", "ALBUMARTIST=Thelonious Monk", "ALBUMARTISTSORT=Monk",
+	}}
+	block.GenerateSortTags(nil)
+	for field, want := range map[string][]string{
+		"ARTISTSORT":      {"Beatles, The"},
+		"ALBUMSORT":       nil,
+		"TITLESORT":       {"Day in the Life, A"},
+		"COMPOSERSORT":    {"Beethoven, Ludwig van", "Bach, Johann Sebastian"},
+		"ALBUMARTISTSORT": {"Monk"},
+	} {
+		if res := block.Get(field); !reflect.DeepEqual(res, want) {
+			t.Errorf("Unexpected %s %q, want %q", field, res, want)
+		}
+	}
+
+	block.GenerateSortTags(&SortRules{Fields: map[string]string{"ALBUMARTIST": "ALBUMARTISTSORT"}, SurnameFirst: []string{"ALBUMARTIST"}, Overwrite: true})
+	if res := block.Get("ALBUMARTISTSORT"); !reflect.DeepEqual(res, []string{"Monk, Thelonious"}) {
+		t.Errorf("Unexpected overwritten ALBUMARTISTSORT %q", res)
+	}
+
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: VorbisComment, Data: marshalVorbisComment("", []string{"ARTIST=The Ólafur Trio"})},
+	}}
+	rules := DefaultSortRules
+	rules.ASCII = true
+	if err := f.GenerateSortTags(&rules); err != nil {
+		t.Fatalf("Failed to generate sort tags: %s", err)
+	}
+	parsed, _ := ParseVorbisComment(f.Meta[1])
+	if res := parsed.Get("ARTISTSORT"); !reflect.DeepEqual(res, []string{"Olafur Trio, The"}) {
+		t.Errorf("Unexpected ASCII ARTISTSORT %q", res)
+	}
+}
+
+func TestClassicalTags(t *testing.T) {
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: VorbisComment, Data: marshalVorbisComment("", []string{"TITLE=I. Allegro con brio", "work=Symphony No. 5", "MOVEMENTNUMBER=1/4", "CONDUCTOR=Carlos Kleiber"})},
+	}}
+	tags, err := f.ClassicalTags()
+	if err != nil {
+		t.Fatalf("Failed to read classical tags: %s", err)
+	}
+	if want := (ClassicalTags{Work: "Symphony No. 5", MovementNumber: 1, MovementTotal: 4, Conductor: "Carlos Kleiber"}); *tags != want {
+		t.Errorf("Unexpected classical tags %+v", tags)
+	}
+
+	tags.Movement = "Allegro con brio"
+	tags.Ensemble = "Wiener Philharmoniker"
+	if err := f.SetClassicalTags(tags); err != nil {
+		t.Fatalf("Failed to set classical tags: %s", err)
+	}
+	block, _ := ParseVorbisComment(f.Meta[1])
+	want := []string{"TITLE=I. Allegro con brio", "WORK=Symphony No. 5", "MOVEMENT=Allegro con brio", "MOVEMENTNUMBER=1", "MOVEMENTTOTAL=4",
+		"CONDUCTOR=Carlos Kleiber", "ENSEMBLE=Wiener Philharmoniker"}
+	if !reflect.DeepEqual(block.Comments, want) {
+		t.Errorf("Unexpected comments %q", block.Comments)
+	}
+
+	atoms := tags.ITunesAtoms()
+	if atoms["©wrk"] != "Symphony No. 5" || atoms["©mvi"] != "1" || atoms["©mvc"] != "4" || atoms["shwm"] != "1" || atoms["----:com.apple.iTunes:ENSEMBLE"] != "Wiener Philharmoniker" {
+		t.Errorf("Unexpected iTunes atoms %q", atoms)
+	}
+	if res := ClassicalTagsFromITunes(atoms); *res != *tags {
+		t.Errorf("iTunes atoms did not round-trip: %+v", res)
+	}
+
+	if err := f.SetClassicalTags(nil); err != nil {
+		t.Fatalf("Failed to remove classical tags: %s", err)
+	}
+	if block, _ := ParseVorbisComment(f.Meta[1]); !reflect.DeepEqual(block.Comments, []string{"TITLE=I. Allegro con brio"}) {
+		t.Errorf("Classical tags were not removed: %q", block.Comments)
+	}
+}
+
+func TestSetStreamInfo(t *testing.T) {
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 0, nil)}}}
+	info, err := f.GetStreamInfo()
+	if err != nil {
+		t.Fatalf("Failed to get stream info: %s", err)
+	}
+	info.SampleCount = 441000
+	info.AudioMD5 = bytes.Repeat([]byte{0xAB}, 16)
+	if err := f.SetStreamInfo(info); err != nil {
+		t.Fatalf("Failed to set stream info: %s", err)
+	}
+	updated, err := f.GetStreamInfo()
+	if err != nil {
+		t.Fatalf("Failed to get updated stream info: %s", err)
+	}
+	if !updated.Equal(info) {
+		t.Errorf("Unexpected stream info %+v", updated)
+	}
+	if meta := info.Marshal(); meta.Type != StreamInfo || !bytes.Equal(meta.Data, f.Meta[0].Data) {
+		t.Error("Marshal does not match the block written by SetStreamInfo")
+	}
+
+	info.ChannelCount = 9
+	if err := f.SetStreamInfo(info); !errors.Is(err, ErrorInvalidStreamInfo) {
+		t.Errorf("Unexpected error for an invalid channel count: %v", err)
+	}
+	if err := (&File{Meta: []*MetaDataBlock{NewPadding(10)}}).SetStreamInfo(updated); err != ErrorNoStreamInfo {
+		t.Errorf("Unexpected error without a StreamInfo block: %v", err)
+	}
+}
+
+func TestNormalizeCompilation(t *testing.T) {
+	album := func(comments ...[]string) []*File {
+		res := make([]*File, len(comments))
+		for i, c := range comments {
+			res[i] = &File{Meta: []*MetaDataBlock{
+				{Type: StreamInfo, Data: make([]byte, 34)},
+				{Type: VorbisComment, Data: marshalVorbisComment("", c)},
+			}}
+		}
+		return res
+	}
+	for _, c := range []struct {
+		name        string
+		tracks      []*File
+		albumArtist string
+		compilation bool
+	}{
+		{"same artist", album([]string{"ARTIST=Radiohead"}, []string{"ARTIST=radiohead", "COMPILATION=1"}), "Radiohead", false},
+		{"featured artists", album([]string{"ARTIST=Nas feat. Lauryn Hill", "ALBUMARTIST=Nas"}, []string{"ARTIST=Nas"}, []string{"ARTIST=Nas", "ALBUMARTIST=Nas"}), "Nas", false},
+		{"different artists", album([]string{"ARTIST=Air"}, []string{"ARTIST=Daft Punk"}), VariousArtists, true},
+		{"VA spelling", album([]string{"ARTIST=Air", "ALBUMARTIST=VA"}, []string{"ARTIST=Air", "ALBUMARTIST=Various"}), VariousArtists, true},
+	} {
+		albumArtist, compilation, err := NormalizeCompilation(c.tracks)
+		if err != nil {
+			t.Fatalf("%s: failed to normalize: %s", c.name, err)
+		}
+		if albumArtist != c.albumArtist || compilation != c.compilation {
+			t.Errorf("%s: got %q, %v, want %q, %v", c.name, albumArtist, compilation, c.albumArtist, c.compilation)
+		}
+		for i, track := range c.tracks {
+			block, _ := ParseVorbisComment(track.Meta[1])
+			if res := block.Get("ALBUMARTIST"); !reflect.DeepEqual(res, []string{c.albumArtist}) {
+				t.Errorf("%s: track %d has ALBUMARTIST %q", c.name, i, res)
+			}
+			if res := block.Get("COMPILATION"); (len(res) == 1 && res[0] == "1") != c.compilation || len(res) > 1 {
+				t.Errorf("%s: track %d has COMPILATION %q", c.name, i, res)
+			}
+		}
+	}
+}
+
+func TestWithBlockTypes(t *testing.T) {
+	frames := testFrame(0, 4096, 0, 0)
+	picture := &PictureBlock{PictureType: PictureTypeFrontCover, MIME: "image/png", Data: bytes.Repeat([]byte{7}, 1<<20)}
+	pictureMeta := picture.Marshal()
+	stream := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		&pictureMeta,
+		{Type: VorbisComment, Data: marshalVorbisComment("", []string{"TITLE=Title"})},
+	}, frames)
+
+	f, err := ParseBytes(bytes.NewReader(stream), WithBlockTypes(VorbisComment), WithBlockHashes())
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if f.Meta[1].Loaded() || f.Meta[1].Len() != len(pictureMeta.Data) {
+		t.Errorf("Picture block was read or has the wrong size %d", f.Meta[1].Len())
+	}
+	if !f.Meta[2].Loaded() {
+		t.Error("VorbisComment block was not read")
+	}
+	if rest, _ := io.ReadAll(f.Frames); !bytes.Equal(rest, frames) {
+		t.Error("Frames do not follow the skipped block")
+	}
+	if _, err := f.WriteTo(io.Discard); err != ErrorNoBlockSource {
+		t.Errorf("Expected ErrorNoBlockSource writing a skipped block, got %v", err)
+	}
+
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	os.WriteFile(fn, stream, 0o644)
+	f, err = ParseFile(fn, WithBlockTypes(VorbisComment))
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	defer f.Close()
+	if f.Meta[1].Loaded() {
+		t.Error("Picture block was read by ParseFile")
+	}
+	if err := f.Meta[1].Load(); err != nil || !bytes.Equal(f.Meta[1].Data, pictureMeta.Data) {
+		t.Errorf("Failed to load the skipped block from the file: %v", err)
+	}
+}
+
+func TestWithLazyBlocks(t *testing.T) {
+	fn, data := testLargeFLACFile(t, 1000)
+	picture := &PictureBlock{PictureType: PictureTypeFrontCover, MIME: "image/png", Data: bytes.Repeat([]byte{7}, 1<<20)}
+	f, _ := ParseBytes(bytes.NewReader(data))
+	pictureMeta := picture.Marshal()
+	f.Meta = append(f.Meta, &pictureMeta)
+	var stream bytes.Buffer
+	f.WriteTo(&stream)
+	os.WriteFile(fn, stream.Bytes(), 0o644)
+
+	var stats AllocStats
+	DebugAllocStats = func(s AllocStats) { stats = s }
+	defer func() { DebugAllocStats = nil }()
+	f, err := ParseFile(fn, WithLazyBlocks())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if stats.LargestBlock > 34 {
+		t.Errorf("Parsing read a block of %d bytes", stats.LargestBlock)
+	}
+	if !f.Meta[0].Loaded() || f.Meta[len(f.Meta)-1].Loaded() {
+		t.Error("Only StreamInfo should be loaded")
+	}
+	f.Meta = append(f.Meta, &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("", []string{"TITLE=Title"})})
+	out := filepath.Join(t.TempDir(), "out.flac")
+	if err := f.Save(out); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+
+	saved, err := ParseFile(out)
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %s", err)
+	}
+	defer saved.Close()
+	parsed, err := ParsePicture(saved.Meta[len(saved.Meta)-2])
+	if err != nil || !parsed.Equal(picture) {
+		t.Errorf("Lazy picture block was not saved: %v", err)
+	}
+}
+
+func TestCheckRelease(t *testing.T) {
+	tracks := make([]*File, 3)
+	for i, comments := range [][]string{
+		{"ALBUM=Kid A", "DATE=2000", "ALBUMARTIST=Radiohead"},
+		{"ALBUM=Kid A", "DATE=2000-10-02", "ALBUMARTIST=Radiohead"},
+		{"ALBUM=Kid A", "DATE=2000"},
+	} {
+		tracks[i] = &File{Meta: []*MetaDataBlock{
+			{Type: StreamInfo, Data: make([]byte, 34)},
+			{Type: VorbisComment, Data: marshalVorbisComment("", comments)},
+		}}
+	}
+	mismatches, err := CheckRelease(tracks)
+	if err != nil {
+		t.Fatalf("Failed to check release: %s", err)
+	}
+	if len(mismatches) != 2 || mismatches[0].Field != "ALBUMARTIST" || mismatches[1].Field != "DATE" {
+		t.Fatalf("Unexpected mismatches %+v", mismatches)
+	}
+	if res := mismatches[1].String(); res != `DATE: "2000" on tracks 1, 3; "2000-10-02" on track 2` {
+		t.Errorf("Unexpected description %q", res)
+	}
+	if res := mismatches[0].String(); res != `ALBUMARTIST: "Radiohead" on tracks 1, 2; missing on track 3` {
+		t.Errorf("Unexpected description %q", res)
+	}
+
+	if err := FixRelease(tracks, mismatches); err != nil {
+		t.Fatalf("Failed to fix release: %s", err)
+	}
+	if mismatches, _ := CheckRelease(tracks); len(mismatches) != 0 {
+		t.Errorf("Release still inconsistent: %+v", mismatches)
+	}
+	block, _ := ParseVorbisComment(tracks[1].Meta[1])
+	if !reflect.DeepEqual(block.Get("DATE"), []string{"2000"}) {
+		t.Errorf("Unexpected fixed DATE %q", block.Get("DATE"))
+	}
+}
+
+func TestRenamePolicy(t *testing.T) {
+	_, data := testLargeFLACFile(t, 100)
+	dir := t.TempDir()
+	write := func(name string, comments ...string) string {
+		f, _ := ParseBytes(bytes.NewReader(data))
+		f.Meta = append(f.Meta, &MetaDataBlock{Type: VorbisComment, Data: marshalVorbisComment("", comments)})
+		fn := filepath.Join(dir, name)
+		out, _ := os.Create(fn)
+		f.WriteTo(out)
+		out.Close()
+		return fn
+	}
+	first := write("a.flac", "ALBUMARTIST=AC/DC", "ALBUM=Back in Black", "TRACKNUMBER=1/10", "TITLE=Hells Bells")
+	second := write("b.flac", "ALBUMARTIST=AC/DC", "ALBUM=Back in Black", "TRACKNUMBER=1", "TITLE=Hells Bells")
+	third := write("c.flac", "ALBUM=CON", "TITLE=What?")
+
+	policy := &RenamePolicy{Template: "{ALBUMARTIST}/{ALBUM}/{TRACKNUMBER:2} {TITLE}.flac", Root: filepath.Join(dir, "library"), OS: "windows"}
+	plan, err := policy.Plan([]string{first, second, third})
+	if err != nil {
+		t.Fatalf("Failed to plan: %s", err)
+	}
+	album := filepath.Join(dir, "library", "AC_DC", "Back in Black")
+	want := []RenameStep{
+		{From: first, To: filepath.Join(album, "01 Hells Bells.flac")},
+		{From: second, To: filepath.Join(album, "01 Hells Bells (2).flac")},
+		{From: third, To: filepath.Join(dir, "library", "Unknown", "_CON", "Unknown What_.flac")},
+	}
+	if !reflect.DeepEqual(plan.Steps, want) {
+		t.Errorf("Unexpected plan %q", plan.Steps)
+	}
+
+	// the last step fails as its destination appears after planning, so the first moves are rolled back
+	os.MkdirAll(filepath.Dir(want[2].To), 0o755)
+	os.WriteFile(want[2].To, nil, 0o644)
+	if err := plan.Apply(); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Expected os.ErrExist, got %v", err)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("Move was not rolled back: %s", err)
+	}
+	if _, err := os.Stat(album); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Created directories were not removed")
+	}
+	os.Remove(want[2].To)
+	if err := plan.Apply(); err != nil {
+		t.Fatalf("Failed to apply plan: %s", err)
+	}
+	if _, err := os.Stat(want[1].To); err != nil {
+		t.Errorf("File was not moved: %s", err)
+	}
+
+	long := &RenamePolicy{Template: "{TITLE}.flac", Root: dir, MaxPath: len(dir) + 12}
+	if dest, err := long.expand(&VorbisCommentBlock{Comments: []string{"TITLE=A very long title"}}); err != nil || filepath.Base(dest) != "A very.flac" {
+		t.Errorf("Unexpected truncated path %q: %v", dest, err)
+	}
+	if _, err := (&RenamePolicy{Template: "{TITLE"}).expand(&VorbisCommentBlock{}); err != ErrorInvalidTemplate {
+		t.Errorf("Unexpected error for an unclosed field: %v", err)
+	}
+}
+
+func TestSaveSyncFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions not supported")
+	}
+	frames := testFrame(0, 4096, 1, 2)
+	meta := []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}
+	dir := t.TempDir()
+	for _, atomic := range []bool{false, true} {
+		fn := filepath.Join(dir, fmt.Sprintf("atomic-%v.flac", atomic))
+		opts := []SaveOption{WithSync(), WithFileMode(0o600)}
+		if atomic {
+			opts = append(opts, WithAtomic())
+		}
+		f := &File{Meta: meta, Frames: bytes.NewReader(frames)}
+		if err := f.Save(fn, opts...); err != nil {
+			t.Fatalf("Failed to save (atomic %v): %s", atomic, err)
+		}
+		if saved, err := os.ReadFile(fn); err != nil || !bytes.Equal(saved, testFLACStream(meta, frames)) {
+			t.Errorf("Unexpected saved file (atomic %v): %v", atomic, err)
+		}
+		if info, err := os.Stat(fn); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("Unexpected permissions (atomic %v): %v", atomic, err)
+		}
+
+		// an existing output keeps its permissions
+		os.Chmod(fn, 0o640)
+		f = &File{Meta: meta, Frames: bytes.NewReader(frames)}
+		if err := f.Save(fn, opts...); err != nil {
+			t.Fatalf("Failed to save over the output (atomic %v): %s", atomic, err)
+		}
+		if info, err := os.Stat(fn); err != nil || info.Mode().Perm() != 0o640 {
+			t.Errorf("Permissions were not kept (atomic %v): %v", atomic, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Temporary file left behind: %v", entries)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, frames)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+
+	ch := make(chan Event, 16)
+	f, err := ParseFile(fn, WithEvents(EventChannel(ch)))
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if err := f.ImportTags(strings.NewReader("TITLE=Events\n")); err != nil {
+		t.Fatalf("Failed to import tags: %s", err)
+	}
+	out := filepath.Join(dir, "out.flac")
+	if err := f.Save(out); err != nil {
+		t.Fatalf("Failed to save file: %s", err)
+	}
+	close(ch)
+
+	var kinds []EventKind
+	var events []Event
+	for e := range ch {
+		kinds = append(kinds, e.Kind)
+		events = append(events, e)
+		if e.Time.IsZero() || e.Err != nil {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	}
+	expected := []EventKind{EventParseStarted, EventParseFinished, EventBlockModified, EventSaveStarted, EventSaveMetadataWritten, EventSaveFinished}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("Unexpected events %v, expected %v", kinds, expected)
+	}
+	if events[0].Path != fn || events[3].Path != out || events[5].Path != out {
+		t.Errorf("Unexpected paths %q %q %q", events[0].Path, events[3].Path, events[5].Path)
+	}
+	if events[2].Block != 1 || events[2].BlockType != VorbisComment || events[0].Block != -1 {
+		t.Errorf("Unexpected block modified event %+v", events[2])
+	}
+	if info, err := os.Stat(out); err != nil || events[5].Bytes != info.Size() || events[4].Bytes != info.Size()-int64(len(frames)) {
+		t.Errorf("Unexpected byte counts %d %d: %v", events[4].Bytes, events[5].Bytes, err)
+	}
+
+	var failed []Event
+	if _, err := ParseBytes(bytes.NewReader([]byte("OggS")), WithEvents(func(e Event) { failed = append(failed, e) })); err == nil {
+		t.Fatal("Expected a parse error")
+	}
+	if len(failed) != 2 || failed[1].Kind != EventParseFinished || !errors.Is(failed[1].Err, ErrorNoFLACHeader) {
+		t.Errorf("Unexpected events of a failed parse %+v", failed)
+	}
+}
+
+type testMetrics struct {
+	parsed, saved int
+	failures      []string
+	written       int64
+	strategy      SaveStrategy
+}
+
+func (m *testMetrics) FileParsed(time.Duration) { m.parsed++ }
+func (m *testMetrics) ParseFailed(reason string, _ time.Duration) {
+	m.failures = append(m.failures, reason)
+}
+func (m *testMetrics) FileSaved(strategy SaveStrategy, written int64, _ time.Duration) {
+	m.saved++
+	m.strategy, m.written = strategy, written
+}
+func (m *testMetrics) SaveFailed(time.Duration) { m.failures = append(m.failures, "save") }
+
+func TestInstrumentation(t *testing.T) {
+	metrics := new(testMetrics)
+	Instrumentation = metrics
+	defer func() {
+		Instrumentation = nil
+	}()
+
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, frames)
+	f, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	var out bytes.Buffer
+	if err := f.SaveTo(&out); err != nil {
+		t.Fatalf("Failed to save stream: %s", err)
+	}
+	ParseBytes(bytes.NewReader([]byte("OggS")))
+	ParseBytes(bytes.NewReader(data[:20]))
+	ParseFile(filepath.Join(t.TempDir(), "missing.flac"))
+
+	if metrics.parsed != 1 || metrics.saved != 1 || metrics.written != int64(len(data)) || metrics.strategy != SaveRewrite {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+	if expected := []string{"no_flac_header", "truncated", "io"}; !reflect.DeepEqual(metrics.failures, expected) {
+		t.Errorf("Unexpected failure reasons %v, expected %v", metrics.failures, expected)
+	}
+}
+
+func TestSaveMetadataInPlace(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		NewPadding(100),
+	}, frames)
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if err := f.ImportTags(strings.NewReader("TITLE=Song\n")); err != nil {
+		t.Fatalf("Failed to import tags: %s", err)
+	}
+	var report SaveReport
+	if err := f.SaveMetadataInPlace(WithReport(&report)); err != nil {
+		t.Fatalf("Failed to save in place: %s", err)
+	}
+	saved, _ := os.ReadFile(fn)
+	if report.Strategy != SavePaddingPatch || len(saved) != len(data) || !bytes.HasSuffix(saved, frames) {
+		t.Errorf("Unexpected in place save %+v", report)
+	}
+	f, err = ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %s", err)
+	}
+	defer f.Close()
+	var tags strings.Builder
+	if err := f.ExportTags(&tags); err != nil || tags.String() != "TITLE=Song\n" {
+		t.Errorf("Unexpected tags %q: %v", tags.String(), err)
+	}
+
+	stream, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	if err := stream.SaveMetadataInPlace(); !errors.Is(err, ErrorNoSourceFile) {
+		t.Errorf("Expected ErrorNoSourceFile, got %v", err)
+	}
+}
+
+func TestSaveInPlaceMergesPadding(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		NewPadding(50),
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", nil)},
+		NewPadding(10),
+	}, frames)
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	// the comment grows by 34 bytes, more than the last Padding block holds
+	f.Meta[2].Data = marshalVorbisComment("go-flac", []string{"TITLE=" + strings.Repeat("x", 24)})
+	var report SaveReport
+	if err := f.SaveMetadataInPlace(WithReport(&report)); err != nil {
+		t.Fatalf("Failed to save in place: %s", err)
+	}
+	saved, _ := os.ReadFile(fn)
+	if report.Strategy != SavePaddingPatch || report.BytesMoved != 0 || len(saved) != len(data) || !bytes.HasSuffix(saved, frames) {
+		t.Errorf("Unexpected save %+v", report)
+	}
+
+	f, err = ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %s", err)
+	}
+	defer f.Close()
+	if len(f.Meta) != 3 || f.Meta[2].Type != Padding || f.Meta[2].Len() != 50+4+10-34 {
+		t.Errorf("Padding blocks not merged: %d blocks", len(f.Meta))
+	}
+}
+
+func TestWithRewindableFrames(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}, NewPadding(10)}, frames)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+
+	f, err := ParseFile(fn, WithRewindableFrames())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	defer f.Close()
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		if _, err := f.WriteTo(&buf); err != nil || !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("Unexpected output of write %d: %v", i, err)
+		}
+	}
+	out := filepath.Join(dir, "out.flac")
+	if err := f.Save(out); err != nil {
+		t.Fatalf("Failed to save file: %s", err)
+	}
+	if saved, err := os.ReadFile(out); err != nil || !bytes.Equal(saved, data) {
+		t.Errorf("Unexpected saved file: %v", err)
+	}
+	if n, err := f.WriteToN(io.Discard, int64(len(data))); err != nil || n != int64(len(data)) {
+		t.Errorf("Unexpected WriteToN after rewinding: %d %v", n, err)
+	}
+
+	r, err := ParseReaderAt(bytes.NewReader(data), int64(len(data)), WithRewindableFrames())
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	r.WriteTo(io.Discard)
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Unexpected output after rewinding: %v", err)
+	}
+
+	b, err := ParseBytes(bytes.NewReader(data), WithRewindableFrames())
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %s", err)
+	}
+	b.WriteTo(io.Discard)
+	if _, err := b.WriteTo(io.Discard); !errors.Is(err, ErrorAlreadyWritten) {
+		t.Errorf("Streams without a source cannot be rewound, got %v", err)
+	}
+}
+
+func TestLargeFile(t *testing.T) {
+	if testing.Short() || runtime.GOOS == "windows" {
+		t.Skip("Large sparse files skipped")
+	}
+	const size = 5<<30 + 123
+	meta := []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(96000, 2, 24, 1<<36-1, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", nil)},
+		NewPadding(100),
+	}
+	data := testFLACStream(meta, testFrame(0, 4096, 1, 2))
+	fn := filepath.Join(t.TempDir(), "large.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	// the audio past the first frame is a hole of zeros that takes no disk space
+	if err := os.Truncate(fn, size); err != nil {
+		t.Skipf("Sparse files not supported: %s", err)
+	}
+
+	f, err := ParseFile(fn, WithRewindableFrames())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	defer f.Close()
+	info, err := f.GetStreamInfo()
+	if err != nil || info.SampleCount != 1<<36-1 {
+		t.Errorf("Unexpected stream info %+v: %v", info, err)
+	}
+	if duration, err := f.Duration(); err != nil || duration != 715827882656250 {
+		t.Errorf("Unexpected duration %s: %v", duration, err)
+	}
+	metadataSize := f.metadataSize()
+	if n, err := f.WriteToN(io.Discard, metadataSize+1000); err != nil || n > metadataSize+1000 {
+		t.Errorf("Unexpected WriteToN: %d %v", n, err)
+	}
+	if frames, ok := f.Frames.(*rewoundFrames); !ok || frames.Size() != size-metadataSize {
+		t.Errorf("Unexpected frames after rewinding %T", f.Frames)
+	}
+
+	if err := f.ImportTags(strings.NewReader("TITLE=Concert\n")); err != nil {
+		t.Fatalf("Failed to import tags: %s", err)
+	}
+	var report SaveReport
+	if err := f.SaveMetadataInPlace(WithReport(&report)); err != nil {
+		t.Fatalf("Failed to save in place: %s", err)
+	}
+	if stat, err := os.Stat(fn); err != nil || stat.Size() != size || report.Strategy != SavePaddingPatch {
+		t.Errorf("Unexpected in place save %+v: %v", report, err)
+	}
+
+	src, err := os.Open(fn)
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer src.Close()
+	lazy, err := ParseReaderAt(src, size)
+	if err != nil {
+		t.Fatalf("Failed to parse large file lazily: %s", err)
+	}
+	if lazy.audioOffset != metadataSize || lazy.srcSize != size {
+		t.Errorf("Unexpected audio offset %d or size %d", lazy.audioOffset, lazy.srcSize)
+	}
+	if err := lazy.Meta[1].Load(); err != nil || !bytes.Contains(lazy.Meta[1].Data, []byte("TITLE=Concert")) {
+		t.Errorf("Unexpected saved comments: %v", err)
+	}
+}
+
+func TestScanFramesMisplacedMetadata(t *testing.T) {
+	var frames []byte
+	for i := uint64(0); i < 3; i++ {
+		frames = append(frames, testFrame(i, 4096, 1, 2)...)
+	}
+	meta := []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 3*4096, nil)}}
+	concatenated := append(append([]byte{}, frames...), testFLACStream(meta, frames)...)
+
+	count := 0
+	err := ScanFrames(bytes.NewReader(concatenated), func(*Frame) error {
+		count++
+		return nil
+	})
+	var misplaced *MisplacedMetadataError
+	if !errors.As(err, &misplaced) || !errors.Is(err, ErrorMetadataInFrames) || misplaced.Offset != int64(len(frames)) || misplaced.Marker != "fLaC" || count != 3 {
+		t.Errorf("Unexpected scan of concatenated frames: %d frames, %v", count, err)
+	}
+	if err := ScanFrames(bytes.NewReader(concatenated), func(*Frame) error { return nil }, WithSalvage(nil)); err != nil {
+		t.Errorf("Unexpected salvage error: %s", err)
+	}
+
+	// garbage without a marker is cut by decoding the last frame
+	garbage := append(append([]byte{}, frames...), 0x12, 0x34, 0x56, 0x78, 0x9A)
+	if err := ScanFrames(bytes.NewReader(garbage), func(*Frame) error { return nil }); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	for _, corrupt := range [][]byte{concatenated, garbage} {
+		f, err := ParseBytes(bytes.NewReader(testFLACStream(meta, corrupt)))
+		if err != nil {
+			t.Fatalf("Failed to parse stream: %s", err)
+		}
+		dropped, err := f.SalvageFrames()
+		if err != nil || dropped != int64(len(corrupt)-len(frames)) {
+			t.Errorf("Unexpected salvage: %d bytes dropped, %v", dropped, err)
+		}
+		var out bytes.Buffer
+		if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), testFLACStream(meta, frames)) {
+			t.Errorf("Unexpected salvaged stream: %v", err)
+		}
+	}
+
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, testFLACStream(meta, concatenated), 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err := ParseFile(fn, WithRewindableFrames())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	defer f.Close()
+	if _, err := f.SalvageFrames(); err != nil {
+		t.Fatalf("Failed to salvage file: %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), testFLACStream(meta, frames)) {
+			t.Errorf("Unexpected salvaged file on write %d: %v", i, err)
+		}
+	}
+}
+
+func TestParseSeeker(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Seek"})},
+	}, frames)
+
+	// a plain io.ReadSeeker positioned after unrelated data
+	prefixed := bytes.NewReader(append([]byte("junk"), data...))
+	prefixed.Seek(4, io.SeekStart)
+	for _, rs := range []io.ReadSeeker{bytes.NewReader(data), struct{ io.ReadSeeker }{prefixed}} {
+		f, err := ParseSeeker(rs, WithLazyBlocks())
+		if err != nil {
+			t.Fatalf("Failed to parse seeker: %s", err)
+		}
+		if f.Meta[1].Loaded() {
+			t.Error("VorbisComment block should be left in the source")
+		}
+		section, ok := f.Frames.(*io.SectionReader)
+		if !ok || section.Size() != int64(len(frames)) {
+			t.Fatalf("Frames is not a seekable view of the audio: %T", f.Frames)
+		}
+		for i := 0; i < 2; i++ {
+			var out bytes.Buffer
+			if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), data) {
+				t.Errorf("Unexpected output of write %d: %v", i, err)
+			}
+		}
+	}
+
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	src, err := os.Open(fn)
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer src.Close()
+	f, err := ParseSeeker(src)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	f.Meta[1].Data = marshalVorbisComment("go-flac", []string{"TITLE=Sought"})
+	var report SaveReport
+	if err := f.Save(fn, WithInPlace(), WithReport(&report)); err != nil || report.Strategy != SaveRewrite {
+		t.Fatalf("Failed to save over the source: %+v %v", report, err)
+	}
+	if saved, err := os.ReadFile(fn); err != nil || !bytes.HasSuffix(saved, frames) || !bytes.Contains(saved, []byte("TITLE=Sought")) {
+		t.Errorf("Unexpected saved file: %v", err)
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	var frames []byte
+	for i := 0; i < 3; i++ {
+		frames = append(frames, testFrame(uint64(i), 4096, int16(i), 1)...)
+	}
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 0, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Live"})},
+	}, frames)
+
+	// the recorder has not finished writing the metadata yet
+	fn := filepath.Join(t.TempDir(), "live.flac")
+	if err := os.WriteFile(fn, data[:20], 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	go func() {
+		out, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return
+		}
+		defer out.Close()
+		for pos := 20; pos < len(data); pos += 16 {
+			time.Sleep(10 * time.Millisecond)
+			end := pos + 16
+			if end > len(data) {
+				end = len(data)
+			}
+			out.Write(data[pos:end])
+		}
+	}()
+
+	f, err := FollowFile(fn, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to follow file: %s", err)
+	}
+	defer f.Close()
+	if tags, err := ParseVorbisComment(f.Meta[1]); err != nil || tags.Comments[0] != "TITLE=Live" {
+		t.Errorf("Unexpected tags: %v", err)
+	}
+	var count int
+	if err := ScanFrames(f.Frames, func(frame *Frame) error {
+		if !bytes.Equal(frame.Data, testFrame(uint64(count), 4096, int16(count), 1)) {
+			t.Errorf("Unexpected frame %d", count)
+		}
+		count++
+		return nil
+	}); err != nil || count != 3 {
+		t.Errorf("Expected 3 frames, got %d: %v", count, err)
+	}
+
+	r := NewFollowReader(bytes.NewReader(nil), 0)
+	if n, err := r.Read(make([]byte, 4)); n != 0 || err != io.EOF {
+		t.Errorf("Expected immediate EOF without idle timeout, got %d %v", n, err)
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Embedded"})},
+	}, frames)
+
+	fsys := fstest.MapFS{"music/test.flac": {Data: data}}
+	f, err := ParseFS(fsys, "music/test.flac", WithLazyBlocks(), WithRewindableFrames())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if f.Meta[1].Loaded() {
+		t.Error("VorbisComment block should be left in the file")
+	}
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("Unexpected output of write %d: %v", i, err)
+		}
+	}
+	f.Close()
+
+	// files of a zip archive cannot be read at an offset
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("test.flac")
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %s", err)
+	}
+	w.Write(data)
+	zw.Close()
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %s", err)
+	}
+	f, err = ParseFS(zr, "test.flac", WithLazyBlocks())
+	if err != nil {
+		t.Fatalf("Failed to parse zipped file: %s", err)
+	}
+	defer f.Close()
+	if !f.Meta[1].Loaded() {
+		t.Error("Blocks of a zipped file should be loaded")
+	}
+	var out bytes.Buffer
+	if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("Unexpected output: %v", err)
+	}
+
+	if _, err := ParseFS(fsys, "missing.flac"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+// cancelingReader cancels a context once it has returned after bytes
+type cancelingReader struct {
+	r      io.Reader
+	after  int
+	cancel context.CancelFunc
+}
+
+func (c *cancelingReader) Read(p []byte) (int, error) {
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	n, err := c.r.Read(p)
+	if c.after -= n; c.after <= 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestParseContext(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Context"})},
+	}, frames)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := ParseContext(ctx, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	cancel()
+	if read, err := io.ReadAll(f.Frames); err != nil || !bytes.Equal(read, frames) {
+		t.Errorf("Frames should be readable after the parse: %v", err)
+	}
+
+	// cancellation within the VorbisComment block
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := ParseContext(ctx, &cancelingReader{r: bytes.NewReader(data), after: 50, cancel: cancel}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if reason := ParseErrorReason(context.Canceled); reason != "canceled" {
+		t.Errorf("Unexpected reason: %s", reason)
+	}
+
+	// a stalled connection is interrupted
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go server.Write(data[:20])
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := ParseContext(ctx, client); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Parse was not interrupted promptly: %s", elapsed)
+	}
+
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	if f, err := ParseFileContext(context.Background(), fn, WithLazyBlocks()); err != nil || f.Meta[1].Loaded() {
+		t.Errorf("Failed to parse file: %v", err)
+	} else {
+		f.Close()
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := ParseFileContext(ctx, fn); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestEncoderStamp(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("reference libFLAC 1.4.3", []string{"ENCODER=flac 1.4.3", "TITLE=Stamp"})},
+	}, frames)
+	stamp := &EncoderStamp{Vendor: "go-flac", Encoder: "tagger 2.0"}
+
+	save := func(opts ...SaveOption) *VorbisCommentBlock {
+		t.Helper()
+		f, err := ParseBytes(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to parse: %s", err)
+		}
+		var out bytes.Buffer
+		if err := f.SaveTo(&out, opts...); err != nil {
+			t.Fatalf("Failed to save: %s", err)
+		}
+		saved, err := ParseBytes(&out)
+		if err != nil {
+			t.Fatalf("Failed to parse saved stream: %s", err)
+		}
+		block, err := ParseVorbisComment(saved.Meta[1])
+		if err != nil {
+			t.Fatalf("Failed to parse VorbisComment: %s", err)
+		}
+		return block
+	}
+
+	if block := save(); block.Vendor != "reference libFLAC 1.4.3" || !reflect.DeepEqual(block.Get("ENCODER"), []string{"flac 1.4.3"}) {
+		t.Errorf("The File should be untouched by default: %+v", block)
+	}
+	if block := save(WithEncoderStamp(stamp)); block.Vendor != "go-flac" || !reflect.DeepEqual(block.Get("ENCODER"), []string{"tagger 2.0"}) {
+		t.Errorf("Unexpected replaced stamp: %+v", block)
+	}
+	appended := *stamp
+	appended.Append = true
+	if block := save(WithEncoderStamp(&appended)); !reflect.DeepEqual(block.Get("ENCODER"), []string{"flac 1.4.3", "tagger 2.0"}) {
+		t.Errorf("Unexpected appended stamp: %+v", block)
+	}
+
+	DefaultEncoderStamp = stamp
+	defer func() { DefaultEncoderStamp = nil }()
+	if block := save(); block.Vendor != "go-flac" {
+		t.Errorf("DefaultEncoderStamp should apply: %+v", block)
+	}
+	if block := save(WithEncoderStamp(nil)); block.Vendor != "reference libFLAC 1.4.3" || len(block.Get("ENCODER")) != 1 {
+		t.Errorf("A nil stamp should touch nothing: %+v", block)
+	}
+
+	// an already stamped block is left as is
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}}
+	if err := f.applyStamp(stamp); err != nil || len(f.Meta) != 2 {
+		t.Fatalf("Failed to add a stamped VorbisComment block: %v", err)
+	}
+	var events []Event
+	f.SetEventSink(func(e Event) { events = append(events, e) })
+	if err := f.applyStamp(stamp); err != nil || len(events) != 0 {
+		t.Errorf("Stamping again should not modify the block: %v %d", err, len(events))
+	}
+}
+
+func TestID3v2Skipping(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Tagged"})},
+	}, frames)
+	// an ID3v2.4 tag of 200 bytes with a footer, then an ID3v2.3 tag of 10 bytes
+	tagged := append([]byte("ID3\x04\x00\x10\x00\x00\x01\x48"), make([]byte, 200+10)...)
+	tagged = append(tagged, "ID3\x03\x00\x00\x00\x00\x00\x00"...)
+	tagged = append(tagged, data...)
+
+	if _, err := ParseBytes(bytes.NewReader(tagged)); err != ErrorNoFLACHeader {
+		t.Errorf("Expected ErrorNoFLACHeader without skipping, got %v", err)
+	}
+	f, err := ParseBytes(bytes.NewReader(tagged), WithID3v2Skipping())
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if f.ID3v2Size() != 230 {
+		t.Errorf("Unexpected ID3v2 size: %d", f.ID3v2Size())
+	}
+	var out bytes.Buffer
+	if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("The ID3v2 tags should be dropped: %v", err)
+	}
+	if _, err := ParseBytes(bytes.NewReader(tagged[:100]), WithID3v2Skipping()); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	f, err = ParseReaderAt(bytes.NewReader(tagged), int64(len(tagged)), WithID3v2Skipping())
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if err := f.Meta[1].Load(); err != nil || !bytes.Contains(f.Meta[1].Data, []byte("TITLE=Tagged")) {
+		t.Errorf("Failed to load block after the ID3v2 tags: %v", err)
+	}
+
+	// an in-place save reuses the space of the tags
+	fn := filepath.Join(t.TempDir(), "tagged.flac")
+	if err := os.WriteFile(fn, tagged, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err = ParseFile(fn, WithID3v2Skipping(), WithLazyBlocks())
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if err := f.Meta[1].Load(); err != nil {
+		t.Fatalf("Failed to load block: %s", err)
+	}
+	f.Meta[1].Data = marshalVorbisComment("go-flac", []string{"TITLE=Retagged"})
+	var report SaveReport
+	if err := f.Save(fn, WithInPlace(), WithReport(&report)); err != nil || report.Strategy != SavePaddingPatch {
+		t.Fatalf("Failed to save in place: %+v %v", report, err)
+	}
+	f.Close()
+	saved, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse saved file: %s", err)
+	}
+	defer saved.Close()
+	if read, err := io.ReadAll(saved.Frames); err != nil || !bytes.Equal(read, frames) {
+		t.Errorf("Unexpected frames after the save: %v", err)
+	}
+}
+
+func TestPartialResults(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Recovered"})},
+		{Type: Picture, Data: make([]byte, 1000)},
+	}, frames)
+	// the stream is cut within the Picture block
+	truncated := data[:len(data)-len(frames)-500]
+
+	if f, err := ParseBytes(bytes.NewReader(truncated)); f != nil || err == nil {
+		t.Errorf("Expected no File without WithPartialResults, got %v", err)
+	}
+	f, err := ParseBytes(bytes.NewReader(truncated), WithPartialResults())
+	if err != io.ErrUnexpectedEOF || f == nil || len(f.Meta) != 2 {
+		t.Fatalf("Expected the blocks before the corruption, got %v", err)
+	}
+	if tags, err := ParseVorbisComment(f.Meta[1]); err != nil || tags.Get("TITLE")[0] != "Recovered" {
+		t.Errorf("Unexpected tags: %v", err)
+	}
+	if _, err := f.Frames.Read(make([]byte, 1)); err != io.ErrUnexpectedEOF {
+		t.Errorf("Frames should return the parse error, got %v", err)
+	}
+	if f, err := ParseBytes(bytes.NewReader(data[:20]), WithPartialResults()); f != nil || err == nil {
+		t.Errorf("Expected no File without a complete block, got %v", err)
+	}
+
+	// garbage instead of audio frames
+	corrupted := append(data[:len(data)-len(frames):len(data)-len(frames)], "garbage"...)
+	if f, err := ParseReaderAt(bytes.NewReader(corrupted), int64(len(corrupted)), WithPartialResults()); err != ErrorNoSyncCode || f == nil || len(f.Meta) != 3 {
+		t.Errorf("Expected the whole metadata, got %v", err)
+	}
+
+	fn := filepath.Join(t.TempDir(), "truncated.flac")
+	if err := os.WriteFile(fn, truncated, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err = ParseFile(fn, WithPartialResults(), WithLazyBlocks())
+	if err != io.ErrUnexpectedEOF || f == nil || len(f.Meta) != 2 {
+		t.Fatalf("Expected the blocks before the corruption, got %v", err)
+	}
+	if !f.Meta[1].Loaded() || !bytes.Contains(f.Meta[1].Data, []byte("TITLE=Recovered")) {
+		t.Error("Blocks left in the closed file should be loaded")
+	}
+}
+
+func TestBeforeMarshal(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 0, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Hooked"})},
+	}, frames)
+
+	var order []string
+	RegisterBeforeMarshal(func(f *File, block *MetaDataBlock) error {
+		order = append(order, "registered "+strconv.Itoa(int(block.Type)))
+		return nil
+	})
+	defer func() { marshalHooks = nil }()
+	// a hook deriving the sample count of StreamInfo from the frames
+	sampleCount := func(f *File, block *MetaDataBlock) error {
+		order = append(order, "option "+strconv.Itoa(int(block.Type)))
+		if block.Type != StreamInfo {
+			return nil
+		}
+		info, err := f.GetStreamInfo()
+		if err != nil {
+			return err
+		}
+		info.SampleCount = 4096
+		return f.SetStreamInfo(info)
+	}
+
+	f, err := ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	var out bytes.Buffer
+	if err := f.SaveTo(&out, WithBeforeMarshal(sampleCount)); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	if expected := []string{"registered 0", "registered 4", "option 0", "option 4"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Unexpected hook calls: %v", order)
+	}
+	saved, err := ParseBytes(&out)
+	if err != nil {
+		t.Fatalf("Failed to parse saved stream: %s", err)
+	}
+	if info, err := saved.GetStreamInfo(); err != nil || info.SampleCount != 4096 {
+		t.Errorf("The hook should update StreamInfo: %v", err)
+	}
+
+	failure := errors.New("hook failed")
+	f, err = ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	fn := filepath.Join(t.TempDir(), "hooked.flac")
+	if err := f.Save(fn, WithBeforeMarshal(func(*File, *MetaDataBlock) error { return failure })); err != failure {
+		t.Errorf("Expected the hook error, got %v", err)
+	}
+	if _, err := os.Stat(fn); !os.IsNotExist(err) {
+		t.Errorf("A failed hook should not create the output: %v", err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	f := &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 44100*90+22050, nil)}}}
+	if duration, err := f.Duration(); err != nil || duration != 90500*time.Millisecond {
+		t.Errorf("Unexpected duration %s: %v", duration, err)
+	}
+	f.Meta[0].Data = testStreamInfoData(44100, 2, 16, 0, nil)
+	if _, err := f.Duration(); err != ErrorUnknownDuration {
+		t.Errorf("Expected ErrorUnknownDuration for a live recording, got %v", err)
+	}
+	f.Meta[0].Type = Padding
+	if _, err := f.Duration(); err != ErrorNoStreamInfo {
+		t.Errorf("Expected ErrorNoStreamInfo, got %v", err)
+	}
+}
+
+func TestBitrate(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 44100, nil)}}, frames)
+	f, err := ParseReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if size, exact, err := f.AudioSize(); err != nil || !exact || size != int64(len(frames)) {
+		t.Errorf("Unexpected audio size %d %v: %v", size, exact, err)
+	}
+	if bitrate, exact, err := f.Bitrate(); err != nil || !exact || bitrate != int64(len(frames))*8 {
+		t.Errorf("Unexpected bitrate %d %v: %v", bitrate, exact, err)
+	}
+
+	f = &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 88200, nil)}}, Frames: bytes.NewReader(make([]byte, 1000))}
+	if bitrate, exact, err := f.Bitrate(); err != nil || !exact || bitrate != 4000 {
+		t.Errorf("Unexpected bitrate %d %v: %v", bitrate, exact, err)
+	}
+
+	// estimated from the frame sizes of StreamInfo
+	info := StreamInfoBlock{BlockSizeMin: 4096, BlockSizeMax: 4096, FrameSizeMin: 100, FrameSizeMax: 300, SampleRate: 44100, ChannelCount: 2, BitDepth: 16, SampleCount: 4096*10 - 100}
+	f = &File{Meta: []*MetaDataBlock{{Type: StreamInfo, Data: info.encode()}}, Frames: io.MultiReader()}
+	if size, exact, err := f.AudioSize(); err != nil || exact || size != 2000 {
+		t.Errorf("Unexpected estimated audio size %d %v: %v", size, exact, err)
+	}
+	f.Meta[0].Data = testStreamInfoData(44100, 2, 16, 44100, nil)
+	if _, _, err := f.Bitrate(); err != ErrorUnknownAudioSize {
+		t.Errorf("Expected ErrorUnknownAudioSize, got %v", err)
+	}
+	f.Meta[0].Data = testStreamInfoData(44100, 2, 16, 0, nil)
+	if _, _, err := f.Bitrate(); err != ErrorUnknownDuration {
+		t.Errorf("Expected ErrorUnknownDuration, got %v", err)
+	}
+}
+
+// xorReader applies a repeating XOR key to the data it reads
+type xorReader struct {
+	r   io.Reader
+	key byte
+}
+
+func (c *xorReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= c.key
+	}
+	return n, err
+}
+
+func TestRegisterWrapper(t *testing.T) {
+	frames := testFrame(0, 4096, 1, 2)
+	data := testFLACStream([]*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Wrapped"})},
+	}, frames)
+	wrapped := []byte("XFLC")
+	for _, b := range data {
+		wrapped = append(wrapped, b^0x5A)
+	}
+
+	if _, err := ParseBytes(bytes.NewReader(wrapped)); err != ErrorNoFLACHeader {
+		t.Errorf("Expected ErrorNoFLACHeader without a registered wrapper, got %v", err)
+	}
+	RegisterWrapper("XFLC", func(r io.Reader) (io.Reader, error) {
+		if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+			return nil, err
+		}
+		return &xorReader{r: r, key: 0x5A}, nil
+	})
+	defer func() { wrappers = nil }()
+
+	f, err := ParseBytes(bytes.NewReader(wrapped))
+	if err != nil {
+		t.Fatalf("Failed to parse wrapped stream: %s", err)
+	}
+	var out bytes.Buffer
+	if _, err := f.WriteTo(&out); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("Unexpected extracted stream: %v", err)
+	}
+	if f, err := ParseBytes(bytes.NewReader(data)); err != nil || f.wrapped {
+		t.Errorf("Plain streams should parse as before: %v", err)
+	}
+	// ParseMetadata still leaves the stream of the caller at the first frame
+	r := bytes.NewReader(data)
+	if _, err := ParseMetadata(r); err != nil {
+		t.Fatalf("Failed to parse metadata: %s", err)
+	}
+	if rest, _ := io.ReadAll(r); !bytes.Equal(rest, frames) {
+		t.Errorf("The stream should be left at the first frame, %d bytes left instead of %d", len(rest), len(frames))
+	}
+
+	fn := filepath.Join(t.TempDir(), "wrapped.flac")
+	if err := os.WriteFile(fn, wrapped, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err = ParseFile(fn, WithLazyBlocks())
+	if err != nil {
+		t.Fatalf("Failed to parse wrapped file: %s", err)
+	}
+	if !f.Meta[1].Loaded() {
+		t.Error("Blocks of a wrapped file should be read")
+	}
+	if f.sourceFile() == nil {
+		t.Error("The wrapped source should be known, so saves over it are detected")
+	}
+	var report SaveReport
+	if err := f.Save(fn, WithInPlace(), WithReport(&report)); err != nil || report.Strategy != SaveRewrite {
+		t.Fatalf("Failed to save over the wrapped source: %+v %v", report, err)
+	}
+	if saved, err := os.ReadFile(fn); err != nil || !bytes.Equal(saved, data) {
+		t.Errorf("The saved file should be plain FLAC: %v", err)
+	}
+}
+
+func TestMaxBlocks(t *testing.T) {
+	meta := []*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}
+	for i := 0; i < DefaultMaxBlocks; i++ {
+		meta = append(meta, &MetaDataBlock{Type: Padding, Data: []byte{}})
+	}
+	data := testFLACStream(meta, testFrame(0, 4096, 1, 2))
+
+	_, err := ParseBytes(bytes.NewReader(data))
+	var countErr *BlockCountError
+	if !errors.As(err, &countErr) || countErr.Limit != DefaultMaxBlocks || !errors.Is(err, ErrorTooManyBlocks) {
+		t.Errorf("Expected a BlockCountError, got %v", err)
+	}
+	if reason := ParseErrorReason(err); reason != "too_many_blocks" {
+		t.Errorf("Unexpected reason: %s", reason)
+	}
+	if _, err := ParseReaderAt(bytes.NewReader(data), int64(len(data)), WithMaxBlocks(10)); !errors.As(err, &countErr) || countErr.Limit != 10 {
+		t.Errorf("Expected a BlockCountError with limit 10, got %v", err)
+	}
+	if f, err := ParseBytes(bytes.NewReader(data), WithMaxBlocks(0)); err != nil || len(f.Meta) != DefaultMaxBlocks+1 {
+		t.Errorf("Failed to parse without limit: %v", err)
+	}
+	if f, err := ParseBytes(bytes.NewReader(data), WithMaxBlocks(DefaultMaxBlocks+1)); err != nil || len(f.Meta) != DefaultMaxBlocks+1 {
+		t.Errorf("Failed to parse within the limit: %v", err)
+	}
+}
+
+func TestVerifyAudioMD5(t *testing.T) {
+	samples := make([]int32, 10000)
+	for i := range samples {
+		samples[i] = int32(8000 * math.Sin(float64(i)/9))
+	}
+	fn := filepath.Join(t.TempDir(), "encoded.flac")
+	out, err := os.Create(fn)
+	if err != nil {
+		t.Fatalf("Failed to create output: %s", err)
+	}
+	opts, _ := CompressionLevel(5)
+	enc, err := NewEncoder(out, StreamInfoBlock{SampleRate: 44100, ChannelCount: 1, BitDepth: 16}, opts)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %s", err)
+	}
+	if err := enc.Write([][]int32{samples}); err != nil {
+		t.Fatalf("Failed to encode: %s", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Failed to close encoder: %s", err)
+	}
+	out.Close()
+
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	defer f.Close()
+	if err := f.VerifyAudioMD5(); err != nil {
+		t.Errorf("Failed to verify: %s", err)
+	}
+	if _, err := f.WriteTo(io.Discard); err != nil {
+		t.Errorf("A verified file should still be writable: %s", err)
+	}
+
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to read file: %s", err)
+	}
+	f, err = ParseBytes(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	info, _ := f.GetStreamInfo()
+	expected := info.AudioMD5
+	info.AudioMD5 = make([]byte, 16)
+	info.AudioMD5[0] = 1
+	f.SetStreamInfo(info)
+	var md5Err *AudioMD5Error
+	if err := f.VerifyAudioMD5(); !errors.As(err, &md5Err) || !errors.Is(err, ErrorAudioMD5Mismatch) || !bytes.Equal(md5Err.Actual, expected) {
+		t.Errorf("Expected an AudioMD5Error, got %v", err)
+	}
+	info.AudioMD5 = nil
+	f.SetStreamInfo(info)
+	if err := f.VerifyAudioMD5(); err != ErrorUnknownAudioMD5 {
+		t.Errorf("Expected ErrorUnknownAudioMD5, got %v", err)
+	}
+}
+
+func TestScanVorbisComment(t *testing.T) {
+	lyrics := strings.Repeat("la ", 1<<20)
+	data := marshalVorbisComment("vendor", []string{"title=Song", "LYRICS=" + lyrics, "Artist=Band", "noseparator", strings.Repeat("X", 2000) + "=long"})
+	meta := []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)},
+		{Type: VorbisComment, Data: data},
+	}
+	stream := testFLACStream(meta, testFrame(0, 4096, 1, 2))
+
+	var fields []string
+	collect := func(name, value string) error {
+		fields = append(fields, name+"="+value)
+		return nil
+	}
+	vendor, err := ScanVorbisComment(bytes.NewReader(data), func(name string) bool { return name != "LYRICS" }, collect)
+	if err != nil || vendor != "vendor" {
+		t.Fatalf("Failed to scan: %q %v", vendor, err)
+	}
+	if expected := []string{"TITLE=Song", "ARTIST=Band", "NOSEPARATOR="}; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Unexpected fields: %q", fields)
+	}
+	fields = nil
+	if _, err := ScanVorbisComment(bytes.NewReader(data), nil, collect); err != nil || len(fields) != 4 || fields[1] != "LYRICS="+lyrics {
+		t.Errorf("A nil want should accept every comment: %v", err)
+	}
+	stop := errors.New("stop")
+	if _, err := ScanVorbisComment(bytes.NewReader(data), nil, func(string, string) error { return stop }); err != stop {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+	for _, size := range []int{2, 12, len(data) / 2, len(data) - 1} {
+		if _, err := ScanVorbisComment(bytes.NewReader(data[:size]), nil, collect); err != ErrorMalformedVorbisComment {
+			t.Errorf("Expected ErrorMalformedVorbisComment for %d bytes, got %v", size, err)
+		}
+	}
+
+	fn := filepath.Join(t.TempDir(), "lyrics.flac")
+	if err := os.WriteFile(fn, stream, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err := ParseFile(fn, WithLazyBlocks())
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	defer f.Close()
+	var title string
+	if _, err := f.ScanVorbisComment(func(name string) bool { return name == "TITLE" }, func(_, value string) error {
+		title = value
+		return nil
+	}); err != nil || title != "Song" {
+		t.Errorf("Unexpected title %q: %v", title, err)
+	}
+	if f.Meta[1].Loaded() {
+		t.Error("Scanning should not load the block")
+	}
+	if vendor, err := (&File{Meta: meta[:1]}).ScanVorbisComment(nil, collect); err != nil || vendor != "" {
+		t.Errorf("A File without VorbisComment should have nothing to scan: %v", err)
+	}
+}
+
+func TestLazyBlockHelpers(t *testing.T) {
+	md5 := bytes.Repeat([]byte{1}, 16)
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, md5)},
+		{Type: VorbisComment, Data: testVorbisCommentData("go-flac", "TITLE=Song", "SERATO_MARKERS=data")},
+	}}
+	chapters := []Chapter{{Start: 1001 * time.Millisecond, Title: "Intro", Image: []byte{0xFF, 0xD8}, ImageMIME: "image/jpeg"}}
+	transcript := Transcript{MIME: TranscriptWebVTT, Language: "en", Data: []byte("WEBVTT\n")}
+	if err := f.SetChapters(chapters); err != nil {
+		t.Fatalf("Failed to set chapters: %s", err)
+	}
+	if err := f.SetTranscript(transcript); err != nil {
+		t.Fatalf("Failed to set transcript: %s", err)
+	}
+	if err := f.SetProvenance(ProvenanceC2PA, []byte("manifest")); err != nil {
+		t.Fatalf("Failed to set provenance: %s", err)
+	}
+	f.Frames = bytes.NewReader(testFrame(0, 4096, 1, 2))
+	fn := filepath.Join(t.TempDir(), "lazy.flac")
+	out, err := os.Create(fn)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %s", err)
+	}
+	if _, err := f.WriteTo(out); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	out.Close()
+
+	parse := func() *File {
+		t.Helper()
+		f, err := ParseFile(fn, WithLazyBlocks())
+		if err != nil {
+			t.Fatalf("Failed to parse: %s", err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	if res, err := parse().Chapters(); err != nil || !reflect.DeepEqual(res, chapters) {
+		t.Errorf("Unexpected chapters of a lazily parsed file %v: %v", res, err)
+	}
+	if res := parse().DJMetadata(); len(res) != 1 || res[0].Field != "SERATO_MARKERS" {
+		t.Errorf("Unexpected DJ metadata of a lazily parsed file: %+v", res)
+	}
+	if res, err := parse().Transcripts(); err != nil || !reflect.DeepEqual(res, []Transcript{transcript}) {
+		t.Errorf("Unexpected transcripts of a lazily parsed file %v: %v", res, err)
+	}
+	if res, err := parse().Provenance(); err != nil || string(res.Manifest) != "manifest" {
+		t.Errorf("Unexpected provenance of a lazily parsed file: %v", err)
+	}
+
+	lazy := parse()
+	if err := lazy.SetClassicalTags(&ClassicalTags{Work: "Symphony"}); err != nil {
+		t.Fatalf("Failed to edit the comments of a lazily parsed file: %s", err)
+	}
+	if err := lazy.Scrub(ScrubPolicy{KeepFields: []string{"TITLE", "WORK"}}); err != nil {
+		t.Fatalf("Failed to scrub a lazily parsed file: %s", err)
+	}
+	block, err := ParseVorbisComment(lazy.Meta[lazy.vorbisCommentIndex()])
+	if err != nil || !reflect.DeepEqual(block.Comments, []string{"TITLE=Song", "WORK=Symphony"}) {
+		t.Errorf("Unexpected comments %v: %v", block, err)
+	}
+}
+
+func TestRemovalEvents(t *testing.T) {
+	type change struct {
+		kind      EventKind
+		block     int
+		blockType BlockType
+	}
+	var changes []change
+	sink := func(e Event) { changes = append(changes, change{e.Kind, e.Block, e.BlockType}) }
+	expect := func(name string, want ...change) {
+		t.Helper()
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("%s: unexpected events %+v", name, changes)
+		}
+		changes = nil
+	}
+
+	f := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: VorbisComment, Data: marshalVorbisComment("go-flac", []string{"TITLE=Song", "COMMENT=private"})},
+		{Type: Picture, Data: marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/png"}, []byte{1})},
+		{Type: CueSheet, Data: make([]byte, 396)},
+	}}
+	f.SetEventSink(sink)
+	if err := f.Scrub(ScrubPolicy{RemoveFields: []string{"COMMENT"}, RemovePictures: true}); err != nil {
+		t.Fatalf("Failed to scrub: %s", err)
+	}
+	expect("Scrub", change{EventBlockModified, 1, VorbisComment}, change{EventBlockRemoved, 2, Picture})
+
+	src := &File{Meta: []*MetaDataBlock{
+		{Type: StreamInfo, Data: make([]byte, 34)},
+		{Type: Picture, Data: marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/jpeg"}, []byte{2})},
+	}}
+	f.Meta = append(f.Meta, &MetaDataBlock{Type: Picture, Data: marshalPicture(pictureHeader{pictureType: PictureTypeFrontCover, mime: "image/png"}, []byte{3})})
+	if err := CopyPictures(f, src); err != nil {
+		t.Fatalf("Failed to copy pictures: %s", err)
+	}
+	expect("CopyPictures", change{EventBlockRemoved, 3, Picture}, change{EventBlockModified, 3, Picture})
+
+	if err := f.SetTranscript(Transcript{MIME: TranscriptWebVTT, Language: "en", Data: []byte("WEBVTT")}); err != nil {
+		t.Fatalf("Failed to set transcript: %s", err)
+	}
+	changes = nil
+	f.RemoveTranscript(TranscriptWebVTT, "en")
+	expect("RemoveTranscript", change{EventBlockRemoved, 4, Application})
+	f.RemoveTranscript(TranscriptWebVTT, "en")
+	expect("RemoveTranscript again")
+
+	f.Meta = append(f.Meta, &MetaDataBlock{Type: Padding, Data: make([]byte, 10)}, &MetaDataBlock{Type: Padding, Data: make([]byte, 20)})
+	if err := f.SetPadding(100); err != nil {
+		t.Fatalf("Failed to set padding: %s", err)
+	}
+	expect("SetPadding", change{EventBlockRemoved, 4, Padding}, change{EventBlockRemoved, 5, Padding}, change{EventBlockModified, 4, Padding})
+
+	if err := f.SetChapters([]Chapter{{Title: "Intro", Image: []byte{1}, ImageMIME: "image/png"}}); err != nil {
+		t.Fatalf("Failed to set chapters: %s", err)
+	}
+	expect("SetChapters", change{EventBlockModified, 1, VorbisComment}, change{EventBlockModified, 5, Picture})
+	if err := f.SetChapters(nil); err != nil {
+		t.Fatalf("Failed to remove chapters: %s", err)
+	}
+	expect("SetChapters without chapters", change{EventBlockModified, 1, VorbisComment}, change{EventBlockRemoved, 5, Picture})
+}
@@ -1,43 +1,85 @@
 package flac
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 )
 
 // File represents a handler of FLAC file
 type File struct {
 	Meta   []*MetaDataBlock
 	Frames io.Reader
+
+	// audioOffset is the offset of the first audio frame in the parsed stream, 0 if the File was not parsed
+	audioOffset int64
+	// src is the parsed stream of srcSize bytes when it supports random access, nil otherwise
+	src     io.ReaderAt
+	srcSize int64
+	// audioEnd is the offset in src where SalvageFrames cut the audio frames, 0 if they extend to the end of src
+	audioEnd int64
+	// events receives the block modifications and saves of the File, nil if they are not reported
+	events EventSink
+	// rewindable reports that WithRewindableFrames was given
+	rewindable bool
+	// id3v2Size is the size of the ID3v2 tags skipped before the "fLaC" marker
+	id3v2Size int64
+	// wrapped reports that the stream was extracted from a vendor wrapper, so its offsets do not refer to the parsed source
+	wrapped bool
 }
 
 // Marshal encodes all meta tags and returns the content of the resulting whole FLAC file
-// If Frames is not nil, it will be written to the output, and then the File will be closed, further calls to WriteTo will return ErrorAlreadyWritten
+// If Frames is not nil, it will be written to the output, and then the File will be closed, further calls to WriteTo will return ErrorAlreadyWritten,
+// unless the File was parsed with WithRewindableFrames
 func (c *File) WriteTo(w io.Writer) (int64, error) {
+	return c.writeTo(w, nil)
+}
+
+// writeTo implements WriteTo, and Save and SaveTo if cfg is not nil
+func (c *File) writeTo(out io.Writer, cfg *saveConfig) (int64, error) {
+	var audit *SaveAudit
+	if cfg != nil {
+		audit = cfg.audit
+	}
+	w := timeoutWriter{out}
 	nInt, err := w.Write([]byte("fLaC"))
 	n := int64(nInt)
 	if err != nil {
 		return n, err
 	}
+	var buf []byte
 	for i, meta := range c.Meta {
 		last := i == len(c.Meta)-1
-		n2, err := w.Write(meta.Marshal(last))
+		n2, err := meta.writeTo(w, last, &buf)
 		if err != nil {
-			return n + int64(n2), err
+			return n + n2, err
 		}
-		n += int64(n2)
+		n += n2
+	}
+	if audit != nil {
+		audit.start(c, n)
+	}
+	if cfg != nil {
+		c.emit(Event{Kind: EventSaveMetadataWritten, Path: cfg.path, Bytes: n})
 	}
 	if c.Frames != nil {
-		defer func() {
-			c.Frames = &ErrorReader{err: ErrorAlreadyWritten}
-		}()
-		defer c.Close()
-		n2, err := io.Copy(w, c.Frames)
+		defer c.framesWritten()
+		n2, err := c.copyFrames(out, audit)
+		if audit != nil {
+			audit.finish(n2)
+		}
 		if err != nil {
 			return n + n2, err
 		}
 		n += n2
+	} else if audit != nil {
+		audit.finish(0)
 	}
 	return n, nil
 }
@@ -47,14 +89,62 @@ func (c *File) WriteTo(w io.Writer) (int64, error) {
 // This is commonly caused by attempting to save the file to the same location as the input file.
 // The only information this library have is an io.Reader so it is impossible to reliably detect such cases.
 // Thus caller should implement logic to prevent such cases.
-func (c *File) Save(fn string) error {
-	f, err := os.Create(fn)
+func (c *File) Save(fn string, opts ...SaveOption) error {
+	cfg := newSaveConfig(opts)
+	cfg.path = fn
+	start := time.Now()
+	c.emit(Event{Kind: EventSaveStarted, Path: fn})
+	err := c.prepareSave(cfg)
+	if err == nil {
+		err = c.save(fn, cfg, start)
+	}
+	recordSave(cfg, time.Since(start), err)
+	c.emit(Event{Kind: EventSaveFinished, Path: fn, Bytes: cfg.written, Err: err})
+	return err
+}
+
+// prepareSave applies the encoder stamp and the marshal hooks of a save to the File
+func (c *File) prepareSave(cfg *saveConfig) error {
+	if err := c.applyStamp(cfg.encoderStamp()); err != nil {
+		return err
+	}
+	return c.beforeMarshal(cfg)
+}
+
+// save implements Save once its start is reported
+func (c *File) save(fn string, cfg *saveConfig, start time.Time) error {
+	if cfg.lock {
+		unlock, err := lockFile(fn, cfg.lockTimeout)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	if cfg.backup != "" {
+		if err := backupFile(fn, cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.inPlace {
+		if source := c.sourceFile(); source != nil {
+			if same, err := sameFile(source, fn); err != nil {
+				return err
+			} else if same {
+				return c.saveOverSource(fn, cfg, start)
+			}
+		}
+	}
+	if cfg.atomic {
+		return c.saveAtomic(fn, cfg, start)
+	}
+
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_TRUNC, cfg.fileMode())
 	if err != nil {
 		return fmt.Errorf("failed to create FLAC output file: %w", err)
 	}
 	defer f.Close()
 
-	if fileIn := isFileBacked(c.Frames); fileIn != nil {
+	if fileIn := c.sourceFile(); fileIn != nil {
 		fileInInfo, err := fileIn.Stat()
 		if err != nil {
 			return fmt.Errorf("failed to get input file info: %w", err)
@@ -68,25 +158,288 @@ func (c *File) Save(fn string) error {
 		}
 	}
 
-	_, err = c.WriteTo(f)
+	if err := c.saveTo(f, cfg, start); err != nil {
+		return err
+	}
+	if cfg.sync {
+		return f.Sync()
+	}
+	return nil
+}
+
+// SaveMetadataInPlace saves the File over the file ParseFile or ParseReaderAt parsed it from, as Save does with the path of that file and WithInPlace:
+// when the metadata fits in the space it took, only the metadata is rewritten, otherwise the file is replaced atomically.
+// The file is found by the name it was opened with, so a relative path must still be valid. It returns ErrorNoSourceFile if the File was not parsed from a file.
+func (c *File) SaveMetadataInPlace(opts ...SaveOption) error {
+	source := c.sourceFile()
+	if source == nil {
+		return ErrorNoSourceFile
+	}
+	return c.Save(source.Name(), append(opts[:len(opts):len(opts)], WithInPlace())...)
+}
+
+// SaveTo encodes the FLAC stream to w with the same options and behavior as Save, for outputs that are not files such as HTTP responses or archive writers.
+// Like Save, it consumes the frames of the File.
+func (c *File) SaveTo(w io.Writer, opts ...SaveOption) error {
+	cfg := newSaveConfig(opts)
+	start := time.Now()
+	c.emit(Event{Kind: EventSaveStarted})
+	err := c.prepareSave(cfg)
+	if err == nil {
+		err = c.saveTo(w, cfg, start)
+	}
+	recordSave(cfg, time.Since(start), err)
+	c.emit(Event{Kind: EventSaveFinished, Bytes: cfg.written, Err: err})
 	return err
 }
 
+func (c *File) saveTo(w io.Writer, cfg *saveConfig, start time.Time) error {
+	if cfg.padding != nil {
+		c.applyPadding(cfg.padding)
+	}
+	if cfg.throttle != nil {
+		w = &throttledWriter{w: w, bucket: cfg.throttle}
+	}
+	n, err := c.writeTo(w, cfg)
+	if err == nil {
+		moved := n - c.metadataSize()
+		cfg.finish(SaveRewrite, n, moved, start)
+	}
+	return err
+}
+
+// metadataSize returns the size of the "fLaC" marker and the metadata blocks
+func (c *File) metadataSize() int64 {
+	size := int64(4)
+	for _, meta := range c.Meta {
+		size += 4 + int64(meta.Len())
+	}
+	return size
+}
+
+// sourceFile returns the file the File was parsed from, nil if it was not parsed from a file
+func (c *File) sourceFile() *os.File {
+	if f := isFileBacked(c.Frames); f != nil {
+		return f
+	}
+	f, _ := c.src.(*os.File)
+	return f
+}
+
+// sameFile reports whether fn names the open file f
+func sameFile(f *os.File, fn string) (bool, error) {
+	fileInInfo, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to get input file info: %w", err)
+	}
+	fileOutInfo, err := os.Stat(fn)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get output file info: %w", err)
+	}
+	return os.SameFile(fileInInfo, fileOutInfo), nil
+}
+
+// saveOverSource saves the File over fn, the file it was parsed from, as allowed by WithInPlace
+func (c *File) saveOverSource(fn string, cfg *saveConfig, start time.Time) error {
+	// the blocks not loaded yet are read from the file about to be overwritten
+	for _, meta := range c.Meta {
+		if err := meta.Load(); err != nil {
+			return err
+		}
+	}
+	// the metadata of a stream extracted from a wrapper is not where it was parsed from in the file
+	if strategy, ok := c.fitMetadata(); ok && !c.wrapped {
+		return c.writeMetadataOver(fn, cfg, strategy, start)
+	}
+
+	return c.saveAtomic(fn, cfg, start)
+}
+
+// saveAtomic writes the File to a temporary file next to fn that replaces fn once complete,
+// so readers never see a partial file and saving over the source file is safe
+func (c *File) saveAtomic(fn string, cfg *saveConfig, start time.Time) error {
+	links := linkCount(fn)
+	if links > 1 && cfg.hardLinks == HardLinkReject {
+		return &HardLinkError{Path: fn, Links: links}
+	}
+	dir := filepath.Dir(fn)
+	tmp, err := createTemp(dir, cfg.fileMode())
+	if err != nil {
+		return fmt.Errorf("failed to create FLAC output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if info, err := os.Stat(fn); err == nil {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to set FLAC output file permissions: %w", err)
+		}
+		if err := copyXattrs(fn, tmp.Name()); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to copy extended attributes to FLAC output file: %w", err)
+		}
+	}
+	err = c.saveTo(tmp, cfg, start)
+	if err == nil && cfg.sync {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if links > 1 && cfg.hardLinks == HardLinkShare {
+		return copyOver(tmp.Name(), fn, cfg.sync)
+	}
+	if err := replaceFile(tmp.Name(), fn); err != nil {
+		return err
+	}
+	if cfg.sync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// createTemp creates a new file with the given permissions in dir, unlike os.CreateTemp which restricts them to 0600
+func createTemp(dir string, mode os.FileMode) (*os.File, error) {
+	for i := 0; ; i++ {
+		fn := filepath.Join(dir, ".flac-save-"+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
+		if os.IsExist(err) && i < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// fitMetadata resizes the last Padding block, or adds one, so that the metadata takes the space it took in the source.
+// When the last Padding block is too small for the metadata growth, all the Padding blocks are merged into it.
+// It reports how the metadata fits, and false if it cannot.
+func (c *File) fitMetadata() (SaveStrategy, bool) {
+	diff := c.audioOffset - c.metadataSize()
+	if diff == 0 {
+		return SaveInPlace, true
+	}
+	last := -1
+	var slack int64
+	for i, block := range c.Meta {
+		if block.Type == Padding {
+			last = i
+			slack += 4 + int64(block.Len())
+		}
+	}
+	if last < 0 {
+		if diff >= 4 && diff-4 <= MaxBlockSize {
+			c.Meta = append(c.Meta, NewPadding(int(diff-4)))
+			return SavePaddingPatch, true
+		}
+		return SaveRewrite, false
+	}
+	if size := int64(c.Meta[last].Len()) + diff; size >= 0 && size <= MaxBlockSize {
+		c.Meta[last] = NewPadding(int(size))
+		return SavePaddingPatch, true
+	}
+	// the headers of the merged blocks become padding too
+	size := slack - 4 + diff
+	if size < 0 || size > MaxBlockSize {
+		return SaveRewrite, false
+	}
+	meta := c.Meta[:0]
+	for i, block := range c.Meta {
+		switch {
+		case i == last:
+			meta = append(meta, NewPadding(int(size)))
+		case block.Type != Padding:
+			meta = append(meta, block)
+		}
+	}
+	c.Meta = meta
+	return SavePaddingPatch, true
+}
+
+// writeMetadataOver overwrites the metadata of fn, which takes the same space as the metadata of the File, and closes the File
+func (c *File) writeMetadataOver(fn string, cfg *saveConfig, strategy SaveStrategy, start time.Time) error {
+	var header bytes.Buffer
+	frames := c.Frames
+	c.Frames = nil
+	_, err := c.writeTo(&header, nil)
+	c.Frames = frames
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(fn, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open FLAC output file: %w", err)
+	}
+	if _, err := f.WriteAt(header.Bytes(), 0); err != nil {
+		f.Close()
+		return err
+	}
+	c.emit(Event{Kind: EventSaveMetadataWritten, Path: fn, Bytes: int64(header.Len())})
+	if cfg.audit != nil {
+		cfg.audit.untouched(c, int64(header.Len()))
+	}
+	if cfg.sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	c.framesWritten()
+	cfg.finish(strategy, int64(header.Len()), 0, start)
+	return nil
+}
+
 // ParseMetadata accepts a reader to a FLAC stream and consumes only FLAC metadata
 // Frames are not read
 // Further calls to WriteTo will only write the metadata
-func ParseMetadata(f io.Reader) (*File, error) {
+func ParseMetadata(f io.Reader, opts ...ParseOption) (*File, error) {
+	cfg := newParseConfig(opts)
+	cfg.parseStarted()
+	r := cfg.capture(f)
+	res, err := parseWrapped(r, cfg, parseMetadata)
+	if err == nil {
+		err = cfg.check(res)
+	}
+	cfg.parseFinished(res, err)
+	if err != nil {
+		cfg.collect(r, err)
+		return cfg.partialResult(res, err), err
+	}
+	reportAllocs(res)
+	return res, nil
+}
+
+// parseMetadata parses the metadata of f, reading every block if cfg is nil
+func parseMetadata(f io.Reader, cfg *parseConfig) (*File, error) {
 	res := new(File)
 
-	if err := readFLACHead(f); err != nil {
-		return nil, err
+	var err error
+	if cfg != nil && cfg.id3v2 {
+		res.id3v2Size, err = readFLACHeadSkippingID3v2(f)
+	} else {
+		err = readFLACHead(f)
 	}
-	meta, err := readMetadataBlocks(f)
 	if err != nil {
 		return nil, err
 	}
+	res.Meta, err = readMetadataBlocks(f, cfg)
+	if err != nil {
+		return res, err
+	}
 
-	res.Meta = meta
+	res.audioOffset = res.id3v2Size + 4
+	for _, block := range res.Meta {
+		res.audioOffset += 4 + int64(block.Len())
+	}
 
 	return res, nil
 }
@@ -94,15 +447,33 @@ func ParseMetadata(f io.Reader) (*File, error) {
 // ParseBytes accepts a reader to a FLAC stream and returns the final file
 // FLAC audio frames are stored as a reader
 // You should call Close() on the returned File to free resources
-func ParseBytes(f io.Reader) (*File, error) {
-	res, err := ParseMetadata(f)
+func ParseBytes(f io.Reader, opts ...ParseOption) (*File, error) {
+	cfg := newParseConfig(opts)
+	cfg.parseStarted()
+	r := cfg.capture(f)
+	res, err := parseWrapped(r, cfg, parseBytes)
+	if err == nil {
+		err = cfg.check(res)
+	}
+	cfg.parseFinished(res, err)
 	if err != nil {
-		return nil, err
+		cfg.collect(r, err)
+		return cfg.partialResult(res, err), err
+	}
+	cfg.release(r, res)
+	reportAllocs(res)
+	return res, nil
+}
+
+func parseBytes(f io.Reader, cfg *parseConfig) (*File, error) {
+	res, err := parseMetadata(f, cfg)
+	if err != nil {
+		return res, err
 	}
 
 	res.Frames, err = checkFLACStream(f)
 	if err != nil {
-		return nil, err
+		return res, err
 	}
 
 	return res, nil
@@ -111,12 +482,9 @@ func ParseBytes(f io.Reader) (*File, error) {
 // ParseFile parses a FLAC file
 // FLAC audio frames are stored as a reader
 // You should call Close() on the returned File to free resources
-func ParseFile(filename string) (*File, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	return ParseBytes(NewBufIOWithInner(f))
+// With WithLazyBlocks, metadata blocks other than StreamInfo are read from the file on demand
+func ParseFile(filename string, opts ...ParseOption) (*File, error) {
+	return ParseFileContext(context.Background(), filename, opts...)
 }
 
 // Close closes the file
@@ -127,3 +495,40 @@ func (f *File) Close() error {
 	}
 	return nil
 }
+
+// rewoundFrames reads the audio frames from the source of a File, again after WithRewindableFrames rewinds them or cut by SalvageFrames
+type rewoundFrames struct {
+	*io.SectionReader
+	// origin is the Frames the File was parsed with, which holds the source open
+	origin io.Reader
+}
+
+func (c *rewoundFrames) Close() error {
+	if closer, ok := c.origin.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// sourceFrames returns a reader of the audio frames in the source of the File, which holds the source open
+func (c *File) sourceFrames() io.Reader {
+	end := c.srcSize
+	if c.audioEnd > 0 {
+		end = c.audioEnd
+	}
+	origin := c.Frames
+	if rewound, ok := origin.(*rewoundFrames); ok {
+		origin = rewound.origin
+	}
+	return &rewoundFrames{SectionReader: io.NewSectionReader(c.src, c.audioOffset, end-c.audioOffset), origin: origin}
+}
+
+// framesWritten closes the File once its frames are written, or rewinds them if it was parsed with WithRewindableFrames
+func (c *File) framesWritten() {
+	if c.rewindable && c.src != nil {
+		c.Frames = c.sourceFrames()
+		return
+	}
+	c.Close()
+	c.Frames = &ErrorReader{err: ErrorAlreadyWritten}
+}
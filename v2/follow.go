@@ -0,0 +1,75 @@
+package flac
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// followPollInterval is how often a FollowReader checks for new data at the end of its source
+const followPollInterval = 100 * time.Millisecond
+
+// FollowReader reads a source that is still growing, such as a FLAC file being written by a recorder, like tail -f does:
+// at the end of the source it waits for more data instead of returning io.EOF, until no data has arrived for its idle timeout.
+type FollowReader struct {
+	r    io.Reader
+	idle time.Duration
+	poll time.Duration
+}
+
+// NewFollowReader returns a FollowReader of r that reports io.EOF once r has returned no data for idle.
+// A zero idle timeout makes it behave like r.
+func NewFollowReader(r io.Reader, idle time.Duration) *FollowReader {
+	return &FollowReader{r: r, idle: idle, poll: followPollInterval}
+}
+
+func (c *FollowReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var waited time.Duration
+	for {
+		n, err := c.r.Read(p)
+		if n > 0 {
+			// the data read is returned now and EOF reported by the next call, if it still applies
+			return n, nil
+		}
+		if err != io.EOF {
+			return n, err
+		}
+		if waited >= c.idle {
+			return 0, io.EOF
+		}
+		wait := c.poll
+		if wait > c.idle-waited {
+			wait = c.idle - waited
+		}
+		time.Sleep(wait)
+		waited += wait
+	}
+}
+
+// Close closes the source if it is an io.Closer
+func (c *FollowReader) Close() error {
+	if closer, ok := c.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// FollowFile parses the FLAC file at filename while it is still being written, waiting for the metadata blocks to be complete.
+// Frames is read through a FollowReader, so ScanFrames and FrameReader process the audio frames as they are appended,
+// and reach the end of the stream once the file has not grown for idle. The last frame is only reported then,
+// as a frame ends where the next one starts. You should call Close() on the returned File to close the file.
+func FollowFile(filename string, idle time.Duration, opts ...ParseOption) (*File, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	res, err := ParseBytes(NewBufIOWithInner(NewFollowReader(f, idle)), opts...)
+	if err != nil {
+		f.Close()
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,226 @@
+package flac
+
+import "io"
+
+// ChannelAssignment describes how the channels of an audio frame are coded
+type ChannelAssignment uint8
+
+const (
+	// ChannelsIndependent each channel is coded separately
+	ChannelsIndependent ChannelAssignment = iota
+	// ChannelsLeftSide stereo coded as left and side (left minus right) channels
+	ChannelsLeftSide
+	// ChannelsRightSide stereo coded as side and right channels
+	ChannelsRightSide
+	// ChannelsMidSide stereo coded as mid (average) and side channels
+	ChannelsMidSide
+)
+
+// FrameHeader is the decoded header of a FLAC audio frame
+type FrameHeader struct {
+	// VariableBlockSize reports whether the stream uses variable block sizes, in which case Number is a sample number instead of a frame number
+	VariableBlockSize bool
+	// BlockSize is the number of inter-channel samples in the frame
+	BlockSize int
+	// SampleRate is the sample rate in Hz, 0 if the frame refers to StreamInfo
+	SampleRate int
+	// Channels is the number of channels
+	Channels int
+	// ChannelAssignment is the stereo decorrelation mode
+	ChannelAssignment ChannelAssignment
+	// BitDepth is the number of bits per sample, 0 if the frame refers to StreamInfo
+	BitDepth int
+	// Number is the frame number for fixed block size streams, or the number of the first sample for variable block size streams
+	Number uint64
+	// Size is the length of the encoded header in bytes, including its CRC-8
+	Size int
+}
+
+// frameSampleRates maps sample rate codes 1 to 11 of the frame header to Hz
+var frameSampleRates = [12]int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+// frameBitDepths maps sample size codes of the frame header to bits per sample, -1 for reserved codes
+var frameBitDepths = [8]int{0, 8, 12, -1, 16, 20, 24, 32}
+
+// maxFrameHeaderSize is the length of the longest possible frame header
+const maxFrameHeaderSize = 16
+
+// maxFrameSize bounds the length of a frame, above the 2.1 MB of 8 verbatim channels of 65535 33-bit samples,
+// so scanning a stream that holds no further sync code does not buffer it whole
+const maxFrameSize = 4 << 20
+
+// isFrameSync reports whether data starts with the 14-bit frame sync code followed by the mandatory zero bit
+func isFrameSync(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0xFF && data[1]&0xFE == 0xF8
+}
+
+// ParseFrameHeader decodes the frame header at the start of data and verifies its CRC-8.
+// It returns ErrorNoSyncCode if data does not start with a frame sync code, ErrorInvalidFrameHeader for reserved or invalid field values,
+// ErrorFrameCRC if the checksum does not match and io.ErrUnexpectedEOF if data ends within the header.
+func ParseFrameHeader(data []byte) (*FrameHeader, error) {
+	if len(data) < 2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if !isFrameSync(data) {
+		return nil, ErrorNoSyncCode
+	}
+	if len(data) < 5 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	res := &FrameHeader{VariableBlockSize: data[1]&1 != 0}
+	blockSizeCode := data[2] >> 4
+	sampleRateCode := data[2] & 0x0F
+	channelCode := data[3] >> 4
+	bitDepthCode := (data[3] >> 1) & 0x07
+	if data[3]&1 != 0 || blockSizeCode == 0 || sampleRateCode == 0x0F {
+		return nil, ErrorInvalidFrameHeader
+	}
+
+	switch {
+	case channelCode < 8:
+		res.Channels = int(channelCode) + 1
+	case channelCode <= 10:
+		res.Channels = 2
+		res.ChannelAssignment = ChannelAssignment(channelCode - 7)
+	default:
+		return nil, ErrorInvalidFrameHeader
+	}
+	if res.BitDepth = frameBitDepths[bitDepthCode]; res.BitDepth < 0 {
+		return nil, ErrorInvalidFrameHeader
+	}
+
+	// the frame or sample number is coded like UTF-8, extended to 7 bytes for 36-bit numbers
+	pos := 4
+	first := data[pos]
+	pos++
+	var extra int
+	switch {
+	case first&0x80 == 0:
+		res.Number = uint64(first)
+	case first&0xE0 == 0xC0:
+		res.Number, extra = uint64(first&0x1F), 1
+	case first&0xF0 == 0xE0:
+		res.Number, extra = uint64(first&0x0F), 2
+	case first&0xF8 == 0xF0:
+		res.Number, extra = uint64(first&0x07), 3
+	case first&0xFC == 0xF8:
+		res.Number, extra = uint64(first&0x03), 4
+	case first&0xFE == 0xFC:
+		res.Number, extra = uint64(first&0x01), 5
+	case first == 0xFE:
+		res.Number, extra = 0, 6
+	default:
+		return nil, ErrorInvalidFrameHeader
+	}
+	if len(data) < pos+extra {
+		return nil, io.ErrUnexpectedEOF
+	}
+	for i := 0; i < extra; i++ {
+		b := data[pos]
+		pos++
+		if b&0xC0 != 0x80 {
+			return nil, ErrorInvalidFrameHeader
+		}
+		res.Number = res.Number<<6 | uint64(b&0x3F)
+	}
+
+	readUint := func(n int) (int, error) {
+		if len(data) < pos+n {
+			return 0, io.ErrUnexpectedEOF
+		}
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<8 | int(data[pos])
+			pos++
+		}
+		return v, nil
+	}
+
+	var err error
+	switch {
+	case blockSizeCode == 1:
+		res.BlockSize = 192
+	case blockSizeCode <= 5:
+		res.BlockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		if res.BlockSize, err = readUint(1); err != nil {
+			return nil, err
+		}
+		res.BlockSize++
+	case blockSizeCode == 7:
+		if res.BlockSize, err = readUint(2); err != nil {
+			return nil, err
+		}
+		res.BlockSize++
+	default:
+		res.BlockSize = 256 << (blockSizeCode - 8)
+	}
+
+	switch {
+	case sampleRateCode < 12:
+		res.SampleRate = frameSampleRates[sampleRateCode]
+	case sampleRateCode == 12:
+		if res.SampleRate, err = readUint(1); err != nil {
+			return nil, err
+		}
+		res.SampleRate *= 1000
+	case sampleRateCode == 13:
+		if res.SampleRate, err = readUint(2); err != nil {
+			return nil, err
+		}
+	case sampleRateCode == 14:
+		if res.SampleRate, err = readUint(2); err != nil {
+			return nil, err
+		}
+		res.SampleRate *= 10
+	}
+
+	if len(data) < pos+1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if crc8(data[:pos]) != data[pos] {
+		return nil, ErrorFrameCRC
+	}
+	res.Size = pos + 1
+	return res, nil
+}
+
+var crc8Table, crc16Table = func() (t8 [256]uint8, t16 [256]uint16) {
+	for i := 0; i < 256; i++ {
+		c8 := uint8(i)
+		c16 := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if c8&0x80 != 0 {
+				c8 = c8<<1 ^ 0x07
+			} else {
+				c8 <<= 1
+			}
+			if c16&0x8000 != 0 {
+				c16 = c16<<1 ^ 0x8005
+			} else {
+				c16 <<= 1
+			}
+		}
+		t8[i] = c8
+		t16[i] = c16
+	}
+	return
+}()
+
+// crc8 computes the CRC-8 (polynomial x^8 + x^2 + x + 1) protecting frame headers
+func crc8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+	return crc
+}
+
+// crc16 computes the CRC-16 (polynomial x^16 + x^15 + x^2 + 1) protecting whole frames
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
@@ -0,0 +1,46 @@
+package flac
+
+import (
+	"io"
+	"io/fs"
+	"time"
+)
+
+// ParseFS parses the FLAC file name of fsys, such as an embed.FS, a zip.Reader or an fstest.MapFS, as ParseFile does for the OS filesystem.
+// Files opened as an io.ReaderAt, as the files of embed.FS, fstest.MapFS and os.DirFS are, keep random access to their data,
+// so WithLazyBlocks and WithRewindableFrames apply; others are read once, as by ParseBytes.
+// You should call Close() on the returned File to close the file.
+func ParseFS(fsys fs.FS, name string, opts ...ParseOption) (*File, error) {
+	start := time.Now()
+	f, err := fsys.Open(name)
+	if err != nil {
+		recordParse(time.Since(start), err)
+		return nil, err
+	}
+	ra, ok := f.(io.ReaderAt)
+	var size int64
+	if ok {
+		stat, err := f.Stat()
+		if ok = err == nil; ok {
+			size = stat.Size()
+		}
+	}
+	opt := fromFile(name)
+	if !ok {
+		opt = func(c *parseConfig) {
+			c.path = name
+		}
+	}
+	res, err := ParseBytes(NewBufIOWithInner(f), append(opts[:len(opts):len(opts)], opt)...)
+	if err != nil {
+		if res != nil && ok {
+			res.loadPartial(ra, size)
+		}
+		f.Close()
+		return res, err
+	}
+	if ok && !res.wrapped {
+		res.attachSource(ra, size)
+	}
+	return res, nil
+}
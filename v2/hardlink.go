@@ -0,0 +1,68 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// HardLinkPolicy selects what an atomic save does with a file that has several hard links
+type HardLinkPolicy int
+
+const (
+	// HardLinkReject fails the save with a HardLinkError
+	HardLinkReject HardLinkPolicy = iota
+	// HardLinkBreak replaces the file, so the saved path gets a new inode and the other links keep the original content
+	HardLinkBreak
+	// HardLinkShare overwrites the content of the file, so every link sees the saved content, at the cost of atomicity
+	HardLinkShare
+)
+
+// WithHardLinks sets what saves replacing the output file, with WithAtomic or WithInPlace, do when it has several hard links.
+// Replacing a file silently breaks hard-linked deduplication setups, so such saves fail with a HardLinkError by default.
+func WithHardLinks(policy HardLinkPolicy) SaveOption {
+	return func(c *saveConfig) {
+		c.hardLinks = policy
+	}
+}
+
+// HardLinkError indicates that a save would replace a file that has several hard links, returned unless WithHardLinks allows it
+type HardLinkError struct {
+	// Path is the file to save
+	Path string
+	// Links is the number of hard links of the file
+	Links int
+}
+
+func (e *HardLinkError) Error() string {
+	return fmt.Sprintf("%s has %d hard links", e.Path, e.Links)
+}
+
+// Is reports whether target is ErrorHardLinked
+func (e *HardLinkError) Is(target error) bool {
+	return target == ErrorHardLinked
+}
+
+// copyOver writes the content of the file at from into the existing file at to, keeping its inode, and flushes it to stable storage if sync is set
+func copyOver(from, to string, sync bool) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open FLAC output file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if sync {
+		if err := dst.Sync(); err != nil {
+			dst.Close()
+			return err
+		}
+	}
+	return dst.Close()
+}
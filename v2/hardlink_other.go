@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package flac
+
+// linkCount returns the number of hard links of the file at path, always 1 where it cannot be determined
+func linkCount(path string) int {
+	return 1
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package flac
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links of the file at path, 1 if it cannot be determined
+func linkCount(path string) int {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 1
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Nlink)
+	}
+	return 1
+}
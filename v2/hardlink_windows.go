@@ -0,0 +1,22 @@
+//go:build windows
+
+package flac
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links of the file at path, 1 if it cannot be determined
+func linkCount(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info); err != nil {
+		return 1
+	}
+	return int(info.NumberOfLinks)
+}
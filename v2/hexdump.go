@@ -0,0 +1,373 @@
+package flac
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// hexDumpWidth is the number of bytes shown on a row of DumpHex, longer fields are truncated
+const hexDumpWidth = 8
+
+// hexDumper writes the rows of DumpHex
+type hexDumper struct {
+	w *bufio.Writer
+	r io.Reader
+	// pos is the stream offset of the next byte of r
+	pos int64
+	// err is the parse failure, the dump stops at the first one
+	err error
+}
+
+// row writes the bytes of a field at stream offset off with its decoded value
+func (c *hexDumper) row(off int64, data []byte, format string, args ...interface{}) {
+	fmt.Fprintf(c.w, "%08x  %-*s  ", off, hexDumpWidth*3+1, hexBytes(data))
+	fmt.Fprintf(c.w, format, args...)
+	c.w.WriteByte('\n')
+}
+
+// fail writes the bytes of a field at stream offset off, marks the byte at index bad where parsing failed with err and stops the dump.
+// A bad index of len(data) marks the end of the stream.
+func (c *hexDumper) fail(off int64, data []byte, bad int, err error) {
+	// show the row holding the bad byte
+	start := bad - bad%hexDumpWidth
+	if start > len(data) {
+		start = len(data)
+	}
+	fmt.Fprintf(c.w, "%08x  %s\n", off+int64(start), hexBytes(data[start:]))
+	c.w.WriteString(strings.Repeat(" ", 10+3*(bad-start)))
+	if bad >= len(data) {
+		fmt.Fprintf(c.w, "^^ unexpected end at offset 0x%08x: %v\n", off+int64(bad), err)
+	} else {
+		fmt.Fprintf(c.w, "^^ parse failed at offset 0x%08x: %v\n", off+int64(bad), err)
+	}
+	c.err = err
+}
+
+// hexBytes formats the first hexDumpWidth bytes of data, followed by an ellipsis if there are more
+func hexBytes(data []byte) string {
+	var res strings.Builder
+	for i, b := range data {
+		if i == hexDumpWidth {
+			res.WriteString("..")
+			break
+		}
+		if i > 0 {
+			res.WriteByte(' ')
+		}
+		fmt.Fprintf(&res, "%02x", b)
+	}
+	return res.String()
+}
+
+// read reads the next n bytes of the stream, reporting a failure if the stream ends before
+func (c *hexDumper) read(n int) ([]byte, bool) {
+	off := c.pos
+	buf := make([]byte, n)
+	read, err := io.ReadFull(c.r, buf)
+	c.pos += int64(read)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		c.fail(off, buf[:read], read, err)
+		return nil, false
+	}
+	return buf, true
+}
+
+// hexBlock walks the fields of the data of a metadata block read into memory
+type hexBlock struct {
+	*hexDumper
+	data []byte
+	// base is the stream offset of data
+	base int64
+	pos  int
+}
+
+// field consumes the next n bytes of the block and writes them with the description returned by describe, reporting whether the block holds them
+func (c *hexBlock) field(n int, describe func(b []byte) string) bool {
+	off := c.base + int64(c.pos)
+	if n > len(c.data)-c.pos {
+		c.fail(off, c.data[c.pos:], len(c.data)-c.pos, fmt.Errorf("%w: field extends past the %d byte block", io.ErrUnexpectedEOF, len(c.data)))
+		return false
+	}
+	b := c.data[c.pos : c.pos+n]
+	c.pos += n
+	c.row(off, b, "%s", describe(b))
+	return true
+}
+
+// DumpHex reads the head of the FLAC stream r, up to and including the first frame header, and writes its raw bytes annotated
+// with the decoded value of every field. It is meant for bug reports about files the parser rejects: the dump stops at the
+// first byte where parsing failed, marks it, and DumpHex returns the parse error. Errors writing to w are returned as well.
+func DumpHex(w io.Writer, r io.Reader) error {
+	c := &hexDumper{w: bufio.NewWriter(w), r: r}
+	c.dumpStream()
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	return c.err
+}
+
+// dumpStream writes the stream marker, the metadata blocks and the first frame header
+func (c *hexDumper) dumpStream() {
+	marker, ok := c.read(4)
+	if !ok {
+		return
+	}
+	for i := range marker {
+		if marker[i] != "fLaC"[i] {
+			c.fail(0, marker, i, ErrorNoFLACHeader)
+			return
+		}
+	}
+	c.row(0, marker, "stream marker %q", marker)
+
+	for i, last := 0, false; !last; i++ {
+		off := c.pos
+		header, ok := c.read(4)
+		if !ok {
+			return
+		}
+		var blockType BlockType
+		var length int
+		blockType, last, length = decodeBlockHeader(header)
+		c.row(off, header[:1], "block #%d: last %t, type %d (%s)", i, last, blockType, blockTypeName(blockType))
+		c.row(off+1, header[1:], "block #%d: length %d", i, length)
+		off = c.pos
+		data, ok := c.read(length)
+		if !ok {
+			return
+		}
+		block := &hexBlock{hexDumper: c, data: data, base: off}
+		if !block.dump(blockType) {
+			return
+		}
+	}
+	c.dumpFrameHeader()
+}
+
+// dump writes the fields of the block data, reporting whether they decoded
+func (c *hexBlock) dump(blockType BlockType) bool {
+	switch blockType {
+	case StreamInfo:
+		return c.dumpStreamInfo()
+	case Padding:
+		return c.field(len(c.data), func(b []byte) string { return "padding" })
+	case Application:
+		return c.field(4, func(b []byte) string { return fmt.Sprintf("application ID %q", b) }) &&
+			c.field(len(c.data)-4, func(b []byte) string { return fmt.Sprintf("application data, %d bytes", len(b)) })
+	case SeekTable:
+		return c.dumpSeekTable()
+	case VorbisComment:
+		return c.dumpVorbisComment()
+	case CueSheet:
+		return c.dumpCueSheet()
+	case Picture:
+		return c.dumpPicture()
+	}
+	return c.field(len(c.data), func(b []byte) string { return fmt.Sprintf("block data, %d bytes", len(b)) })
+}
+
+func (c *hexBlock) dumpStreamInfo() bool {
+	uint24 := func(b []byte) int { return int(b[0])<<16 | int(b[1])<<8 | int(b[2]) }
+	ok := c.field(2, func(b []byte) string { return fmt.Sprintf("minimum blocksize: %d samples", binary.BigEndian.Uint16(b)) }) &&
+		c.field(2, func(b []byte) string { return fmt.Sprintf("maximum blocksize: %d samples", binary.BigEndian.Uint16(b)) }) &&
+		c.field(3, func(b []byte) string { return fmt.Sprintf("minimum framesize: %d bytes", uint24(b)) }) &&
+		c.field(3, func(b []byte) string { return fmt.Sprintf("maximum framesize: %d bytes", uint24(b)) }) &&
+		c.field(8, func(b []byte) string {
+			v := binary.BigEndian.Uint64(b)
+			return fmt.Sprintf("sample_rate: %d Hz, channels: %d, bits-per-sample: %d, total samples: %d", v>>44, v>>41&0x07+1, v>>36&0x1F+1, v&0xFFFFFFFFF)
+		}) &&
+		c.field(16, func(b []byte) string { return fmt.Sprintf("MD5 signature: %x", b) })
+	if !ok {
+		return false
+	}
+	info, err := parseStreamInfo(c.data)
+	if err == nil {
+		err = info.checkFormat()
+	}
+	if err != nil {
+		// the sample rate starts at byte 10, the channel count and bit depth share byte 12
+		bad := 12
+		var infoErr *StreamInfoError
+		if errors.As(err, &infoErr) && infoErr.Field == "SampleRate" {
+			bad = 10
+		}
+		c.fail(c.base+10, c.data[10:18], bad-10, err)
+		return false
+	}
+	return true
+}
+
+func (c *hexBlock) dumpSeekTable() bool {
+	for i := 0; i < len(c.data)/seekPointSize; i++ {
+		c.field(8, func(b []byte) string {
+			if sample := binary.BigEndian.Uint64(b); sample != SeekPointPlaceholder {
+				return fmt.Sprintf("point %d: sample_number=%d", i, sample)
+			}
+			return fmt.Sprintf("point %d: PLACEHOLDER", i)
+		})
+		c.field(8, func(b []byte) string { return fmt.Sprintf("point %d: stream_offset=%d", i, binary.BigEndian.Uint64(b)) })
+		c.field(2, func(b []byte) string { return fmt.Sprintf("point %d: frame_samples=%d", i, binary.BigEndian.Uint16(b)) })
+	}
+	if c.pos != len(c.data) {
+		c.fail(c.base+int64(c.pos), c.data[c.pos:], 0, ErrorMalformedSeekTable)
+		return false
+	}
+	return true
+}
+
+// lengthPrefixed consumes a field of the length stored in the 4 bytes before it, failing at the length if it exceeds the block
+func (c *hexBlock) lengthPrefixed(order binary.ByteOrder, name string, describe func(b []byte) string) bool {
+	off := c.base + int64(c.pos)
+	var length uint32
+	if !c.field(4, func(b []byte) string {
+		length = order.Uint32(b)
+		return fmt.Sprintf("%s length: %d", name, length)
+	}) {
+		return false
+	}
+	if rest := len(c.data) - c.pos; uint64(length) > uint64(rest) {
+		c.fail(off, c.data[off-c.base:c.pos], 0, fmt.Errorf("%s length %d exceeds the %d remaining bytes of the block", name, length, rest))
+		return false
+	}
+	return c.field(int(length), describe)
+}
+
+func (c *hexBlock) dumpVorbisComment() bool {
+	var count uint32
+	ok := c.lengthPrefixed(binary.LittleEndian, "vendor string", func(b []byte) string { return fmt.Sprintf("vendor string: %s", b) }) &&
+		c.field(4, func(b []byte) string {
+			count = binary.LittleEndian.Uint32(b)
+			return fmt.Sprintf("comments: %d", count)
+		})
+	for i := uint32(0); ok && i < count; i++ {
+		name := fmt.Sprintf("comment[%d]", i)
+		ok = c.lengthPrefixed(binary.LittleEndian, name, func(b []byte) string { return fmt.Sprintf("%s: %s", name, b) })
+	}
+	return ok
+}
+
+func (c *hexBlock) dumpCueSheet() bool {
+	number := func(format string, count *int) func(b []byte) string {
+		return func(b []byte) string {
+			if count != nil {
+				*count = int(b[0])
+			}
+			return fmt.Sprintf(format, b[0])
+		}
+	}
+	offset := func(format string) func(b []byte) string {
+		return func(b []byte) string { return fmt.Sprintf(format, binary.BigEndian.Uint64(b)) }
+	}
+	text := func(format string) func(b []byte) string {
+		return func(b []byte) string { return fmt.Sprintf(format, strings.TrimRight(string(b), "\x00")) }
+	}
+
+	var tracks int
+	ok := c.field(128, text("media catalog number: %s")) &&
+		c.field(8, offset("lead-in: %d")) &&
+		c.field(259, func(b []byte) string { return fmt.Sprintf("is CD: %t", b[0]&0x80 != 0) }) &&
+		c.field(1, number("number of tracks: %d", &tracks))
+	for i := 0; ok && i < tracks; i++ {
+		var indices int
+		ok = c.field(8, offset(fmt.Sprintf("track[%d] offset: %%d", i))) &&
+			c.field(1, number(fmt.Sprintf("track[%d] number: %%d", i), nil)) &&
+			c.field(12, text(fmt.Sprintf("track[%d] ISRC: %%s", i))) &&
+			c.field(14, func(b []byte) string {
+				return fmt.Sprintf("track[%d] audio: %t, pre-emphasis: %t", i, b[0]&0x80 == 0, b[0]&0x40 != 0)
+			}) &&
+			c.field(1, number(fmt.Sprintf("track[%d] number of index points: %%d", i), &indices))
+		for j := 0; ok && j < indices; j++ {
+			ok = c.field(8, offset(fmt.Sprintf("track[%d] index[%d] offset: %%d", i, j))) &&
+				c.field(4, number(fmt.Sprintf("track[%d] index[%d] number: %%d", i, j), nil))
+		}
+	}
+	return ok
+}
+
+func (c *hexBlock) dumpPicture() bool {
+	value := func(format string) func(b []byte) string {
+		return func(b []byte) string { return fmt.Sprintf(format, binary.BigEndian.Uint32(b)) }
+	}
+	text := func(name string) func(b []byte) string {
+		return func(b []byte) string { return fmt.Sprintf("%s: %s", name, b) }
+	}
+	return c.field(4, func(b []byte) string {
+		t := PictureType(binary.BigEndian.Uint32(b))
+		return fmt.Sprintf("picture type: %d (%s)", t, pictureTypeName(t))
+	}) &&
+		c.lengthPrefixed(binary.BigEndian, "MIME type", text("MIME type")) &&
+		c.lengthPrefixed(binary.BigEndian, "description", text("description")) &&
+		c.field(4, value("width: %d")) &&
+		c.field(4, value("height: %d")) &&
+		c.field(4, value("depth: %d")) &&
+		c.field(4, value("colors: %d")) &&
+		c.lengthPrefixed(binary.BigEndian, "picture data", func(b []byte) string { return fmt.Sprintf("picture data, %d bytes", len(b)) })
+}
+
+// dumpFrameHeader writes the header of the first audio frame, if the stream has one
+func (c *hexDumper) dumpFrameHeader() {
+	off := c.pos
+	data := make([]byte, maxFrameHeaderSize)
+	n, _ := io.ReadFull(c.r, data)
+	data = data[:n]
+	if n == 0 {
+		c.row(off, nil, "end of stream, no audio frames")
+		return
+	}
+	header, err := ParseFrameHeader(data)
+	if err != nil {
+		c.fail(off, data, frameHeaderFailure(data, err), err)
+		return
+	}
+	if header.VariableBlockSize {
+		c.row(off, data[:2], "frame sync, variable blocksize")
+	} else {
+		c.row(off, data[:2], "frame sync, fixed blocksize")
+	}
+	c.row(off+2, data[2:3], "blocksize code %d, sample rate code %d", data[2]>>4, data[2]&0x0F)
+	c.row(off+3, data[3:4], "channels: %d, assignment %d, sample size code %d", header.Channels, header.ChannelAssignment, data[3]>>1&0x07)
+	number := "frame number"
+	if header.VariableBlockSize {
+		number = "sample number"
+	}
+	c.row(off+4, data[4:header.Size-1], "%s: %d, blocksize: %d samples, sample rate: %d Hz, bits-per-sample: %d",
+		number, header.Number, header.BlockSize, header.SampleRate, header.BitDepth)
+	c.row(off+int64(header.Size)-1, data[header.Size-1:header.Size], "CRC-8")
+}
+
+// frameHeaderFailure returns the index of the byte of the frame header data where ParseFrameHeader failed with err
+func frameHeaderFailure(data []byte, err error) int {
+	switch {
+	case err == ErrorNoSyncCode:
+		if data[0] == 0xFF {
+			return 1
+		}
+		return 0
+	case err == ErrorInvalidFrameHeader && (data[2]>>4 == 0 || data[2]&0x0F == 0x0F):
+		return 2
+	case err == ErrorInvalidFrameHeader && (data[3]&1 != 0 || data[3]>>4 > 10 || frameBitDepths[data[3]>>1&0x07] < 0):
+		return 3
+	}
+	// the remaining fields fail on the last byte read, so the failure is at the shortest prefix that does not end early
+	bad := len(data)
+	for n := 5; n < len(data); n++ {
+		if _, err := ParseFrameHeader(data[:n]); err != io.ErrUnexpectedEOF {
+			bad = n - 1
+			break
+		}
+	}
+	// the continuation bytes of the frame number are only checked once all of them are read
+	for i := 5; err == ErrorInvalidFrameHeader && i < bad; i++ {
+		if data[i]&0xC0 != 0x80 {
+			return i
+		}
+	}
+	return bad
+}
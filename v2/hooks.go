@@ -0,0 +1,44 @@
+package flac
+
+import "sync"
+
+// MarshalHook updates a metadata block of f before it is serialized by a save, for extensions keeping derived blocks,
+// such as a SeekTable or StreamInfo, in line with the rest of the File. It may modify the Data of block;
+// an error aborts the save.
+type MarshalHook func(f *File, block *MetaDataBlock) error
+
+var (
+	marshalHooksMu sync.Mutex
+	marshalHooks   []MarshalHook
+)
+
+// RegisterBeforeMarshal adds hook to the hooks called by every Save and SaveTo, typically from the init function of the package providing it.
+// Registered hooks run in the order they were registered, before those given with WithBeforeMarshal.
+func RegisterBeforeMarshal(hook MarshalHook) {
+	marshalHooksMu.Lock()
+	defer marshalHooksMu.Unlock()
+	marshalHooks = append(marshalHooks, hook)
+}
+
+// WithBeforeMarshal makes Save and SaveTo call hook for every metadata block of the File before writing it
+func WithBeforeMarshal(hook MarshalHook) SaveOption {
+	return func(c *saveConfig) {
+		c.hooks = append(c.hooks, hook)
+	}
+}
+
+// beforeMarshal calls the registered hooks and the hooks of cfg for every block of the File. Each hook sees the blocks as left by the previous one;
+// blocks a hook adds to Meta are passed to the following hooks.
+func (c *File) beforeMarshal(cfg *saveConfig) error {
+	marshalHooksMu.Lock()
+	hooks := append(marshalHooks[:len(marshalHooks):len(marshalHooks)], cfg.hooks...)
+	marshalHooksMu.Unlock()
+	for _, hook := range hooks {
+		for _, block := range append([]*MetaDataBlock(nil), c.Meta...) {
+			if err := hook(c, block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package flac
+
+import "io"
+
+// WithID3v2Skipping makes parsing skip the ID3v2 tags that some taggers prepend to the "fLaC" marker, as libFLAC does,
+// instead of failing with ErrorNoFLACHeader. File.ID3v2Size reports the number of bytes skipped.
+// The tags are not kept: writing the File produces a plain FLAC stream, and WithInPlace saves turn their space into padding.
+func WithID3v2Skipping() ParseOption {
+	return func(c *parseConfig) {
+		c.id3v2 = true
+	}
+}
+
+// ID3v2Size returns the number of bytes of ID3v2 tags skipped before the "fLaC" marker with WithID3v2Skipping
+func (c *File) ID3v2Size() int64 {
+	return c.id3v2Size
+}
+
+// id3v2TagSize returns the size of the ID3v2 tag starting with the 10 byte header, including the header and the footer,
+// or false if the header is malformed
+func id3v2TagSize(header []byte) (int64, bool) {
+	if header[3] == 0xFF || header[4] == 0xFF {
+		return 0, false
+	}
+	// the size is a syncsafe integer: 7 bits per byte, the high bits clear
+	var size int64
+	for _, b := range header[6:10] {
+		if b&0x80 != 0 {
+			return 0, false
+		}
+		size = size<<7 | int64(b)
+	}
+	size += 10
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	return size, true
+}
+
+// readFLACHeadSkippingID3v2 is readFLACHead skipping the ID3v2 tags before the "fLaC" marker, and returns the number of bytes skipped
+func readFLACHeadSkippingID3v2(f io.Reader) (int64, error) {
+	var skipped int64
+	head := make([]byte, 10)
+	for {
+		if _, err := io.ReadFull(f, head[:4]); err != nil {
+			return skipped, err
+		}
+		if string(head[:3]) != "ID3" {
+			break
+		}
+		if _, err := io.ReadFull(f, head[4:]); err != nil {
+			return skipped, err
+		}
+		size, ok := id3v2TagSize(head)
+		if !ok {
+			return skipped, ErrorNoFLACHeader
+		}
+		n, err := io.CopyN(io.Discard, f, size-10)
+		skipped += 10 + n
+		if err == io.EOF {
+			return skipped, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, checkFLACHead(head[:4])
+}
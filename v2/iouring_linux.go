@@ -0,0 +1,280 @@
+//go:build linux && iouring
+
+package flac
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysIOURingSetup = 425
+	sysIOURingEnter = 426
+
+	uringOffSQRing = 0
+	uringOffCQRing = 0x8000000
+	uringOffSQEs   = 0x10000000
+
+	uringEnterGetEvents = 1
+
+	uringOpRead  = 22
+	uringOpWrite = 23
+
+	// uringDepth chunks of uringChunkSize bytes are read, then written, at once
+	uringDepth     = 8
+	uringChunkSize = 256 << 10
+)
+
+// uringParams mirrors struct io_uring_params
+type uringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFD         uint32
+	resv         [3]uint32
+	sqOff        uringSQOffsets
+	cqOff        uringCQOffsets
+}
+
+// uringSQOffsets mirrors struct io_sqring_offsets
+type uringSQOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+// uringCQOffsets mirrors struct io_cqring_offsets
+type uringCQOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+// uringSQE mirrors struct io_uring_sqe
+type uringSQE struct {
+	opcode   uint8
+	flags    uint8
+	ioprio   uint16
+	fd       int32
+	off      uint64
+	addr     uint64
+	len      uint32
+	rwFlags  uint32
+	userData uint64
+	_        [3]uint64
+}
+
+// uringCQE mirrors struct io_uring_cqe
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring is an io_uring instance used by a single goroutine
+type uring struct {
+	fd                     int
+	sqRing, cqRing, sqeMem []byte
+
+	sqHead, sqTail *uint32
+	sqMask         *uint32
+	sqArray        []uint32
+	sqes           []uringSQE
+	cqHead, cqTail *uint32
+	cqMask         *uint32
+	cqes           []uringCQE
+}
+
+func newURing(entries uint32) (*uring, error) {
+	var p uringParams
+	fd, _, errno := syscall.Syscall(sysIOURingSetup, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	r := &uring{fd: int(fd)}
+	mmap := func(offset int64, size uint32) ([]byte, error) {
+		return syscall.Mmap(r.fd, offset, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	}
+	var err error
+	if r.sqRing, err = mmap(uringOffSQRing, p.sqOff.array+p.sqEntries*4); err != nil {
+		r.close()
+		return nil, err
+	}
+	if r.cqRing, err = mmap(uringOffCQRing, p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(uringCQE{}))); err != nil {
+		r.close()
+		return nil, err
+	}
+	if r.sqeMem, err = mmap(uringOffSQEs, p.sqEntries*uint32(unsafe.Sizeof(uringSQE{}))); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.tail]))
+	r.sqMask = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.array])), p.sqEntries)
+	r.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&r.sqeMem[0])), p.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.tail]))
+	r.cqMask = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes])), p.cqEntries)
+	return r, nil
+}
+
+func (r *uring) close() {
+	for _, mem := range [][]byte{r.sqeMem, r.cqRing, r.sqRing} {
+		if mem != nil {
+			syscall.Munmap(mem)
+		}
+	}
+	syscall.Close(r.fd)
+}
+
+// push queues a read or write of buf at offset in fd, tagged with id
+func (r *uring) push(op uint8, fd uintptr, buf []byte, offset int64, id int) {
+	tail := *r.sqTail
+	i := tail & *r.sqMask
+	r.sqes[i] = uringSQE{
+		opcode:   op,
+		fd:       int32(fd),
+		off:      uint64(offset),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      uint32(len(buf)),
+		userData: uint64(id),
+	}
+	r.sqArray[i] = i
+	atomic.StoreUint32(r.sqTail, tail+1)
+}
+
+// pop returns the next completion, false if there is none
+func (r *uring) pop() (uringCQE, bool) {
+	head := *r.cqHead
+	if head == atomic.LoadUint32(r.cqTail) {
+		return uringCQE{}, false
+	}
+	cqe := r.cqes[head&*r.cqMask]
+	atomic.StoreUint32(r.cqHead, head+1)
+	return cqe, true
+}
+
+// enter submits the submit queued operations and waits for at least one completion
+func (r *uring) enter(submit uint32) error {
+	for {
+		_, _, errno := syscall.Syscall6(sysIOURingEnter, uintptr(r.fd), uintptr(submit), 1, uringEnterGetEvents, 0, 0)
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+			// the operations were submitted before the wait was interrupted
+			submit = 0
+		default:
+			return errno
+		}
+	}
+}
+
+// drain waits for the pending operations still referencing their buffers after enter failed, polling the completion queue.
+// The operations the kernel has not taken from the submission queue never complete and are left to the ring teardown.
+func (r *uring) drain(pending int) {
+	pending -= int(*r.sqTail - atomic.LoadUint32(r.sqHead))
+	for pending > 0 {
+		if _, ok := r.pop(); ok {
+			pending--
+		} else {
+			runtime.Gosched()
+		}
+	}
+}
+
+// transfer reads or writes bufs at consecutive offsets from offset in fd, resubmitting short transfers until they complete.
+// On error it still waits for the operations in flight, which reference bufs.
+func (r *uring) transfer(op uint8, fd uintptr, offset int64, bufs [][]byte) error {
+	var offsets [uringDepth]int64
+	var done [uringDepth]int
+	for i, buf := range bufs {
+		offsets[i] = offset
+		offset += int64(len(buf))
+		r.push(op, fd, buf, offsets[i], i)
+	}
+	var err error
+	submit, pending := uint32(len(bufs)), len(bufs)
+	for pending > 0 {
+		if err := r.enter(submit); err != nil {
+			r.drain(pending)
+			return err
+		}
+		submit = 0
+		for cqe, ok := r.pop(); ok; cqe, ok = r.pop() {
+			i := int(cqe.userData)
+			switch {
+			case cqe.res < 0 && err == nil:
+				err = syscall.Errno(-cqe.res)
+			case cqe.res == 0 && err == nil && op == uringOpRead:
+				err = io.ErrUnexpectedEOF
+			case cqe.res == 0 && err == nil:
+				err = io.ErrShortWrite
+			}
+			done[i] += int(cqe.res)
+			if err == nil && done[i] < len(bufs[i]) {
+				r.push(op, fd, bufs[i][done[i]:], offsets[i]+int64(done[i]), i)
+				submit++
+			} else {
+				pending--
+			}
+		}
+	}
+	return err
+}
+
+// uringCopy copies the file src from offset to its end to dst at its position through io_uring, for high throughput retagging services.
+// It reports false, leaving the copy to the caller, if io_uring is unavailable, such as on kernels before 5.6 or under seccomp filters blocking it.
+func uringCopy(dst, src *os.File, offset int64) (int64, bool, error) {
+	info, err := src.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, false, nil
+	}
+	dstOffset, err := dst.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false, nil
+	}
+	r, err := newURing(uringDepth)
+	if err != nil {
+		return 0, false, nil
+	}
+	defer r.close()
+
+	var bufs [uringDepth][]byte
+	for i := range bufs {
+		bufs[i] = make([]byte, uringChunkSize)
+	}
+	batch := make([][]byte, 0, uringDepth)
+	size := info.Size() - offset
+	var n int64
+	for n < size {
+		batch = batch[:0]
+		for i, pos := 0, n; i < uringDepth && pos < size; i++ {
+			length := int64(uringChunkSize)
+			if size-pos < length {
+				length = size - pos
+			}
+			batch = append(batch, bufs[i][:length])
+			pos += length
+		}
+		if err := r.transfer(uringOpRead, src.Fd(), offset+n, batch); err != nil {
+			return n, true, &os.PathError{Op: "read", Path: src.Name(), Err: err}
+		}
+		if err := r.transfer(uringOpWrite, dst.Fd(), dstOffset+n, batch); err != nil {
+			return n, true, &os.PathError{Op: "write", Path: dst.Name(), Err: err}
+		}
+		for _, buf := range batch {
+			n += int64(len(buf))
+		}
+	}
+	runtime.KeepAlive(bufs)
+	_, err = dst.Seek(dstOffset+n, io.SeekStart)
+	return n, true, err
+}
@@ -0,0 +1,39 @@
+//go:build linux && iouring
+
+package flac
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestURingDrain(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, make([]byte, 4096), 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatalf("Failed to open test file: %s", err)
+	}
+	defer f.Close()
+	r, err := newURing(uringDepth)
+	if err != nil {
+		t.Skipf("io_uring not available: %s", err)
+	}
+	defer r.close()
+
+	// one read is taken by the kernel without waiting for it, the other stays queued as if enter had failed before taking it
+	bufs := [][]byte{make([]byte, 2048), make([]byte, 2048)}
+	r.push(uringOpRead, f.Fd(), bufs[0], 0, 0)
+	if _, _, errno := syscall.Syscall6(sysIOURingEnter, uintptr(r.fd), 1, 0, 0, 0, 0); errno != 0 {
+		t.Fatalf("Failed to submit read: %s", errno)
+	}
+	r.push(uringOpRead, f.Fd(), bufs[1], 2048, 1)
+	r.drain(len(bufs))
+	if *r.cqHead != 1 {
+		t.Errorf("Expected the submitted read to be drained, %d completions consumed", *r.cqHead)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux || !iouring
+
+package flac
+
+import "os"
+
+// uringCopy is only available on Linux with the iouring build tag, the caller falls back to io.Copy
+func uringCopy(dst, src *os.File, offset int64) (int64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,107 @@
+package flac
+
+import "io"
+
+// ParseReaderAt parses the FLAC stream of the given size stored in r without reading metadata block data up front.
+// Only StreamInfo is loaded; the other blocks keep a reference to their location in r and are read on demand by MetaDataBlock.Load, Reader or WriteTo,
+// so listing the types and sizes of blocks never reads large pictures into memory.
+// Frames is a view of the audio frames in r. r must stay readable for as long as the File is used.
+func ParseReaderAt(r io.ReaderAt, size int64, opts ...ParseOption) (*File, error) {
+	cfg := newParseConfig(opts)
+	cfg.parseStarted()
+	res, err := parseReaderAt(r, size, cfg)
+	if err == nil {
+		err = cfg.check(res)
+	}
+	cfg.parseFinished(res, err)
+	if err != nil {
+		cfg.collectAt(r, size, err)
+		return cfg.partialResult(res, err), err
+	}
+	reportAllocs(res)
+	return res, nil
+}
+
+func parseReaderAt(r io.ReaderAt, size int64, cfg *parseConfig) (*File, error) {
+	res := new(File)
+
+	var err error
+	if cfg.id3v2 {
+		res.id3v2Size, err = readFLACHeadSkippingID3v2(io.NewSectionReader(r, 0, size))
+	} else {
+		err = readFLACHead(io.NewSectionReader(r, 0, size))
+	}
+	if err != nil {
+		return nil, err
+	}
+	offset := res.id3v2Size + 4
+	header := make([]byte, 4)
+	for isfinal := false; !isfinal; {
+		if err := cfg.checkBlockCount(len(res.Meta) + 1); err != nil {
+			return res, err
+		}
+		if offset+4 > size {
+			return res, io.ErrUnexpectedEOF
+		}
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return res, err
+		}
+		offset += 4
+
+		block := new(MetaDataBlock)
+		block.Type, isfinal, block.size = decodeBlockHeader(header)
+		if offset+int64(block.size) > size {
+			return res, io.ErrUnexpectedEOF
+		}
+		block.lazy = true
+		block.src = r
+		block.offset = offset
+		offset += int64(block.size)
+		if block.Type == StreamInfo {
+			if err := block.Load(); err != nil {
+				return res, err
+			}
+		}
+		res.Meta = append(res.Meta, block)
+	}
+	res.audioOffset = offset
+	res.src = r
+	res.srcSize = size
+
+	frames, err := checkFLACStream(io.NewSectionReader(r, offset, size-offset))
+	if err != nil {
+		return res, err
+	}
+	res.Frames = frames
+
+	return res, nil
+}
+
+// attachSource records the location of every metadata block in r, which holds the size bytes of the stream the File was parsed from
+func (c *File) attachSource(r io.ReaderAt, size int64) {
+	c.src = r
+	c.srcSize = size
+	offset := c.id3v2Size + 4
+	for _, block := range c.Meta {
+		offset += 4
+		block.src = r
+		block.offset = offset
+		block.size = block.Len()
+		offset += int64(block.size)
+	}
+}
+
+// BlockSectionReader returns a reader of the data of the i-th metadata block as stored in the source file,
+// so large blocks such as pictures can be streamed without buffering.
+// It is available for Files returned by ParseFile and ParseReaderAt, until the File is closed, and returns ErrorNoBlockSource otherwise.
+// The reader reflects the source, not modifications made to Data since parsing.
+func (c *File) BlockSectionReader(i int) (*io.SectionReader, error) {
+	if i < 0 || i >= len(c.Meta) {
+		return nil, ErrorBlockIndex
+	}
+	block := c.Meta[i]
+	if block.src == nil {
+		return nil, ErrorNoBlockSource
+	}
+	return io.NewSectionReader(block.src, block.offset, int64(block.size)), nil
+}
@@ -0,0 +1,42 @@
+package flac
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockRetryInterval is the delay between attempts to take a lock held by another process
+const lockRetryInterval = 50 * time.Millisecond
+
+// WithLock makes Save hold an advisory lock on the output file while writing it, waiting up to timeout for another process to
+// release it and failing with ErrorFileLocked after that. The lock is a "<file>.lock" file created exclusively, which unlike flock
+// also works over NFS. Processes that save without WithLock are not excluded.
+func WithLock(timeout time.Duration) SaveOption {
+	return func(c *saveConfig) {
+		c.lock = true
+		c.lockTimeout = timeout
+	}
+}
+
+// lockFile takes the advisory lock of fn, returning the function releasing it
+func lockFile(fn string, timeout time.Duration) (func(), error) {
+	lock := fn + ".lock"
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			// the process ID helps removing locks left by crashed processes
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+		if !time.Now().Before(deadline) {
+			return nil, ErrorFileLocked
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
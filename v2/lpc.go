@@ -0,0 +1,213 @@
+package flac
+
+import "math"
+
+// Apodization is a window applied to the samples of a block before the LPC analysis, as selected with -A by the reference encoder.
+// It returns one or more windows of length n; each of them is tried and the best predictor is kept.
+type Apodization func(n int) [][]float64
+
+// Rectangle is the rectangular window, leaving the samples unchanged
+func Rectangle() Apodization {
+	return func(n int) [][]float64 {
+		w := make([]float64, n)
+		for i := range w {
+			w[i] = 1
+		}
+		return [][]float64{w}
+	}
+}
+
+// Hann is the Hann window
+func Hann() Apodization {
+	return func(n int) [][]float64 {
+		w := make([]float64, n)
+		for i := range w {
+			w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		}
+		return [][]float64{w}
+	}
+}
+
+// Welch is the Welch (parabolic) window
+func Welch() Apodization {
+	return func(n int) [][]float64 {
+		w := make([]float64, n)
+		half := float64(n-1) / 2
+		for i := range w {
+			x := (float64(i) - half) / half
+			w[i] = 1 - x*x
+		}
+		return [][]float64{w}
+	}
+}
+
+// tukeyWindow returns a Tukey window over [start, end) of a block of n samples, zero outside.
+// p is the fraction of the window that is tapered, 0 for a rectangle and 1 for a Hann window.
+func tukeyWindow(n, start, end int, p float64) []float64 {
+	w := make([]float64, n)
+	size := end - start
+	taper := int(p / 2 * float64(size))
+	for i := 0; i < size; i++ {
+		v := 1.0
+		if i < taper {
+			v = 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(taper))
+		} else if i >= size-taper {
+			v = 0.5 - 0.5*math.Cos(math.Pi*float64(size-1-i)/float64(taper))
+		}
+		w[start+i] = v
+	}
+	return w
+}
+
+// Tukey is the Tukey (tapered cosine) window with the tapered fraction p, the default of the reference encoder being Tukey(0.5)
+func Tukey(p float64) Apodization {
+	return func(n int) [][]float64 {
+		return [][]float64{tukeyWindow(n, 0, n, p)}
+	}
+}
+
+// SubdivideTukey tries a Tukey(0.5) window over the whole block and over each of its halves, thirds and so on up to parts,
+// which lets the predictor follow signals that change within the block, as subdivide_tukey(parts) of the reference encoder
+func SubdivideTukey(parts int) Apodization {
+	return func(n int) [][]float64 {
+		var res [][]float64
+		for k := 1; k <= parts; k++ {
+			size := n / k
+			if size < 32 {
+				break
+			}
+			for i := 0; i < k; i++ {
+				res = append(res, tukeyWindow(n, i*size, (i+1)*size, 0.5))
+			}
+		}
+		return res
+	}
+}
+
+// autocorrelation computes the autocorrelation of the windowed samples for lags 0 to maxLag
+func autocorrelation(samples []int32, window []float64, maxLag int) []float64 {
+	x := make([]float64, len(samples))
+	for i, v := range samples {
+		x[i] = float64(v) * window[i]
+	}
+	res := make([]float64, maxLag+1)
+	for lag := range res {
+		var sum float64
+		for i := lag; i < len(x); i++ {
+			sum += x[i] * x[i-lag]
+		}
+		res[lag] = sum
+	}
+	return res
+}
+
+// levinsonDurbin computes the LPC coefficients of every order up to the maximum from the autocorrelation,
+// returning coefs[order-1] and the prediction error of each order
+func levinsonDurbin(autoc []float64, maxOrder int) (coefs [][]float64, errs []float64) {
+	err := autoc[0]
+	lpc := make([]float64, maxOrder)
+	for i := 0; i < maxOrder; i++ {
+		if err <= 0 {
+			break
+		}
+		r := -autoc[i+1]
+		for j := 0; j < i; j++ {
+			r -= lpc[j] * autoc[i-j]
+		}
+		r /= err
+		lpc[i] = r
+		for j := 0; j < i/2; j++ {
+			lpc[j], lpc[i-1-j] = lpc[j]+r*lpc[i-1-j], lpc[i-1-j]+r*lpc[j]
+		}
+		if i%2 == 1 {
+			lpc[i/2] += lpc[i/2] * r
+		}
+		err *= 1 - r*r
+		order := make([]float64, i+1)
+		for j := range order {
+			// the predictor is the negated filter
+			order[j] = -lpc[j]
+		}
+		coefs = append(coefs, order)
+		errs = append(errs, err)
+	}
+	return coefs, errs
+}
+
+// bestLPCOrder estimates which order minimizes the encoded size from the prediction errors, like the reference encoder does without exhaustive search
+func bestLPCOrder(errs []float64, blockSize, bps, precision int) int {
+	best, bestBits := 0, math.Inf(1)
+	for i, err := range errs {
+		order := i + 1
+		if order > blockSize {
+			break
+		}
+		perSample := 0.0
+		if err > 0 {
+			perSample = 0.5 * math.Log2(0.5*err/float64(blockSize))
+			if perSample < 0 {
+				perSample = 0
+			}
+		}
+		bits := float64(order*(bps+precision)) + perSample*float64(blockSize-order)
+		if bits < bestBits {
+			best, bestBits = order, bits
+		}
+	}
+	return best
+}
+
+// quantizeLPC quantizes the coefficients to precision bits, returning them with the right shift to apply to the prediction
+func quantizeLPC(coefs []float64, precision int) ([]int32, int, bool) {
+	var maxAbs float64
+	for _, c := range coefs {
+		if a := math.Abs(c); a > maxAbs {
+			maxAbs = a
+		}
+	}
+	if maxAbs <= 0 {
+		return nil, 0, false
+	}
+	limit := int32(1)<<(precision-1) - 1
+	_, exp := math.Frexp(maxAbs)
+	shift := precision - 1 - exp
+	if shift > 15 {
+		shift = 15
+	}
+	if shift < 0 {
+		return nil, 0, false
+	}
+	res := make([]int32, len(coefs))
+	var carry float64
+	for i, c := range coefs {
+		carry += c * float64(int64(1)<<shift)
+		q := math.Round(carry)
+		if q > float64(limit) {
+			q = float64(limit)
+		} else if q < float64(-limit-1) {
+			q = float64(-limit - 1)
+		}
+		carry -= q
+		res[i] = int32(q)
+	}
+	return res, shift, true
+}
+
+// lpcPrecision returns the coefficient precision the reference encoder uses for the block size
+func lpcPrecision(blockSize int) int {
+	switch {
+	case blockSize <= 192:
+		return 7
+	case blockSize <= 384:
+		return 8
+	case blockSize <= 576:
+		return 9
+	case blockSize <= 1152:
+		return 10
+	case blockSize <= 2304:
+		return 11
+	case blockSize <= 4608:
+		return 12
+	}
+	return 13
+}
@@ -0,0 +1,250 @@
+package flac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lyrics are stored in Vorbis comments:
+//
+//	LYRICS=<synchronized lyrics in LRC format, or the plain text when there are none>
+//	UNSYNCEDLYRICS=<plain text, only along with synchronized lyrics>
+//
+// Players reading LRC from the LYRICS field display synchronized lyrics, the others still find the plain text.
+const (
+	lyricsField         = "LYRICS"
+	unsyncedLyricsField = "UNSYNCEDLYRICS"
+)
+
+// LyricsQuery describes the track whose lyrics are looked up, as found in the metadata of a File
+type LyricsQuery struct {
+	// Artist is the ARTIST field
+	Artist string
+	// Title is the TITLE field
+	Title string
+	// Album is the ALBUM field
+	Album string
+	// Duration is the length of the track from StreamInfo, zero when unknown
+	Duration time.Duration
+}
+
+// LyricLine is a line of synchronized lyrics
+type LyricLine struct {
+	// Start is the offset of the line from the beginning of the track
+	Start time.Duration
+	// Text is the line text
+	Text string
+}
+
+// Lyrics are the lyrics of a track
+type Lyrics struct {
+	// Plain is the unsynchronized text
+	Plain string
+	// Synced are the timed lines, nil if only the plain text is known
+	Synced []LyricLine
+}
+
+// LyricsProvider looks up the lyrics of a track, for example in an online database.
+// It returns ErrorNoLyrics if it has no lyrics for the query.
+type LyricsProvider interface {
+	FetchLyrics(ctx context.Context, query LyricsQuery) (*Lyrics, error)
+}
+
+// parseLRCTime parses a mm:ss.xx LRC timestamp
+func parseLRCTime(s string) (time.Duration, bool) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(s[:i])
+	if err != nil || minutes < 0 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(s[i+1:], 64)
+	if err != nil || seconds < 0 || seconds >= 60 {
+		return 0, false
+	}
+	return time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)), true
+}
+
+// ParseLRC decodes lyrics in LRC format into lines ordered by start time.
+// Lines with several timestamps are repeated at each of them, and the [offset:ms] tag is applied; the other ID tags, such as [ar:...], are ignored.
+// It returns ErrorInvalidLRC if s holds no timed line.
+func ParseLRC(s string) ([]LyricLine, error) {
+	var res []LyricLine
+	var offset time.Duration
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		var starts []time.Duration
+		for strings.HasPrefix(line, "[") {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				break
+			}
+			tag := line[1:end]
+			if start, ok := parseLRCTime(tag); ok {
+				starts = append(starts, start)
+			} else if value, ok := strings.CutPrefix(tag, "offset:"); ok {
+				ms, err := strconv.Atoi(strings.TrimSpace(value))
+				if err != nil {
+					return nil, ErrorInvalidLRC
+				}
+				offset = time.Duration(ms) * time.Millisecond
+			}
+			line = line[end+1:]
+		}
+		for _, start := range starts {
+			res = append(res, LyricLine{Start: start, Text: strings.TrimSpace(line)})
+		}
+	}
+	if len(res) == 0 {
+		return nil, ErrorInvalidLRC
+	}
+	// a positive offset makes the lyrics appear sooner
+	for i := range res {
+		if res[i].Start -= offset; res[i].Start < 0 {
+			res[i].Start = 0
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Start < res[j].Start
+	})
+	return res, nil
+}
+
+// FormatLRC encodes lines in LRC format with [mm:ss.xx] timestamps
+func FormatLRC(lines []LyricLine) string {
+	var res strings.Builder
+	for _, line := range lines {
+		cs := line.Start.Milliseconds() / 10
+		fmt.Fprintf(&res, "[%02d:%02d.%02d]%s\n", cs/6000, cs/100%60, cs%100, line.Text)
+	}
+	return res.String()
+}
+
+// lyricsQuery builds the lyrics query from the Vorbis comments and StreamInfo of the File
+func (c *File) lyricsQuery() (LyricsQuery, error) {
+	var res LyricsQuery
+	res.Duration, _ = c.Duration()
+	i := c.vorbisCommentIndex()
+	if i < 0 {
+		return res, nil
+	}
+	_, comments, err := loadVorbisComment(c.Meta[i])
+	if err != nil {
+		return res, err
+	}
+	for _, comment := range comments {
+		name, value := splitVorbisComment(comment)
+		switch name {
+		case "ARTIST":
+			res.Artist = value
+		case "TITLE":
+			res.Title = value
+		case "ALBUM":
+			res.Album = value
+		}
+	}
+	return res, nil
+}
+
+// Lyrics returns the lyrics stored in the first VorbisComment block of the File, nil if there are none.
+// A LYRICS field in LRC format is decoded into Synced, with the plain text taken from UNSYNCEDLYRICS or else made of the synchronized lines.
+func (c *File) Lyrics() (*Lyrics, error) {
+	i := c.vorbisCommentIndex()
+	if i < 0 {
+		return nil, nil
+	}
+	_, comments, err := loadVorbisComment(c.Meta[i])
+	if err != nil {
+		return nil, err
+	}
+	var lyrics, unsynced string
+	var found bool
+	for _, comment := range comments {
+		name, value := splitVorbisComment(comment)
+		switch name {
+		case lyricsField:
+			lyrics, found = value, true
+		case unsyncedLyricsField:
+			unsynced, found = value, true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	res := &Lyrics{Plain: lyrics}
+	if synced, err := ParseLRC(lyrics); err == nil {
+		res.Synced = synced
+		res.Plain = unsynced
+		if res.Plain == "" {
+			text := make([]string, len(synced))
+			for i, line := range synced {
+				text[i] = line.Text
+			}
+			res.Plain = strings.Join(text, "\n")
+		}
+	} else if lyrics == "" {
+		res.Plain = unsynced
+	}
+	return res, nil
+}
+
+// SetLyrics replaces the lyrics of the File, or removes them if lyrics is nil.
+// A VorbisComment block is added when the File has none.
+func (c *File) SetLyrics(lyrics *Lyrics) error {
+	idx := c.vorbisCommentIndex()
+	var vendor string
+	var comments []string
+	if idx >= 0 {
+		var err error
+		if vendor, comments, err = loadVorbisComment(c.Meta[idx]); err != nil {
+			return err
+		}
+	}
+
+	kept := comments[:0]
+	for _, comment := range comments {
+		if name, _ := splitVorbisComment(comment); name != lyricsField && name != unsyncedLyricsField {
+			kept = append(kept, comment)
+		}
+	}
+	comments = kept
+	switch {
+	case lyrics == nil:
+	case len(lyrics.Synced) > 0:
+		comments = append(comments, lyricsField+"="+FormatLRC(lyrics.Synced))
+		if lyrics.Plain != "" {
+			comments = append(comments, unsyncedLyricsField+"="+lyrics.Plain)
+		}
+	case lyrics.Plain != "":
+		comments = append(comments, lyricsField+"="+lyrics.Plain)
+	}
+	data := marshalVorbisComment(vendor, comments)
+
+	if idx < 0 {
+		c.Meta = append(c.Meta, &MetaDataBlock{Type: VorbisComment, Data: data})
+		idx = len(c.Meta) - 1
+	} else {
+		c.Meta[idx].Data = data
+	}
+	c.modified(c.Meta[idx])
+	return nil
+}
+
+// FetchAndEmbedLyrics looks up the lyrics of the File with provider, using its Vorbis comments and duration, and embeds them with SetLyrics
+func (c *File) FetchAndEmbedLyrics(ctx context.Context, provider LyricsProvider) error {
+	query, err := c.lyricsQuery()
+	if err != nil {
+		return err
+	}
+	lyrics, err := provider.FetchLyrics(ctx, query)
+	if err != nil {
+		return err
+	}
+	return c.SetLyrics(lyrics)
+}
@@ -0,0 +1,93 @@
+package flac
+
+import "fmt"
+
+const (
+	// MaxBlockSize is the largest payload the 24-bit length of a metadata block header can describe.
+	// Parsing allocates at most MaxBlockSize bytes per metadata block, whatever the input claims.
+	MaxBlockSize = 1<<24 - 1
+	// FrameReadAhead is the number of bytes of audio frames ParseBytes reads from its reader while parsing, to check the sync code.
+	// Frames are never buffered beyond that: they are streamed from the reader when the File is written.
+	// ParseFile reads the file through a buffered reader of fixed size, so it holds at most that buffer of frames.
+	FrameReadAhead = 2
+	// DefaultMaxBlocks is the number of metadata blocks a parse accepts unless WithMaxBlocks sets another limit.
+	// Real files hold a few dozen blocks at most, while a stream of empty blocks declares one every 4 bytes.
+	DefaultMaxBlocks = 4096
+)
+
+// BlockCountError indicates that a stream declares more metadata blocks than the parse accepts, as hostile inputs made of
+// millions of empty blocks do. errors.Is(err, ErrorTooManyBlocks) reports whether an error is a BlockCountError.
+type BlockCountError struct {
+	// Limit is the number of blocks the parse accepted
+	Limit int
+}
+
+func (e *BlockCountError) Error() string {
+	return fmt.Sprintf("more than %d metadata blocks", e.Limit)
+}
+
+// Is reports whether target is ErrorTooManyBlocks
+func (e *BlockCountError) Is(target error) bool {
+	return target == ErrorTooManyBlocks
+}
+
+// WithMaxBlocks makes parsing fail with a BlockCountError once a stream declares more than n metadata blocks, instead of DefaultMaxBlocks.
+// A limit of 0 or less removes the limit, for trusted inputs only.
+func WithMaxBlocks(n int) ParseOption {
+	return func(c *parseConfig) {
+		c.maxBlocks = n
+		if n <= 0 {
+			c.maxBlocks = -1
+		}
+	}
+}
+
+// checkBlockCount returns a BlockCountError if count blocks exceed the limit of the parse
+func (c *parseConfig) checkBlockCount(count int) error {
+	limit := DefaultMaxBlocks
+	if c != nil && c.maxBlocks != 0 {
+		limit = c.maxBlocks
+	}
+	if limit > 0 && count > limit {
+		return &BlockCountError{Limit: limit}
+	}
+	return nil
+}
+
+// AllocStats describes the memory a successful parse allocated for metadata and frames
+type AllocStats struct {
+	// Blocks is the number of metadata blocks whose data was read into memory
+	Blocks int
+	// BlockBytes is the total size of the data of those blocks
+	BlockBytes int64
+	// LargestBlock is the size of the largest of those blocks, at most MaxBlockSize
+	LargestBlock int
+	// FrameBytes is the number of bytes of audio frames held by the File, at most FrameReadAhead
+	FrameBytes int
+}
+
+// DebugAllocStats, if not nil, is called with the allocations of every successful ParseMetadata, ParseBytes, ParseFile and ParseReaderAt,
+// to let embedders verify the memory bounds of the parser. It is meant for tests and debugging and must not be changed while parsing.
+var DebugAllocStats func(AllocStats)
+
+// reportAllocs passes the allocations of the parsed File to DebugAllocStats
+func reportAllocs(res *File) {
+	if DebugAllocStats == nil {
+		return
+	}
+	var stats AllocStats
+	for _, block := range res.Meta {
+		if !block.Loaded() {
+			continue
+		}
+		stats.Blocks++
+		stats.BlockBytes += int64(len(block.Data))
+		if len(block.Data) > stats.LargestBlock {
+			stats.LargestBlock = len(block.Data)
+		}
+	}
+	if frames, ok := res.Frames.(*PrefixReader); ok {
+		stats.FrameBytes = len(frames.prefix)
+	}
+	DebugAllocStats(stats)
+}
@@ -2,6 +2,8 @@ package flac
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"io"
 )
 
 // BlockType representation of types of FLAC Metadata Block
@@ -42,19 +44,192 @@ const (
 type MetaDataBlock struct {
 	Type BlockType
 	Data BlockData
+
+	// lazy reports that Data is not materialized yet and the block data is size bytes read from src, or zeros when src is nil
+	lazy bool
+	size int
+	// src and offset locate the block data in the parsed source when it supports random access
+	src    io.ReaderAt
+	offset int64
+	// skipped reports that the data was skipped by WithBlockTypes, so it cannot be read without src
+	skipped bool
+
+	// hash is the SHA-256 hash of the data when hashed is set, which was Data when hashedData is not nil and the pending data otherwise
+	hash       [sha256.Size]byte
+	hashed     bool
+	hashedData []byte
+}
+
+// NewPadding creates a Padding block of n zero bytes.
+// The zeros are not allocated: Data stays nil and WriteTo streams them to the output, so multi-megabyte padding costs no memory.
+// Assigning Data replaces the lazy zeros. NewPadding panics if n is negative.
+func NewPadding(n int) *MetaDataBlock {
+	if n < 0 {
+		panic("flac.NewPadding: negative size")
+	}
+	return &MetaDataBlock{Type: Padding, lazy: true, size: n}
+}
+
+// pending reports whether the block data is not materialized in Data
+func (c *MetaDataBlock) pending() bool {
+	return c.lazy && c.Data == nil
+}
+
+// Len returns the size of the block data, including data that is not loaded yet
+func (c *MetaDataBlock) Len() int {
+	if c.pending() {
+		return c.size
+	}
+	return len(c.Data)
+}
+
+// Loaded reports whether Data holds the block data, false for lazily parsed blocks until Load is called
+func (c *MetaDataBlock) Loaded() bool {
+	return !c.pending()
+}
+
+// Load reads the block data into Data if it was not loaded yet.
+// Blocks returned by ParseReaderAt are not loaded, except StreamInfo, and must be loaded before Data is accessed.
+func (c *MetaDataBlock) Load() error {
+	if !c.pending() {
+		return nil
+	}
+	data := make([]byte, c.size)
+	if _, err := io.ReadFull(c.Reader(), data); err != nil {
+		return err
+	}
+	c.Data = data
+	if c.hashed && c.hashedData == nil {
+		c.hashedData = data
+	}
+	return nil
+}
+
+// Reader returns a reader of the block data which reads directly from the source of lazily parsed blocks without loading them
+func (c *MetaDataBlock) Reader() io.Reader {
+	switch {
+	case !c.pending():
+		return bytes.NewReader(c.Data)
+	case c.src != nil:
+		return io.NewSectionReader(c.src, c.offset, int64(c.size))
+	case c.skipped:
+		return &ErrorReader{err: ErrorNoBlockSource}
+	default:
+		return io.LimitReader(zeroReader{}, int64(c.size))
+	}
+}
+
+// Hash returns the SHA-256 hash of the block data, for diff, merge and caching layers to compare blocks without reading Data again.
+// The hash is computed on first use, or at parse time with WithBlockHashes, and kept until Data is replaced; modifying Data in place requires assigning it again.
+// Data that is not loaded is hashed from its source without loading it.
+func (c *MetaDataBlock) Hash() ([sha256.Size]byte, error) {
+	if c.hashed && c.sameHashedData() {
+		return c.hash, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, c.Reader()); err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	h.Sum(c.hash[:0])
+	c.hashed = true
+	c.hashedData = nil
+	if !c.pending() {
+		c.hashedData = c.Data
+	}
+	return c.hash, nil
+}
+
+// sameHashedData reports whether the block data is still the data that was hashed
+func (c *MetaDataBlock) sameHashedData() bool {
+	if c.pending() {
+		return c.hashedData == nil
+	}
+	if c.hashedData == nil || len(c.Data) != len(c.hashedData) {
+		return false
+	}
+	return len(c.Data) == 0 || &c.Data[0] == &c.hashedData[0]
 }
 
 // Marshal encodes this MetaDataBlock without touching block data
 // isfinal defines whether this is the last metadata block of the FLAC file
+// Data that is not loaded is read from its source; Marshal returns nil if it cannot be read, AppendMarshal reports the error
+// The length of a block larger than the 24-bit limit is truncated in the header, File.WriteTo reports ErrorBlockTooLarge instead
 func (c *MetaDataBlock) Marshal(isfinal bool) []byte {
-	res := bytes.NewBuffer([]byte{})
+	res, err := c.AppendMarshal(make([]byte, 0, 4+c.Len()), isfinal)
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+// AppendMarshal appends the encoding of this MetaDataBlock to dst and returns the extended buffer, like Marshal
+// Reusing dst across calls avoids allocating a slice per block when encoding many blocks
+// If data that is not loaded fails to read from its source, dst is returned unchanged with the error,
+// and ErrorNoBlockSource is returned for blocks whose data was skipped while parsing a stream that cannot be read again
+func (c *MetaDataBlock) AppendMarshal(dst []byte, isfinal bool) ([]byte, error) {
+	if c.pending() && c.src == nil && c.skipped {
+		return dst, ErrorNoBlockSource
+	}
+	origin := len(dst)
+	dst = c.appendHeader(dst, isfinal)
+	if !c.pending() {
+		return append(dst, c.Data...), nil
+	}
+	start := len(dst)
+	if cap(dst)-start < c.size {
+		grown := make([]byte, start, start+c.size)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:start+c.size]
+	data := dst[start:]
+	if c.src != nil {
+		if _, err := io.ReadFull(c.Reader(), data); err != nil {
+			return dst[:origin], err
+		}
+		return dst, nil
+	}
+	// lazy padding
+	for i := range data {
+		data[i] = 0
+	}
+	return dst, nil
+}
+
+// appendHeader appends the 4 byte block header to dst, keeping the low 24 bits of the block length
+func (c *MetaDataBlock) appendHeader(dst []byte, isfinal bool) []byte {
+	typ := byte(c.Type)
 	if isfinal {
-		res.WriteByte(byte(c.Type + 1<<7))
-	} else {
-		res.WriteByte(byte(c.Type))
-	}
-	size := encodeUint32(uint32(len(c.Data)))
-	res.Write(size[len(size)-3:])
-	res.Write(c.Data)
-	return res.Bytes()
+		typ = byte(c.Type + 1<<7)
+	}
+	n := uint32(c.Len())
+	return append(dst, typ, byte(n>>16), byte(n>>8), byte(n))
+}
+
+// writeBufferSize is the size up to which loaded blocks are encoded into the write buffer instead of writing their data directly
+const writeBufferSize = 64 << 10
+
+// writeTo writes the encoded block to w, streaming data that is not loaded instead of allocating it.
+// buf is a scratch buffer reused across blocks.
+func (c *MetaDataBlock) writeTo(w io.Writer, isfinal bool, buf *[]byte) (int64, error) {
+	if c.Len() > MaxBlockSize {
+		return 0, ErrorBlockTooLarge
+	}
+	if !c.pending() && len(c.Data) <= writeBufferSize {
+		// loaded data is encoded without reading, so it cannot fail
+		*buf, _ = c.AppendMarshal((*buf)[:0], isfinal)
+		n, err := w.Write(*buf)
+		return int64(n), err
+	}
+	*buf = c.appendHeader((*buf)[:0], isfinal)
+	n, err := w.Write(*buf)
+	if err != nil {
+		return int64(n), err
+	}
+	if c.pending() {
+		n2, err := io.CopyN(w, c.Reader(), int64(c.size))
+		return int64(n) + n2, err
+	}
+	n2, err := w.Write(c.Data)
+	return int64(n + n2), err
 }
@@ -0,0 +1,84 @@
+package flac
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// Metrics receives the measurements of parses and saves, for adapters exporting them to monitoring systems such as Prometheus or OpenTelemetry.
+// Its methods are called synchronously and possibly concurrently from the goroutines running the operations.
+type Metrics interface {
+	// FileParsed is called after every successful ParseMetadata, ParseBytes, ParseFile and ParseReaderAt with its duration
+	FileParsed(duration time.Duration)
+	// ParseFailed is called after every failed parse with the reason returned by ParseErrorReason and its duration
+	ParseFailed(reason string, duration time.Duration)
+	// FileSaved is called after every successful Save and SaveTo with the strategy used, the number of bytes written and its duration
+	FileSaved(strategy SaveStrategy, written int64, duration time.Duration)
+	// SaveFailed is called after every failed Save and SaveTo with its duration
+	SaveFailed(duration time.Duration)
+}
+
+// Instrumentation, if not nil, receives the measurements of every parse and save. It must not be changed while the package is in use.
+var Instrumentation Metrics
+
+// parseErrorReasons maps the parse errors to the labels ParseErrorReason returns, in the order they are matched
+var parseErrorReasons = []struct {
+	err    error
+	reason string
+}{
+	{ErrorHeaderConsumed, "header_consumed"},
+	{ErrorNoFLACHeader, "no_flac_header"},
+	{ErrorNoStreamInfo, "no_stream_info"},
+	{ErrorStreamInfoEarlyEOF, "truncated"},
+	{io.ErrUnexpectedEOF, "truncated"},
+	{io.EOF, "truncated"},
+	{ErrorNoSyncCode, "no_sync_code"},
+	{ErrorInvalidBlockType, "invalid_block_type"},
+	{ErrorTooManyBlocks, "too_many_blocks"},
+	{ErrorInvalidStreamInfo, "invalid_stream_info"},
+	{ErrorTimeout, "timeout"},
+	{context.DeadlineExceeded, "timeout"},
+	{context.Canceled, "canceled"},
+}
+
+// ParseErrorReason classifies a parse error into a short label such as "no_flac_header" or "truncated", suitable as a metric label:
+// errors reading the stream are "io" and the others "other", so the set of labels stays small.
+func ParseErrorReason(err error) string {
+	for _, r := range parseErrorReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return "io"
+	}
+	return "other"
+}
+
+// recordParse passes the outcome of a parse to Instrumentation
+func recordParse(duration time.Duration, err error) {
+	if Instrumentation == nil {
+		return
+	}
+	if err != nil {
+		Instrumentation.ParseFailed(ParseErrorReason(err), duration)
+	} else {
+		Instrumentation.FileParsed(duration)
+	}
+}
+
+// recordSave passes the outcome of a save to Instrumentation
+func recordSave(cfg *saveConfig, duration time.Duration, err error) {
+	if Instrumentation == nil {
+		return
+	}
+	if err != nil {
+		Instrumentation.SaveFailed(duration)
+	} else {
+		Instrumentation.FileSaved(cfg.strategy, cfg.written, duration)
+	}
+}
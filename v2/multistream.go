@@ -0,0 +1,90 @@
+package flac
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// streamStartSize is the length of the "fLaC" marker and StreamInfo block header that start a stream
+const streamStartSize = 8
+
+// isStreamStart reports whether b starts with the "fLaC" marker followed by the header of a StreamInfo block
+func isStreamStart(b []byte) bool {
+	return len(b) >= streamStartSize && string(b[:4]) == "fLaC" && b[4]&0x7F == byte(StreamInfo) && b[5] == 0 && b[6] == 0 && b[7] == 34
+}
+
+// Streams iterates over FLAC streams stored back to back in one input, as produced by some capture tools dumping gapless webcasts
+type Streams struct {
+	r   *bufio.Reader
+	cur *streamSection
+}
+
+// NewStreams returns an iterator over the FLAC streams concatenated in r
+func NewStreams(r io.Reader) *Streams {
+	return &Streams{r: bufio.NewReader(r)}
+}
+
+// Next parses the next stream into a File whose Frames end where the following stream starts.
+// The frames of the previous File that were not read are skipped. It returns io.EOF when there is no stream left.
+// Closing the Files does not close the input.
+func (c *Streams) Next() (*File, error) {
+	if c.cur != nil {
+		if _, err := io.Copy(io.Discard, c.cur); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := c.r.Peek(1); err != nil {
+		return nil, err
+	}
+	c.cur = &streamSection{r: c.r}
+	res, err := parseMetadata(c.cur, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the metadata may embed anything, only the frames are searched for the next stream
+	c.cur.split = true
+	if res.Frames, err = checkFLACStream(c.cur); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// streamSection reads r up to the start of the next stream once split is set
+type streamSection struct {
+	r     *bufio.Reader
+	split bool
+	done  bool
+}
+
+func (c *streamSection) Read(p []byte) (int, error) {
+	if !c.split {
+		return c.r.Read(p)
+	}
+	if c.done || len(p) == 0 {
+		return 0, io.EOF
+	}
+	head, err := c.r.Peek(streamStartSize)
+	if len(head) == 0 {
+		return 0, err
+	}
+	if isStreamStart(head) {
+		c.done = true
+		return 0, io.EOF
+	}
+	buf, _ := c.r.Peek(c.r.Buffered())
+	n := len(buf)
+	if i := bytes.Index(buf[1:], []byte("fLaC")); i >= 0 {
+		// stop before a possible stream start, checked by the next call
+		n = i + 1
+	} else if err == nil && n > 3 {
+		// hold back what may be the beginning of a marker
+		n -= 3
+	}
+	if n > len(p) {
+		n = len(p)
+	}
+	copy(p, buf[:n])
+	c.r.Discard(n)
+	return n, nil
+}
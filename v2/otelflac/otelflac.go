@@ -0,0 +1,142 @@
+// Package otelflac wraps the parse and save operations of package flac in OpenTelemetry spans,
+// so services see FLAC file IO in their traces along with the size of the files, their block counts and the save strategy used.
+package otelflac
+
+import (
+	"context"
+	"io"
+	"os"
+
+	flac "github.com/go-flac/go-flac/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the tracer spans are created with
+const ScopeName = "github.com/go-flac/go-flac/v2/otelflac"
+
+// Attribute keys set on the spans
+const (
+	// PathKey is the file parsed or saved
+	PathKey = attribute.Key("flac.path")
+	// FileSizeKey is the size in bytes of the file parsed
+	FileSizeKey = attribute.Key("flac.file.size")
+	// BlockCountKey is the number of metadata blocks of the File parsed or saved
+	BlockCountKey = attribute.Key("flac.blocks")
+	// MetadataSizeKey is the size in bytes of the "fLaC" marker and the metadata blocks of the File parsed or saved
+	MetadataSizeKey = attribute.Key("flac.metadata.size")
+	// StrategyKey is the way a save wrote the File, as returned by flac.SaveStrategy.String
+	StrategyKey = attribute.Key("flac.save.strategy")
+	// BytesWrittenKey is the number of bytes a save wrote
+	BytesWrittenKey = attribute.Key("flac.save.bytes_written")
+	// BytesMovedKey is the number of audio bytes a save copied
+	BytesMovedKey = attribute.Key("flac.save.bytes_moved")
+)
+
+// Tracer creates the spans of the operations it wraps
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New returns a Tracer creating spans with provider, or with the global provider of package otel if provider is nil
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(ScopeName)}
+}
+
+// ParseFile calls flac.ParseFileContext in a "flac.ParseFile" span, so the parse stops once ctx is done
+func (c *Tracer) ParseFile(ctx context.Context, filename string, opts ...flac.ParseOption) (*flac.File, error) {
+	ctx, span := c.tracer.Start(ctx, "flac.ParseFile", trace.WithAttributes(PathKey.String(filename)))
+	defer span.End()
+	if info, err := os.Stat(filename); err == nil {
+		span.SetAttributes(FileSizeKey.Int64(info.Size()))
+	}
+	res, err := flac.ParseFileContext(ctx, filename, opts...)
+	return res, parsed(span, res, err)
+}
+
+// ParseBytes calls flac.ParseContext in a "flac.ParseBytes" span, so the parse stops once ctx is done
+func (c *Tracer) ParseBytes(ctx context.Context, r io.Reader, opts ...flac.ParseOption) (*flac.File, error) {
+	ctx, span := c.tracer.Start(ctx, "flac.ParseBytes")
+	defer span.End()
+	res, err := flac.ParseContext(ctx, r, opts...)
+	return res, parsed(span, res, err)
+}
+
+// ParseMetadata calls flac.ParseMetadata in a "flac.ParseMetadata" span
+func (c *Tracer) ParseMetadata(ctx context.Context, r io.Reader, opts ...flac.ParseOption) (*flac.File, error) {
+	_, span := c.tracer.Start(ctx, "flac.ParseMetadata")
+	defer span.End()
+	res, err := flac.ParseMetadata(r, opts...)
+	return res, parsed(span, res, err)
+}
+
+// Save calls File.Save in a "flac.Save" span
+func (c *Tracer) Save(ctx context.Context, f *flac.File, fn string, opts ...flac.SaveOption) error {
+	_, span := c.tracer.Start(ctx, "flac.Save", trace.WithAttributes(PathKey.String(fn)))
+	defer span.End()
+	var report flac.SaveReport
+	span.SetAttributes(metadataAttributes(f)...)
+	return saved(span, &report, f.Save(fn, append(opts[:len(opts):len(opts)], flac.WithReport(&report))...))
+}
+
+// SaveTo calls File.SaveTo in a "flac.SaveTo" span
+func (c *Tracer) SaveTo(ctx context.Context, f *flac.File, w io.Writer, opts ...flac.SaveOption) error {
+	_, span := c.tracer.Start(ctx, "flac.SaveTo")
+	defer span.End()
+	var report flac.SaveReport
+	span.SetAttributes(metadataAttributes(f)...)
+	return saved(span, &report, f.SaveTo(w, append(opts[:len(opts):len(opts)], flac.WithReport(&report))...))
+}
+
+// SaveMetadataInPlace calls File.SaveMetadataInPlace in a "flac.SaveMetadataInPlace" span
+func (c *Tracer) SaveMetadataInPlace(ctx context.Context, f *flac.File, opts ...flac.SaveOption) error {
+	_, span := c.tracer.Start(ctx, "flac.SaveMetadataInPlace")
+	defer span.End()
+	var report flac.SaveReport
+	span.SetAttributes(metadataAttributes(f)...)
+	return saved(span, &report, f.SaveMetadataInPlace(append(opts[:len(opts):len(opts)], flac.WithReport(&report))...))
+}
+
+// metadataAttributes describes the metadata blocks of f
+func metadataAttributes(f *flac.File) []attribute.KeyValue {
+	size := int64(4)
+	for _, meta := range f.Meta {
+		size += 4 + int64(meta.Len())
+	}
+	return []attribute.KeyValue{BlockCountKey.Int(len(f.Meta)), MetadataSizeKey.Int64(size)}
+}
+
+// parsed records the outcome of a parse on span
+func parsed(span trace.Span, res *flac.File, err error) error {
+	if err != nil {
+		fail(span, err)
+		return err
+	}
+	span.SetAttributes(metadataAttributes(res)...)
+	return nil
+}
+
+// saved records the outcome of a save described by report on span
+func saved(span trace.Span, report *flac.SaveReport, err error) error {
+	if err != nil {
+		fail(span, err)
+		return err
+	}
+	span.SetAttributes(
+		StrategyKey.String(report.Strategy.String()),
+		BytesWrittenKey.Int64(report.BytesWritten),
+		BytesMovedKey.Int64(report.BytesMoved),
+	)
+	return nil
+}
+
+// fail marks span as failed with err
+func fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,76 @@
+package otelflac
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	flac "github.com/go-flac/go-flac/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testFrames starts with a frame sync code, which is all the parser checks of the frames
+var testFrames = []byte{0xff, 0xf8, 0x69, 0x08, 0x00, 0x00}
+
+// testStream is a FLAC stream with a StreamInfo block, a Padding block and testFrames
+func testStream() []byte {
+	info := (&flac.StreamInfoBlock{BlockSizeMin: 4096, BlockSizeMax: 4096, SampleRate: 44100, ChannelCount: 2, BitDepth: 16}).Marshal()
+	f := &flac.File{Meta: []*flac.MetaDataBlock{&info, flac.NewPadding(10)}, Frames: bytes.NewReader(testFrames)}
+	var buf bytes.Buffer
+	f.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	res := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		res[kv.Key] = kv.Value
+	}
+	return res
+}
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	ctx := context.Background()
+
+	data := testStream()
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	f, err := tracer.ParseFile(ctx, fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	defer f.Close()
+	if err := tracer.SaveTo(ctx, f, io.Discard); err != nil {
+		t.Fatalf("Failed to save file: %s", err)
+	}
+	if _, err := tracer.ParseBytes(ctx, bytes.NewReader([]byte("OggS"))); err == nil {
+		t.Fatal("Expected a parse error")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Unexpected number of spans %d", len(spans))
+	}
+	parse := attributes(spans[0])
+	if spans[0].Name() != "flac.ParseFile" || parse[PathKey].AsString() != fn || parse[FileSizeKey].AsInt64() != int64(len(data)) ||
+		parse[BlockCountKey].AsInt64() != 2 || parse[MetadataSizeKey].AsInt64() != int64(len(data)-len(testFrames)) {
+		t.Errorf("Unexpected parse span %s %v", spans[0].Name(), parse)
+	}
+	save := attributes(spans[1])
+	if spans[1].Name() != "flac.SaveTo" || save[StrategyKey].AsString() != flac.SaveRewrite.String() || save[BytesWrittenKey].AsInt64() != int64(len(data)) {
+		t.Errorf("Unexpected save span %s %v", spans[1].Name(), save)
+	}
+	if spans[2].Status().Code != codes.Error || len(spans[2].Events()) != 1 {
+		t.Errorf("Failed parse not recorded: %+v", spans[2].Status())
+	}
+}
@@ -0,0 +1,107 @@
+package flac
+
+// PaddingPolicy decides the size of the Padding block written when a File is saved by rewriting it, so future edits fit in place
+type PaddingPolicy interface {
+	// PaddingSize returns the number of padding bytes to reserve after metadataSize bytes of metadata, excluding any padding
+	PaddingSize(metadataSize int64) int
+}
+
+// PaddingFunc adapts a function to the PaddingPolicy interface
+type PaddingFunc func(metadataSize int64) int
+
+// PaddingSize calls f(metadataSize)
+func (f PaddingFunc) PaddingSize(metadataSize int64) int {
+	return f(metadataSize)
+}
+
+// ProportionalPadding reserves Percent percent of the metadata size plus Extra bytes
+type ProportionalPadding struct {
+	Percent int
+	Extra   int
+}
+
+// PaddingSize returns the padding for metadataSize bytes of metadata
+// Sizes are computed in int64 and capped at MaxBlockSize, so they never wrap around on 32-bit platforms.
+func (p ProportionalPadding) PaddingSize(metadataSize int64) int {
+	size := metadataSize*int64(p.Percent)/100 + int64(p.Extra)
+	if size > MaxBlockSize {
+		return MaxBlockSize
+	}
+	return int(size)
+}
+
+// DefaultPadding reserves 10% of the metadata size plus 4 KiB, leaving room for tag edits and a small picture
+var DefaultPadding PaddingPolicy = ProportionalPadding{Percent: 10, Extra: 4 << 10}
+
+// WithPadding makes Save and SaveTo replace the Padding blocks of a File they rewrite by a single Padding block at the end, sized by policy.
+// It does not apply when WithInPlace saves only the metadata.
+func WithPadding(policy PaddingPolicy) SaveOption {
+	return func(c *saveConfig) {
+		c.padding = policy
+	}
+}
+
+// applyPadding replaces the Padding blocks by one block of the size chosen by policy, omitted if the size is not positive
+func (c *File) applyPadding(policy PaddingPolicy) {
+	c.removePadding()
+	size := policy.PaddingSize(c.metadataSize())
+	if size > MaxBlockSize {
+		size = MaxBlockSize
+	}
+	if size > 0 {
+		c.Meta = append(c.Meta, NewPadding(size))
+	}
+}
+
+// removePadding removes the Padding blocks
+func (c *File) removePadding() {
+	c.removeBlocks(func(block *MetaDataBlock) bool {
+		return block.Type == Padding
+	})
+}
+
+// PaddingSize returns the total size of the Padding blocks of the File
+func (c *File) PaddingSize() int {
+	var res int
+	for _, block := range c.Meta {
+		if block.Type == Padding {
+			res += block.Len()
+		}
+	}
+	return res
+}
+
+// SetPadding replaces the Padding blocks of the File by a single Padding block of n bytes at the end of the metadata, or removes them if n is not positive.
+// It returns ErrorBlockTooLarge if n exceeds MaxBlockSize.
+func (c *File) SetPadding(n int) error {
+	if n > MaxBlockSize {
+		return ErrorBlockTooLarge
+	}
+	c.removePadding()
+	if n > 0 {
+		c.Meta = append(c.Meta, NewPadding(n))
+		c.modified(c.Meta[len(c.Meta)-1])
+	}
+	return nil
+}
+
+// EnsurePadding merges the Padding blocks of the File into a single block at the end of the metadata, grown to n bytes if they hold less,
+// so the next n bytes of metadata growth fit without rewriting the audio
+func (c *File) EnsurePadding(n int) error {
+	if size := c.PaddingSize(); size > n {
+		n = size
+	}
+	if n > MaxBlockSize {
+		n = MaxBlockSize
+	}
+	return c.SetPadding(n)
+}
+
+// ShrinkPadding merges the Padding blocks of the File into a single block at the end of the metadata, shrunk to n bytes if they hold more,
+// or removes them if n is not positive
+func (c *File) ShrinkPadding(n int) error {
+	if size := c.PaddingSize(); size < n {
+		n = size
+	}
+	return c.SetPadding(n)
+}
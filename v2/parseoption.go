@@ -0,0 +1,122 @@
+package flac
+
+import "time"
+
+// ParseOption configures the behavior of ParseMetadata, ParseBytes, ParseFile and ParseReaderAt
+type ParseOption func(*parseConfig)
+
+type parseConfig struct {
+	corpus      CorpusSink
+	corpusLimit int
+	strict      bool
+	hashes      bool
+	// types holds the block types whose data is read, or nil to read every block
+	types map[BlockType]bool
+	// lazy and file report that WithLazyBlocks was given and that the stream is a source ParseFile, ParseSeeker or ParseFS can read blocks from later
+	lazy, file bool
+	// events receives the events of the parse, path is the file ParseFile parses
+	events EventSink
+	path   string
+	// started is the time the parse started
+	started time.Time
+	// rewindable reports that WithRewindableFrames was given
+	rewindable bool
+	// id3v2 reports that WithID3v2Skipping was given
+	id3v2 bool
+	// partial reports that WithPartialResults was given
+	partial bool
+	// maxBlocks is the limit set by WithMaxBlocks, 0 for DefaultMaxBlocks and -1 for none
+	maxBlocks int
+}
+
+func newParseConfig(opts []ParseOption) *parseConfig {
+	cfg := new(parseConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithStrict makes parsing reject streams that the specification forbids but that are accepted by default:
+// metadata blocks of the type Invalid (127) fail with ErrorInvalidBlockType instead of being preserved byte-exact.
+func WithStrict() ParseOption {
+	return func(c *parseConfig) {
+		c.strict = true
+	}
+}
+
+// WithBlockHashes makes parsing compute the hash of every metadata block, so MetaDataBlock.Hash returns it without reading the block again.
+// Blocks parsed by ParseReaderAt are read from the source to be hashed but stay unloaded.
+func WithBlockHashes() ParseOption {
+	return func(c *parseConfig) {
+		c.hashes = true
+	}
+}
+
+// WithBlockTypes makes ParseMetadata, ParseBytes and ParseFile read the data of StreamInfo and of the blocks of the given types only,
+// so callers extracting tags do not read large pictures into memory. The other blocks are kept in Meta without their data, which ParseFile
+// loads on demand from the file like ParseReaderAt does. ParseMetadata and ParseBytes cannot read it back: Load and WriteTo fail with ErrorNoBlockSource.
+// Skipped blocks are not hashed by WithBlockHashes. ParseReaderAt reads no block data up front and ignores this option.
+func WithBlockTypes(types ...BlockType) ParseOption {
+	return func(c *parseConfig) {
+		c.types = make(map[BlockType]bool, len(types))
+		for _, t := range types {
+			c.types[t] = true
+		}
+	}
+}
+
+// WithLazyBlocks makes ParseFile, ParseSeeker and ParseFS leave the data of the metadata blocks other than StreamInfo in their source, as ParseReaderAt does,
+// so files with large pictures take little memory. The blocks are read on demand by MetaDataBlock.Load, Reader and WriteTo
+// and must be loaded before Data is accessed, before the File is closed.
+// ParseMetadata and ParseBytes cannot read a stream again and ignore this option.
+func WithLazyBlocks() ParseOption {
+	return func(c *parseConfig) {
+		c.lazy = true
+	}
+}
+
+// WithRewindableFrames makes WriteTo, Save and the other writers rewind Frames to the start of the audio once written, instead of closing the File,
+// so it can be written to several destinations without being parsed again. It applies to Files parsed by ParseFile and ParseReaderAt,
+// which read the audio again from their source; the caller must Close the File when done.
+func WithRewindableFrames() ParseOption {
+	return func(c *parseConfig) {
+		c.rewindable = true
+	}
+}
+
+// fromFile marks the stream as the file at path opened by ParseFile, or as the source of ParseSeeker if path is empty
+func fromFile(path string) ParseOption {
+	return func(c *parseConfig) {
+		c.file = true
+		c.path = path
+	}
+}
+
+// skips reports whether the data of blocks of type t is skipped while parsing
+func (c *parseConfig) skips(t BlockType) bool {
+	if c == nil || t == StreamInfo {
+		return false
+	}
+	return c.lazy && c.file || c.types != nil && !c.types[t]
+}
+
+// check applies the strict mode checks to a parsed File and computes the block hashes requested by WithBlockHashes
+func (c *parseConfig) check(res *File) error {
+	for _, block := range res.Meta {
+		if c.strict && block.Type == Invalid {
+			return ErrorInvalidBlockType
+		}
+	}
+	if c.hashes {
+		for _, block := range res.Meta {
+			if block.skipped {
+				continue
+			}
+			if _, err := block.Hash(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
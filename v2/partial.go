@@ -0,0 +1,35 @@
+package flac
+
+import "io"
+
+// WithPartialResults makes a failed parse return, along with its error, a File holding the metadata blocks read before the failure,
+// so recovery tools can still read the tags of a file corrupted after them. Blocks cut by the end of the stream are left out.
+// Reading the Frames of a partial File returns the parse error. A File is returned only if at least one block was read.
+func WithPartialResults() ParseOption {
+	return func(c *parseConfig) {
+		c.partial = true
+	}
+}
+
+// partialResult returns the File a parse that failed with err returns, nil unless WithPartialResults was given
+func (c *parseConfig) partialResult(res *File, err error) *File {
+	if !c.partial || res == nil || len(res.Meta) == 0 {
+		return nil
+	}
+	res.Frames = &ErrorReader{err: err}
+	return res
+}
+
+// loadPartial reads the blocks of a partial File that WithLazyBlocks left in r, which holds the size bytes of the stream
+// and is closed once the parse returns
+func (c *File) loadPartial(r io.ReaderAt, size int64) {
+	if c.wrapped {
+		return
+	}
+	c.attachSource(r, size)
+	for _, block := range c.Meta {
+		block.Load()
+		block.src = nil
+	}
+	c.src, c.srcSize = nil, 0
+}
@@ -0,0 +1,144 @@
+package flac
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Peaks is the waveform overview of an audio stream, one peak per SamplesPerPeak inter-channel samples and channel
+type Peaks struct {
+	// SampleRate is the sample rate of the stream in Hz
+	SampleRate int
+	// SamplesPerPeak is the number of inter-channel samples summarized by each peak; the last peak may cover fewer
+	SamplesPerPeak int
+	// BitDepth is the number of bits per sample of Min and Max
+	BitDepth int
+	// Min and Max hold the lowest and highest sample of each peak, Min[channel][peak]
+	Min, Max [][]int32
+	// RMS holds the root mean square of each peak, normalized to full scale (0 to 1)
+	RMS [][]float64
+}
+
+// ComputePeaks decodes the audio of the File and summarizes every resolution inter-channel samples into one min/max and RMS peak per channel,
+// for drawing waveforms. Decoding consumes Frames.
+func (c *File) ComputePeaks(resolution int) (*Peaks, error) {
+	if resolution <= 0 {
+		return nil, ErrorInvalidResolution
+	}
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	dec, err := c.Decoder()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Peaks{
+		SampleRate:     info.SampleRate,
+		SamplesPerPeak: resolution,
+		BitDepth:       info.BitDepth,
+		Min:            make([][]int32, info.ChannelCount),
+		Max:            make([][]int32, info.ChannelCount),
+		RMS:            make([][]float64, info.ChannelCount),
+	}
+	fullScale := math.Ldexp(1, info.BitDepth-1)
+	sums := make([]float64, info.ChannelCount)
+	count := 0
+	flush := func() {
+		for ch := range sums {
+			res.RMS[ch] = append(res.RMS[ch], math.Sqrt(sums[ch]/float64(count))/fullScale)
+			sums[ch] = 0
+		}
+		count = 0
+	}
+
+	for {
+		frame, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(frame.Samples) != info.ChannelCount {
+			return nil, ErrorInvalidFrameHeader
+		}
+		for i := 0; i < frame.Header.BlockSize; i++ {
+			for ch, samples := range frame.Samples {
+				v := samples[i]
+				if count == 0 {
+					res.Min[ch] = append(res.Min[ch], v)
+					res.Max[ch] = append(res.Max[ch], v)
+				} else {
+					last := len(res.Min[ch]) - 1
+					if v < res.Min[ch][last] {
+						res.Min[ch][last] = v
+					}
+					if v > res.Max[ch][last] {
+						res.Max[ch][last] = v
+					}
+				}
+				sums[ch] += float64(v) * float64(v)
+			}
+			if count++; count == resolution {
+				flush()
+			}
+		}
+	}
+	if count > 0 {
+		flush()
+	}
+	return res, nil
+}
+
+// Len returns the number of peaks per channel
+func (p *Peaks) Len() int {
+	if len(p.Min) == 0 {
+		return 0
+	}
+	return len(p.Min[0])
+}
+
+// WriteBBC writes the min/max peaks in the binary waveform data format (version 2) of the BBC audiowaveform tool,
+// as read by waveform UIs such as peaks.js. bits selects 8 or 16-bit peak values; samples are scaled from BitDepth.
+func (p *Peaks) WriteBBC(w io.Writer, bits int) error {
+	var flags uint32
+	switch bits {
+	case 8:
+		flags = 1
+	case 16:
+	default:
+		return ErrorInvalidResolution
+	}
+
+	length := p.Len()
+	buf := make([]byte, 0, 24+length*len(p.Min)*2*bits/8)
+	buf = binary.LittleEndian.AppendUint32(buf, 2)
+	buf = binary.LittleEndian.AppendUint32(buf, flags)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.SampleRate))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.SamplesPerPeak))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(length))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(p.Min)))
+
+	scale := func(v int32) int32 {
+		if shift := p.BitDepth - bits; shift > 0 {
+			return v >> shift
+		}
+		return v << (bits - p.BitDepth)
+	}
+	for i := 0; i < length; i++ {
+		for ch := range p.Min {
+			lo, hi := scale(p.Min[ch][i]), scale(p.Max[ch][i])
+			if bits == 8 {
+				buf = append(buf, byte(int8(lo)), byte(int8(hi)))
+			} else {
+				buf = binary.LittleEndian.AppendUint16(buf, uint16(int16(lo)))
+				buf = binary.LittleEndian.AppendUint16(buf, uint16(int16(hi)))
+			}
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
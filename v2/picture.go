@@ -0,0 +1,253 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// PictureType is the kind of picture stored in a Picture metadata block, using the same values as ID3v2 APIC frames
+type PictureType uint32
+
+const (
+	// PictureTypeOther Other
+	PictureTypeOther PictureType = iota
+	// PictureTypeFileIcon 32x32 pixels 'file icon' (PNG only)
+	PictureTypeFileIcon
+	// PictureTypeOtherIcon Other file icon
+	PictureTypeOtherIcon
+	// PictureTypeFrontCover Cover (front)
+	PictureTypeFrontCover
+	// PictureTypeBackCover Cover (back)
+	PictureTypeBackCover
+	// PictureTypeLeaflet Leaflet page
+	PictureTypeLeaflet
+	// PictureTypeMedia Media (e.g. label side of CD)
+	PictureTypeMedia
+	// PictureTypeLeadArtist Lead artist/lead performer/soloist
+	PictureTypeLeadArtist
+	// PictureTypeArtist Artist/performer
+	PictureTypeArtist
+	// PictureTypeConductor Conductor
+	PictureTypeConductor
+	// PictureTypeBand Band/Orchestra
+	PictureTypeBand
+	// PictureTypeComposer Composer
+	PictureTypeComposer
+	// PictureTypeLyricist Lyricist/text writer
+	PictureTypeLyricist
+	// PictureTypeRecordingLocation Recording Location
+	PictureTypeRecordingLocation
+	// PictureTypeDuringRecording During recording
+	PictureTypeDuringRecording
+	// PictureTypeDuringPerformance During performance
+	PictureTypeDuringPerformance
+	// PictureTypeScreenCapture Movie/video screen capture
+	PictureTypeScreenCapture
+	// PictureTypeBrightColouredFish A bright coloured fish
+	PictureTypeBrightColouredFish
+	// PictureTypeIllustration Illustration
+	PictureTypeIllustration
+	// PictureTypeBandArtistLogotype Band/artist logotype
+	PictureTypeBandArtistLogotype
+	// PictureTypePublisherStudioLogotype Publisher/Studio logotype
+	PictureTypePublisherStudioLogotype
+)
+
+// pictureHeader holds the fields of a Picture metadata block preceding the image data
+type pictureHeader struct {
+	pictureType PictureType
+	mime        string
+	description string
+	// width, height, depth and colors describe the image, zero when unknown; colors is only set for indexed images
+	width, height, depth, colors uint32
+}
+
+// parsePicture decodes the picture type, MIME type and description of the data of a Picture metadata block along with the image data
+func parsePicture(data []byte) (res pictureHeader, image []byte, err error) {
+	if len(data) < 4 {
+		return res, nil, ErrorMalformedPicture
+	}
+	res.pictureType = PictureType(binary.BigEndian.Uint32(data))
+	data = data[4:]
+	for _, field := range []*string{&res.mime, &res.description} {
+		if len(data) < 4 {
+			return res, nil, ErrorMalformedPicture
+		}
+		length := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(length) > uint64(len(data)) {
+			return res, nil, ErrorMalformedPicture
+		}
+		*field = string(data[:length])
+		data = data[length:]
+	}
+	if len(data) < 20 {
+		return res, nil, ErrorMalformedPicture
+	}
+	res.width = binary.BigEndian.Uint32(data)
+	res.height = binary.BigEndian.Uint32(data[4:])
+	res.depth = binary.BigEndian.Uint32(data[8:])
+	res.colors = binary.BigEndian.Uint32(data[12:])
+	length := binary.BigEndian.Uint32(data[16:])
+	data = data[20:]
+	if uint64(length) > uint64(len(data)) {
+		return res, nil, ErrorMalformedPicture
+	}
+	return res, data[:length], nil
+}
+
+// marshalPicture encodes the data of a Picture metadata block
+func marshalPicture(header pictureHeader, image []byte) []byte {
+	res := make([]byte, 0, 32+len(header.mime)+len(header.description)+len(image))
+	res = binary.BigEndian.AppendUint32(res, uint32(header.pictureType))
+	res = binary.BigEndian.AppendUint32(res, uint32(len(header.mime)))
+	res = append(res, header.mime...)
+	res = binary.BigEndian.AppendUint32(res, uint32(len(header.description)))
+	res = append(res, header.description...)
+	res = binary.BigEndian.AppendUint32(res, header.width)
+	res = binary.BigEndian.AppendUint32(res, header.height)
+	res = binary.BigEndian.AppendUint32(res, header.depth)
+	res = binary.BigEndian.AppendUint32(res, header.colors)
+	res = binary.BigEndian.AppendUint32(res, uint32(len(image)))
+	res = append(res, image...)
+	return res
+}
+
+// PictureBlock is the decoded form of a Picture metadata block
+type PictureBlock struct {
+	PictureType PictureType
+	// MIME is the MIME type of the image, or "-->" when Data is a URL to the image
+	MIME        string
+	Description string
+	// Width, Height and ColorDepth describe the image in pixels and bits per pixel, zero when unknown
+	Width, Height, ColorDepth uint32
+	// IndexedColors is the number of colors of an indexed image such as a GIF, zero for non-indexed images
+	IndexedColors uint32
+	// Data is the image data
+	Data []byte
+
+	// trailing holds the bytes following the image data, kept so the block round-trips byte-exact
+	trailing []byte
+}
+
+// ParsePicture decodes a Picture metadata block, loading it first if it was parsed lazily.
+// The image data is not copied, so modifying Data of the result also modifies the block.
+func ParsePicture(meta *MetaDataBlock) (*PictureBlock, error) {
+	if meta.Type != Picture {
+		return nil, ErrorUnexpectedBlockType
+	}
+	if err := meta.Load(); err != nil {
+		return nil, err
+	}
+	header, image, err := parsePicture(meta.Data)
+	if err != nil {
+		return nil, err
+	}
+	res := &PictureBlock{
+		PictureType:   header.pictureType,
+		MIME:          header.mime,
+		Description:   header.description,
+		Width:         header.width,
+		Height:        header.height,
+		ColorDepth:    header.depth,
+		IndexedColors: header.colors,
+		Data:          image,
+	}
+	if end := 32 + len(header.mime) + len(header.description) + len(image); end < len(meta.Data) {
+		res.trailing = append([]byte(nil), meta.Data[end:]...)
+	}
+	return res, nil
+}
+
+// Marshal encodes the PictureBlock into a MetaDataBlock
+func (c *PictureBlock) Marshal() MetaDataBlock {
+	header := pictureHeader{
+		pictureType: c.PictureType,
+		mime:        c.MIME,
+		description: c.Description,
+		width:       c.Width,
+		height:      c.Height,
+		depth:       c.ColorDepth,
+		colors:      c.IndexedColors,
+	}
+	return MetaDataBlock{
+		Type: Picture,
+		Data: append(marshalPicture(header, c.Data), c.trailing...),
+	}
+}
+
+// Equal reports whether both blocks hold the same image with the same description and properties
+func (c *PictureBlock) Equal(other *PictureBlock) bool {
+	return c.PictureType == other.PictureType && c.MIME == other.MIME && c.Description == other.Description &&
+		c.Width == other.Width && c.Height == other.Height && c.ColorDepth == other.ColorDepth && c.IndexedColors == other.IndexedColors &&
+		bytes.Equal(c.Data, other.Data)
+}
+
+// pictureData returns the data of a Picture block, read from the source of lazily parsed blocks without loading them,
+// so listing pictures does not keep every image in memory
+func pictureData(meta *MetaDataBlock) ([]byte, error) {
+	if meta.Loaded() {
+		return meta.Data, nil
+	}
+	return io.ReadAll(meta.Reader())
+}
+
+// pictureTypeOf reads the picture type of a Picture metadata block without loading the rest of a lazily parsed block
+func pictureTypeOf(meta *MetaDataBlock) (PictureType, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(meta.Reader(), buf); err != nil {
+		return 0, ErrorMalformedPicture
+	}
+	return PictureType(binary.BigEndian.Uint32(buf)), nil
+}
+
+// CopyPictures copies the Picture blocks of src whose type is one of types, or all Picture blocks if types is empty, to dst.
+// Pictures of dst with the same types are removed first, so applying album art to every track of an album replaces the previous art.
+// Block bytes are copied as they are without decoding; blocks of src that are not loaded stay lazy and the data of loaded blocks is shared between both Files.
+func CopyPictures(dst, src *File, types ...PictureType) error {
+	wanted := func(PictureType) bool { return true }
+	if len(types) > 0 {
+		set := make(map[PictureType]bool, len(types))
+		for _, t := range types {
+			set[t] = true
+		}
+		wanted = func(t PictureType) bool { return set[t] }
+	}
+
+	var copied []*MetaDataBlock
+	for _, block := range src.Meta {
+		if block.Type != Picture {
+			continue
+		}
+		t, err := pictureTypeOf(block)
+		if err != nil {
+			return err
+		}
+		if wanted(t) {
+			cp := *block
+			copied = append(copied, &cp)
+		}
+	}
+
+	replaced := make(map[*MetaDataBlock]bool)
+	for _, block := range dst.Meta {
+		if block.Type != Picture {
+			continue
+		}
+		t, err := pictureTypeOf(block)
+		if err != nil {
+			return err
+		}
+		if wanted(t) {
+			replaced[block] = true
+		}
+	}
+
+	dst.removeBlocks(func(block *MetaDataBlock) bool { return replaced[block] })
+	for _, block := range copied {
+		dst.Meta = append(dst.Meta, block)
+		dst.modified(block)
+	}
+	return nil
+}
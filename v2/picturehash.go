@@ -0,0 +1,173 @@
+package flac
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+)
+
+// PictureChecksumApplicationID is the Application block ID used to store checksums of the embedded pictures:
+//
+//	ID "ARTH" | version (1 byte) | hash name length (1 byte) | hash name | image digest of every Picture block, in order
+//
+// The hash name is the crypto.Hash name, e.g. "SHA-256". Only the image data is hashed, so editing the description of a picture keeps its checksum valid.
+var PictureChecksumApplicationID = [4]byte{'A', 'R', 'T', 'H'}
+
+// pictureChecksumVersion is the version of the picture checksum block layout
+const pictureChecksumVersion = 1
+
+// PictureChecksumError indicates that embedded pictures do not match the checksums stored by SetPictureChecksums
+type PictureChecksumError struct {
+	// Indices are the indices in Meta of the Picture blocks whose image does not match its checksum, or that have no checksum
+	Indices []int
+	// Missing is the number of checksums left without a picture, after pictures were removed
+	Missing int
+}
+
+func (e *PictureChecksumError) Error() string {
+	return fmt.Sprintf("picture checksum mismatch at blocks %v, %d pictures missing", e.Indices, e.Missing)
+}
+
+// Is reports whether target is ErrorPictureChecksumMismatch
+func (e *PictureChecksumError) Is(target error) bool {
+	return target == ErrorPictureChecksumMismatch
+}
+
+// hashByName returns the registered crypto.Hash named name
+func hashByName(name string) (crypto.Hash, bool) {
+	for h := crypto.Hash(1); h < 64; h++ {
+		if h.String() == name {
+			return h, h.Available()
+		}
+	}
+	return 0, false
+}
+
+// pictureImage returns the image data of a Picture block, reading lazily parsed blocks without loading them
+func pictureImage(meta *MetaDataBlock) ([]byte, error) {
+	data, err := pictureData(meta)
+	if err != nil {
+		return nil, err
+	}
+	_, image, err := parsePicture(data)
+	return image, err
+}
+
+// pictureDigests returns the digest of the image of every Picture block along with its index in Meta
+func (c *File) pictureDigests(h crypto.Hash) (digests [][]byte, indices []int, err error) {
+	for i, meta := range c.Meta {
+		if meta.Type != Picture {
+			continue
+		}
+		image, err := pictureImage(meta)
+		if err != nil {
+			return nil, nil, err
+		}
+		hash := h.New()
+		hash.Write(image)
+		digests = append(digests, hash.Sum(nil))
+		indices = append(indices, i)
+	}
+	return digests, indices, nil
+}
+
+func parsePictureChecksums(meta *MetaDataBlock) (crypto.Hash, [][]byte, bool, error) {
+	app, err := ParseApplication(meta)
+	if err != nil || app.ID != PictureChecksumApplicationID {
+		return 0, nil, false, nil
+	}
+	data := app.Data
+	if len(data) < 2 || data[0] != pictureChecksumVersion || len(data) < 2+int(data[1]) {
+		return 0, nil, true, ErrorMalformedPictureChecksums
+	}
+	h, ok := hashByName(string(data[2 : 2+data[1]]))
+	if !ok {
+		return 0, nil, true, ErrorHashUnavailable
+	}
+	data = data[2+data[1]:]
+	if len(data)%h.Size() != 0 {
+		return 0, nil, true, ErrorMalformedPictureChecksums
+	}
+	var digests [][]byte
+	for ; len(data) > 0; data = data[h.Size():] {
+		digests = append(digests, data[:h.Size()])
+	}
+	return h, digests, true, nil
+}
+
+// SetPictureChecksums stores the digest of the image of every embedded picture computed with h, replacing any stored checksums,
+// so sync tools can detect corrupted or substituted artwork with VerifyPictureChecksums without comparing images.
+// Any hash registered with the crypto package can be used; it returns ErrorHashUnavailable if h is not linked into the binary.
+func (c *File) SetPictureChecksums(h crypto.Hash) error {
+	if !h.Available() {
+		return ErrorHashUnavailable
+	}
+	digests, _, err := c.pictureDigests(h)
+	if err != nil {
+		return err
+	}
+	name := h.String()
+	data := make([]byte, 0, 2+len(name)+len(digests)*h.Size())
+	data = append(data, pictureChecksumVersion, byte(len(name)))
+	data = append(data, name...)
+	for _, digest := range digests {
+		data = append(data, digest...)
+	}
+	if len(data)+4 > MaxBlockSize {
+		return ErrorBlockTooLarge
+	}
+
+	c.RemovePictureChecksums()
+	app := ApplicationBlock{ID: PictureChecksumApplicationID, Data: data}
+	meta := app.Marshal()
+	c.Meta = append(c.Meta, &meta)
+	c.modified(&meta)
+	return nil
+}
+
+// VerifyPictureChecksums checks the embedded pictures against the checksums stored by SetPictureChecksums, in order.
+// Application blocks of lazily parsed Files are loaded to find the checksums, pictures are read without being loaded.
+// It returns ErrorNoPictureChecksums if there are none, and a PictureChecksumError listing the pictures that changed, were added or were removed.
+func (c *File) VerifyPictureChecksums() error {
+	for _, meta := range c.Meta {
+		if meta.Type != Application {
+			continue
+		}
+		if err := meta.Load(); err != nil {
+			return err
+		}
+		h, stored, ok, err := parsePictureChecksums(meta)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		digests, indices, err := c.pictureDigests(h)
+		if err != nil {
+			return err
+		}
+		res := new(PictureChecksumError)
+		for i, digest := range digests {
+			if i >= len(stored) || !bytes.Equal(digest, stored[i]) {
+				res.Indices = append(res.Indices, indices[i])
+			}
+		}
+		if len(stored) > len(digests) {
+			res.Missing = len(stored) - len(digests)
+		}
+		if len(res.Indices) > 0 || res.Missing > 0 {
+			return res
+		}
+		return nil
+	}
+	return ErrorNoPictureChecksums
+}
+
+// RemovePictureChecksums removes any stored picture checksums
+func (c *File) RemovePictureChecksums() {
+	c.removeBlocks(func(block *MetaDataBlock) bool {
+		_, _, ok, _ := parsePictureChecksums(block)
+		return ok
+	})
+}
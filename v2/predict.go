@@ -0,0 +1,124 @@
+package flac
+
+import "math/bits"
+
+// The prediction loops below dominate decoding time. Every predicted sample feeds the prediction of the next one, so the vector
+// kernels of predict_amd64.s and predict_arm64.s restore 4 samples at a time: the part of their predictions that only depends on
+// earlier samples is a matrix product, and only the samples of the group itself are chained one at a time. The fixed predictors
+// are running sums of the residual, which vectorize as prefix sums. Building with the purego tag, or on other architectures, leaves
+// the Go loops, which like the reference decoder pick a 32-bit accumulator whenever the bit depth, coefficient precision and order
+// guarantee the prediction cannot overflow it, and unroll the common orders so the coefficients and the sample history stay in
+// registers. BenchmarkRestoreLPC and BenchmarkRestoreFixed measure the kernels against the generic loops.
+
+// restoreFixed adds the fixed polynomial prediction of the given order to the residual in out[order:], for samples of bps bits
+func restoreFixed(out []int32, order, bps int) {
+	// the running sums wrap around like the 64-bit predictions truncated to 32 bits, whatever the bit depth
+	if restoreFixedVector(out, order) {
+		return
+	}
+	// the order 4 predictor multiplies samples by up to 6 and sums to at most 16 times the largest sample
+	if bps+4 > 32 {
+		restoreFixedWide(out, order)
+		return
+	}
+	switch order {
+	case 1:
+		for i := 1; i < len(out); i++ {
+			out[i] += out[i-1]
+		}
+	case 2:
+		for i := 2; i < len(out); i++ {
+			out[i] += 2*out[i-1] - out[i-2]
+		}
+	case 3:
+		for i := 3; i < len(out); i++ {
+			out[i] += 3*(out[i-1]-out[i-2]) + out[i-3]
+		}
+	case 4:
+		for i := 4; i < len(out); i++ {
+			out[i] += 4*(out[i-1]+out[i-3]) - 6*out[i-2] - out[i-4]
+		}
+	}
+}
+
+// restoreFixedWide is restoreFixed with 64-bit arithmetic
+func restoreFixedWide(out []int32, order int) {
+	switch order {
+	case 1:
+		for i := 1; i < len(out); i++ {
+			out[i] += out[i-1]
+		}
+	case 2:
+		for i := 2; i < len(out); i++ {
+			out[i] += int32(2*int64(out[i-1]) - int64(out[i-2]))
+		}
+	case 3:
+		for i := 3; i < len(out); i++ {
+			out[i] += int32(3*int64(out[i-1]) - 3*int64(out[i-2]) + int64(out[i-3]))
+		}
+	case 4:
+		for i := 4; i < len(out); i++ {
+			out[i] += int32(4*int64(out[i-1]) - 6*int64(out[i-2]) + 4*int64(out[i-3]) - int64(out[i-4]))
+		}
+	}
+}
+
+// restoreLPC adds the linear prediction with the quantized coefficients of precision bits and shift to the residual in out[len(coefs):],
+// for samples of bps bits
+func restoreLPC(out []int32, coefs []int32, shift uint, bps, precision int) {
+	order := len(coefs)
+	wide := bps+precision+bits.Len(uint(order)) > 32
+	if restoreLPCVector(out, coefs, shift, wide) {
+		return
+	}
+	if wide {
+		restoreLPCWide(out, coefs, shift)
+		return
+	}
+	if order > 12 || len(out) <= 12 {
+		restoreLPC32(out, coefs, shift)
+		return
+	}
+	// the first samples lack the history of the zero-padded coefficients
+	restoreLPC32(out[:12], coefs, shift)
+	var c [12]int32
+	copy(c[:], coefs)
+	if order <= 8 {
+		for i := 12; i < len(out); i++ {
+			h := out[i-8 : i : i]
+			sum := c[0]*h[7] + c[1]*h[6] + c[2]*h[5] + c[3]*h[4] + c[4]*h[3] + c[5]*h[2] + c[6]*h[1] + c[7]*h[0]
+			out[i] += sum >> shift
+		}
+		return
+	}
+	for i := 12; i < len(out); i++ {
+		h := out[i-12 : i : i]
+		sum := c[0]*h[11] + c[1]*h[10] + c[2]*h[9] + c[3]*h[8] + c[4]*h[7] + c[5]*h[6] +
+			c[6]*h[5] + c[7]*h[4] + c[8]*h[3] + c[9]*h[2] + c[10]*h[1] + c[11]*h[0]
+		out[i] += sum >> shift
+	}
+}
+
+// restoreLPC32 is the generic restoreLPC with a 32-bit accumulator
+func restoreLPC32(out []int32, coefs []int32, shift uint) {
+	order := len(coefs)
+	for i := order; i < len(out); i++ {
+		var sum int32
+		for j, c := range coefs {
+			sum += c * out[i-1-j]
+		}
+		out[i] += sum >> shift
+	}
+}
+
+// restoreLPCWide is the generic restoreLPC with a 64-bit accumulator
+func restoreLPCWide(out []int32, coefs []int32, shift uint) {
+	order := len(coefs)
+	for i := order; i < len(out); i++ {
+		var sum int64
+		for j, c := range coefs {
+			sum += int64(c) * int64(out[i-1-j])
+		}
+		out[i] += int32(sum >> shift)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !purego
+
+package flac
+
+import "golang.org/x/sys/cpu"
+
+// hasLPCKernel reports whether the CPU has the SSE4.1 multiplications of the LPC kernels; the fixed kernel only needs SSE2
+var hasLPCKernel = cpu.X86.HasSSE41
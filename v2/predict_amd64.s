@@ -0,0 +1,366 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func lpcKernel32(out []int32, start int, columns *[4 * maxLPCOrder]int32, window int, shift uint, c0, c1, c2 int32)
+TEXT ·lpcKernel32(SB), NOSPLIT, $0-68
+	MOVQ out_base+0(FP), DI
+	MOVQ out_len+8(FP), DX
+	MOVQ start+24(FP), AX
+	MOVQ columns+32(FP), SI
+	MOVQ window+40(FP), R11
+	MOVQ shift+48(FP), CX
+	MOVL c0+56(FP), R8
+	MOVL c1+60(FP), R9
+	MOVL c2+64(FP), R10
+	SHLQ $2, R11
+	LEAQ (DI)(DX*4), DX
+	LEAQ (DI)(AX*4), DI
+	CMPQ DI, DX
+	JAE lpc32Done
+
+	// the columns of the newest 4 samples of the window stay in registers, as do the samples
+	LEAQ -64(SI)(R11*4), AX
+	MOVOU 0(AX), X10
+	MOVOU 16(AX), X11
+	MOVOU 32(AX), X12
+	MOVOU 48(AX), X13
+	MOVOU -16(DI), X9
+	SUBQ $16, R11
+
+lpc32Group:
+	MOVQ DI, R12
+	SUBQ R11, R12
+	SUBQ $16, R12
+	MOVQ SI, R13
+	PXOR X0, X0
+	PXOR X1, X1
+	LEAQ -16(DI), R14
+	CMPQ R12, R14
+	JAE lpc32Newest
+
+lpc32Window:
+	MOVOU (R12), X4
+	PSHUFD $0x00, X4, X5
+	MOVOU 0(R13), X6
+	PMULLD X5, X6
+	PADDD X6, X0
+	PSHUFD $0x55, X4, X5
+	MOVOU 16(R13), X7
+	PMULLD X5, X7
+	PADDD X7, X1
+	PSHUFD $0xAA, X4, X5
+	MOVOU 32(R13), X6
+	PMULLD X5, X6
+	PADDD X6, X0
+	PSHUFD $0xFF, X4, X5
+	MOVOU 48(R13), X7
+	PMULLD X5, X7
+	PADDD X7, X1
+	ADDQ $16, R12
+	ADDQ $64, R13
+	CMPQ R12, R14
+	JB lpc32Window
+
+lpc32Newest:
+	PSHUFD $0x00, X9, X5
+	PMULLD X10, X5
+	PADDD X5, X0
+	PSHUFD $0x55, X9, X6
+	PMULLD X11, X6
+	PADDD X6, X1
+	PSHUFD $0xAA, X9, X7
+	PMULLD X12, X7
+	PADDD X7, X0
+	PSHUFD $0xFF, X9, X8
+	PMULLD X13, X8
+	PADDD X8, X1
+	PADDD X1, X0
+
+	// the samples of the group weigh in the prediction of the ones after them
+	MOVL X0, AX
+	SARL CX, AX
+	ADDL (DI), AX
+
+	PEXTRD $1, X0, BX
+	MOVL AX, R14
+	IMULL R8, R14
+	ADDL R14, BX
+	SARL CX, BX
+	ADDL 4(DI), BX
+
+	PEXTRD $2, X0, R12
+	MOVL BX, R14
+	IMULL R8, R14
+	ADDL R14, R12
+	MOVL AX, R14
+	IMULL R9, R14
+	ADDL R14, R12
+	SARL CX, R12
+	ADDL 8(DI), R12
+
+	PEXTRD $3, X0, R13
+	MOVL R12, R14
+	IMULL R8, R14
+	ADDL R14, R13
+	MOVL BX, R14
+	IMULL R9, R14
+	ADDL R14, R13
+	MOVL AX, R14
+	IMULL R10, R14
+	ADDL R14, R13
+	SARL CX, R13
+	ADDL 12(DI), R13
+
+	MOVQ AX, X9
+	PINSRD $1, BX, X9
+	PINSRD $2, R12, X9
+	PINSRD $3, R13, X9
+	MOVOU X9, (DI)
+
+	ADDQ $16, DI
+	CMPQ DI, DX
+	JB lpc32Group
+
+lpc32Done:
+	RET
+
+// func lpcKernel64(out []int32, start int, columns *[4 * maxLPCOrder]int32, window int, shift uint, c0, c1, c2 int32)
+//
+// PMULDQ multiplies the even lanes, so the products for the 1st and 3rd samples of a group sum in X0 and, with the columns
+// shifted down a lane, those for the 2nd and 4th in X1.
+TEXT ·lpcKernel64(SB), NOSPLIT, $0-68
+	MOVQ out_base+0(FP), DI
+	MOVQ out_len+8(FP), DX
+	MOVQ start+24(FP), AX
+	MOVQ columns+32(FP), SI
+	MOVQ window+40(FP), R11
+	MOVQ shift+48(FP), CX
+	MOVLQSX c0+56(FP), R8
+	MOVLQSX c1+60(FP), R9
+	MOVLQSX c2+64(FP), R10
+	SHLQ $2, R11
+	LEAQ (DI)(DX*4), DX
+	LEAQ (DI)(AX*4), DI
+	CMPQ DI, DX
+	JAE lpc64Done
+
+	LEAQ -64(SI)(R11*4), AX
+	MOVOU 0(AX), X10
+	MOVOU 16(AX), X11
+	MOVOU 32(AX), X12
+	MOVOU 48(AX), X13
+	MOVOU X10, X2
+	PSRLQ $32, X2
+	MOVOU X11, X3
+	PSRLQ $32, X3
+	MOVOU X12, X8
+	PSRLQ $32, X8
+	MOVOU X13, X14
+	PSRLQ $32, X14
+	MOVOU -16(DI), X9
+	SUBQ $16, R11
+
+lpc64Group:
+	MOVQ DI, R12
+	SUBQ R11, R12
+	SUBQ $16, R12
+	MOVQ SI, R13
+	PXOR X0, X0
+	PXOR X1, X1
+	LEAQ -16(DI), R14
+	CMPQ R12, R14
+	JAE lpc64Newest
+
+lpc64Window:
+	MOVOU (R12), X4
+	PSHUFD $0x00, X4, X5
+	MOVOU 0(R13), X6
+	MOVOU X6, X7
+	PSRLQ $32, X7
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	PMULDQ X5, X7
+	PADDQ X7, X1
+	PSHUFD $0x55, X4, X5
+	MOVOU 16(R13), X6
+	MOVOU X6, X7
+	PSRLQ $32, X7
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	PMULDQ X5, X7
+	PADDQ X7, X1
+	PSHUFD $0xAA, X4, X5
+	MOVOU 32(R13), X6
+	MOVOU X6, X7
+	PSRLQ $32, X7
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	PMULDQ X5, X7
+	PADDQ X7, X1
+	PSHUFD $0xFF, X4, X5
+	MOVOU 48(R13), X6
+	MOVOU X6, X7
+	PSRLQ $32, X7
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	PMULDQ X5, X7
+	PADDQ X7, X1
+	ADDQ $16, R12
+	ADDQ $64, R13
+	CMPQ R12, R14
+	JB lpc64Window
+
+lpc64Newest:
+	PSHUFD $0x00, X9, X5
+	MOVOU X10, X6
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	MOVOU X2, X7
+	PMULDQ X5, X7
+	PADDQ X7, X1
+	PSHUFD $0x55, X9, X5
+	MOVOU X11, X6
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	MOVOU X3, X7
+	PMULDQ X5, X7
+	PADDQ X7, X1
+	PSHUFD $0xAA, X9, X5
+	MOVOU X12, X6
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	MOVOU X8, X7
+	PMULDQ X5, X7
+	PADDQ X7, X1
+	PSHUFD $0xFF, X9, X5
+	MOVOU X13, X6
+	PMULDQ X5, X6
+	PADDQ X6, X0
+	MOVOU X14, X7
+	PMULDQ X5, X7
+	PADDQ X7, X1
+
+	// the samples of the group weigh in the prediction of the ones after them
+	MOVQ X0, AX
+	SARQ CX, AX
+	ADDL (DI), AX
+
+	MOVQ X1, BX
+	MOVLQSX AX, R14
+	IMULQ R8, R14
+	ADDQ R14, BX
+	SARQ CX, BX
+	ADDL 4(DI), BX
+
+	PEXTRQ $1, X0, R12
+	MOVLQSX BX, R14
+	IMULQ R8, R14
+	ADDQ R14, R12
+	MOVLQSX AX, R14
+	IMULQ R9, R14
+	ADDQ R14, R12
+	SARQ CX, R12
+	ADDL 8(DI), R12
+
+	PEXTRQ $1, X1, R13
+	MOVLQSX R12, R14
+	IMULQ R8, R14
+	ADDQ R14, R13
+	MOVLQSX BX, R14
+	IMULQ R9, R14
+	ADDQ R14, R13
+	MOVLQSX AX, R14
+	IMULQ R10, R14
+	ADDQ R14, R13
+	SARQ CX, R13
+	ADDL 12(DI), R13
+
+	MOVQ AX, X9
+	PINSRD $1, BX, X9
+	PINSRD $2, R12, X9
+	PINSRD $3, R13, X9
+	MOVOU X9, (DI)
+
+	ADDQ $16, DI
+	CMPQ DI, DX
+	JB lpc64Group
+
+lpc64Done:
+	RET
+
+// SCAN replaces the 4 samples in X4 with their running sum, starting from the carry broadcast in c, and broadcasts the last sum
+// to c for the next group.
+#define SCAN(c) \
+	MOVOU X4, X5; \
+	PSLLO $4, X5; \
+	PADDD X5, X4; \
+	MOVOU X4, X5; \
+	PSLLO $8, X5; \
+	PADDD X5, X4; \
+	PADDD c, X4; \
+	PSHUFD $0xFF, X4, c
+
+// func fixedKernel(out []int32, carries *[4]int32, order int)
+TEXT ·fixedKernel(SB), NOSPLIT, $0-40
+	MOVQ out_base+0(FP), DI
+	MOVQ out_len+8(FP), DX
+	MOVQ carries+24(FP), SI
+	MOVQ order+32(FP), AX
+	LEAQ (DI)(DX*4), DX
+	MOVOU (SI), X4
+	PSHUFD $0x00, X4, X0
+	PSHUFD $0x55, X4, X1
+	PSHUFD $0xAA, X4, X2
+	PSHUFD $0xFF, X4, X3
+	CMPQ DI, DX
+	JAE fixedDone
+	CMPQ AX, $2
+	JB fixed1
+	JE fixed2
+	CMPQ AX, $3
+	JE fixed3
+
+fixed4:
+	MOVOU (DI), X4
+	SCAN(X0)
+	SCAN(X1)
+	SCAN(X2)
+	SCAN(X3)
+	MOVOU X4, (DI)
+	ADDQ $16, DI
+	CMPQ DI, DX
+	JB fixed4
+	RET
+
+fixed3:
+	MOVOU (DI), X4
+	SCAN(X0)
+	SCAN(X1)
+	SCAN(X2)
+	MOVOU X4, (DI)
+	ADDQ $16, DI
+	CMPQ DI, DX
+	JB fixed3
+	RET
+
+fixed2:
+	MOVOU (DI), X4
+	SCAN(X0)
+	SCAN(X1)
+	MOVOU X4, (DI)
+	ADDQ $16, DI
+	CMPQ DI, DX
+	JB fixed2
+	RET
+
+fixed1:
+	MOVOU (DI), X4
+	SCAN(X0)
+	MOVOU X4, (DI)
+	ADDQ $16, DI
+	CMPQ DI, DX
+	JB fixed1
+
+fixedDone:
+	RET
@@ -0,0 +1,6 @@
+//go:build !purego
+
+package flac
+
+// hasLPCKernel is always set, as every arm64 CPU has the NEON instructions of the kernels
+const hasLPCKernel = true
@@ -0,0 +1,282 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// The assembler lacks the by element forms of MLA and SMLAL, which multiply a vector by a lane of another one, so they are encoded here.
+// m is a register below V16 and i a lane of its 4 32-bit lanes.
+#define ELEM(op, d, n, m, i) WORD $(op | ((i)&1)<<21 | (m)<<16 | ((i)>>1)<<11 | (n)<<5 | (d))
+
+// VMLAS adds the 4 products of Vn.S4 with Vm.S[i] to Vd.S4
+#define VMLAS(d, n, m, i) ELEM(0x6F800000, d, n, m, i)
+
+// VSMLAL adds the 2 64-bit products of the low lanes of Vn.S4 with Vm.S[i] to Vd.D2, and VSMLAL2 those of the high lanes
+#define VSMLAL(d, n, m, i) ELEM(0x0F802000, d, n, m, i)
+#define VSMLAL2(d, n, m, i) ELEM(0x4F802000, d, n, m, i)
+
+// func lpcKernel32(out []int32, start int, columns *[4 * maxLPCOrder]int32, window int, shift uint, c0, c1, c2 int32)
+TEXT ·lpcKernel32(SB), NOSPLIT, $0-68
+	MOVD out_base+0(FP), R0
+	MOVD out_len+8(FP), R1
+	MOVD start+24(FP), R2
+	MOVD columns+32(FP), R3
+	MOVD window+40(FP), R4
+	MOVD shift+48(FP), R5
+	MOVW c0+56(FP), R6
+	MOVW c1+60(FP), R7
+	MOVW c2+64(FP), R8
+	ADD R1<<2, R0, R1
+	ADD R2<<2, R0, R0
+	CMP R1, R0
+	BHS lpc32Done
+
+	// the columns of the newest 4 samples of the window stay in registers, as do the samples
+	ADD R4<<4, R3, R9
+	SUB $64, R9
+	VLD1 (R9), [V10.S4, V11.S4, V12.S4, V13.S4]
+	SUB $16, R0, R9
+	VLD1 (R9), [V9.S4]
+	LSL $2, R4
+	SUB $16, R4
+
+lpc32Group:
+	SUB R4, R0, R9
+	SUB $16, R9
+	MOVD R3, R10
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	SUB $16, R0, R11
+	CMP R11, R9
+	BHS lpc32Newest
+
+lpc32Window:
+	VLD1.P 16(R9), [V4.S4]
+	VLD1.P 64(R10), [V5.S4, V6.S4, V7.S4, V8.S4]
+	VMLAS(0, 5, 4, 0)
+	VMLAS(1, 6, 4, 1)
+	VMLAS(0, 7, 4, 2)
+	VMLAS(1, 8, 4, 3)
+	CMP R11, R9
+	BLO lpc32Window
+
+lpc32Newest:
+	VMLAS(0, 10, 9, 0)
+	VMLAS(1, 11, 9, 1)
+	VMLAS(0, 12, 9, 2)
+	VMLAS(1, 13, 9, 3)
+	VADD V1.S4, V0.S4, V0.S4
+
+	// the samples of the group weigh in the prediction of the ones after them
+	VMOV V0.S[0], R11
+	ASRW R5, R11
+	MOVW (R0), R15
+	ADDW R15, R11
+
+	VMOV V0.S[1], R12
+	MADDW R6, R12, R11, R12
+	ASRW R5, R12
+	MOVW 4(R0), R15
+	ADDW R15, R12
+
+	VMOV V0.S[2], R13
+	MADDW R6, R13, R12, R13
+	MADDW R7, R13, R11, R13
+	ASRW R5, R13
+	MOVW 8(R0), R15
+	ADDW R15, R13
+
+	VMOV V0.S[3], R14
+	MADDW R6, R14, R13, R14
+	MADDW R7, R14, R12, R14
+	MADDW R8, R14, R11, R14
+	ASRW R5, R14
+	MOVW 12(R0), R15
+	ADDW R15, R14
+
+	VMOV R11, V9.S[0]
+	VMOV R12, V9.S[1]
+	VMOV R13, V9.S[2]
+	VMOV R14, V9.S[3]
+	VST1.P [V9.S4], 16(R0)
+
+	CMP R1, R0
+	BLO lpc32Group
+
+lpc32Done:
+	RET
+
+// func lpcKernel64(out []int32, start int, columns *[4 * maxLPCOrder]int32, window int, shift uint, c0, c1, c2 int32)
+//
+// The products for the 1st and 2nd samples of a group sum in V0 and V2, those for the 3rd and 4th in V1 and V3.
+TEXT ·lpcKernel64(SB), NOSPLIT, $0-68
+	MOVD out_base+0(FP), R0
+	MOVD out_len+8(FP), R1
+	MOVD start+24(FP), R2
+	MOVD columns+32(FP), R3
+	MOVD window+40(FP), R4
+	MOVD shift+48(FP), R5
+	MOVW c0+56(FP), R6
+	MOVW c1+60(FP), R7
+	MOVW c2+64(FP), R8
+	ADD R1<<2, R0, R1
+	ADD R2<<2, R0, R0
+	CMP R1, R0
+	BHS lpc64Done
+
+	ADD R4<<4, R3, R9
+	SUB $64, R9
+	VLD1 (R9), [V10.S4, V11.S4, V12.S4, V13.S4]
+	SUB $16, R0, R9
+	VLD1 (R9), [V9.S4]
+	LSL $2, R4
+	SUB $16, R4
+
+lpc64Group:
+	SUB R4, R0, R9
+	SUB $16, R9
+	MOVD R3, R10
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+	SUB $16, R0, R11
+	CMP R11, R9
+	BHS lpc64Newest
+
+lpc64Window:
+	VLD1.P 16(R9), [V4.S4]
+	VLD1.P 64(R10), [V5.S4, V6.S4, V7.S4, V8.S4]
+	VSMLAL(0, 5, 4, 0)
+	VSMLAL2(1, 5, 4, 0)
+	VSMLAL(2, 6, 4, 1)
+	VSMLAL2(3, 6, 4, 1)
+	VSMLAL(0, 7, 4, 2)
+	VSMLAL2(1, 7, 4, 2)
+	VSMLAL(2, 8, 4, 3)
+	VSMLAL2(3, 8, 4, 3)
+	CMP R11, R9
+	BLO lpc64Window
+
+lpc64Newest:
+	VSMLAL(0, 10, 9, 0)
+	VSMLAL2(1, 10, 9, 0)
+	VSMLAL(2, 11, 9, 1)
+	VSMLAL2(3, 11, 9, 1)
+	VSMLAL(0, 12, 9, 2)
+	VSMLAL2(1, 12, 9, 2)
+	VSMLAL(2, 13, 9, 3)
+	VSMLAL2(3, 13, 9, 3)
+	VADD V2.D2, V0.D2, V0.D2
+	VADD V3.D2, V1.D2, V1.D2
+
+	// the samples of the group weigh in the prediction of the ones after them
+	VMOV V0.D[0], R11
+	ASR R5, R11
+	MOVW (R0), R15
+	ADDW R15, R11
+	SXTW R11, R11
+
+	VMOV V0.D[1], R12
+	MADD R6, R12, R11, R12
+	ASR R5, R12
+	MOVW 4(R0), R15
+	ADDW R15, R12
+	SXTW R12, R12
+
+	VMOV V1.D[0], R13
+	MADD R6, R13, R12, R13
+	MADD R7, R13, R11, R13
+	ASR R5, R13
+	MOVW 8(R0), R15
+	ADDW R15, R13
+	SXTW R13, R13
+
+	VMOV V1.D[1], R14
+	MADD R6, R14, R13, R14
+	MADD R7, R14, R12, R14
+	MADD R8, R14, R11, R14
+	ASR R5, R14
+	MOVW 12(R0), R15
+	ADDW R15, R14
+
+	VMOV R11, V9.S[0]
+	VMOV R12, V9.S[1]
+	VMOV R13, V9.S[2]
+	VMOV R14, V9.S[3]
+	VST1.P [V9.S4], 16(R0)
+
+	CMP R1, R0
+	BLO lpc64Group
+
+lpc64Done:
+	RET
+
+// SCAN replaces the 4 samples in V4 with their running sum, starting from the carry broadcast in c, and broadcasts the last sum
+// to c for the next group. V31 is zero.
+#define SCAN(c) \
+	VEXT $12, V4.B16, V31.B16, V5.B16; \
+	VADD V5.S4, V4.S4, V4.S4; \
+	VEXT $8, V4.B16, V31.B16, V5.B16; \
+	VADD V5.S4, V4.S4, V4.S4; \
+	VADD c.S4, V4.S4, V4.S4; \
+	VDUP V4.S[3], c.S4
+
+// func fixedKernel(out []int32, carries *[4]int32, order int)
+TEXT ·fixedKernel(SB), NOSPLIT, $0-40
+	MOVD out_base+0(FP), R0
+	MOVD out_len+8(FP), R1
+	MOVD carries+24(FP), R2
+	MOVD order+32(FP), R3
+	ADD R1<<2, R0, R1
+	VLD1 (R2), [V4.S4]
+	VDUP V4.S[0], V0.S4
+	VDUP V4.S[1], V1.S4
+	VDUP V4.S[2], V2.S4
+	VDUP V4.S[3], V3.S4
+	VEOR V31.B16, V31.B16, V31.B16
+	CMP R1, R0
+	BHS fixedDone
+	CMP $2, R3
+	BLO fixed1
+	BEQ fixed2
+	CMP $3, R3
+	BEQ fixed3
+
+fixed4:
+	VLD1 (R0), [V4.S4]
+	SCAN(V0)
+	SCAN(V1)
+	SCAN(V2)
+	SCAN(V3)
+	VST1.P [V4.S4], 16(R0)
+	CMP R1, R0
+	BLO fixed4
+	RET
+
+fixed3:
+	VLD1 (R0), [V4.S4]
+	SCAN(V0)
+	SCAN(V1)
+	SCAN(V2)
+	VST1.P [V4.S4], 16(R0)
+	CMP R1, R0
+	BLO fixed3
+	RET
+
+fixed2:
+	VLD1 (R0), [V4.S4]
+	SCAN(V0)
+	SCAN(V1)
+	VST1.P [V4.S4], 16(R0)
+	CMP R1, R0
+	BLO fixed2
+	RET
+
+fixed1:
+	VLD1 (R0), [V4.S4]
+	SCAN(V0)
+	VST1.P [V4.S4], 16(R0)
+	CMP R1, R0
+	BLO fixed1
+
+fixedDone:
+	RET
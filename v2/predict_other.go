@@ -0,0 +1,13 @@
+//go:build !(amd64 || arm64) || purego
+
+package flac
+
+// restoreLPCVector reports false, as there is no vector kernel for this architecture or the purego build tag is set
+func restoreLPCVector(out []int32, coefs []int32, shift uint, wide bool) bool {
+	return false
+}
+
+// restoreFixedVector reports false, as there is no vector kernel for this architecture or the purego build tag is set
+func restoreFixedVector(out []int32, order int) bool {
+	return false
+}
@@ -0,0 +1,91 @@
+//go:build (amd64 || arm64) && !purego
+
+package flac
+
+// The vector kernels restore groups of 4 samples. The prediction of a group from the samples before it is a product of the window of
+// the last samples with a matrix holding, for each sample of the window, the coefficients it is multiplied by in the 4 predictions.
+// The kernels accumulate that product in vector registers, then add the contributions of the samples of the group itself one sample
+// at a time, and store the group with a single vector store so the next group loads it back without a store forwarding stall.
+
+// lpcKernel32 restores out[start:], in groups of 4 samples, with the columns of lpcColumns for a window of the given length, a multiple of 4.
+// c0, c1 and c2 are the first 3 coefficients, which weight the samples of a group in the prediction of the next ones. The sums are 32-bit.
+//
+//go:noescape
+func lpcKernel32(out []int32, start int, columns *[4 * maxLPCOrder]int32, window int, shift uint, c0, c1, c2 int32)
+
+// lpcKernel64 is lpcKernel32 with 64-bit sums
+//
+//go:noescape
+func lpcKernel64(out []int32, start int, columns *[4 * maxLPCOrder]int32, window int, shift uint, c0, c1, c2 int32)
+
+// fixedKernel restores out, in groups of 4 samples, as order running sums of the residual, starting from carries
+//
+//go:noescape
+func fixedKernel(out []int32, carries *[4]int32, order int)
+
+// maxLPCOrder is the largest order of an LPC subframe
+const maxLPCOrder = 32
+
+// lpcColumns fills columns with the coefficients weighting each sample of a window of the given length in the prediction of
+// the 4 samples following it: columns[4*k+t] multiplies the k-th sample of the window in the prediction of the t-th sample.
+// The samples of the group itself, weighted by the first t coefficients, are left to the kernel.
+func lpcColumns(columns *[4 * maxLPCOrder]int32, coefs []int32, window int) {
+	for k := 0; k < window; k++ {
+		for t := 0; t < 4; t++ {
+			if j := window + t - 1 - k; j >= t && j < len(coefs) {
+				columns[4*k+t] = coefs[j]
+			}
+		}
+	}
+}
+
+// restoreLPCVector is restoreLPC32, or restoreLPCWide if wide is set, running the vector kernel where available.
+// It reports false, leaving out untouched, if the kernel cannot be used.
+func restoreLPCVector(out []int32, coefs []int32, shift uint, wide bool) bool {
+	order := len(coefs)
+	window := (order + 3) &^ 3
+	if !hasLPCKernel || order == 0 || len(out) < window+4 {
+		return false
+	}
+	generic := restoreLPC32
+	if wide {
+		generic = restoreLPCWide
+	}
+	var columns [4 * maxLPCOrder]int32
+	lpcColumns(&columns, coefs, window)
+	var c [3]int32
+	copy(c[:], coefs)
+
+	// the samples before the first whole window and after the last whole group are restored one at a time
+	generic(out[:window], coefs, shift)
+	end := window + (len(out)-window)&^3
+	if wide {
+		lpcKernel64(out[:end], window, &columns, window, shift, c[0], c[1], c[2])
+	} else {
+		lpcKernel32(out[:end], window, &columns, window, shift, c[0], c[1], c[2])
+	}
+	generic(out[end-order:], coefs, shift)
+	return true
+}
+
+// restoreFixedVector is restoreFixed running the vector kernel. The residual of a fixed predictor of order n is the n-th difference
+// of the samples, so the samples are n running sums of it, each starting from the difference of the warm-up samples of that level.
+// It reports false, leaving out untouched, if the kernel cannot be used.
+func restoreFixedVector(out []int32, order int) bool {
+	if order == 0 || len(out) < order+4 {
+		return false
+	}
+	// carries[m] is the difference of level order-1-m at the last warm-up sample
+	var carries, diff [4]int32
+	copy(diff[:], out[:order])
+	for level := 0; level < order; level++ {
+		carries[order-1-level] = diff[order-1]
+		for i := order - 1; i > level; i-- {
+			diff[i] -= diff[i-1]
+		}
+	}
+	end := order + (len(out)-order)&^3
+	fixedKernel(out[order:end], &carries, order)
+	restoreFixedWide(out[end-order:], order)
+	return true
+}
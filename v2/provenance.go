@@ -0,0 +1,130 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// ProvenanceApplicationID is the Application block ID used to embed provenance manifests:
+//
+//	ID "PROV" | version (1 byte) | audio MD5 (16 bytes) | MIME length (4 bytes) | MIME | manifest
+//
+// The audio MD5 is copied from StreamInfo when the manifest is embedded, binding the manifest to the audio it describes.
+var ProvenanceApplicationID = [4]byte{'P', 'R', 'O', 'V'}
+
+const (
+	// ProvenanceC2PA is the MIME type of C2PA manifest stores
+	ProvenanceC2PA = "application/c2pa"
+	// ProvenanceJSONLD is the MIME type of JSON-LD provenance documents
+	ProvenanceJSONLD = "application/ld+json"
+)
+
+// provenanceVersion is the version of the provenance block layout
+const provenanceVersion = 1
+
+// Provenance is a provenance manifest embedded in a File
+type Provenance struct {
+	// MIME is the format of Manifest, e.g. ProvenanceC2PA
+	MIME string
+	// Manifest is the manifest document
+	Manifest []byte
+	// AudioMD5 is the StreamInfo audio MD5 the manifest was bound to
+	AudioMD5 []byte
+}
+
+func parseProvenance(meta *MetaDataBlock) (*Provenance, bool, error) {
+	if meta.Type != Application {
+		return nil, false, nil
+	}
+	if err := meta.Load(); err != nil {
+		return nil, false, err
+	}
+	app, err := ParseApplication(meta)
+	if err != nil || app.ID != ProvenanceApplicationID {
+		return nil, false, nil
+	}
+	data := app.Data
+	if len(data) < 21 || data[0] != provenanceVersion {
+		return nil, true, ErrorMalformedProvenance
+	}
+	res := &Provenance{AudioMD5: data[1:17]}
+	length := binary.BigEndian.Uint32(data[17:])
+	data = data[21:]
+	if uint64(length) > uint64(len(data)) {
+		return nil, true, ErrorMalformedProvenance
+	}
+	res.MIME = string(data[:length])
+	res.Manifest = data[length:]
+	return res, true, nil
+}
+
+// Provenance returns the provenance manifest embedded in the File.
+// It returns ErrorNoProvenance if there is none, and the manifest along with ErrorProvenanceMismatch if the audio MD5 in StreamInfo no longer matches the one the manifest was bound to.
+func (c *File) Provenance() (*Provenance, error) {
+	for _, meta := range c.Meta {
+		res, ok, err := parseProvenance(meta)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		info, err := c.GetStreamInfo()
+		if err != nil {
+			return res, err
+		}
+		if !bytes.Equal(info.AudioMD5, res.AudioMD5) {
+			return res, ErrorProvenanceMismatch
+		}
+		return res, nil
+	}
+	return nil, ErrorNoProvenance
+}
+
+// SetProvenance embeds a provenance manifest bound to the audio MD5 in StreamInfo, replacing any existing one.
+// It returns ErrorUnknownAudioMD5 if StreamInfo does not carry an audio MD5 to bind to.
+func (c *File) SetProvenance(mime string, manifest []byte) error {
+	if err := c.requireAudioMD5(); err != nil {
+		return err
+	}
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 0, 21+len(mime)+len(manifest))
+	data = append(data, provenanceVersion)
+	data = append(data, info.AudioMD5...)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(mime)))
+	data = append(data, mime...)
+	data = append(data, manifest...)
+	if len(data)+4 > MaxBlockSize {
+		return ErrorBlockTooLarge
+	}
+
+	c.RemoveProvenance()
+	app := ApplicationBlock{ID: ProvenanceApplicationID, Data: data}
+	meta := app.Marshal()
+	c.Meta = append(c.Meta, &meta)
+	c.modified(&meta)
+	return nil
+}
+
+// RemoveProvenance removes any embedded provenance manifest
+func (c *File) RemoveProvenance() {
+	c.removeBlocks(func(block *MetaDataBlock) bool {
+		_, ok, _ := parseProvenance(block)
+		return ok
+	})
+}
+
+// requireAudioMD5 returns ErrorUnknownAudioMD5 if StreamInfo does not carry the audio MD5 that provenance manifests and signatures bind to
+func (c *File) requireAudioMD5() error {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(info.AudioMD5, make([]byte, 16)) {
+		return ErrorUnknownAudioMD5
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package flac
+
+import (
+	"bytes"
+	"io"
+)
+
+// WriteToN writes the File like WriteTo but stops before exceeding maxBytes, for previews and streaming quotas.
+// Audio frames are written whole, so the output ends cleanly on a frame boundary; if the frames cannot be delimited
+// the output is cut at exactly maxBytes instead. StreamInfo still describes the whole stream.
+// It returns ErrorQuotaTooSmall if the metadata alone does not fit. Like WriteTo, it consumes Frames and closes the File.
+func (c *File) WriteToN(w io.Writer, maxBytes int64) (int64, error) {
+	if c.metadataSize() > maxBytes {
+		return 0, ErrorQuotaTooSmall
+	}
+
+	frames := c.Frames
+	c.Frames = nil
+	n, err := c.writeTo(w, nil)
+	if err != nil || frames == nil {
+		return n, err
+	}
+	defer func() {
+		c.Frames = frames
+		c.framesWritten()
+	}()
+
+	s := &frameScanner{r: timeoutReader{frames}}
+	w = timeoutWriter{w}
+	for {
+		frame, err := s.next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			if isTimeout(err) {
+				return n, err
+			}
+			// the frames cannot be delimited, fall back to a byte exact cut
+			rest := io.MultiReader(bytes.NewReader(s.buf), s.r)
+			n2, err := io.CopyN(w, rest, maxBytes-n)
+			if err == io.EOF {
+				err = nil
+			}
+			return n + n2, err
+		}
+		if n+int64(len(frame.Data)) > maxBytes {
+			return n, nil
+		}
+		n2, err := w.Write(frame.Data)
+		n += int64(n2)
+		if err != nil {
+			return n, err
+		}
+	}
+}
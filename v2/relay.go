@@ -0,0 +1,84 @@
+package flac
+
+import (
+	"io"
+	"time"
+)
+
+// TimedFrame is an audio frame with its position in the stream
+type TimedFrame struct {
+	Frame
+	// Sample is the number of the first inter-channel sample of the frame
+	Sample int64
+	// Timestamp is the presentation time of the first sample of the frame
+	Timestamp time.Duration
+	// Duration is the playback duration of the frame
+	Duration time.Duration
+}
+
+// FrameReader reads audio frames one at a time, for relaying a stream frame by frame without buffering it.
+// A frame is returned as soon as the header of the following frame arrives, or at the end of the stream.
+type FrameReader struct {
+	s          frameScanner
+	sampleRate int
+	blockSize  int
+}
+
+// NewFrameReader returns a FrameReader of the audio frames read from r, such as File.Frames.
+// info provides the sample rate and nominal block size for frames that refer to StreamInfo; it may be nil if every frame header carries its sample rate.
+func NewFrameReader(r io.Reader, info *StreamInfoBlock) *FrameReader {
+	res := &FrameReader{s: frameScanner{r: r}}
+	if info != nil {
+		res.sampleRate = info.SampleRate
+		res.blockSize = info.BlockSizeMax
+	}
+	return res
+}
+
+// FrameReader returns a FrameReader of the audio frames of the File. Reading frames consumes Frames.
+func (c *File) FrameReader() (*FrameReader, error) {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	if c.Frames == nil {
+		return nil, ErrorNoFrames
+	}
+	return NewFrameReader(c.Frames, info), nil
+}
+
+// ReadFrame returns the next frame, or io.EOF at the end of the stream.
+// The Data of the returned frame is only valid until the next call to ReadFrame.
+func (c *FrameReader) ReadFrame() (*TimedFrame, error) {
+	frame, err := c.s.next()
+	if err != nil {
+		return nil, err
+	}
+	res := &TimedFrame{Frame: *frame}
+	header := &frame.Header
+	if header.VariableBlockSize {
+		res.Sample = int64(header.Number)
+	} else {
+		blockSize := c.blockSize
+		if blockSize == 0 {
+			blockSize = header.BlockSize
+		}
+		res.Sample = int64(header.Number) * int64(blockSize)
+	}
+	rate := header.SampleRate
+	if rate == 0 {
+		rate = c.sampleRate
+	}
+	if rate > 0 {
+		res.Timestamp = samplesToDuration(res.Sample, rate)
+		res.Duration = samplesToDuration(int64(header.BlockSize), rate)
+	}
+	return res, nil
+}
+
+// samplesToDuration converts a number of samples at the given rate to a duration without overflowing for long streams
+func samplesToDuration(samples int64, rate int) time.Duration {
+	seconds := samples / int64(rate)
+	rest := samples % int64(rate)
+	return time.Duration(seconds)*time.Second + time.Duration(rest)*time.Second/time.Duration(rate)
+}
@@ -0,0 +1,113 @@
+package flac
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReleaseFields are the album-level fields CheckRelease expects every track of a release to agree on
+var ReleaseFields = []string{"ALBUM", "ALBUMARTIST", "DATE", "DISCTOTAL"}
+
+// ReleaseMismatch reports a field the tracks of a release disagree on
+type ReleaseMismatch struct {
+	Field string
+	// Tracks maps the values of the field, joined with "; " when a track holds several, to the indices of the tracks holding them.
+	// Tracks without the field are listed under "".
+	Tracks map[string][]int
+	// Majority holds the values of the field most tracks agree on, ignoring tracks without it; ties go to the first track
+	Majority []string
+}
+
+// CheckRelease compares the fields of the tracks of a release, ReleaseFields if none are given, and reports the fields they disagree on,
+// in the order of the fields. A field missing from some tracks only is a disagreement; a field missing from every track is not.
+func CheckRelease(tracks []*File, fields ...string) ([]ReleaseMismatch, error) {
+	if len(fields) == 0 {
+		fields = ReleaseFields
+	}
+	blocks := make([]*VorbisCommentBlock, len(tracks))
+	for i, track := range tracks {
+		blocks[i] = new(VorbisCommentBlock)
+		if idx := track.vorbisCommentIndex(); idx >= 0 {
+			block, err := ParseVorbisComment(track.Meta[idx])
+			if err != nil {
+				return nil, err
+			}
+			blocks[i] = block
+		}
+	}
+
+	var res []ReleaseMismatch
+	for _, field := range fields {
+		mismatch := ReleaseMismatch{Field: strings.ToUpper(field), Tracks: make(map[string][]int)}
+		best := 0
+		for i, block := range blocks {
+			values := block.Get(field)
+			key := strings.Join(values, "; ")
+			mismatch.Tracks[key] = append(mismatch.Tracks[key], i)
+			if key != "" && len(mismatch.Tracks[key]) > best {
+				best = len(mismatch.Tracks[key])
+				mismatch.Majority = values
+			}
+		}
+		if len(mismatch.Tracks) > 1 {
+			res = append(res, mismatch)
+		}
+	}
+	return res, nil
+}
+
+// FixRelease writes the majority values of every mismatch to all the tracks, replacing the values they held.
+// A VorbisComment block is added to the tracks that have none.
+func FixRelease(tracks []*File, mismatches []ReleaseMismatch) error {
+	for _, track := range tracks {
+		if err := track.editVorbisComment(true, func(block *VorbisCommentBlock) error {
+			for _, mismatch := range mismatches {
+				block.Remove(mismatch.Field)
+				for _, value := range mismatch.Majority {
+					block.Comments = append(block.Comments, mismatch.Field+"="+value)
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String describes the disagreement, e.g. `DATE: "2001" on tracks 1, 2; "2002" on track 3`, numbering tracks from 1
+func (c *ReleaseMismatch) String() string {
+	keys := make([]string, 0, len(c.Tracks))
+	for key := range c.Tracks {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.Tracks[keys[i]][0] < c.Tracks[keys[j]][0]
+	})
+	var b strings.Builder
+	b.WriteString(c.Field)
+	b.WriteString(": ")
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		if key == "" {
+			b.WriteString("missing")
+		} else {
+			b.WriteString(strconv.Quote(key))
+		}
+		if len(c.Tracks[key]) == 1 {
+			b.WriteString(" on track ")
+		} else {
+			b.WriteString(" on tracks ")
+		}
+		for j, track := range c.Tracks[key] {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Itoa(track + 1))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,276 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenamePolicy computes the destination paths of FLAC files from their tags
+type RenamePolicy struct {
+	// Template is the destination path relative to Root, with '/' separating directories. {FIELD} is replaced by the first value of the Vorbis comment
+	// FIELD, and {FIELD:N} by its number part (before any '/') padded with zeros to N digits, e.g. "{ALBUMARTIST}/{ALBUM}/{TRACKNUMBER:2} {TITLE}.flac".
+	Template string
+	// Root is the directory the destination paths are relative to
+	Root string
+	// Missing replaces the fields a file does not have, "Unknown" if empty
+	Missing string
+	// OS selects the characters replaced in path components, runtime.GOOS if empty: Windows also forbids <>:"\|?* and control characters,
+	// trailing dots and spaces and reserved names such as CON, and macOS forbids ':'
+	OS string
+	// Replacement replaces forbidden characters, "_" if empty
+	Replacement string
+	// MaxPath is the maximum length of a destination path in bytes, 260 on Windows and 4096 elsewhere if zero.
+	// Longer file names are truncated, keeping their extension; path components are always limited to 255 bytes.
+	MaxPath int
+}
+
+// RenameStep moves a file
+type RenameStep struct {
+	From, To string
+}
+
+// RenamePlan lists the moves computed by RenamePolicy.Plan. Callers may execute the steps themselves or call Apply.
+type RenamePlan struct {
+	Steps []RenameStep
+}
+
+// maxComponent is the maximum length of a path component in bytes on common file systems
+const maxComponent = 255
+
+// windowsReserved are the device names Windows reserves as file names, with or without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func (c *RenamePolicy) targetOS() string {
+	if c.OS == "" {
+		return runtime.GOOS
+	}
+	return c.OS
+}
+
+func (c *RenamePolicy) replacement() string {
+	if c.Replacement == "" {
+		return "_"
+	}
+	return c.Replacement
+}
+
+// sanitize replaces the characters of a path component forbidden by the target OS
+func (c *RenamePolicy) sanitize(s string) string {
+	goos := c.targetOS()
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '/' || r == 0:
+		case goos == "windows" && (r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r)):
+		case goos == "darwin" && r == ':':
+		default:
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteString(c.replacement())
+	}
+	s = b.String()
+	if goos == "windows" {
+		s = strings.TrimRight(s, ". ")
+		base, _, _ := strings.Cut(s, ".")
+		if windowsReserved[strings.ToUpper(base)] {
+			s = c.replacement() + s
+		}
+	}
+	if s == "" || s == "." || s == ".." {
+		s = c.replacement()
+	}
+	return s
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence, keeping the extension ext
+func truncate(s, ext string, n int) string {
+	stem := strings.TrimSuffix(s, ext)
+	n -= len(ext)
+	if len(stem) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(stem[n]) {
+		n--
+	}
+	return strings.TrimRight(stem[:n], " ") + ext
+}
+
+// expand builds the destination path of a file with the given comments
+func (c *RenamePolicy) expand(block *VorbisCommentBlock) (string, error) {
+	missing := c.Missing
+	if missing == "" {
+		missing = "Unknown"
+	}
+	components := strings.Split(c.Template, "/")
+	for i, component := range components {
+		var b strings.Builder
+		for component != "" {
+			open := strings.IndexByte(component, '{')
+			if open < 0 {
+				b.WriteString(component)
+				break
+			}
+			end := strings.IndexByte(component[open:], '}')
+			if end < 0 {
+				return "", ErrorInvalidTemplate
+			}
+			b.WriteString(component[:open])
+			field, width, padded := strings.Cut(component[open+1:open+end], ":")
+			value := missing
+			if values := block.Get(field); len(values) > 0 && values[0] != "" {
+				value = values[0]
+			}
+			if padded {
+				n, err := strconv.Atoi(width)
+				if err != nil || n < 0 {
+					return "", ErrorInvalidTemplate
+				}
+				if number, _, _ := strings.Cut(value, "/"); number != "" && strings.Trim(number, "0123456789") == "" {
+					value = number
+					if len(value) < n {
+						value = strings.Repeat("0", n-len(value)) + value
+					}
+				}
+			}
+			b.WriteString(value)
+			component = component[open+end+1:]
+		}
+		components[i] = c.sanitize(b.String())
+	}
+
+	last := len(components) - 1
+	for i := range components[:last] {
+		components[i] = truncate(components[i], "", maxComponent)
+	}
+	ext := filepath.Ext(components[last])
+	components[last] = truncate(components[last], ext, maxComponent)
+
+	maxPath := c.MaxPath
+	if maxPath == 0 {
+		maxPath = 4096
+		if c.targetOS() == "windows" {
+			maxPath = 260
+		}
+	}
+	dir := filepath.Join(append([]string{c.Root}, components[:last]...)...)
+	room := maxPath - len(dir) - 1
+	if room <= len(ext) {
+		return "", ErrorPathTooLong
+	}
+	return filepath.Join(dir, truncate(components[last], ext, room)), nil
+}
+
+// Plan reads the tags of the FLAC files at paths and computes their destination paths.
+// A destination already taken by another file of the plan or by an existing file gets a " (2)", " (3)"... suffix before its extension;
+// paths are compared case-insensitively for Windows and macOS. Files already at their destination are left out of the plan.
+func (c *RenamePolicy) Plan(paths []string) (*RenamePlan, error) {
+	key := func(p string) string {
+		if goos := c.targetOS(); goos == "windows" || goos == "darwin" {
+			return strings.ToLower(p)
+		}
+		return p
+	}
+	taken := make(map[string]bool, len(paths))
+	res := new(RenamePlan)
+	for _, path := range paths {
+		f, err := ParseFile(path, WithBlockTypes(VorbisComment))
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		block := new(VorbisCommentBlock)
+		if idx := f.vorbisCommentIndex(); idx >= 0 {
+			if block, err = ParseVorbisComment(f.Meta[idx]); err != nil {
+				return nil, err
+			}
+		}
+		dest, err := c.expand(block)
+		if err != nil {
+			return nil, err
+		}
+
+		ext := filepath.Ext(dest)
+		candidate := dest
+		for n := 2; ; n++ {
+			if candidate == path {
+				break
+			}
+			if !taken[key(candidate)] {
+				if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+					break
+				}
+			}
+			candidate = strings.TrimSuffix(dest, ext) + " (" + strconv.Itoa(n) + ")" + ext
+		}
+		taken[key(candidate)] = true
+		if candidate != path {
+			res.Steps = append(res.Steps, RenameStep{From: path, To: candidate})
+		}
+	}
+	return res, nil
+}
+
+// Apply executes the plan, creating the destination directories. If a step fails, the files already moved are moved back
+// and the directories created are removed before the error is returned.
+func (c *RenamePlan) Apply() error {
+	var done []RenameStep
+	var created []string
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.Rename(done[i].To, done[i].From)
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i])
+		}
+	}
+	for _, step := range c.Steps {
+		dirs, err := mkdirs(filepath.Dir(step.To))
+		created = append(created, dirs...)
+		if err == nil {
+			if _, err = os.Lstat(step.To); err == nil {
+				err = &os.LinkError{Op: "rename", Old: step.From, New: step.To, Err: os.ErrExist}
+			} else if errors.Is(err, os.ErrNotExist) {
+				err = os.Rename(step.From, step.To)
+			}
+		}
+		if err != nil {
+			rollback()
+			return fmt.Errorf("failed to move %s: %w", step.From, err)
+		}
+		done = append(done, step)
+	}
+	return nil
+}
+
+// mkdirs creates dir and its missing parents, returning the directories it created from the outermost
+func mkdirs(dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	var created []string
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+			return created, err
+		}
+		created = append(created, missing[i])
+	}
+	return created, nil
+}
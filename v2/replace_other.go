@@ -0,0 +1,23 @@
+//go:build !windows
+
+package flac
+
+import "os"
+
+// replaceFile atomically replaces target by the file at path
+func replaceFile(path, target string) error {
+	return os.Rename(path, target)
+}
+
+// syncDir flushes the directory entries of dir to stable storage, making a rename in it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+//go:build windows
+
+package flac
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procReplaceFileW = syscall.NewLazyDLL("kernel32.dll").NewProc("ReplaceFileW")
+
+// replacefileIgnoreMergeErrors makes ReplaceFile succeed even if the ACLs or alternate data streams cannot be merged
+const replacefileIgnoreMergeErrors = 0x2
+
+// replaceFile atomically replaces target by the file at path.
+// ReplaceFile keeps the ACLs, alternate data streams and other attributes of target, which a rename would replace by those of path.
+func replaceFile(path, target string) error {
+	targetPtr, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	r, _, err := procReplaceFileW.Call(uintptr(unsafe.Pointer(targetPtr)), uintptr(unsafe.Pointer(pathPtr)), 0, replacefileIgnoreMergeErrors, 0, 0)
+	if r != 0 {
+		return nil
+	}
+	if err == syscall.ERROR_FILE_NOT_FOUND {
+		// there is nothing to replace, MoveFileEx is atomic for new files
+		return os.Rename(path, target)
+	}
+	return &os.LinkError{Op: "replace", Old: path, New: target, Err: err}
+}
+
+// syncDir does nothing as Windows does not support flushing a directory handle
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package flac
+
+import "math"
+
+// Resampler converts decoded audio to another sample rate. Implementations may buffer input across frames, so the frames returned by
+// Resample can hold fewer samples than the input, possibly none, and Flush must be called at the end of the stream for the rest.
+type Resampler interface {
+	// Resample converts frame, returning the output samples available so far
+	Resample(frame *AudioFrame) (*AudioFrame, error)
+	// Flush returns the output samples still buffered at the end of the stream
+	Flush() (*AudioFrame, error)
+}
+
+// resamplerTaps is half the number of input samples each output sample of sincResampler is interpolated from
+const resamplerTaps = 16
+
+// sincResampler is a band-limited windowed-sinc resampler
+type sincResampler struct {
+	from, to int
+	// step is the distance between output samples in input samples
+	step float64
+	// cutoff is the low-pass cutoff relative to the input Nyquist frequency, lowered when downsampling to prevent aliasing
+	cutoff   float64
+	buf      [][]float64
+	pos      float64
+	inTotal  int64
+	outTotal int64
+	last     AudioFrame
+}
+
+// NewResampler returns a windowed-sinc Resampler from one sample rate to another.
+// It is a basic converter for workflows such as 96 kHz to 44.1 kHz CD copies; applications needing a specific filter can provide their own Resampler.
+func NewResampler(from, to int) (Resampler, error) {
+	if from <= 0 || to <= 0 {
+		return nil, ErrorInvalidSampleRate
+	}
+	res := &sincResampler{from: from, to: to, step: float64(from) / float64(to), cutoff: 1}
+	if to < from {
+		res.cutoff = float64(to) / float64(from)
+	}
+	// the first output sample is centered on the first input sample, preceded by silence
+	res.pos = resamplerTaps - 1
+	return res, nil
+}
+
+// kernel returns the filter weight of an input sample at distance x from the output position
+func (c *sincResampler) kernel(x float64) float64 {
+	if x == 0 {
+		return c.cutoff
+	}
+	window := 0.5 + 0.5*math.Cos(math.Pi*x/resamplerTaps)
+	return math.Sin(math.Pi*c.cutoff*x) / (math.Pi * x) * window
+}
+
+// output interpolates every output sample whose filter taps are available, up to limit output samples in total
+func (c *sincResampler) output(limit int64) *AudioFrame {
+	res := c.last
+	res.Header.VariableBlockSize = true
+	res.Header.SampleRate = c.to
+	res.Header.Number = uint64(c.outTotal)
+	res.Sample = c.outTotal
+	res.Samples = make([][]int32, len(c.buf))
+
+	highest := math.Ldexp(1, c.last.BitDepth-1) - 1
+	lowest := -highest - 1
+	for c.outTotal < limit {
+		i := int(c.pos)
+		if len(c.buf) == 0 || i+resamplerTaps >= len(c.buf[0]) {
+			break
+		}
+		frac := c.pos - float64(i)
+		for ch, in := range c.buf {
+			var v float64
+			for j := -resamplerTaps + 1; j <= resamplerTaps; j++ {
+				v += in[i+j] * c.kernel(float64(j)-frac)
+			}
+			res.Samples[ch] = append(res.Samples[ch], int32(math.Max(lowest, math.Min(highest, math.Round(v)))))
+		}
+		c.outTotal++
+		c.pos += c.step
+	}
+	res.Header.BlockSize = int(c.outTotal - res.Sample)
+
+	// drop the input samples no further output sample depends on
+	if drop := int(c.pos) - resamplerTaps + 1; drop > 0 && len(c.buf) > 0 {
+		if drop > len(c.buf[0]) {
+			drop = len(c.buf[0])
+		}
+		for ch := range c.buf {
+			c.buf[ch] = append(c.buf[ch][:0], c.buf[ch][drop:]...)
+		}
+		c.pos -= float64(drop)
+	}
+	return &res
+}
+
+func (c *sincResampler) Resample(frame *AudioFrame) (*AudioFrame, error) {
+	if c.buf == nil {
+		c.buf = make([][]float64, len(frame.Samples))
+		for ch := range c.buf {
+			c.buf[ch] = make([]float64, resamplerTaps-1)
+		}
+	}
+	if len(frame.Samples) != len(c.buf) {
+		return nil, ErrorInvalidChannelMap
+	}
+	c.last = *frame
+	for ch, samples := range frame.Samples {
+		for _, v := range samples {
+			c.buf[ch] = append(c.buf[ch], float64(v))
+		}
+	}
+	c.inTotal += int64(frame.Header.BlockSize)
+	return c.output(math.MaxInt64), nil
+}
+
+func (c *sincResampler) Flush() (*AudioFrame, error) {
+	for ch := range c.buf {
+		c.buf[ch] = append(c.buf[ch], make([]float64, resamplerTaps)...)
+	}
+	total := (c.inTotal*int64(c.to) + int64(c.from) - 1) / int64(c.from)
+	return c.output(total), nil
+}
@@ -0,0 +1,73 @@
+package flac
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// resumeCheckSize is the number of bytes of frames before the resume point compared with the source
+const resumeCheckSize = 4096
+
+// ResumeSave completes fn, the output of an out-of-place Save that failed while copying the audio frames with a FrameCopyError,
+// by appending the frames missing from it instead of copying the whole audio again.
+// The File must be parsed again from the source of the failed save with ParseFile or ParseReaderAt, as the failed save closed it.
+// The metadata written to fn is kept as it is; its frames are checked to end like the source frames at the same offset.
+// It returns ErrorNotResumable if the File has no seekable source or fn is not a partial copy of its frames, and consumes the File like Save.
+func (c *File) ResumeSave(fn string) error {
+	if c.src == nil {
+		return ErrorNotResumable
+	}
+	out, err := os.OpenFile(fn, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open FLAC output file: %w", err)
+	}
+	defer out.Close()
+
+	written, err := parseMetadata(NewBufIOWithInner(out), nil)
+	if err != nil {
+		return ErrorNotResumable
+	}
+	size, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	copied := size - written.audioOffset
+	total := c.srcSize - c.audioOffset
+	if copied < 0 || copied > total {
+		return ErrorNotResumable
+	}
+
+	check := int64(resumeCheckSize)
+	if check > copied {
+		check = copied
+	}
+	tail := make([]byte, check)
+	source := make([]byte, check)
+	if _, err := out.ReadAt(tail, size-check); err != nil {
+		return err
+	}
+	if _, err := c.src.ReadAt(source, c.audioOffset+copied-check); err != nil {
+		return err
+	}
+	if !bytes.Equal(tail, source) {
+		return ErrorNotResumable
+	}
+
+	defer c.framesWritten()
+	src := &readRecorder{r: io.NewSectionReader(c.src, c.audioOffset+copied, total-copied)}
+	n, err := io.Copy(out, src)
+	if err == nil && n < total-copied {
+		err = io.ErrUnexpectedEOF
+		src.err = err
+	}
+	if err != nil {
+		op := "write"
+		if src.err != nil {
+			op = "read"
+		}
+		return &FrameCopyError{Op: op, Copied: copied + n, Err: err}
+	}
+	return out.Close()
+}
@@ -0,0 +1,210 @@
+package flac
+
+import (
+	"crypto/sha256"
+	"hash"
+	"os"
+	"time"
+)
+
+// SaveOption configures the behavior of File.Save
+type SaveOption func(*saveConfig)
+
+type saveConfig struct {
+	audit   *SaveAudit
+	reports []*SaveReport
+	inPlace bool
+	padding PaddingPolicy
+	atomic  bool
+	sync    bool
+	mode    os.FileMode
+
+	hardLinks HardLinkPolicy
+
+	backup       string
+	backupKeep   int
+	backupHeader bool
+
+	lock        bool
+	lockTimeout time.Duration
+
+	throttle *tokenBucket
+
+	// stampSet reports that WithEncoderStamp was given, even with a nil stamp
+	stamp    *EncoderStamp
+	stampSet bool
+	// hooks are given by WithBeforeMarshal
+	hooks []MarshalHook
+
+	// path is the file Save writes, written the number of bytes written once the save completes
+	path     string
+	written  int64
+	strategy SaveStrategy
+}
+
+func newSaveConfig(opts []SaveOption) *saveConfig {
+	cfg := new(saveConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithAudit makes Save record the byte ranges it wrote into audit
+func WithAudit(audit *SaveAudit) SaveOption {
+	return func(c *saveConfig) {
+		c.audit = audit
+	}
+}
+
+// WithReport makes Save and SaveTo describe how they saved the File in report.
+// It may be given several times, for wrappers that need the report as well as their caller.
+func WithReport(report *SaveReport) SaveOption {
+	return func(c *saveConfig) {
+		if report != nil {
+			c.reports = append(c.reports, report)
+		}
+	}
+}
+
+// WithInPlace allows Save to overwrite the file the File was parsed from.
+// When the new metadata fits in the space of the original metadata, resizing, merging or adding Padding if needed, only the metadata is rewritten
+// and the audio is left untouched. Otherwise the file is rewritten to a temporary file that replaces it once complete.
+// With WithAudit, a metadata-only write records the header range and an empty audio range. Without this option, saving over the source file fails.
+func WithInPlace() SaveOption {
+	return func(c *saveConfig) {
+		c.inPlace = true
+	}
+}
+
+// WithAtomic makes Save write to a temporary file in the directory of the output that replaces the output once complete,
+// so other processes never see a partially written file. The replaced file keeps its permissions, on Linux its extended attributes
+// where permitted, and on Windows its ACLs and alternate data streams.
+func WithAtomic() SaveOption {
+	return func(c *saveConfig) {
+		c.atomic = true
+	}
+}
+
+// WithSync makes Save flush the output to stable storage before returning, so a saved file survives a power loss.
+// With WithAtomic, the temporary file is flushed before it replaces the output and the directory is flushed after, where the OS allows it.
+func WithSync() SaveOption {
+	return func(c *saveConfig) {
+		c.sync = true
+	}
+}
+
+// WithFileMode sets the permissions of the files Save creates, 0666 by default as with os.Create; the umask of the process applies.
+// An existing output keeps its permissions.
+func WithFileMode(mode os.FileMode) SaveOption {
+	return func(c *saveConfig) {
+		c.mode = mode.Perm()
+	}
+}
+
+// fileMode returns the permissions of the files Save creates
+func (c *saveConfig) fileMode() os.FileMode {
+	if c.mode == 0 {
+		return 0o666
+	}
+	return c.mode
+}
+
+// SaveStrategy is the way Save wrote a File
+type SaveStrategy int
+
+const (
+	// SaveRewrite wrote the whole stream, metadata and audio
+	SaveRewrite SaveStrategy = iota
+	// SaveInPlace overwrote the metadata of the source file, which kept its size
+	SaveInPlace
+	// SavePaddingPatch overwrote the metadata of the source file after resizing or adding Padding to keep its size
+	SavePaddingPatch
+)
+
+// String returns the name of the strategy
+func (s SaveStrategy) String() string {
+	switch s {
+	case SaveRewrite:
+		return "rewrite"
+	case SaveInPlace:
+		return "in-place"
+	case SavePaddingPatch:
+		return "padding-patch"
+	}
+	return "unknown"
+}
+
+// SaveReport describes what a save did, so batch tools can log it and tune their padding policies
+type SaveReport struct {
+	// Strategy is the way the File was written
+	Strategy SaveStrategy
+	// BytesWritten is the number of bytes written to the output
+	BytesWritten int64
+	// BytesMoved is the number of audio bytes copied, 0 if only the metadata was rewritten
+	BytesMoved int64
+	// Duration is the time the save took
+	Duration time.Duration
+}
+
+func (c *saveConfig) finish(strategy SaveStrategy, written, moved int64, start time.Time) {
+	c.written = written
+	c.strategy = strategy
+	report := SaveReport{Strategy: strategy, BytesWritten: written, BytesMoved: moved, Duration: time.Since(start)}
+	for _, r := range c.reports {
+		*r = report
+	}
+}
+
+// ByteRange is the half-open range of byte offsets [Start, End)
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// Len returns the number of bytes in the range
+func (r ByteRange) Len() int64 {
+	return r.End - r.Start
+}
+
+// SaveAudit records exactly which parts of the output a save produced, so archives can prove that retagging did not alter the audio content
+type SaveAudit struct {
+	// Header is the range of the output holding the "fLaC" marker and the metadata blocks
+	Header ByteRange
+	// Audio is the range of the output holding the audio frames, copied verbatim from the source.
+	// It is empty when a save over the source rewrote only the metadata and left the audio untouched.
+	Audio ByteRange
+	// SourceAudioOffset is the offset of the audio frames in the parsed source, -1 if the File was not parsed from a stream
+	SourceAudioOffset int64
+	// AudioShifted reports whether the audio frames start at a different offset in the output than in the source
+	AudioShifted bool
+	// AudioSHA256 is the SHA-256 digest of the audio bytes written, to be compared with a digest of the source audio, nil if no audio was written
+	AudioSHA256 []byte
+
+	hash hash.Hash
+}
+
+func (a *SaveAudit) start(c *File, headerSize int64) {
+	a.Header = ByteRange{0, headerSize}
+	a.Audio = ByteRange{headerSize, headerSize}
+	a.SourceAudioOffset = -1
+	if c.audioOffset > 0 {
+		a.SourceAudioOffset = c.audioOffset
+	}
+	a.AudioShifted = a.SourceAudioOffset != headerSize
+	a.AudioSHA256 = nil
+	a.hash = sha256.New()
+}
+
+// untouched records a save over the source that wrote only the metadata, leaving the audio where it was
+func (a *SaveAudit) untouched(c *File, headerSize int64) {
+	a.start(c, headerSize)
+	a.AudioSHA256 = nil
+	a.hash = nil
+}
+
+func (a *SaveAudit) finish(audioSize int64) {
+	a.Audio.End = a.Audio.Start + audioSize
+	a.AudioSHA256 = a.hash.Sum(nil)
+	a.hash = nil
+}
@@ -0,0 +1,358 @@
+package flac
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Frame is an encoded audio frame located by ScanFrames
+type Frame struct {
+	// Header is the decoded frame header
+	Header FrameHeader
+	// Offset is the position of the frame from the start of the scanned frame data
+	Offset int64
+	// Data is the whole encoded frame, including header and CRC-16. It is only valid during the callback.
+	Data []byte
+}
+
+// frameScanner splits frame data into frames without decoding the subframes.
+// FLAC frames carry no length, so a frame ends where a valid frame header starts and the CRC-16 of the bytes before it checks out, or at the end of the stream.
+type frameScanner struct {
+	r      io.Reader
+	buf    []byte
+	eof    bool
+	err    error
+	offset int64
+	// stop is returned by next once set, after the frame that precedes misplaced metadata or a salvage cut
+	stop error
+	// salvage and info implement WithSalvage
+	salvage bool
+	info    *StreamInfoBlock
+}
+
+// ScanOption configures the behavior of ScanFrames
+type ScanOption func(*frameScanner)
+
+// WithSalvage makes ScanFrames stop without error at the end of the last valid frame when it is followed by data that is not a frame,
+// such as misplaced metadata, garbage or a truncated frame. A frame whose end is not followed by another frame is delimited by decoding it;
+// info provides the bit depth for frames referring to StreamInfo, as for DecodeFrame, and may be nil otherwise.
+func WithSalvage(info *StreamInfoBlock) ScanOption {
+	return func(c *frameScanner) {
+		c.salvage = true
+		c.info = info
+	}
+}
+
+// MisplacedMetadataError indicates that metadata was found after an audio frame, where the specification allows only frames,
+// as in concatenated files or files with ID3 or APE tags appended
+type MisplacedMetadataError struct {
+	// Offset is the position of the metadata from the start of the scanned frame data
+	Offset int64
+	// Marker names what was found: "fLaC", "StreamInfo", "ID3", "TAG" or "APETAGEX"
+	Marker string
+}
+
+func (e *MisplacedMetadataError) Error() string {
+	return fmt.Sprintf("%s metadata found among audio frames at offset %d", e.Marker, e.Offset)
+}
+
+// Is reports whether target is ErrorMetadataInFrames
+func (e *MisplacedMetadataError) Is(target error) bool {
+	return target == ErrorMetadataInFrames
+}
+
+// metadataMarkers are the starts of the metadata the scanner recognizes after a frame
+var metadataMarkers = []struct {
+	prefix, marker string
+}{
+	{"fLaC", "fLaC"},
+	// a StreamInfo block header, last or not, with its fixed length
+	{"\x00\x00\x00\x22", "StreamInfo"},
+	{"\x80\x00\x00\x22", "StreamInfo"},
+	{"ID3", "ID3"},
+	{"TAG", "TAG"},
+	{"APETAGEX", "APETAGEX"},
+}
+
+// metadataAt returns the marker of the metadata starting at pos in the buffer, or "" if there is none
+func (s *frameScanner) metadataAt(pos int) string {
+	s.fill(pos + 8)
+	for _, m := range metadataMarkers {
+		if bytes.HasPrefix(s.buf[pos:], []byte(m.prefix)) {
+			return m.marker
+		}
+	}
+	return ""
+}
+
+// frame returns the first size bytes of the buffer as a frame and consumes them
+func (s *frameScanner) frame(header *FrameHeader, size int) *Frame {
+	res := &Frame{Header: *header, Offset: s.offset, Data: s.buf[:size]}
+	s.buf = s.buf[size:]
+	s.offset += int64(size)
+	return res
+}
+
+// salvaged ends the scan when the frame at the start of the buffer cannot be delimited within its first n bytes, returning err
+// unless WithSalvage was given. With WithSalvage, the frame is returned if decoding it finds its end, and the scan stops after it.
+func (s *frameScanner) salvaged(header *FrameHeader, n int, err error) (*Frame, error) {
+	if !s.salvage {
+		return nil, err
+	}
+	s.stop = io.EOF
+	if _, size, err := decodeFrame(s.buf[:n], s.info); err == nil {
+		return s.frame(header, size), nil
+	}
+	return nil, io.EOF
+}
+
+// fill reads until buf holds at least n bytes or the reader is exhausted
+func (s *frameScanner) fill(n int) {
+	for len(s.buf) < n && !s.eof {
+		if cap(s.buf) < n {
+			size := 2 * cap(s.buf)
+			if size < n {
+				size = n
+			}
+			if size < 64<<10 {
+				size = 64 << 10
+			}
+			buf := make([]byte, len(s.buf), size)
+			copy(buf, s.buf)
+			s.buf = buf
+		}
+		m, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+m]
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			s.eof = true
+			s.err = err
+		}
+	}
+}
+
+// next returns the next frame, io.EOF at the end of the stream, or io.ErrUnexpectedEOF if the stream ends within a frame
+func (s *frameScanner) next() (*Frame, error) {
+	if s.stop != nil {
+		return nil, s.stop
+	}
+	s.fill(maxFrameHeaderSize)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.buf) == 0 {
+		return nil, io.EOF
+	}
+	header, err := ParseFrameHeader(s.buf)
+	if err == io.ErrUnexpectedEOF && !s.eof {
+		err = ErrorInvalidFrameHeader
+	}
+	if err != nil {
+		if s.salvage {
+			s.stop = io.EOF
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	crc := crc16(s.buf[:header.Size])
+	pos := header.Size
+	for {
+		if pos >= len(s.buf) {
+			s.fill(pos + 1)
+			if s.err != nil {
+				return nil, s.err
+			}
+			if pos >= len(s.buf) {
+				if crc != 0 {
+					return s.salvaged(header, pos, io.ErrUnexpectedEOF)
+				}
+				break
+			}
+		}
+		if pos > maxFrameSize {
+			return s.salvaged(header, pos, ErrorFrameTooLarge)
+		}
+		if crc == 0 && pos >= header.Size+2 {
+			if s.buf[pos] == 0xFF {
+				s.fill(pos + maxFrameHeaderSize)
+				if s.err != nil {
+					return nil, s.err
+				}
+				if _, err := ParseFrameHeader(s.buf[pos:]); err == nil || err == io.ErrUnexpectedEOF && s.eof {
+					break
+				}
+			} else if marker := s.metadataAt(pos); marker != "" {
+				s.stop = &MisplacedMetadataError{Offset: s.offset + int64(pos), Marker: marker}
+				if s.salvage {
+					s.stop = io.EOF
+				}
+				break
+			} else if s.err != nil {
+				return nil, s.err
+			}
+		}
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s.buf[pos]]
+		pos++
+	}
+	return s.frame(header, pos), nil
+}
+
+// ScanFrames splits the audio frame data read from r, such as File.Frames, into frames and calls fn for each of them in order.
+// Subframes are not decoded: frames are delimited by their headers and verified with their CRC-16.
+// Scanning stops at the first error returned by fn. If the data ends within a frame, as in interrupted recordings,
+// the complete frames are reported and io.ErrUnexpectedEOF is returned. If metadata follows a frame, as in concatenated files,
+// the frames before it are reported and a MisplacedMetadataError is returned. WithSalvage ends the scan cleanly at the last valid frame instead.
+func ScanFrames(r io.Reader, fn func(*Frame) error, opts ...ScanOption) error {
+	s := &frameScanner{r: r}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for {
+		frame, err := s.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+}
+
+// FinalizeStreamInfo repairs the StreamInfo block of the FLAC file at path in place from its audio frames, as needed by recordings
+// that were interrupted before their header was finalized. The sample count and the minimum and maximum block and frame sizes are
+// recomputed by scanning the frames; a truncated last frame is ignored. The audio MD5 cannot be derived without decoding and is left unchanged.
+// The repaired StreamInfo is returned.
+func FinalizeStreamInfo(path string) (*StreamInfoBlock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ParseReaderAt(f, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.GetStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var stats frameStats
+	stats.reset(*info)
+	err = ScanFrames(file.Frames, func(frame *Frame) error {
+		stats.account(&frame.Header, len(frame.Data))
+		return nil
+	})
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	res := stats.result()
+	if stats.frames == 0 {
+		res.BlockSizeMin, res.BlockSizeMax = info.BlockSizeMin, info.BlockSizeMax
+	}
+
+	if _, err := f.WriteAt(res.encode(), file.Meta[0].offset); err != nil {
+		return nil, err
+	}
+	return &res, f.Sync()
+}
+
+// RecomputeStreamInfoBounds scans the audio frames of the File and replaces BlockSizeMin, BlockSizeMax, FrameSizeMin and FrameSizeMax
+// of its StreamInfo block with the values found, as some encoders leave them zero, which strict players reject. Other fields are kept.
+// Files with a random access source, as returned by ParseFile and ParseReaderAt, are scanned from the source; otherwise Frames is read
+// into memory so it can still be written afterwards. The updated StreamInfo is returned.
+func (c *File) RecomputeStreamInfoBounds() (*StreamInfoBlock, error) {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return nil, err
+	}
+	var frames io.Reader
+	switch {
+	case c.src != nil && c.audioOffset > 0:
+		frames = io.NewSectionReader(c.src, c.audioOffset, c.srcSize-c.audioOffset)
+	case c.Frames != nil:
+		data, err := io.ReadAll(c.Frames)
+		if err != nil {
+			return nil, err
+		}
+		c.Close()
+		c.Frames = bytes.NewReader(data)
+		frames = bytes.NewReader(data)
+	default:
+		return nil, ErrorNoFrames
+	}
+
+	var stats frameStats
+	stats.reset(*info)
+	if err := ScanFrames(frames, func(frame *Frame) error {
+		stats.account(&frame.Header, len(frame.Data))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if stats.frames == 0 {
+		return nil, ErrorNoFrames
+	}
+	found := stats.result()
+	info.BlockSizeMin, info.BlockSizeMax = found.BlockSizeMin, found.BlockSizeMax
+	info.FrameSizeMin, info.FrameSizeMax = found.FrameSizeMin, found.FrameSizeMax
+	c.Meta[0].Data = info.encode()
+	c.modified(c.Meta[0])
+	return info, nil
+}
+
+// SalvageFrames cuts Frames at the end of the last valid frame, so that writing the File drops what follows it:
+// misplaced metadata, garbage or a truncated frame. It returns the number of bytes dropped.
+// Files with a random access source, as returned by ParseFile and ParseReaderAt, are scanned from the source and keep reading from it;
+// otherwise Frames is read into memory. The sample count of StreamInfo is not updated.
+func (c *File) SalvageFrames() (int64, error) {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return 0, err
+	}
+	var frames io.Reader
+	var data []byte
+	var size int64
+	switch {
+	case c.src != nil && c.audioOffset > 0:
+		size = c.srcSize - c.audioOffset
+		frames = io.NewSectionReader(c.src, c.audioOffset, size)
+	case c.Frames != nil:
+		if data, err = io.ReadAll(c.Frames); err != nil {
+			return 0, err
+		}
+		c.Close()
+		c.Frames = bytes.NewReader(data)
+		size = int64(len(data))
+		frames = bytes.NewReader(data)
+	default:
+		return 0, ErrorNoFrames
+	}
+
+	var valid int64
+	if err := ScanFrames(frames, func(frame *Frame) error {
+		valid = frame.Offset + int64(len(frame.Data))
+		return nil
+	}, WithSalvage(info)); err != nil {
+		return 0, err
+	}
+	if data != nil {
+		c.Frames = bytes.NewReader(data[:valid])
+	} else {
+		c.audioEnd = c.audioOffset + valid
+		c.Frames = c.sourceFrames()
+	}
+	return size - valid, nil
+}
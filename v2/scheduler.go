@@ -0,0 +1,143 @@
+package flac
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits a byte rate while allowing bursts of up to burst bytes
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(bytesPerSecond int64) *tokenBucket {
+	burst := float64(bytesPerSecond) / 10
+	if burst < 4096 {
+		burst = 4096
+	}
+	return &tokenBucket{rate: float64(bytesPerSecond), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// take removes n tokens, at most burst, waiting until they are available
+func (c *tokenBucket) take(n int) {
+	c.mu.Lock()
+	now := time.Now()
+	c.tokens += now.Sub(c.last).Seconds() * c.rate
+	if c.tokens > c.burst {
+		c.tokens = c.burst
+	}
+	c.last = now
+	c.tokens -= float64(n)
+	wait := time.Duration(-c.tokens / c.rate * float64(time.Second))
+	c.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// throttledWriter writes through a tokenBucket
+type throttledWriter struct {
+	w      io.Writer
+	bucket *tokenBucket
+}
+
+func (c *throttledWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		chunk := len(p)
+		if chunk > int(c.bucket.burst) {
+			chunk = int(c.bucket.burst)
+		}
+		c.bucket.take(chunk)
+		written, err := c.w.Write(p[:chunk])
+		n += written
+		if err != nil {
+			return n, err
+		}
+		p = p[chunk:]
+	}
+	return n, nil
+}
+
+// withThrottle makes Save write through bucket
+func withThrottle(bucket *tokenBucket) SaveOption {
+	return func(c *saveConfig) {
+		c.throttle = bucket
+	}
+}
+
+// saveJob is a Save queued in a SaveScheduler
+type saveJob struct {
+	file *File
+	fn   string
+	opts []SaveOption
+	done chan error
+}
+
+// SaveScheduler runs Save operations in the background, in the order they are scheduled, with caps on the number of concurrent saves
+// and on the bytes written per second shared by all of them, so desktop applications can retag in the background without saturating the disk.
+type SaveScheduler struct {
+	mu          sync.Mutex
+	queue       []saveJob
+	running     int
+	concurrency int
+	bucket      *tokenBucket
+	wg          sync.WaitGroup
+}
+
+// NewSaveScheduler returns a SaveScheduler running up to concurrency saves at once, at least one, and writing up to bytesPerSecond bytes per second
+// in total, or without limit if bytesPerSecond is not positive. Throttled saves do not use the io_uring copier.
+func NewSaveScheduler(concurrency int, bytesPerSecond int64) *SaveScheduler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	res := &SaveScheduler{concurrency: concurrency}
+	if bytesPerSecond > 0 {
+		res.bucket = newTokenBucket(bytesPerSecond)
+	}
+	return res
+}
+
+// Schedule queues saving f to fn with opts. The returned channel receives the result of Save once it ran; f must not be used until then.
+func (c *SaveScheduler) Schedule(f *File, fn string, opts ...SaveOption) <-chan error {
+	job := saveJob{file: f, fn: fn, opts: opts, done: make(chan error, 1)}
+	if c.bucket != nil {
+		job.opts = append(job.opts[:len(job.opts):len(job.opts)], withThrottle(c.bucket))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue = append(c.queue, job)
+	if c.running < c.concurrency {
+		c.running++
+		c.wg.Add(1)
+		go c.run()
+	}
+	return job.done
+}
+
+// run saves the queued Files until the queue is empty
+func (c *SaveScheduler) run() {
+	defer c.wg.Done()
+	for {
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			c.running--
+			c.mu.Unlock()
+			return
+		}
+		job := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+		job.done <- job.file.Save(job.fn, job.opts...)
+	}
+}
+
+// Wait blocks until every scheduled save completed
+func (c *SaveScheduler) Wait() {
+	c.wg.Wait()
+}
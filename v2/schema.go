@@ -0,0 +1,125 @@
+package flac
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+)
+
+// BlockSummary identifies a metadata block in a MetadataSchema
+type BlockSummary struct {
+	Type BlockType
+	// Size is the length of the block data
+	Size int
+	// Digest is the SHA-256 digest of the block data
+	Digest [sha256.Size]byte
+}
+
+// MetadataSchema lists the metadata blocks of a file, to tell when other tools touching the same library add or remove blocks.
+// Padding is left out, as taggers resize it on every edit.
+type MetadataSchema struct {
+	Blocks []BlockSummary
+}
+
+// ReadMetadataSchema validates the metadata of the FLAC file fn, as with WithStrict, and summarizes its blocks.
+// Blocks are hashed as they are read, so large pictures are not held in memory.
+func ReadMetadataSchema(fn string) (*MetadataSchema, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	file, err := ParseReaderAt(f, stat.Size(), WithStrict())
+	if err != nil {
+		return nil, err
+	}
+	res := new(MetadataSchema)
+	for _, block := range file.Meta {
+		if block.Type == Padding {
+			continue
+		}
+		digest, err := block.Hash()
+		if err != nil {
+			return nil, err
+		}
+		res.Blocks = append(res.Blocks, BlockSummary{Type: block.Type, Size: block.Len(), Digest: digest})
+	}
+	return res, nil
+}
+
+// SchemaDrift lists the blocks added and removed between two MetadataSchemas. A modified block is both removed and added.
+type SchemaDrift struct {
+	Added   []BlockSummary
+	Removed []BlockSummary
+}
+
+// Empty reports whether the schemas hold the same blocks, regardless of their order
+func (c *SchemaDrift) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// Drift compares the schema with current, a later schema of the same file
+func (c *MetadataSchema) Drift(current *MetadataSchema) *SchemaDrift {
+	left := make(map[BlockSummary]int, len(c.Blocks))
+	for _, block := range c.Blocks {
+		left[block]++
+	}
+	res := new(SchemaDrift)
+	for _, block := range current.Blocks {
+		if left[block] > 0 {
+			left[block]--
+		} else {
+			res.Added = append(res.Added, block)
+		}
+	}
+	for _, block := range c.Blocks {
+		if left[block] > 0 {
+			left[block]--
+			res.Removed = append(res.Removed, block)
+		}
+	}
+	return res
+}
+
+// schemaWatcher re-validates a file after it changed and reports the drift from the previous schema
+type schemaWatcher struct {
+	fn     string
+	schema *MetadataSchema
+	report func(*SchemaDrift, error)
+}
+
+func newSchemaWatcher(fn string, report func(*SchemaDrift, error)) (*schemaWatcher, error) {
+	schema, err := ReadMetadataSchema(fn)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaWatcher{fn: fn, schema: schema, report: report}, nil
+}
+
+func (c *schemaWatcher) check() {
+	schema, err := ReadMetadataSchema(c.fn)
+	if err != nil {
+		c.report(nil, err)
+		return
+	}
+	if drift := c.schema.Drift(schema); !drift.Empty() {
+		c.report(drift, nil)
+	}
+	c.schema = schema
+}
+
+// WatchMetadata re-validates the metadata of fn every time another process writes or replaces it, until ctx is done,
+// and calls report with the drift from the previous schema when blocks were added or removed, or with the error when the metadata no longer validates.
+// It returns the error of the first validation, and ctx.Err() once ctx is done.
+// Changes are detected with inotify on Linux and by polling the modification time elsewhere.
+func WatchMetadata(ctx context.Context, fn string, report func(*SchemaDrift, error)) error {
+	watcher, err := newSchemaWatcher(fn, report)
+	if err != nil {
+		return err
+	}
+	return watcher.watch(ctx)
+}
@@ -0,0 +1,92 @@
+package flac
+
+import "strings"
+
+// ScrubPolicy selects the metadata File.Scrub removes
+type ScrubPolicy struct {
+	// KeepFields lists the only Vorbis comment fields kept, nil keeps every field not matched by RemoveFields
+	KeepFields []string
+	// RemoveFields lists the Vorbis comment fields removed; a name ending in "*" matches every field starting with it
+	RemoveFields []string
+	// ClearVendor empties the vendor string of the VorbisComment block, which names the encoder
+	ClearVendor bool
+	// RemovePictures removes Picture blocks, whose images may carry camera and location data of their own
+	RemovePictures bool
+	// RemoveApplications removes Application blocks, which hold data of the software that wrote the File
+	RemoveApplications bool
+	// RemoveCueSheet removes CueSheet blocks, which identify the disc the audio was ripped from
+	RemoveCueSheet bool
+}
+
+// ScrubIdentifying removes comments, location and encoder information while keeping the artistic tags and artwork
+var ScrubIdentifying = ScrubPolicy{
+	RemoveFields: []string{
+		"COMMENT", "DESCRIPTION", "LOCATION", "GEO*", "CONTACT",
+		"ENCODER", "ENCODER_*", "ENCODED_BY", "ENCODED-BY", "ENCODEDBY", "ENCODING", "ENCODING_*",
+	},
+	ClearVendor:        true,
+	RemoveApplications: true,
+}
+
+// ScrubAllPersonal removes every Vorbis comment, picture, application data and cue sheet, leaving only StreamInfo, SeekTable and Padding
+var ScrubAllPersonal = ScrubPolicy{
+	KeepFields:         []string{},
+	ClearVendor:        true,
+	RemovePictures:     true,
+	RemoveApplications: true,
+	RemoveCueSheet:     true,
+}
+
+// matchScrubField reports whether the upper-cased field name matches one of the names or prefix patterns
+func matchScrubField(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToUpper(pattern)
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Scrub removes the metadata selected by policy, for platforms accepting uploads that must not leak personal information.
+// A VorbisComment block left empty is kept, as it costs a few bytes only.
+func (c *File) Scrub(policy ScrubPolicy) error {
+	for _, block := range c.Meta {
+		if block.Type != VorbisComment {
+			continue
+		}
+		vendor, comments, err := loadVorbisComment(block)
+		if err != nil {
+			return err
+		}
+		kept := comments[:0]
+		for _, comment := range comments {
+			name, _ := splitVorbisComment(comment)
+			if policy.KeepFields != nil && !matchScrubField(policy.KeepFields, name) || matchScrubField(policy.RemoveFields, name) {
+				continue
+			}
+			kept = append(kept, comment)
+		}
+		if policy.ClearVendor {
+			vendor = ""
+		}
+		block.Data = marshalVorbisComment(vendor, kept)
+		c.modified(block)
+	}
+	c.removeBlocks(func(block *MetaDataBlock) bool {
+		switch block.Type {
+		case Picture:
+			return policy.RemovePictures
+		case Application:
+			return policy.RemoveApplications
+		case CueSheet:
+			return policy.RemoveCueSheet
+		}
+		return false
+	})
+	return nil
+}
@@ -0,0 +1,65 @@
+package flac
+
+import (
+	"io"
+	"sync"
+)
+
+// seekerAt reads at arbitrary offsets of an io.ReadSeeker, serializing the seeks
+type seekerAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (c *seekerAt) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(c.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// ParseSeeker parses the FLAC stream read from rs, starting at its current position and ending at its end.
+// Metadata blocks are read like ParseBytes does, but the File keeps random access to rs: Frames is an *io.SectionReader
+// of the audio frames, rewound once written as with WithRewindableFrames, and blocks can be left in rs with WithLazyBlocks.
+// rs is read with ReadAt if it implements io.ReaderAt, and with seeks otherwise; it must stay usable for as long as the File is used,
+// and is not closed by Close. Files extracted from a wrapper registered with RegisterWrapper are read once, as by ParseBytes.
+func ParseSeeker(rs io.ReadSeeker, opts ...ParseOption) (*File, error) {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	ra, ok := rs.(io.ReaderAt)
+	if !ok {
+		ra = &seekerAt{rs: rs}
+	}
+	size := end - start
+	if start != 0 {
+		// the source is kept as is when possible, so saving over a file it reads from is detected
+		ra = io.NewSectionReader(ra, start, size)
+	}
+
+	res, err := ParseBytes(NewBufIOWithInner(io.NewSectionReader(ra, 0, size)), append(opts[:len(opts):len(opts)], fromFile(""))...)
+	if err != nil {
+		if res != nil && !res.wrapped {
+			res.attachSource(ra, size)
+		}
+		return res, err
+	}
+	if res.wrapped {
+		return res, nil
+	}
+	res.attachSource(ra, size)
+	res.Frames = io.NewSectionReader(ra, res.audioOffset, size-res.audioOffset)
+	res.rewindable = true
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package flac
+
+import "encoding/binary"
+
+// SeekPointPlaceholder is the sample number of placeholder seek points, which carry no position and reserve room for seek points added later
+const SeekPointPlaceholder uint64 = 0xFFFFFFFFFFFFFFFF
+
+// seekPointSize is the encoded size of a seek point
+const seekPointSize = 18
+
+// seekPoint is a decoded entry of a SeekTable block
+type seekPoint struct {
+	sample  uint64
+	offset  uint64
+	samples uint16
+}
+
+// parseSeekTable decodes the seek points of a SeekTable metadata block
+func parseSeekTable(data []byte) ([]seekPoint, error) {
+	if len(data)%seekPointSize != 0 {
+		return nil, ErrorMalformedSeekTable
+	}
+	res := make([]seekPoint, len(data)/seekPointSize)
+	for i := range res {
+		p := data[i*seekPointSize:]
+		res[i] = seekPoint{
+			sample:  binary.BigEndian.Uint64(p),
+			offset:  binary.BigEndian.Uint64(p[8:]),
+			samples: binary.BigEndian.Uint16(p[16:]),
+		}
+	}
+	return res, nil
+}
+
+// SeekPoint is a seek point of a SeekTable metadata block
+type SeekPoint struct {
+	// SampleNumber is the number of the first sample of the target frame, or SeekPointPlaceholder
+	SampleNumber uint64
+	// Offset is the position of the target frame in bytes, from the first byte of the first frame
+	Offset uint64
+	// FrameSamples is the number of samples of the target frame
+	FrameSamples uint16
+}
+
+// IsPlaceholder reports whether the seek point is a placeholder
+func (c SeekPoint) IsPlaceholder() bool {
+	return c.SampleNumber == SeekPointPlaceholder
+}
+
+// SeekTableBlock is the decoded form of a SeekTable metadata block
+type SeekTableBlock struct {
+	// Points are the seek points in ascending sample order, followed by the placeholders
+	Points []SeekPoint
+}
+
+// ParseSeekTable decodes a SeekTable metadata block, loading it first if it was parsed lazily
+func ParseSeekTable(meta *MetaDataBlock) (*SeekTableBlock, error) {
+	if meta.Type != SeekTable {
+		return nil, ErrorUnexpectedBlockType
+	}
+	if err := meta.Load(); err != nil {
+		return nil, err
+	}
+	points, err := parseSeekTable(meta.Data)
+	if err != nil {
+		return nil, err
+	}
+	res := &SeekTableBlock{Points: make([]SeekPoint, len(points))}
+	for i, p := range points {
+		res.Points[i] = SeekPoint{SampleNumber: p.sample, Offset: p.offset, FrameSamples: p.samples}
+	}
+	return res, nil
+}
+
+// Marshal encodes the SeekTableBlock into a MetaDataBlock
+func (c *SeekTableBlock) Marshal() MetaDataBlock {
+	data := make([]byte, 0, len(c.Points)*seekPointSize)
+	for _, p := range c.Points {
+		data = binary.BigEndian.AppendUint64(data, p.SampleNumber)
+		data = binary.BigEndian.AppendUint64(data, p.Offset)
+		data = binary.BigEndian.AppendUint16(data, p.FrameSamples)
+	}
+	return MetaDataBlock{
+		Type: SeekTable,
+		Data: data,
+	}
+}
+
+// Equal reports whether both blocks hold the same seek points in the same order
+func (c *SeekTableBlock) Equal(other *SeekTableBlock) bool {
+	if len(c.Points) != len(other.Points) {
+		return false
+	}
+	for i := range c.Points {
+		if c.Points[i] != other.Points[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// seekPoints returns the non-placeholder seek points of the first SeekTable block of the File, nil if there is none
+func (c *File) seekPoints() ([]seekPoint, error) {
+	for _, meta := range c.Meta {
+		if meta.Type != SeekTable {
+			continue
+		}
+		if err := meta.Load(); err != nil {
+			return nil, err
+		}
+		points, err := parseSeekTable(meta.Data)
+		if err != nil {
+			return nil, err
+		}
+		res := points[:0]
+		for _, p := range points {
+			if p.sample != SeekPointPlaceholder {
+				res = append(res, p)
+			}
+		}
+		return res, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,178 @@
+package flac
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// SignatureApplicationID is the Application block ID used to store detached signatures:
+//
+//	ID "SIGN" | version (1 byte) | signature length (4 bytes) | signature
+//
+// The signature covers a SHA-256 digest of the canonicalized metadata, see File.Sign.
+var SignatureApplicationID = [4]byte{'S', 'I', 'G', 'N'}
+
+// signatureVersion is the version of the signature block layout and digest canonicalization
+const signatureVersion = 1
+
+// parseSignature returns the signature stored in meta and whether meta is a signature block, loading Application blocks that are not loaded yet
+func parseSignature(meta *MetaDataBlock) ([]byte, bool, error) {
+	if meta.Type != Application {
+		return nil, false, nil
+	}
+	if err := meta.Load(); err != nil {
+		return nil, false, err
+	}
+	app, err := ParseApplication(meta)
+	if err != nil || app.ID != SignatureApplicationID {
+		return nil, false, nil
+	}
+	data := app.Data
+	if len(data) < 5 || data[0] != signatureVersion {
+		return nil, true, ErrorMalformedSignature
+	}
+	length := binary.BigEndian.Uint32(data[1:])
+	if uint64(length) != uint64(len(data)-5) {
+		return nil, true, ErrorMalformedSignature
+	}
+	return data[5:], true, nil
+}
+
+// signatureDigest computes the SHA-256 digest signed by Sign.
+// StreamInfo, which carries the audio MD5, and every other block except Padding and signatures are covered.
+// Vorbis comments are canonicalized as for VorbisCommentBlock.Equal, and the other blocks are hashed individually and sorted,
+// so reordering blocks or fields and resizing padding do not invalidate a signature, while reordering the values of a field does.
+// Blocks that are not loaded are read from the source, and only VorbisComment and Application blocks are loaded.
+func (c *File) signatureDigest() ([]byte, error) {
+	var blocks [][]byte
+	for _, meta := range c.Meta {
+		if meta.Type == Padding {
+			continue
+		}
+		if _, ok, err := parseSignature(meta); err != nil {
+			return nil, err
+		} else if ok {
+			continue
+		}
+		h := sha256.New()
+		h.Write([]byte{byte(meta.Type)})
+		if meta.Type == VorbisComment {
+			if err := meta.Load(); err != nil {
+				return nil, err
+			}
+			block, err := ParseVorbisComment(meta)
+			if err != nil {
+				return nil, err
+			}
+			for _, comment := range block.canonical() {
+				h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(comment))))
+				h.Write([]byte(comment))
+			}
+		} else if _, err := io.Copy(h, meta.Reader()); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, h.Sum(nil))
+	}
+	sort.Slice(blocks, func(i, j int) bool { return bytes.Compare(blocks[i], blocks[j]) < 0 })
+
+	h := sha256.New()
+	h.Write([]byte{signatureVersion})
+	for _, block := range blocks {
+		h.Write(block)
+	}
+	return h.Sum(nil), nil
+}
+
+// Sign stores a detached signature of the File in an Application block, replacing any existing signature.
+// The signature covers StreamInfo, including the audio MD5, and the canonicalized tags and other metadata.
+// The audio frames are bound through the MD5 only; check them against StreamInfo to detect tampering with the audio itself.
+// It returns ErrorUnknownAudioMD5 if StreamInfo does not carry an audio MD5, as the signature would then cover no audio.
+// RSA keys sign with PKCS #1 v1.5, ECDSA keys with ASN.1 signatures, and Ed25519 keys sign the digest as the message.
+func (c *File) Sign(signer crypto.Signer) error {
+	if err := c.requireAudioMD5(); err != nil {
+		return err
+	}
+	digest, err := c.signatureDigest()
+	if err != nil {
+		return err
+	}
+	var opts crypto.SignerOpts = crypto.SHA256
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		opts = crypto.Hash(0)
+	}
+	sig, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return err
+	}
+
+	c.RemoveSignature()
+	data := make([]byte, 0, 5+len(sig))
+	data = append(data, signatureVersion)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(sig)))
+	data = append(data, sig...)
+	app := ApplicationBlock{ID: SignatureApplicationID, Data: data}
+	meta := app.Marshal()
+	c.Meta = append(c.Meta, &meta)
+	c.modified(&meta)
+	return nil
+}
+
+// Verify checks the signature stored by Sign against the public key.
+// It returns ErrorNoSignature if the File is not signed and ErrorSignatureInvalid if the metadata changed since it was signed.
+// A signed File whose audio MD5 was cleared is rejected with ErrorUnknownAudioMD5, since its audio is no longer bound to the signature.
+func (c *File) Verify(pub crypto.PublicKey) error {
+	var sig []byte
+	for _, meta := range c.Meta {
+		s, ok, err := parseSignature(meta)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		sig = s
+		break
+	}
+	if sig == nil {
+		return ErrorNoSignature
+	}
+	if err := c.requireAudioMD5(); err != nil {
+		return err
+	}
+	digest, err := c.signatureDigest()
+	if err != nil {
+		return err
+	}
+
+	var valid bool
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest, sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, digest, sig)
+	default:
+		return ErrorUnsupportedKey
+	}
+	if !valid {
+		return ErrorSignatureInvalid
+	}
+	return nil
+}
+
+// RemoveSignature removes any signature stored by Sign
+func (c *File) RemoveSignature() {
+	c.removeBlocks(func(block *MetaDataBlock) bool {
+		_, ok, _ := parseSignature(block)
+		return ok
+	})
+}
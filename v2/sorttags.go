@@ -0,0 +1,115 @@
+package flac
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortFields maps the fields with a conventional sort field to that field
+var SortFields = map[string]string{
+	"ARTIST":      "ARTISTSORT",
+	"ALBUMARTIST": "ALBUMARTISTSORT",
+	"ALBUM":       "ALBUMSORT",
+	"TITLE":       "TITLESORT",
+	"COMPOSER":    "COMPOSERSORT",
+}
+
+// SortRules configures how GenerateSortTags derives sort names
+type SortRules struct {
+	// Fields maps the fields to derive sort names from to the fields receiving them, SortFields if nil
+	Fields map[string]string
+	// Articles are the leading words moved to the end of a sort name, compared case-insensitively, e.g. "The Beatles" sorts as "Beatles, The"
+	Articles []string
+	// SurnameFirst lists the fields holding person names, sorted by their last word: "Johann Sebastian Bach" sorts as "Bach, Johann Sebastian"
+	// and "Ludwig van Beethoven" as "Beethoven, Ludwig van". Names already holding a comma are kept as they are.
+	SurnameFirst []string
+	// ASCII transliterates the sort names with Transliterate
+	ASCII bool
+	// Overwrite replaces the sort fields that are already set instead of keeping them
+	Overwrite bool
+}
+
+// DefaultSortRules moves English articles and sorts composers by surname
+var DefaultSortRules = SortRules{
+	Articles:     []string{"The", "A", "An"},
+	SurnameFirst: []string{"COMPOSER"},
+}
+
+// sortName returns the sort name of value, surname first if person is set
+func (c *SortRules) sortName(value string, person bool) string {
+	res := value
+	switch {
+	case person && !strings.Contains(value, ","):
+		words := strings.Fields(value)
+		if len(words) < 2 {
+			break
+		}
+		res = words[len(words)-1] + ", " + strings.Join(words[:len(words)-1], " ")
+	default:
+		for _, article := range c.Articles {
+			if len(value) > len(article)+1 && strings.EqualFold(value[:len(article)], article) && value[len(article)] == ' ' {
+				res = strings.TrimLeft(value[len(article)+1:], " ") + ", " + value[:len(article)]
+				break
+			}
+		}
+	}
+	if c.ASCII {
+		res = Transliterate(res)
+	}
+	return res
+}
+
+// GenerateSortTags derives the sort names of the fields of rules.Fields, or of DefaultSortRules if rules is nil, and writes them to their sort fields.
+// A sort field is only written when a sort name differs from its value, and kept when it is already set unless rules.Overwrite is set,
+// in which case it is also removed when the values need no sort name.
+func (c *VorbisCommentBlock) GenerateSortTags(rules *SortRules) {
+	if rules == nil {
+		rules = &DefaultSortRules
+	}
+	fields := rules.Fields
+	if fields == nil {
+		fields = SortFields
+	}
+	person := make(map[string]bool, len(rules.SurnameFirst))
+	for _, field := range rules.SurnameFirst {
+		person[strings.ToUpper(field)] = true
+	}
+	sources := make([]string, 0, len(fields))
+	for source := range fields {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	values := c.fields()
+	for _, source := range sources {
+		target := strings.ToUpper(fields[source])
+		source = strings.ToUpper(source)
+		if len(values[target]) > 0 && !rules.Overwrite {
+			continue
+		}
+		names := make([]string, len(values[source]))
+		changed := false
+		for i, value := range values[source] {
+			names[i] = rules.sortName(value, person[source])
+			changed = changed || names[i] != value
+		}
+		if !changed {
+			if rules.Overwrite {
+				c.Remove(target)
+			}
+			continue
+		}
+		c.Remove(target)
+		for _, name := range names {
+			c.Comments = append(c.Comments, target+"="+name)
+		}
+	}
+}
+
+// GenerateSortTags applies VorbisCommentBlock.GenerateSortTags to the first VorbisComment block of the File, if any
+func (c *File) GenerateSortTags(rules *SortRules) error {
+	return c.editVorbisComment(false, func(block *VorbisCommentBlock) error {
+		block.GenerateSortTags(rules)
+		return nil
+	})
+}
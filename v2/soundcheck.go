@@ -0,0 +1,164 @@
+package flac
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ReplayGainTrackGain is the Vorbis comment field holding the track gain, e.g. "-6.48 dB"
+	ReplayGainTrackGain = "REPLAYGAIN_TRACK_GAIN"
+	// ReplayGainTrackPeak is the Vorbis comment field holding the track peak as a fraction of full scale, e.g. "0.988553"
+	ReplayGainTrackPeak = "REPLAYGAIN_TRACK_PEAK"
+	// ReplayGainAlbumGain is the Vorbis comment field holding the album gain
+	ReplayGainAlbumGain = "REPLAYGAIN_ALBUM_GAIN"
+	// ReplayGainAlbumPeak is the Vorbis comment field holding the album peak
+	ReplayGainAlbumPeak = "REPLAYGAIN_ALBUM_PEAK"
+	// SoundCheckField is the Vorbis comment field iTunes-compatible taggers use to store the iTunNORM value
+	SoundCheckField = "ITUNNORM"
+)
+
+// soundCheckMax is the largest gain value iTunes accepts in the SoundCheck fields
+const soundCheckMax = 65534
+
+// soundCheckUnknown is the value iTunes writes for the undocumented statistics fields
+const soundCheckUnknown = 0x00024CA8
+
+// SoundCheck holds the ten values of an iTunes iTunNORM tag.
+// Values 0 and 1 are the left/right gain relative to 1/1000 W, values 2 and 3 the same gain relative to 1/2500 W, and values 6 and 7 the left/right peak as 16-bit sample magnitudes.
+// The remaining values are statistics iTunes does not use for playback.
+type SoundCheck [10]uint32
+
+// SoundCheckFromReplayGain computes the SoundCheck values for a ReplayGain gain in dB and a peak as a fraction of full scale
+func SoundCheckFromReplayGain(gain, peak float64) SoundCheck {
+	scale := math.Pow(10, -gain/10)
+	clamp := func(v float64) uint32 {
+		v = math.Round(v)
+		if v > soundCheckMax {
+			return soundCheckMax
+		}
+		if v < 0 {
+			return 0
+		}
+		return uint32(v)
+	}
+	g1000 := clamp(1000 * scale)
+	g2500 := clamp(2500 * scale)
+	p := math.Round(peak * 32768)
+	if p > 0x7FFF {
+		p = 0x7FFF
+	} else if p < 0 {
+		p = 0
+	}
+	return SoundCheck{
+		g1000, g1000,
+		g2500, g2500,
+		soundCheckUnknown, soundCheckUnknown,
+		uint32(p), uint32(p),
+		soundCheckUnknown, soundCheckUnknown,
+	}
+}
+
+// ParseSoundCheck decodes an iTunNORM value made of ten space separated 8-digit hexadecimal numbers
+func ParseSoundCheck(s string) (SoundCheck, error) {
+	var res SoundCheck
+	fields := strings.Fields(s)
+	if len(fields) != len(res) {
+		return res, ErrorInvalidSoundCheck
+	}
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 16, 32)
+		if err != nil {
+			return res, ErrorInvalidSoundCheck
+		}
+		res[i] = uint32(v)
+	}
+	return res, nil
+}
+
+// String encodes the SoundCheck values in the iTunNORM format, including the leading space iTunes writes
+func (s SoundCheck) String() string {
+	var b strings.Builder
+	for _, v := range s {
+		fmt.Fprintf(&b, " %08X", v)
+	}
+	return b.String()
+}
+
+// ReplayGain converts the SoundCheck values back to a ReplayGain gain in dB and a peak as a fraction of full scale.
+// The louder of the two channels is used for both.
+func (s SoundCheck) ReplayGain() (gain, peak float64) {
+	g := s[0]
+	if s[1] > g {
+		g = s[1]
+	}
+	if g == 0 {
+		g = 1
+	}
+	p := s[6]
+	if s[7] > p {
+		p = s[7]
+	}
+	return -10 * math.Log10(float64(g)/1000), float64(p) / 32768
+}
+
+// ParseReplayGain parses a ReplayGain gain value such as "-6.48 dB"
+func ParseReplayGain(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[len(s)-2:], "dB") {
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// FormatReplayGain formats a gain in dB the way ReplayGain scanners write it, e.g. "-6.48 dB"
+func FormatReplayGain(gain float64) string {
+	return strconv.FormatFloat(gain, 'f', 2, 64) + " dB"
+}
+
+// FormatReplayGainPeak formats a peak the way ReplayGain scanners write it, e.g. "0.988553"
+func FormatReplayGainPeak(peak float64) string {
+	return strconv.FormatFloat(peak, 'f', 6, 64)
+}
+
+// SoundCheckFromTags computes SoundCheck values from the REPLAYGAIN_TRACK_GAIN and REPLAYGAIN_TRACK_PEAK fields.
+// Field names are matched case-insensitively; a missing peak is treated as 0.
+func SoundCheckFromTags(fields map[string][]string) (SoundCheck, error) {
+	var gainValue, peakValue string
+	for name, values := range fields {
+		if len(values) == 0 {
+			continue
+		}
+		switch strings.ToUpper(name) {
+		case ReplayGainTrackGain:
+			gainValue = values[0]
+		case ReplayGainTrackPeak:
+			peakValue = values[0]
+		}
+	}
+	if gainValue == "" {
+		return SoundCheck{}, ErrorNoReplayGain
+	}
+	gain, err := ParseReplayGain(gainValue)
+	if err != nil {
+		return SoundCheck{}, fmt.Errorf("invalid %s %q: %w", ReplayGainTrackGain, gainValue, err)
+	}
+	var peak float64
+	if peakValue != "" {
+		if peak, err = strconv.ParseFloat(strings.TrimSpace(peakValue), 64); err != nil {
+			return SoundCheck{}, fmt.Errorf("invalid %s %q: %w", ReplayGainTrackPeak, peakValue, err)
+		}
+	}
+	return SoundCheckFromReplayGain(gain, peak), nil
+}
+
+// Tags returns the REPLAYGAIN_TRACK_GAIN and REPLAYGAIN_TRACK_PEAK fields equivalent to the SoundCheck values
+func (s SoundCheck) Tags() map[string][]string {
+	gain, peak := s.ReplayGain()
+	return map[string][]string{
+		ReplayGainTrackGain: {FormatReplayGain(gain)},
+		ReplayGainTrackPeak: {FormatReplayGainPeak(peak)},
+	}
+}
@@ -0,0 +1,89 @@
+package flac
+
+import "errors"
+
+// EncoderStamp describes the software writing a File, recorded by Save and SaveTo in its VorbisComment block
+type EncoderStamp struct {
+	// Vendor replaces the vendor string of the VorbisComment block if not empty
+	Vendor string
+	// Encoder is the value of the ENCODER comment if not empty
+	Encoder string
+	// EncodedBy is the value of the ENCODEDBY comment if not empty
+	EncodedBy string
+	// Append keeps the existing ENCODER and ENCODEDBY values and adds the new ones after them unless already present,
+	// so the file records every tool that wrote it. Otherwise the existing values are replaced.
+	Append bool
+}
+
+// DefaultEncoderStamp is applied by every Save and SaveTo without WithEncoderStamp. It is nil, leaving the File untouched,
+// unless the application sets it; it must not be changed while the package is in use.
+var DefaultEncoderStamp *EncoderStamp
+
+// WithEncoderStamp makes Save and SaveTo record stamp in the VorbisComment block of the File before writing it,
+// adding the block if needed. A nil stamp touches nothing, even if DefaultEncoderStamp is set, for archives that must keep files as they were.
+// The VorbisComment block is only re-encoded if the stamp changes it.
+func WithEncoderStamp(stamp *EncoderStamp) SaveOption {
+	return func(c *saveConfig) {
+		c.stamp = stamp
+		c.stampSet = true
+	}
+}
+
+// encoderStamp returns the stamp the save applies, nil for none
+func (c *saveConfig) encoderStamp() *EncoderStamp {
+	if c.stampSet {
+		return c.stamp
+	}
+	return DefaultEncoderStamp
+}
+
+// errUnstamped ends the edit of a VorbisComment block that the stamp does not change
+var errUnstamped = errors.New("stamp already present")
+
+// applyStamp records stamp in the VorbisComment block of the File
+func (c *File) applyStamp(stamp *EncoderStamp) error {
+	if stamp == nil || *stamp == (EncoderStamp{}) {
+		return nil
+	}
+	err := c.editVorbisComment(true, func(block *VorbisCommentBlock) error {
+		changed := false
+		if stamp.Vendor != "" && block.Vendor != stamp.Vendor {
+			block.Vendor = stamp.Vendor
+			changed = true
+		}
+		for _, field := range [...]struct{ name, value string }{{"ENCODER", stamp.Encoder}, {"ENCODEDBY", stamp.EncodedBy}} {
+			if field.value == "" {
+				continue
+			}
+			values := block.Get(field.name)
+			if stamp.Append && contains(values, field.value) || !stamp.Append && len(values) == 1 && values[0] == field.value {
+				continue
+			}
+			if !stamp.Append {
+				block.Remove(field.name)
+			}
+			if err := block.Add(field.name, field.value); err != nil {
+				return err
+			}
+			changed = true
+		}
+		if !changed {
+			return errUnstamped
+		}
+		return nil
+	})
+	if err == errUnstamped {
+		return nil
+	}
+	return err
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,10 @@ package flac
 
 import (
 	"bytes"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"time"
 )
 
 // StreamInfoBlock represents the undecoded data of StreamInfo block
@@ -32,7 +35,12 @@ func (c *File) GetStreamInfo() (*StreamInfoBlock, error) {
 	if c.Meta[0].Type != StreamInfo {
 		return nil, ErrorNoStreamInfo
 	}
-	streamInfo := bytes.NewReader(c.Meta[0].Data)
+	return parseStreamInfo(c.Meta[0].Data)
+}
+
+// parseStreamInfo decodes the data of a StreamInfo metadata block
+func parseStreamInfo(data []byte) (*StreamInfoBlock, error) {
+	streamInfo := bytes.NewReader(data)
 	res := StreamInfoBlock{}
 
 	if buf, err := readUint16(streamInfo); err != nil {
@@ -123,6 +131,135 @@ func (c *File) GetStreamInfo() (*StreamInfoBlock, error) {
 		return nil, ErrorStreamInfoEarlyEOF
 	}
 
+	if err := res.checkFormat(); err != nil {
+		return nil, err
+	}
 	return &res, nil
 
 }
+
+// StreamInfoError reports a StreamInfo field holding a value the format does not allow.
+// errors.Is(err, ErrorInvalidStreamInfo) reports whether an error is a StreamInfoError.
+type StreamInfoError struct {
+	// Field is the name of the offending StreamInfoBlock field
+	Field string
+	// Value is the value of the field
+	Value int64
+}
+
+func (e *StreamInfoError) Error() string {
+	return fmt.Sprintf("invalid stream info: %s %d", e.Field, e.Value)
+}
+
+// Is reports whether target is ErrorInvalidStreamInfo
+func (e *StreamInfoError) Is(target error) bool {
+	return target == ErrorInvalidStreamInfo
+}
+
+// checkFormat validates the fields describing the audio format, which sample and duration computations depend on
+func (c *StreamInfoBlock) checkFormat() error {
+	if c.SampleRate == 0 {
+		return &StreamInfoError{Field: "SampleRate", Value: int64(c.SampleRate)}
+	}
+	if c.ChannelCount < 1 || c.ChannelCount > 8 {
+		return &StreamInfoError{Field: "ChannelCount", Value: int64(c.ChannelCount)}
+	}
+	if c.BitDepth < 4 || c.BitDepth > 32 {
+		return &StreamInfoError{Field: "BitDepth", Value: int64(c.BitDepth)}
+	}
+	return nil
+}
+
+// Validate checks every field of the StreamInfoBlock against the limits of the format, returning a StreamInfoError for the first invalid one.
+// Block and frame sizes of zero mean unknown, as some encoders leave them, so Files parsed with them can be saved back unchanged.
+func (c *StreamInfoBlock) Validate() error {
+	if err := c.checkFormat(); err != nil {
+		return err
+	}
+	if c.SampleRate > 655350 {
+		return &StreamInfoError{Field: "SampleRate", Value: int64(c.SampleRate)}
+	}
+	if c.BlockSizeMin != 0 && c.BlockSizeMin < 16 || c.BlockSizeMin > 65535 {
+		return &StreamInfoError{Field: "BlockSizeMin", Value: int64(c.BlockSizeMin)}
+	}
+	if c.BlockSizeMax != 0 && c.BlockSizeMax < c.BlockSizeMin || c.BlockSizeMax > 65535 {
+		return &StreamInfoError{Field: "BlockSizeMax", Value: int64(c.BlockSizeMax)}
+	}
+	if c.FrameSizeMin != 0 && c.FrameSizeMax != 0 && c.FrameSizeMax < c.FrameSizeMin {
+		return &StreamInfoError{Field: "FrameSizeMax", Value: int64(c.FrameSizeMax)}
+	}
+	if c.SampleCount < 0 || c.SampleCount >= 1<<36 {
+		return &StreamInfoError{Field: "SampleCount", Value: c.SampleCount}
+	}
+	return nil
+}
+
+// encode packs the StreamInfoBlock into the 34 bytes of a StreamInfo metadata block
+func (c *StreamInfoBlock) encode() []byte {
+	res := make([]byte, 34)
+	binary.BigEndian.PutUint16(res[0:], uint16(c.BlockSizeMin))
+	binary.BigEndian.PutUint16(res[2:], uint16(c.BlockSizeMax))
+	putUint24(res[4:], uint32(c.FrameSizeMin))
+	putUint24(res[7:], uint32(c.FrameSizeMax))
+	packed := uint64(c.SampleRate)<<44 | uint64(c.ChannelCount-1)&0x7<<41 | uint64(c.BitDepth-1)&0x1F<<36 | uint64(c.SampleCount)&0xFFFFFFFFF
+	binary.BigEndian.PutUint64(res[10:], packed)
+	copy(res[18:], c.AudioMD5)
+	return res
+}
+
+// Marshal encodes the StreamInfoBlock into a MetaDataBlock.
+// Values too large for their field are truncated, so Validate should be called first.
+func (c *StreamInfoBlock) Marshal() MetaDataBlock {
+	return MetaDataBlock{
+		Type: StreamInfo,
+		Data: c.encode(),
+	}
+}
+
+// SetStreamInfo encodes info into the first metadata block of the File after checking its values with Validate.
+// It returns ErrorNoStreamInfo if the first block is not a StreamInfo block.
+func (c *File) SetStreamInfo(info *StreamInfoBlock) error {
+	if len(c.Meta) == 0 || c.Meta[0].Type != StreamInfo {
+		return ErrorNoStreamInfo
+	}
+	if err := info.Validate(); err != nil {
+		return err
+	}
+	c.Meta[0].Data = info.encode()
+	c.modified(c.Meta[0])
+	return nil
+}
+
+// Duration returns the length of the stream, or ErrorUnknownDuration if the encoder did not record the sample count or the sample rate,
+// as for live recordings. The 36-bit sample count is converted without overflowing time.Duration.
+func (c *StreamInfoBlock) Duration() (time.Duration, error) {
+	if c.SampleCount <= 0 || c.SampleRate <= 0 {
+		return 0, ErrorUnknownDuration
+	}
+	return samplesToDuration(c.SampleCount, c.SampleRate), nil
+}
+
+// Duration returns the length of the stream of the File according to its StreamInfo block, or ErrorUnknownDuration if it is not recorded
+func (c *File) Duration() (time.Duration, error) {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return 0, err
+	}
+	return info.Duration()
+}
+
+// Equal reports whether both blocks hold the same values. A nil AudioMD5 equals an all-zero one, as both mean the signature is unknown.
+func (c *StreamInfoBlock) Equal(other *StreamInfoBlock) bool {
+	return c.BlockSizeMin == other.BlockSizeMin && c.BlockSizeMax == other.BlockSizeMax &&
+		c.FrameSizeMin == other.FrameSizeMin && c.FrameSizeMax == other.FrameSizeMax &&
+		c.SampleRate == other.SampleRate && c.ChannelCount == other.ChannelCount && c.BitDepth == other.BitDepth &&
+		c.SampleCount == other.SampleCount && bytes.Equal(c.audioMD5(), other.audioMD5())
+}
+
+// audioMD5 returns AudioMD5, or 16 zero bytes when it is not set
+func (c *StreamInfoBlock) audioMD5() []byte {
+	if len(c.AudioMD5) == 0 {
+		return make([]byte, 16)
+	}
+	return c.AudioMD5
+}
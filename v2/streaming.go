@@ -0,0 +1,42 @@
+package flac
+
+import "sort"
+
+// StreamingBlockLimit is the size above which OptimizeForStreaming considers a metadata block large and moves it after the small ones
+const StreamingBlockLimit = 64 << 10
+
+// streamingRank orders metadata blocks for progressive playback: what a player needs to start first, bulky data last
+func streamingRank(block *MetaDataBlock) int {
+	switch {
+	case block.Type == StreamInfo:
+		return 0
+	case block.Type == SeekTable:
+		return 1
+	case block.Type == Picture:
+		return 7
+	case block.Len() > StreamingBlockLimit:
+		return 6
+	case block.Type == VorbisComment:
+		return 2
+	case block.Type == CueSheet:
+		return 3
+	case block.Type == Padding:
+		return 5
+	}
+	return 4
+}
+
+// OptimizeForStreaming reorders the metadata blocks to minimize the bytes a player must download before the first audio frame.
+// StreamInfo comes first, followed by the SeekTable, the VorbisComment and the other blocks up to StreamingBlockLimit bytes, then
+// Padding, larger blocks and finally pictures, keeping the relative order of blocks of the same rank.
+// Pictures are removed instead of moved if stripPictures is set, which invalidates signatures made by Sign; reordering alone does not.
+func (c *File) OptimizeForStreaming(stripPictures bool) {
+	if stripPictures {
+		c.removeBlocks(func(block *MetaDataBlock) bool {
+			return block.Type == Picture
+		})
+	}
+	sort.SliceStable(c.Meta, func(i, j int) bool {
+		return streamingRank(c.Meta[i]) < streamingRank(c.Meta[j])
+	})
+}
@@ -0,0 +1,34 @@
+package flac
+
+// StripMetadata removes every metadata block except StreamInfo and the blocks of the given types, including Padding unless kept,
+// leaving a minimal file for privacy scrubbing or for fingerprinting services
+func (c *File) StripMetadata(keep ...BlockType) {
+	c.removeBlocks(func(block *MetaDataBlock) bool {
+		return block.Type != StreamInfo && !containsBlockType(keep, block.Type)
+	})
+}
+
+func containsBlockType(types []BlockType, t BlockType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveStripped saves the File with StreamInfo as its only metadata block to fn, like Save.
+// The metadata of the File itself is left untouched, but its frames are consumed just as by Save.
+func (c *File) SaveStripped(fn string, opts ...SaveOption) error {
+	stripped := *c
+	stripped.Meta = nil
+	for _, block := range c.Meta {
+		if block.Type == StreamInfo {
+			stripped.Meta = append(stripped.Meta, block)
+		}
+	}
+	err := stripped.Save(fn, opts...)
+	// the frames are shared, so the File sees them written, or rewound by WithRewindableFrames, like the copy saved
+	c.Frames = stripped.Frames
+	return err
+}
@@ -0,0 +1,314 @@
+package flac
+
+import (
+	"math"
+	"math/bits"
+)
+
+// subframe types as coded in the subframe header
+const (
+	subframeConstant = 0
+	subframeVerbatim = 1
+	subframeFixed    = 8
+	subframeLPC      = 32
+)
+
+// subframe is the encoding chosen for the samples of one channel
+type subframe struct {
+	kind int
+	// order is the predictor order of fixed and LPC subframes
+	order int
+	// wasted is the number of zero low bits common to every sample, removed before coding
+	wasted uint
+	// bps is the number of bits per sample after removing wasted bits
+	bps     uint
+	samples []int32
+	// coefs, precision and shift are the quantized predictor of LPC subframes
+	coefs     []int32
+	precision uint
+	shift     int
+	residual  []int32
+	rice      riceCoding
+	// bits is the encoded size of the subframe
+	bits int
+}
+
+// riceCoding is the partitioned Rice coding of a residual
+type riceCoding struct {
+	order  int
+	params []uint
+}
+
+// method returns the residual coding method: 0 for 4-bit Rice parameters, 1 for 5-bit ones
+func (c *riceCoding) method() uint {
+	for _, p := range c.params {
+		if p > 14 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// encodeSubframe chooses the smallest encoding of the samples of a channel with bps bits per sample
+func encodeSubframe(opts *EncoderOptions, samples []int32, bps uint) *subframe {
+	var or int32
+	for _, v := range samples {
+		or |= v
+	}
+	var wasted uint
+	if or != 0 {
+		wasted = uint(bits.TrailingZeros32(uint32(or)))
+	}
+	if wasted > 0 {
+		shifted := make([]int32, len(samples))
+		for i, v := range samples {
+			shifted[i] = v >> wasted
+		}
+		samples = shifted
+	}
+	headerBits := 8
+	if wasted > 0 {
+		headerBits += int(wasted)
+	}
+	bps -= wasted
+
+	constant := true
+	for _, v := range samples {
+		if v != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return &subframe{kind: subframeConstant, wasted: wasted, bps: bps, samples: samples, bits: headerBits + int(bps)}
+	}
+	best := &subframe{kind: subframeVerbatim, wasted: wasted, bps: bps, samples: samples, bits: headerBits + len(samples)*int(bps)}
+
+	residual := make([]int32, len(samples))
+	for order := 0; order <= 4 && order < len(samples); order++ {
+		if !fixedResidual(samples, order, residual) {
+			continue
+		}
+		rice, size := bestRiceCoding(residual, order, opts)
+		if size == math.MaxInt {
+			continue
+		}
+		if n := headerBits + order*int(bps) + size; n < best.bits {
+			best = &subframe{kind: subframeFixed, order: order, wasted: wasted, bps: bps, samples: samples,
+				residual: append([]int32(nil), residual...), rice: rice, bits: n}
+		}
+	}
+
+	maxOrder := opts.MaxLPCOrder
+	if maxOrder >= len(samples) {
+		maxOrder = len(samples) - 1
+	}
+	if maxOrder < 1 {
+		return best
+	}
+	precision := opts.LPCPrecision
+	if precision == 0 {
+		precision = lpcPrecision(len(samples))
+	}
+	apodizations := opts.Apodizations
+	if len(apodizations) == 0 {
+		apodizations = []Apodization{Tukey(0.5)}
+	}
+	for _, apodization := range apodizations {
+		for _, window := range apodization(len(samples)) {
+			coefs, errs := levinsonDurbin(autocorrelation(samples, window, maxOrder), maxOrder)
+			if len(coefs) == 0 {
+				continue
+			}
+			orders := []int{bestLPCOrder(errs, len(samples), int(bps), precision)}
+			if opts.ExhaustiveModelSearch {
+				orders = orders[:0]
+				for order := 1; order <= len(coefs); order++ {
+					orders = append(orders, order)
+				}
+			}
+			for _, order := range orders {
+				qcoefs, shift, ok := quantizeLPC(coefs[order-1], precision)
+				if !ok || !lpcResidual(samples, qcoefs, shift, residual) {
+					continue
+				}
+				rice, size := bestRiceCoding(residual, order, opts)
+				if size == math.MaxInt {
+					continue
+				}
+				if n := headerBits + order*int(bps) + 4 + 5 + order*precision + size; n < best.bits {
+					best = &subframe{kind: subframeLPC, order: order, wasted: wasted, bps: bps, samples: samples,
+						coefs: qcoefs, precision: uint(precision), shift: shift,
+						residual: append([]int32(nil), residual...), rice: rice, bits: n}
+				}
+			}
+		}
+	}
+	return best
+}
+
+// fixedResidual computes the residual of the fixed predictor of the given order into res[order:], reporting false if it does not fit 32 bits
+func fixedResidual(samples []int32, order int, res []int32) bool {
+	for i := order; i < len(samples); i++ {
+		var v int64
+		x := int64(samples[i])
+		switch order {
+		case 0:
+			v = x
+		case 1:
+			v = x - int64(samples[i-1])
+		case 2:
+			v = x - 2*int64(samples[i-1]) + int64(samples[i-2])
+		case 3:
+			v = x - 3*int64(samples[i-1]) + 3*int64(samples[i-2]) - int64(samples[i-3])
+		case 4:
+			v = x - 4*int64(samples[i-1]) + 6*int64(samples[i-2]) - 4*int64(samples[i-3]) + int64(samples[i-4])
+		}
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return false
+		}
+		res[i] = int32(v)
+	}
+	return true
+}
+
+// lpcResidual computes the residual of the quantized linear predictor into res[len(coefs):], reporting false if it does not fit 32 bits
+func lpcResidual(samples []int32, coefs []int32, shift int, res []int32) bool {
+	for i := len(coefs); i < len(samples); i++ {
+		var sum int64
+		for j, c := range coefs {
+			sum += int64(c) * int64(samples[i-1-j])
+		}
+		v := int64(samples[i]) - sum>>uint(shift)
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return false
+		}
+		res[i] = int32(v)
+	}
+	return true
+}
+
+// zigzag maps a signed residual to the unsigned value coded by the Rice code
+func zigzag(v int32) uint64 {
+	return uint64(uint32(v<<1) ^ uint32(v>>31))
+}
+
+// bestRiceParam returns the Rice parameter coding the partition in the fewest bits, and that size
+func bestRiceParam(partition []int32) (uint, int) {
+	var sum uint64
+	for _, v := range partition {
+		sum += zigzag(v)
+	}
+	n := uint64(len(partition))
+	if n == 0 {
+		return 0, 0
+	}
+	// the optimal parameter is close to log2 of the mean
+	k := uint(0)
+	if mean := sum / n; mean > 0 {
+		k = uint(bits.Len64(mean)) - 1
+	}
+	best, bestBits := uint(0), math.MaxInt
+	for _, p := range []uint{k - 1, k, k + 1} {
+		if p > 30 {
+			continue
+		}
+		size := uint64(0)
+		for _, v := range partition {
+			size += zigzag(v)>>p + 1 + uint64(p)
+		}
+		if int(size) < bestBits {
+			best, bestBits = p, int(size)
+		}
+	}
+	return best, bestBits
+}
+
+// bestRiceCoding chooses the partition order and Rice parameters minimizing the coded size of residual[order:], returning the size in bits.
+// When no order of the allowed range divides the block, as for a short final block, lower orders are tried down to 0 as the reference encoder does.
+// The size is math.MaxInt if the residual cannot be coded at all, which only happens when order exceeds its length.
+func bestRiceCoding(residual []int32, order int, opts *EncoderOptions) (riceCoding, int) {
+	var best riceCoding
+	bestBits := math.MaxInt
+	n := len(residual)
+	for po := opts.MaxPartitionOrder; po >= 0; po-- {
+		if po < opts.MinPartitionOrder && bestBits != math.MaxInt {
+			break
+		}
+		size := n >> uint(po)
+		if size<<uint(po) != n || size < order || (po > 0 && size == order) {
+			continue
+		}
+		coding := riceCoding{order: po, params: make([]uint, 1<<uint(po))}
+		total := 2 + 4
+		for p := range coding.params {
+			start, end := p*size, (p+1)*size
+			if p == 0 {
+				start = order
+			}
+			k, partBits := bestRiceParam(residual[start:end])
+			coding.params[p] = k
+			total += partBits
+		}
+		total += len(coding.params) * int(4+coding.method())
+		if total < bestBits {
+			best, bestBits = coding, total
+		}
+	}
+	return best, bestBits
+}
+
+// write appends the encoded subframe
+func (c *subframe) write(w *bitWriter) {
+	kind := c.kind
+	switch c.kind {
+	case subframeFixed:
+		kind += c.order
+	case subframeLPC:
+		kind += c.order - 1
+	}
+	if c.wasted > 0 {
+		w.writeBits(uint64(kind)<<1|1, 8)
+		w.writeUnary(uint64(c.wasted - 1))
+	} else {
+		w.writeBits(uint64(kind)<<1, 8)
+	}
+
+	switch c.kind {
+	case subframeConstant:
+		w.writeSigned(int64(c.samples[0]), c.bps)
+		return
+	case subframeVerbatim:
+		for _, v := range c.samples {
+			w.writeSigned(int64(v), c.bps)
+		}
+		return
+	}
+	for _, v := range c.samples[:c.order] {
+		w.writeSigned(int64(v), c.bps)
+	}
+	if c.kind == subframeLPC {
+		w.writeBits(uint64(c.precision-1), 4)
+		w.writeSigned(int64(c.shift), 5)
+		for _, coef := range c.coefs {
+			w.writeSigned(int64(coef), c.precision)
+		}
+	}
+
+	method := c.rice.method()
+	w.writeBits(uint64(method), 2)
+	w.writeBits(uint64(c.rice.order), 4)
+	size := len(c.residual) >> uint(c.rice.order)
+	for p, k := range c.rice.params {
+		w.writeBits(uint64(k), 4+method)
+		start, end := p*size, (p+1)*size
+		if p == 0 {
+			start = c.order
+		}
+		for _, v := range c.residual[start:end] {
+			u := zigzag(v)
+			w.writeUnary(u >> k)
+			w.writeBits(u, k)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3v2PictureFrame is the ID3v2 frame carrying embedded pictures. FLAC stores pictures in Picture metadata blocks instead of Vorbis comments,
+// so this frame has no entry in ID3v2ToVorbis; ConvertAPICToPicture and ConvertPictureToAPIC map it to and from a Picture block.
+const ID3v2PictureFrame = "APIC"
+
+// ID3v2 text encodings of APIC descriptions
+const (
+	id3v2Latin1  = 0
+	id3v2UTF16   = 1
+	id3v2UTF16BE = 2
+	id3v2UTF8    = 3
+)
+
+// id3v2UserTextPrefix prefixes user defined ID3v2 text frames (TXXX) in the maps accepted by the converters, e.g. "TXXX:CATALOGNUMBER"
+const id3v2UserTextPrefix = "TXXX:"
+
+// ID3v2ToVorbis maps ID3v2.3/2.4 text frame IDs to their conventional Vorbis comment field names.
+var ID3v2ToVorbis = map[string]string{
+	"TIT1": "GROUPING",
+	"TIT2": "TITLE",
+	"TIT3": "SUBTITLE",
+	"TALB": "ALBUM",
+	"TPE1": "ARTIST",
+	"TPE2": "ALBUMARTIST",
+	"TPE3": "CONDUCTOR",
+	"TPE4": "REMIXER",
+	"TCOM": "COMPOSER",
+	"TEXT": "LYRICIST",
+	"TRCK": "TRACKNUMBER",
+	"TPOS": "DISCNUMBER",
+	"TCON": "GENRE",
+	"TDRC": "DATE",
+	"TYER": "DATE",
+	"TDOR": "ORIGINALDATE",
+	"TORY": "ORIGINALDATE",
+	"TSRC": "ISRC",
+	"TPUB": "LABEL",
+	"TCOP": "COPYRIGHT",
+	"TENC": "ENCODEDBY",
+	"TSSE": "ENCODERSETTINGS",
+	"TBPM": "BPM",
+	"TKEY": "KEY",
+	"TLAN": "LANGUAGE",
+	"TMED": "MEDIA",
+	"TMOO": "MOOD",
+	"TSOA": "ALBUMSORT",
+	"TSOP": "ARTISTSORT",
+	"TSOT": "TITLESORT",
+	"TSO2": "ALBUMARTISTSORT",
+	"TSOC": "COMPOSERSORT",
+	"TCMP": "COMPILATION",
+	"COMM": "COMMENT",
+	"USLT": "LYRICS",
+}
+
+// VorbisToID3v2 maps Vorbis comment field names to the ID3v2.4 frame IDs they are written to.
+// Where ID3v2.3 and ID3v2.4 use different frames for the same field, the ID3v2.4 frame is used.
+var VorbisToID3v2 = map[string]string{
+	"GROUPING":        "TIT1",
+	"TITLE":           "TIT2",
+	"SUBTITLE":        "TIT3",
+	"ALBUM":           "TALB",
+	"ARTIST":          "TPE1",
+	"ALBUMARTIST":     "TPE2",
+	"CONDUCTOR":       "TPE3",
+	"REMIXER":         "TPE4",
+	"COMPOSER":        "TCOM",
+	"LYRICIST":        "TEXT",
+	"TRACKNUMBER":     "TRCK",
+	"DISCNUMBER":      "TPOS",
+	"GENRE":           "TCON",
+	"DATE":            "TDRC",
+	"ORIGINALDATE":    "TDOR",
+	"ISRC":            "TSRC",
+	"LABEL":           "TPUB",
+	"COPYRIGHT":       "TCOP",
+	"ENCODEDBY":       "TENC",
+	"ENCODERSETTINGS": "TSSE",
+	"BPM":             "TBPM",
+	"KEY":             "TKEY",
+	"LANGUAGE":        "TLAN",
+	"MEDIA":           "TMED",
+	"MOOD":            "TMOO",
+	"ALBUMSORT":       "TSOA",
+	"ARTISTSORT":      "TSOP",
+	"TITLESORT":       "TSOT",
+	"ALBUMARTISTSORT": "TSO2",
+	"COMPOSERSORT":    "TSOC",
+	"COMPILATION":     "TCMP",
+	"COMMENT":         "COMM",
+	"LYRICS":          "USLT",
+}
+
+// id3v2FallbackFrames lists the ID3v2.3 frames that are only converted when the file lacks the ID3v2.4 frame replacing them,
+// as taggers writing both versions store the same date twice
+var id3v2FallbackFrames = map[string]string{
+	"TYER": "TDRC",
+	"TORY": "TDOR",
+}
+
+// numberTotalFields lists the ID3v2 frames that pack a number and a total as "n/total" and the Vorbis fields they are split into
+var numberTotalFields = map[string][2]string{
+	"TRCK": {"TRACKNUMBER", "TRACKTOTAL"},
+	"TPOS": {"DISCNUMBER", "DISCTOTAL"},
+}
+
+// ConvertID3v2ToVorbis converts ID3v2 text frames, keyed by frame ID, into Vorbis comment fields.
+// "n/total" values of TRCK and TPOS are split into TRACKNUMBER/TRACKTOTAL and DISCNUMBER/DISCTOTAL.
+// User defined text frames keyed as "TXXX:DESCRIPTION" become a field named after the upper-cased description.
+// TYER and TORY are only used when TDRC and TDOR are missing. Frames without a Vorbis equivalent, including APIC, are returned sorted in unmapped.
+func ConvertID3v2ToVorbis(frames map[string][]string) (fields map[string][]string, unmapped []string) {
+	fields = make(map[string][]string)
+	for frame, values := range frames {
+		if replacement, ok := id3v2FallbackFrames[frame]; ok && len(frames[replacement]) > 0 {
+			continue
+		}
+		if strings.HasPrefix(frame, id3v2UserTextPrefix) {
+			name := strings.ToUpper(frame[len(id3v2UserTextPrefix):])
+			fields[name] = append(fields[name], values...)
+			continue
+		}
+		if split, ok := numberTotalFields[frame]; ok {
+			for _, value := range values {
+				number, total := value, ""
+				if i := strings.IndexByte(value, '/'); i >= 0 {
+					number, total = value[:i], value[i+1:]
+				}
+				fields[split[0]] = append(fields[split[0]], number)
+				if total != "" {
+					fields[split[1]] = append(fields[split[1]], total)
+				}
+			}
+			continue
+		}
+		name, ok := ID3v2ToVorbis[frame]
+		if !ok {
+			unmapped = append(unmapped, frame)
+			continue
+		}
+		fields[name] = append(fields[name], values...)
+	}
+	sort.Strings(unmapped)
+	return
+}
+
+// ConvertVorbisToID3v2 converts Vorbis comment fields into ID3v2.4 text frames keyed by frame ID.
+// Field names are matched case-insensitively. TRACKTOTAL and DISCTOTAL are folded into TRCK and TPOS as "n/total".
+// Fields without a dedicated frame are returned as user defined text frames keyed as "TXXX:FIELD".
+func ConvertVorbisToID3v2(fields map[string][]string) map[string][]string {
+	upper := make(map[string][]string, len(fields))
+	for name, values := range fields {
+		name = strings.ToUpper(name)
+		upper[name] = append(upper[name], values...)
+	}
+
+	frames := make(map[string][]string)
+	for frame, split := range numberTotalFields {
+		numbers, totals := upper[split[0]], upper[split[1]]
+		delete(upper, split[0])
+		delete(upper, split[1])
+		if len(numbers) == 0 {
+			if len(totals) != 0 {
+				frames[id3v2UserTextPrefix+split[1]] = totals
+			}
+			continue
+		}
+		for i, number := range numbers {
+			if i < len(totals) && totals[i] != "" {
+				number += "/" + totals[i]
+			}
+			frames[frame] = append(frames[frame], number)
+		}
+	}
+	for name, values := range upper {
+		frame, ok := VorbisToID3v2[name]
+		if !ok {
+			frame = id3v2UserTextPrefix + name
+		}
+		frames[frame] = append(frames[frame], values...)
+	}
+	return frames
+}
+
+// ConvertAPICToPicture converts the body of an ID3v2.3/2.4 APIC frame, the bytes following the frame header, into a Picture block.
+// The description is decoded from any of the ID3v2 text encodings. The image dimensions are left unknown.
+func ConvertAPICToPicture(frame []byte) (*PictureBlock, error) {
+	if len(frame) < 1 || frame[0] > id3v2UTF8 {
+		return nil, ErrorMalformedAPIC
+	}
+	encoding := frame[0]
+	mime, rest, ok := cutID3v2String(frame[1:], id3v2Latin1)
+	if !ok || len(rest) < 1 {
+		return nil, ErrorMalformedAPIC
+	}
+	pictureType := PictureType(rest[0])
+	description, image, ok := cutID3v2String(rest[1:], encoding)
+	if !ok {
+		return nil, ErrorMalformedAPIC
+	}
+	return &PictureBlock{
+		PictureType: pictureType,
+		MIME:        decodeID3v2String(mime, id3v2Latin1),
+		Description: decodeID3v2String(description, encoding),
+		Data:        image,
+	}, nil
+}
+
+// ConvertPictureToAPIC converts a Picture block into the body of an ID3v2.4 APIC frame, with a UTF-8 description.
+// Picture types above 255 do not fit the frame and are written as PictureTypeOther.
+func ConvertPictureToAPIC(picture *PictureBlock) []byte {
+	pictureType := picture.PictureType
+	if pictureType > 0xFF {
+		pictureType = PictureTypeOther
+	}
+	frame := make([]byte, 0, len(picture.MIME)+len(picture.Description)+len(picture.Data)+4)
+	frame = append(frame, id3v2UTF8)
+	frame = append(frame, picture.MIME...)
+	frame = append(frame, 0, byte(pictureType))
+	frame = append(frame, picture.Description...)
+	frame = append(frame, 0)
+	return append(frame, picture.Data...)
+}
+
+// cutID3v2String splits a terminated string in the given encoding from the start of data, without its terminator
+func cutID3v2String(data []byte, encoding byte) (value, rest []byte, ok bool) {
+	if encoding != id3v2UTF16 && encoding != id3v2UTF16BE {
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			return data[:i], data[i+1:], true
+		}
+		return nil, nil, false
+	}
+	// UTF-16 strings end with a zero code unit
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 && data[i+1] == 0 {
+			return data[:i], data[i+2:], true
+		}
+	}
+	return nil, nil, false
+}
+
+// decodeID3v2String decodes a string in the given encoding, without its terminator.
+// UTF-16 strings without a byte order mark are read as big-endian.
+func decodeID3v2String(data []byte, encoding byte) string {
+	switch encoding {
+	case id3v2Latin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case id3v2UTF16, id3v2UTF16BE:
+		var order binary.ByteOrder = binary.BigEndian
+		if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE {
+			order, data = binary.LittleEndian, data[2:]
+		} else if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF {
+			data = data[2:]
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(units))
+	}
+	return strings.ToValidUTF8(string(data), "\uFFFD")
+}
@@ -0,0 +1,69 @@
+package flac
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ExportTags writes the comments in the text format of metaflac --export-tags-to: every comment as it is stored, followed by a newline.
+// Like metaflac, values are not escaped, so a value holding a newline is split across lines and cannot be imported back.
+// Comments are written as UTF-8, as with metaflac --no-utf8-convert.
+func (c *VorbisCommentBlock) ExportTags(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, comment := range c.Comments {
+		bw.WriteString(comment)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// ImportTags appends the comments read from r in the text format of metaflac --import-tags-from: one NAME=value comment per line.
+// Like metaflac, existing comments are kept, and a line without '=' fails with ErrorMalformedTags while an invalid field name fails with ErrorInvalidFieldName;
+// no comment is appended if any line is invalid. The last line may omit its newline.
+func (c *VorbisCommentBlock) ImportTags(r io.Reader) error {
+	var comments []string
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line != "" {
+			name, value, ok := strings.Cut(strings.TrimSuffix(line, "\n"), "=")
+			if !ok {
+				return ErrorMalformedTags
+			}
+			if err := checkFieldName(name); err != nil {
+				return err
+			}
+			comments = append(comments, name+"="+value)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	c.Comments = append(c.Comments, comments...)
+	return nil
+}
+
+// ExportTags writes the comments of the first VorbisComment block of the File as VorbisCommentBlock.ExportTags does, or nothing if the File has none
+func (c *File) ExportTags(w io.Writer) error {
+	idx := c.vorbisCommentIndex()
+	if idx < 0 {
+		return nil
+	}
+	block, err := ParseVorbisComment(c.Meta[idx])
+	if err != nil {
+		return err
+	}
+	return block.ExportTags(w)
+}
+
+// ImportTags appends the comments read from r to the first VorbisComment block of the File as VorbisCommentBlock.ImportTags does.
+// A VorbisComment block is added when the File has none.
+func (c *File) ImportTags(r io.Reader) error {
+	return c.editVorbisComment(true, func(block *VorbisCommentBlock) error {
+		return block.ImportTags(r)
+	})
+}
@@ -0,0 +1,166 @@
+package flac
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// TranscriptApplicationID is the Application block ID used to embed transcripts.
+// Each block holds one chunk of a transcript:
+//
+//	ID "TRNS" | version (1 byte) | chunk index (2 bytes) | chunk count (2 bytes) |
+//	MIME length (4 bytes) | MIME | language length (4 bytes) | language | chunk data
+//
+// Integers are big-endian. Transcripts larger than a single metadata block are split across consecutive blocks.
+var TranscriptApplicationID = [4]byte{'T', 'R', 'N', 'S'}
+
+const (
+	// TranscriptWebVTT is the MIME type of WebVTT transcripts
+	TranscriptWebVTT = "text/vtt"
+	// TranscriptTTML is the MIME type of TTML transcripts
+	TranscriptTTML = "application/ttml+xml"
+)
+
+// transcriptVersion is the version of the transcript block layout
+const transcriptVersion = 1
+
+// transcriptChunkSize is the largest chunk of transcript data stored in one block, leaving room for the chunk header
+var transcriptChunkSize = MaxBlockSize - 1024
+
+// Transcript is a text transcript of the audio, such as WebVTT captions
+type Transcript struct {
+	// MIME is the format of Data, e.g. TranscriptWebVTT
+	MIME string
+	// Language is the BCP 47 language tag of the transcript, may be empty
+	Language string
+	// Data is the transcript document
+	Data []byte
+}
+
+type transcriptChunk struct {
+	index, count int
+	mime, lang   string
+	data         []byte
+}
+
+func parseTranscriptChunk(meta *MetaDataBlock) (*transcriptChunk, bool, error) {
+	if meta.Type != Application {
+		return nil, false, nil
+	}
+	if err := meta.Load(); err != nil {
+		return nil, false, err
+	}
+	app, err := ParseApplication(meta)
+	if err != nil || app.ID != TranscriptApplicationID {
+		return nil, false, nil
+	}
+	data := app.Data
+	if len(data) < 5 || data[0] != transcriptVersion {
+		return nil, true, ErrorMalformedTranscript
+	}
+	res := &transcriptChunk{
+		index: int(binary.BigEndian.Uint16(data[1:])),
+		count: int(binary.BigEndian.Uint16(data[3:])),
+	}
+	data = data[5:]
+	for _, field := range []*string{&res.mime, &res.lang} {
+		if len(data) < 4 {
+			return nil, true, ErrorMalformedTranscript
+		}
+		length := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(length) > uint64(len(data)) {
+			return nil, true, ErrorMalformedTranscript
+		}
+		*field = string(data[:length])
+		data = data[length:]
+	}
+	if res.index >= res.count {
+		return nil, true, ErrorMalformedTranscript
+	}
+	res.data = data
+	return res, true, nil
+}
+
+// Transcripts reassembles the transcripts embedded in the File, in the order their first chunk appears
+func (c *File) Transcripts() ([]Transcript, error) {
+	type key struct{ mime, lang string }
+	var order []key
+	chunks := make(map[key][]*transcriptChunk)
+	for _, meta := range c.Meta {
+		chunk, ok, err := parseTranscriptChunk(meta)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		k := key{chunk.mime, chunk.lang}
+		if _, seen := chunks[k]; !seen {
+			order = append(order, k)
+		}
+		chunks[k] = append(chunks[k], chunk)
+	}
+
+	res := make([]Transcript, 0, len(order))
+	for _, k := range order {
+		parts := chunks[k]
+		sort.Slice(parts, func(i, j int) bool { return parts[i].index < parts[j].index })
+		var data []byte
+		for i, part := range parts {
+			if part.index != i || part.count != len(parts) {
+				return nil, ErrorIncompleteTranscript
+			}
+			data = append(data, part.data...)
+		}
+		res = append(res, Transcript{MIME: k.mime, Language: k.lang, Data: data})
+	}
+	return res, nil
+}
+
+// SetTranscript embeds t in the File, replacing any transcript with the same MIME type and language.
+// The transcript is split into as many Application blocks as needed to respect the metadata block size limit.
+func (c *File) SetTranscript(t Transcript) error {
+	c.RemoveTranscript(t.MIME, t.Language)
+
+	header := len(t.MIME) + len(t.Language) + 17
+	chunkSize := transcriptChunkSize - header
+	if chunkSize <= 0 {
+		return ErrorMalformedTranscript
+	}
+	count := (len(t.Data) + chunkSize - 1) / chunkSize
+	if count == 0 {
+		count = 1
+	}
+	if count > 0xFFFF {
+		return ErrorTranscriptTooLarge
+	}
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(t.Data) {
+			end = len(t.Data)
+		}
+		data := make([]byte, 0, header+end-i*chunkSize)
+		data = append(data, transcriptVersion)
+		data = binary.BigEndian.AppendUint16(data, uint16(i))
+		data = binary.BigEndian.AppendUint16(data, uint16(count))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(t.MIME)))
+		data = append(data, t.MIME...)
+		data = binary.BigEndian.AppendUint32(data, uint32(len(t.Language)))
+		data = append(data, t.Language...)
+		data = append(data, t.Data[i*chunkSize:end]...)
+		app := ApplicationBlock{ID: TranscriptApplicationID, Data: data}
+		meta := app.Marshal()
+		c.Meta = append(c.Meta, &meta)
+		c.modified(&meta)
+	}
+	return nil
+}
+
+// RemoveTranscript removes all chunks of the transcript with the given MIME type and language
+func (c *File) RemoveTranscript(mime, language string) {
+	c.removeBlocks(func(block *MetaDataBlock) bool {
+		chunk, ok, err := parseTranscriptChunk(block)
+		return ok && err == nil && chunk.mime == mime && chunk.lang == language
+	})
+}
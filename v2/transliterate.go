@@ -0,0 +1,111 @@
+package flac
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TransliterationFields maps the fields transliterated by default to the fields receiving their ASCII form, the sort fields
+var TransliterationFields = SortFields
+
+// transliterations holds the ASCII forms of the lower-case letters that do not decompose into an ASCII letter and a diacritic,
+// and of the typographic punctuation common in tags
+var transliterations = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'ł': "l", 'þ': "th", 'ħ': "h", 'ı': "i", 'ŋ': "n",
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'є': "ye", 'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m",
+	'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	// punctuation
+	'‘': "'", '’': "'", '“': "\"", '”': "\"", '–': "-", '—': "-",
+}
+
+// transliterateRune appends the ASCII form of r to b, reporting false if r has none
+func transliterateRune(b *strings.Builder, r rune) bool {
+	if r < utf8.RuneSelf {
+		b.WriteRune(r)
+		return true
+	}
+	s, ok := transliterations[unicode.ToLower(r)]
+	if !ok {
+		return false
+	}
+	if unicode.IsUpper(r) && s != "" {
+		s = strings.ToUpper(s[:1]) + s[1:]
+	}
+	b.WriteString(s)
+	return true
+}
+
+// Transliterate returns an ASCII form of s for devices that only display ASCII, such as car head units:
+// diacritics are removed, letters such as 'ß' and Cyrillic and Greek letters are romanized, and other characters are replaced with '?'
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFC.String(s) {
+		if transliterateRune(&b, r) {
+			continue
+		}
+		found := false
+		for _, d := range norm.NFKD.String(string(r)) {
+			if !unicode.Is(unicode.Mn, d) && transliterateRune(&b, d) {
+				found = true
+			}
+		}
+		if !found {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// Transliterate writes the ASCII form of the values of the fields that are keys of fields to the fields they map to,
+// or of the fields of TransliterationFields if fields is nil. The original values are kept.
+// A target field is only written when it is not set yet and a value of its source field is not ASCII, so sort names set by hand are preserved.
+func (c *VorbisCommentBlock) Transliterate(fields map[string]string) {
+	if fields == nil {
+		fields = TransliterationFields
+	}
+	sources := make([]string, 0, len(fields))
+	for source := range fields {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	values := c.fields()
+	for _, source := range sources {
+		target := strings.ToUpper(fields[source])
+		source = strings.ToUpper(source)
+		if len(values[target]) > 0 {
+			continue
+		}
+		ascii := true
+		for _, value := range values[source] {
+			for i := 0; i < len(value); i++ {
+				if value[i] >= utf8.RuneSelf {
+					ascii = false
+				}
+			}
+		}
+		if ascii {
+			continue
+		}
+		for _, value := range values[source] {
+			c.Comments = append(c.Comments, target+"="+Transliterate(value))
+		}
+	}
+}
+
+// Transliterate applies VorbisCommentBlock.Transliterate to the first VorbisComment block of the File, if any
+func (c *File) Transliterate(fields map[string]string) error {
+	return c.editVorbisComment(false, func(block *VorbisCommentBlock) error {
+		block.Transliterate(fields)
+		return nil
+	})
+}
@@ -2,7 +2,6 @@ package flac
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"io"
 	"os"
@@ -36,6 +35,16 @@ func (e *ErrorReader) Read(p []byte) (n int, err error) {
 	return 0, e.err
 }
 
+// zeroReader is an infinite source of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
 func isFileBacked(r io.Reader) *os.File {
 	if f, ok := r.(*os.File); ok {
 		return f
@@ -43,6 +52,14 @@ func isFileBacked(r io.Reader) *os.File {
 		return isFileBacked(p.r)
 	} else if b, ok := r.(*BufIOWithInner); ok {
 		return isFileBacked(b.inner)
+	} else if f, ok := r.(*rewoundFrames); ok {
+		return isFileBacked(f.origin)
+	} else if f, ok := r.(*FollowReader); ok {
+		return isFileBacked(f.r)
+	} else if c, ok := r.(*contextReader); ok {
+		return isFileBacked(c.r)
+	} else if w, ok := r.(*wrappedReader); ok {
+		return isFileBacked(w.source)
 	}
 	return nil
 }
@@ -68,12 +85,10 @@ func (c *PrefixReader) Close() error {
 	return nil
 }
 
-func encodeUint32(n uint32) []byte {
-	buf := bytes.NewBuffer([]byte{})
-	if err := binary.Write(buf, binary.BigEndian, n); err != nil {
-		panic(err)
-	}
-	return buf.Bytes()
+func putUint24(b []byte, n uint32) {
+	b[0] = byte(n >> 16)
+	b[1] = byte(n >> 8)
+	b[2] = byte(n)
 }
 
 func readUint8(r io.Reader) (res uint8, err error) {
@@ -105,22 +120,25 @@ func checkFLACStream(f io.Reader) (io.Reader, error) {
 	return &PrefixReader{prefix: first2Bytes, r: f}, nil
 }
 
-func parseMetadataBlock(f io.Reader) (block *MetaDataBlock, isfinal bool, err error) {
+func parseMetadataBlock(f io.Reader, cfg *parseConfig) (block *MetaDataBlock, isfinal bool, err error) {
 	block = new(MetaDataBlock)
 	header := make([]byte, 4)
 	_, err = io.ReadFull(f, header)
 	if err != nil {
 		return
 	}
-	isfinal = header[0]>>7 != 0
-	block.Type = BlockType(header[0] << 1 >> 1)
-	var length uint32
-	err = binary.Read(bytes.NewBuffer(header), binary.BigEndian, &length)
-	if err != nil {
+	var length int
+	block.Type, isfinal, length = decodeBlockHeader(header)
+
+	if cfg.skips(block.Type) {
+		if _, err = io.CopyN(io.Discard, f, int64(length)); err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		block.lazy = true
+		block.skipped = true
+		block.size = length
 		return
 	}
-	length = length << 8 >> 8
-
 	buf := make([]byte, length)
 	_, err = io.ReadFull(f, buf)
 	if err != nil {
@@ -131,11 +149,22 @@ func parseMetadataBlock(f io.Reader) (block *MetaDataBlock, isfinal bool, err er
 	return
 }
 
-func readMetadataBlocks(f io.Reader) (blocks []*MetaDataBlock, err error) {
+// decodeBlockHeader decodes the type, last-metadata-block flag and data length of a 4 byte metadata block header
+func decodeBlockHeader(header []byte) (blockType BlockType, isfinal bool, length int) {
+	isfinal = header[0]>>7 != 0
+	blockType = BlockType(header[0] << 1 >> 1)
+	length = int(binary.BigEndian.Uint32(header) << 8 >> 8)
+	return
+}
+
+func readMetadataBlocks(f io.Reader, cfg *parseConfig) (blocks []*MetaDataBlock, err error) {
 	finishMetaData := false
 	for !finishMetaData {
+		if err = cfg.checkBlockCount(len(blocks) + 1); err != nil {
+			return
+		}
 		var block *MetaDataBlock
-		block, finishMetaData, err = parseMetadataBlock(f)
+		block, finishMetaData, err = parseMetadataBlock(f, cfg)
 		if err != nil {
 			return
 		}
@@ -150,8 +179,25 @@ func readFLACHead(f io.Reader) error {
 	if err != nil {
 		return err
 	}
-	if string(buffer) != "fLaC" {
+	return checkFLACHead(buffer)
+}
+
+// checkFLACHead reports why the first 4 bytes of a stream are not the "fLaC" marker, or nil if they are
+func checkFLACHead(head []byte) error {
+	if string(head) != "fLaC" {
+		if isConsumedHead(head) {
+			return ErrorHeaderConsumed
+		}
 		return ErrorNoFLACHeader
 	}
 	return nil
 }
+
+// isConsumedHead reports whether the first 4 bytes of a stream lacking the "fLaC" marker are what follows the marker of a FLAC stream,
+// the header of the StreamInfo block, or an audio frame sync code
+func isConsumedHead(head []byte) bool {
+	if blockType, _, length := decodeBlockHeader(head); blockType == StreamInfo && length == 34 {
+		return true
+	}
+	return isFrameSync(head)
+}
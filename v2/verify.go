@@ -0,0 +1,68 @@
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// AudioMD5Error indicates that the MD5 of the decoded audio differs from the audio MD5 recorded in StreamInfo
+type AudioMD5Error struct {
+	// Expected is the audio MD5 of StreamInfo
+	Expected []byte
+	// Actual is the MD5 of the decoded audio
+	Actual []byte
+}
+
+func (e *AudioMD5Error) Error() string {
+	return fmt.Sprintf("audio MD5 mismatch: StreamInfo records %s, decoded audio hashes to %s", hex.EncodeToString(e.Expected), hex.EncodeToString(e.Actual))
+}
+
+// Is reports whether target is ErrorAudioMD5Mismatch
+func (e *AudioMD5Error) Is(target error) bool {
+	return target == ErrorAudioMD5Mismatch
+}
+
+// VerifyAudioMD5 decodes every audio frame of the File and compares the MD5 of the samples with the audio MD5 of StreamInfo, as flac -t does.
+// It returns an AudioMD5Error on mismatch, ErrorUnknownAudioMD5 if the encoder did not record the MD5, and the decoding error if a frame
+// is corrupted, including a wrong CRC-16. Files with a random access source, as returned by ParseFile and ParseReaderAt, are decoded
+// from the source and can still be written afterwards; otherwise decoding consumes Frames.
+func (c *File) VerifyAudioMD5() error {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(info.audioMD5(), make([]byte, 16)) {
+		return ErrorUnknownAudioMD5
+	}
+	var frames io.Reader
+	switch {
+	case c.src != nil && c.audioOffset > 0:
+		frames = c.sourceFrames()
+	case c.Frames != nil:
+		frames = c.Frames
+	default:
+		return ErrorNoFrames
+	}
+
+	decoder := NewDecoder(frames, info)
+	hash := md5.New()
+	var pcm []byte
+	for {
+		frame, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		pcm = appendPCM(pcm[:0], frame.Samples, frame.BitDepth)
+		hash.Write(pcm)
+	}
+	if sum := hash.Sum(nil); !bytes.Equal(sum, info.AudioMD5) {
+		return &AudioMD5Error{Expected: info.AudioMD5, Actual: sum}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package flac
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// parseVorbisComment splits the data of a VorbisComment metadata block into the vendor string and the raw "NAME=value" comments
+func parseVorbisComment(data []byte) (vendor string, comments []string, err error) {
+	vendor, comments, _, err = decodeVorbisComment(data)
+	return vendor, comments, err
+}
+
+// loadVorbisComment is parseVorbisComment on the data of meta, which is loaded first if it was parsed lazily
+func loadVorbisComment(meta *MetaDataBlock) (vendor string, comments []string, err error) {
+	if err := meta.Load(); err != nil {
+		return "", nil, err
+	}
+	return parseVorbisComment(meta.Data)
+}
+
+// decodeVorbisComment is parseVorbisComment also returning the bytes following the last comment
+func decodeVorbisComment(data []byte) (vendor string, comments []string, rest []byte, err error) {
+	readString := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		length := binary.LittleEndian.Uint32(data)
+		data = data[4:]
+		if uint64(length) > uint64(len(data)) {
+			return "", false
+		}
+		s := string(data[:length])
+		data = data[length:]
+		return s, true
+	}
+
+	var ok bool
+	if vendor, ok = readString(); !ok {
+		return "", nil, nil, ErrorMalformedVorbisComment
+	}
+	if len(data) < 4 {
+		return "", nil, nil, ErrorMalformedVorbisComment
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	// every comment takes at least its 4 byte length, so a larger count cannot be valid
+	if uint64(count)*4 > uint64(len(data)) {
+		return "", nil, nil, ErrorMalformedVorbisComment
+	}
+	comments = make([]string, 0, count)
+	for i := uint32(0); i < count; i++ {
+		comment, ok := readString()
+		if !ok {
+			return "", nil, nil, ErrorMalformedVorbisComment
+		}
+		comments = append(comments, comment)
+	}
+	return vendor, comments, data, nil
+}
+
+// splitVorbisComment splits a raw comment into its upper-cased field name and value
+func splitVorbisComment(comment string) (name, value string) {
+	if i := strings.IndexByte(comment, '='); i >= 0 {
+		return strings.ToUpper(comment[:i]), comment[i+1:]
+	}
+	return strings.ToUpper(comment), ""
+}
+
+// marshalVorbisComment encodes the vendor string and raw comments into the data of a VorbisComment metadata block
+func marshalVorbisComment(vendor string, comments []string) []byte {
+	size := 8 + len(vendor)
+	for _, comment := range comments {
+		size += 4 + len(comment)
+	}
+	res := make([]byte, 0, size)
+	res = binary.LittleEndian.AppendUint32(res, uint32(len(vendor)))
+	res = append(res, vendor...)
+	res = binary.LittleEndian.AppendUint32(res, uint32(len(comments)))
+	for _, comment := range comments {
+		res = binary.LittleEndian.AppendUint32(res, uint32(len(comment)))
+		res = append(res, comment...)
+	}
+	return res
+}
+
+// VorbisCommentBlock is the decoded form of a VorbisComment metadata block
+type VorbisCommentBlock struct {
+	// Vendor names the software that wrote the block, usually the encoder
+	Vendor string
+	// Comments are the raw "NAME=value" comments, in the order of the block; names are case-insensitive and may repeat
+	Comments []string
+
+	// trailing holds the bytes some writers leave after the last comment, kept so the block round-trips byte-exact
+	trailing []byte
+}
+
+// ParseVorbisComment decodes a VorbisComment metadata block, loading it first if it was parsed lazily
+func ParseVorbisComment(meta *MetaDataBlock) (*VorbisCommentBlock, error) {
+	if meta.Type != VorbisComment {
+		return nil, ErrorUnexpectedBlockType
+	}
+	if err := meta.Load(); err != nil {
+		return nil, err
+	}
+	vendor, comments, rest, err := decodeVorbisComment(meta.Data)
+	if err != nil {
+		return nil, err
+	}
+	res := &VorbisCommentBlock{Vendor: vendor, Comments: comments}
+	if len(rest) > 0 {
+		res.trailing = append([]byte(nil), rest...)
+	}
+	return res, nil
+}
+
+// Marshal encodes the VorbisCommentBlock into a MetaDataBlock
+func (c *VorbisCommentBlock) Marshal() MetaDataBlock {
+	return MetaDataBlock{
+		Type: VorbisComment,
+		Data: append(marshalVorbisComment(c.Vendor, c.Comments), c.trailing...),
+	}
+}
+
+// Equal reports whether both blocks have the same vendor and the same values for every field.
+// Field names are compared case-insensitively and the order of different fields is ignored, but the order of the values of a field is not,
+// as it is meaningful for fields such as ARTIST. The bytes some writers leave after the comments are ignored.
+func (c *VorbisCommentBlock) Equal(other *VorbisCommentBlock) bool {
+	if c.Vendor != other.Vendor || len(c.Comments) != len(other.Comments) {
+		return false
+	}
+	mine, theirs := c.canonical(), other.canonical()
+	for i := range mine {
+		if mine[i] != theirs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// canonical returns the comments as "NAME=value" with upper-cased names, sorted by name while the values of a field keep their order.
+// Blocks with the same canonical comments are Equal, and signatures cover the canonical comments.
+func (c *VorbisCommentBlock) canonical() []string {
+	res := make([]string, len(c.Comments))
+	names := make([]string, len(c.Comments))
+	for i, comment := range c.Comments {
+		name, value := splitVorbisComment(comment)
+		res[i], names[i] = name+"="+value, name
+	}
+	sort.Stable(byFieldName{res, names})
+	return res
+}
+
+// byFieldName sorts canonical comments by their field names
+type byFieldName struct {
+	comments, names []string
+}
+
+func (c byFieldName) Len() int           { return len(c.comments) }
+func (c byFieldName) Less(i, j int) bool { return c.names[i] < c.names[j] }
+func (c byFieldName) Swap(i, j int) {
+	c.comments[i], c.comments[j] = c.comments[j], c.comments[i]
+	c.names[i], c.names[j] = c.names[j], c.names[i]
+}
+
+// fields groups the values of the comments by upper-cased field name
+func (c *VorbisCommentBlock) fields() map[string][]string {
+	res := make(map[string][]string)
+	for _, comment := range c.Comments {
+		name, value := splitVorbisComment(comment)
+		res[name] = append(res[name], value)
+	}
+	return res
+}
+
+// Get returns the values of the comments named name, compared case-insensitively
+func (c *VorbisCommentBlock) Get(name string) []string {
+	name = strings.ToUpper(name)
+	var res []string
+	for _, comment := range c.Comments {
+		if n, value := splitVorbisComment(comment); n == name {
+			res = append(res, value)
+		}
+	}
+	return res
+}
+
+// Add appends a comment named name with the given value. The name must be made of the ASCII characters 0x20 to 0x7D except '='.
+func (c *VorbisCommentBlock) Add(name, value string) error {
+	if err := checkFieldName(name); err != nil {
+		return err
+	}
+	c.Comments = append(c.Comments, name+"="+value)
+	return nil
+}
+
+// checkFieldName returns ErrorInvalidFieldName unless name is a valid Vorbis comment field name
+func checkFieldName(name string) error {
+	if name == "" {
+		return ErrorInvalidFieldName
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] > 0x7d || name[i] == '=' {
+			return ErrorInvalidFieldName
+		}
+	}
+	return nil
+}
+
+// Remove deletes every comment named name, compared case-insensitively, and returns how many were removed
+func (c *VorbisCommentBlock) Remove(name string) int {
+	name = strings.ToUpper(name)
+	kept := c.Comments[:0]
+	for _, comment := range c.Comments {
+		if n, _ := splitVorbisComment(comment); n != name {
+			kept = append(kept, comment)
+		}
+	}
+	removed := len(c.Comments) - len(kept)
+	c.Comments = kept
+	return removed
+}
+
+// editVorbisComment decodes the first VorbisComment block of the File, calls edit and encodes the block back unless edit fails.
+// Without a VorbisComment block, edit is called with an empty block that is added to the File if create is set, and not called otherwise.
+func (c *File) editVorbisComment(create bool, edit func(*VorbisCommentBlock) error) error {
+	idx := c.vorbisCommentIndex()
+	block := new(VorbisCommentBlock)
+	switch {
+	case idx >= 0:
+		var err error
+		if block, err = ParseVorbisComment(c.Meta[idx]); err != nil {
+			return err
+		}
+	case !create:
+		return nil
+	}
+	if err := edit(block); err != nil {
+		return err
+	}
+	meta := block.Marshal()
+	if idx < 0 {
+		c.Meta = append(c.Meta, &meta)
+		c.modified(&meta)
+	} else {
+		c.Meta[idx].Data = meta.Data
+		c.modified(c.Meta[idx])
+	}
+	return nil
+}
+
+// vorbisCommentIndex returns the index of the first VorbisComment block in Meta, or -1 if there is none
+func (c *File) vorbisCommentIndex() int {
+	for i, meta := range c.Meta {
+		if meta.Type == VorbisComment {
+			return i
+		}
+	}
+	return -1
+}
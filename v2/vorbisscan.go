@@ -0,0 +1,123 @@
+package flac
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// maxScannedFieldName is the length beyond which ScanVorbisComment stops looking for the end of a field name and skips the comment
+const maxScannedFieldName = 1 << 10
+
+// ScanVorbisComment reads the data of a VorbisComment metadata block from r one comment at a time, without holding the block in memory.
+// For every comment, want is called with its upper-cased field name; fn is called with the name and value of the comments it accepts,
+// while the values of the others are skipped unread, so multi-megabyte lyrics or credits cost nothing to a caller interested in TITLE.
+// A nil want accepts every comment. Comments whose name exceeds 1 KiB, which no valid field name does, are skipped.
+// Scanning stops at the first error returned by fn. It returns the vendor string, or ErrorMalformedVorbisComment if the data is truncated.
+func ScanVorbisComment(r io.Reader, want func(name string) bool, fn func(name, value string) error) (string, error) {
+	br := bufio.NewReader(r)
+	vendorSize, err := readVorbisLength(br)
+	if err != nil {
+		return "", err
+	}
+	vendor, err := readVorbisString(br, vendorSize)
+	if err != nil {
+		return "", err
+	}
+	count, err := readVorbisLength(br)
+	if err != nil {
+		return vendor, err
+	}
+	for i := uint32(0); i < count; i++ {
+		size, err := readVorbisLength(br)
+		if err != nil {
+			return vendor, err
+		}
+		name, rest, err := readVorbisFieldName(br, size)
+		if err != nil {
+			return vendor, err
+		}
+		if rest < 0 || want != nil && !want(name) {
+			if rest < 0 {
+				rest = -rest
+			}
+			if _, err := br.Discard(int(rest)); err != nil {
+				return vendor, vorbisTruncated(err)
+			}
+			continue
+		}
+		value, err := readVorbisString(br, uint32(rest))
+		if err != nil {
+			return vendor, err
+		}
+		if err := fn(name, value); err != nil {
+			return vendor, err
+		}
+	}
+	return vendor, nil
+}
+
+// ScanVorbisComment calls ScanVorbisComment on the first VorbisComment block of the File. Blocks left in the source by WithLazyBlocks
+// or ParseReaderAt are read from it without being loaded. A File without a VorbisComment block has no comments to scan.
+func (c *File) ScanVorbisComment(want func(name string) bool, fn func(name, value string) error) (string, error) {
+	i := c.vorbisCommentIndex()
+	if i < 0 {
+		return "", nil
+	}
+	return ScanVorbisComment(c.Meta[i].Reader(), want, fn)
+}
+
+// vorbisTruncated converts the end of the data within a VorbisComment block into ErrorMalformedVorbisComment
+func vorbisTruncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrorMalformedVorbisComment
+	}
+	return err
+}
+
+// readVorbisLength reads a 32-bit little-endian length
+func readVorbisLength(r *bufio.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, vorbisTruncated(err)
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+// readVorbisString reads a string of size bytes, which cannot exceed the size of a metadata block
+func readVorbisString(r *bufio.Reader, size uint32) (string, error) {
+	if size > MaxBlockSize {
+		return "", ErrorMalformedVorbisComment
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", vorbisTruncated(err)
+	}
+	return string(buf), nil
+}
+
+// readVorbisFieldName reads the field name of a comment of size bytes up to the '=' separator, and returns it upper-cased
+// with the number of bytes of the value left to read. If the name is too long, the number of bytes left is returned negated.
+func readVorbisFieldName(r *bufio.Reader, size uint32) (string, int64, error) {
+	if size > MaxBlockSize {
+		return "", 0, ErrorMalformedVorbisComment
+	}
+	var name []byte
+	rest := int64(size)
+	for rest > 0 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", 0, vorbisTruncated(err)
+		}
+		rest--
+		if b == '=' {
+			break
+		}
+		if len(name) == maxScannedFieldName {
+			return "", -rest, nil
+		}
+		name = append(name, b)
+	}
+	return strings.ToUpper(string(name)), rest, nil
+}
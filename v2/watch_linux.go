@@ -0,0 +1,68 @@
+package flac
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// watch runs WatchMetadata with inotify
+func (c *schemaWatcher) watch(ctx context.Context) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	// the directory is watched, as taggers often replace the file with a renamed temporary file
+	dir, name := filepath.Split(c.fn)
+	if dir == "" {
+		dir = "."
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	// a non-blocking file goes through the runtime poller, so closing it interrupts Read
+	events := os.NewFile(uintptr(fd), "inotify")
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		events.Close()
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := events.Read(buf)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		changed := false
+		for pos := 0; pos+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[pos]))
+			nameBytes := buf[pos+syscall.SizeofInotifyEvent : pos+syscall.SizeofInotifyEvent+int(event.Len)]
+			if string(trimNUL(nameBytes)) == name {
+				changed = true
+			}
+			pos += syscall.SizeofInotifyEvent + int(event.Len)
+		}
+		if changed {
+			c.check()
+		}
+	}
+}
+
+// trimNUL removes the NUL padding of a name in an inotify event
+func trimNUL(b []byte) []byte {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
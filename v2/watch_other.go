@@ -0,0 +1,34 @@
+//go:build !linux
+
+package flac
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// watchPollInterval is how often WatchMetadata checks the modification time of the file without inotify
+const watchPollInterval = time.Second
+
+// watch runs WatchMetadata by polling the modification time of the file
+func (c *schemaWatcher) watch(ctx context.Context) error {
+	last, _ := os.Stat(c.fn)
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		info, err := os.Stat(c.fn)
+		if err != nil {
+			continue
+		}
+		if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() || !os.SameFile(info, last) {
+			c.check()
+		}
+		last = info
+	}
+}
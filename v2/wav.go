@@ -0,0 +1,189 @@
+package flac
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// wavChannelMasks are the WAVE_FORMAT_EXTENSIBLE speaker masks of the FLAC channel layouts, indexed by channel count
+var wavChannelMasks = [9]uint32{0, 0x4, 0x3, 0x7, 0x33, 0x37, 0x3F, 0x70F, 0x63F}
+
+// wavHeaderSize is the length of the RIFF header written by WAVWriter up to the data chunk payload
+const wavHeaderSize = 12 + 8 + 40 + 8
+
+// WAVWriter writes decoded audio as a RIFF WAVE file.
+// Bit depths that are not a multiple of 8 are stored left-justified in the next container size, as the WAVE format requires.
+type WAVWriter struct {
+	w        io.Writer
+	start    int64
+	channels int
+	bitDepth int
+	samples  int64
+	written  int64
+	closed   bool
+	buf      []byte
+}
+
+// NewWAVWriter writes a WAVE header for the given format and returns a writer for the samples.
+// samples is the expected number of inter-channel samples, 0 if unknown; Close corrects the header when the output is seekable and the count differs.
+func NewWAVWriter(w io.Writer, sampleRate, channels, bitDepth int, samples int64) (*WAVWriter, error) {
+	if channels < 1 || channels >= len(wavChannelMasks) || bitDepth < 1 || bitDepth > 32 {
+		return nil, ErrorInvalidChannelMap
+	}
+	res := &WAVWriter{w: w, start: -1, channels: channels, bitDepth: bitDepth, samples: samples}
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			res.start = pos
+		}
+	}
+
+	containerBytes := (bitDepth + 7) / 8
+	blockAlign := channels * containerBytes
+	header := make([]byte, 0, wavHeaderSize)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, res.riffSize(samples))
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 40)
+	// WAVE_FORMAT_EXTENSIBLE describes every depth and channel layout, including those the plain PCM format cannot
+	header = binary.LittleEndian.AppendUint16(header, 0xFFFE)
+	header = binary.LittleEndian.AppendUint16(header, uint16(channels))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate*blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(containerBytes*8))
+	header = binary.LittleEndian.AppendUint16(header, 22)
+	header = binary.LittleEndian.AppendUint16(header, uint16(bitDepth))
+	header = binary.LittleEndian.AppendUint32(header, wavChannelMasks[channels])
+	// KSDATAFORMAT_SUBTYPE_PCM
+	header = append(header, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, res.dataSize(samples))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// dataSize returns the data chunk size for the given sample count, the maximum if it is unknown or too large
+func (c *WAVWriter) dataSize(samples int64) uint32 {
+	size := samples * int64(c.channels*((c.bitDepth+7)/8))
+	if samples <= 0 || size > 0xFFFFFFFF-wavHeaderSize {
+		return 0xFFFFFFFF - wavHeaderSize
+	}
+	return uint32(size)
+}
+
+// riffSize returns the RIFF chunk size for the given sample count, including the pad byte of odd sized data
+func (c *WAVWriter) riffSize(samples int64) uint32 {
+	size := c.dataSize(samples)
+	return wavHeaderSize - 8 + size + size&1
+}
+
+// WriteFrame writes the samples of a decoded frame, which must have the channel count and bit depth of the writer
+func (c *WAVWriter) WriteFrame(frame *AudioFrame) error {
+	if c.closed {
+		return ErrorWriterClosed
+	}
+	if len(frame.Samples) != c.channels || frame.BitDepth != c.bitDepth {
+		return ErrorInvalidChannelMap
+	}
+	containerBytes := (c.bitDepth + 7) / 8
+	shift := uint(containerBytes*8 - c.bitDepth)
+	c.buf = c.buf[:0]
+	for i := 0; i < frame.Header.BlockSize; i++ {
+		for _, samples := range frame.Samples {
+			v := uint32(samples[i] << shift)
+			switch containerBytes {
+			case 1:
+				// 8-bit WAVE samples are unsigned
+				c.buf = append(c.buf, byte(v)^0x80)
+			case 2:
+				c.buf = binary.LittleEndian.AppendUint16(c.buf, uint16(v))
+			case 3:
+				c.buf = append(c.buf, byte(v), byte(v>>8), byte(v>>16))
+			default:
+				c.buf = binary.LittleEndian.AppendUint32(c.buf, v)
+			}
+		}
+	}
+	if _, err := c.w.Write(c.buf); err != nil {
+		return err
+	}
+	c.written += int64(frame.Header.BlockSize)
+	return nil
+}
+
+// Close pads the data chunk to an even size and, if the output implements io.Seeker and the sample count differs from the expected one,
+// rewrites the chunk sizes of the header. The underlying writer is not closed.
+func (c *WAVWriter) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.dataSize(c.written)&1 != 0 {
+		if _, err := c.w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	if c.written == c.samples || c.start < 0 {
+		return nil
+	}
+	s := c.w.(io.Seeker)
+	end, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	patch := func(offset int64, v uint32) error {
+		if _, err := s.Seek(c.start+offset, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := c.w.Write(binary.LittleEndian.AppendUint32(nil, v))
+		return err
+	}
+	if err := patch(4, c.riffSize(c.written)); err != nil {
+		return err
+	}
+	if err := patch(wavHeaderSize-4, c.dataSize(c.written)); err != nil {
+		return err
+	}
+	_, err = s.Seek(end, io.SeekStart)
+	return err
+}
+
+// ExportWAV decodes the audio of the File and writes it to w as a WAVE file. If m is not nil, the channels are mixed by it,
+// e.g. with StereoDownmix for devices that cannot play multichannel audio. Decoding consumes Frames.
+func (c *File) ExportWAV(w io.Writer, m ChannelMap) error {
+	info, err := c.GetStreamInfo()
+	if err != nil {
+		return err
+	}
+	dec, err := c.Decoder()
+	if err != nil {
+		return err
+	}
+	channels := info.ChannelCount
+	if m != nil {
+		channels = len(m)
+	}
+	out, err := NewWAVWriter(w, info.SampleRate, channels, info.BitDepth, info.SampleCount)
+	if err != nil {
+		return err
+	}
+	for {
+		frame, err := dec.Next()
+		if err == io.EOF {
+			return out.Close()
+		}
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			if frame, err = m.Apply(frame); err != nil {
+				return err
+			}
+		}
+		if err := out.WriteFrame(frame); err != nil {
+			return err
+		}
+	}
+}
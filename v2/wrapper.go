@@ -0,0 +1,110 @@
+package flac
+
+import (
+	"io"
+	"sync"
+)
+
+// WrapperTransform extracts the FLAC stream of a file wrapped in a vendor container, such as the simple XOR or obfuscation wrappers
+// of some store downloads. r reads the wrapped file from its start, magic bytes included.
+type WrapperTransform func(r io.Reader) (io.Reader, error)
+
+type wrapper struct {
+	magic     string
+	transform WrapperTransform
+}
+
+var (
+	wrappersMu sync.Mutex
+	wrappers   []wrapper
+)
+
+// RegisterWrapper makes ParseMetadata, ParseBytes, ParseFile, ParseFS, ParseSeeker and ParseContext pass the streams starting with magic,
+// which must not be empty, through transform and parse the FLAC stream it returns, so wrapped files open transparently.
+// The first matching transform in registration order is used. As offsets in the extracted stream do not refer to the source,
+// Files parsed from a wrapper keep no random access to it: WithLazyBlocks does not apply and saves over the source always rewrite it.
+// ParseReaderAt does not look for wrappers.
+func RegisterWrapper(magic string, transform WrapperTransform) {
+	wrappersMu.Lock()
+	defer wrappersMu.Unlock()
+	wrappers = append(wrappers, wrapper{magic: magic, transform: transform})
+}
+
+// wrappedReader reads the FLAC stream extracted from the wrapped stream source
+type wrappedReader struct {
+	r      io.Reader
+	source io.Reader
+}
+
+func (c *wrappedReader) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Close closes the extracted stream and the wrapped stream if they are io.Closers
+func (c *wrappedReader) Close() error {
+	var err error
+	if closer, ok := c.r.(io.Closer); ok {
+		err = closer.Close()
+	}
+	if closer, ok := c.source.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// unwrap returns the FLAC stream of r, extracted by the transform registered for its magic bytes if there is one, and whether it was extracted.
+// Only the bytes needed to match the magic are read ahead, so a stream that is not wrapped is read no further than the parse requires.
+// An extracted stream is not a source blocks can be read from later, so lazy blocks are disabled.
+func (c *parseConfig) unwrap(r io.Reader) (io.Reader, bool, error) {
+	wrappersMu.Lock()
+	registered := wrappers
+	wrappersMu.Unlock()
+	if len(registered) == 0 {
+		return r, false, nil
+	}
+	peek := 0
+	for _, w := range registered {
+		if len(w.magic) > peek {
+			peek = len(w.magic)
+		}
+	}
+	var head []byte
+	if b, ok := r.(*BufIOWithInner); ok {
+		head, _ = b.Buf.Peek(peek)
+	} else {
+		head = make([]byte, peek)
+		n, err := io.ReadFull(r, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, false, err
+		}
+		head = head[:n]
+		r = &PrefixReader{prefix: head, r: r}
+	}
+	for _, w := range registered {
+		if len(head) < len(w.magic) || string(head[:len(w.magic)]) != w.magic {
+			continue
+		}
+		extracted, err := w.transform(r)
+		if err != nil {
+			return nil, false, err
+		}
+		c.file = false
+		return &wrappedReader{r: extracted, source: r}, true, nil
+	}
+	return r, false, nil
+}
+
+// parseWrapped runs parse on the FLAC stream of r, extracted from its wrapper if a transform is registered for it
+func parseWrapped(r io.Reader, cfg *parseConfig, parse func(io.Reader, *parseConfig) (*File, error)) (*File, error) {
+	f, wrapped, err := cfg.unwrap(r)
+	if err != nil {
+		return nil, err
+	}
+	res, err := parse(f, cfg)
+	if res != nil {
+		res.wrapped = wrapped
+	}
+	return res, err
+}
@@ -0,0 +1,37 @@
+package flac
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// copyXattrs copies the extended attributes of the file at from to the file at to.
+// Filesystems without extended attributes, or without the namespace of an attribute, are tolerated: ENOTSUP is ignored.
+func copyXattrs(from, to string) error {
+	size, err := syscall.Listxattr(from, nil)
+	if errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+	if err != nil || size == 0 {
+		return err
+	}
+	names := make([]byte, size)
+	if size, err = syscall.Listxattr(from, names); err != nil {
+		return err
+	}
+	for _, name := range strings.Split(strings.TrimRight(string(names[:size]), "\x00"), "\x00") {
+		size, err := syscall.Getxattr(from, name, nil)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, size)
+		if size, err = syscall.Getxattr(from, name, value); err != nil {
+			return err
+		}
+		if err := syscall.Setxattr(to, name, value[:size], 0); err != nil && !errors.Is(err, syscall.ENOTSUP) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package flac
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSaveAtomicXattrs(t *testing.T) {
+	data := testFLACStream([]*MetaDataBlock{{Type: StreamInfo, Data: testStreamInfoData(44100, 2, 16, 4096, nil)}}, testFrame(0, 4096, 1, 2))
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	if err := syscall.Setxattr(fn, "user.test", []byte("sidecar"), 0); err != nil {
+		t.Skipf("Extended attributes not supported: %s", err)
+	}
+
+	f, err := ParseFile(fn)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if err := f.Save(fn, WithAtomic()); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	value := make([]byte, 16)
+	n, err := syscall.Getxattr(fn, "user.test", value)
+	if err != nil || !bytes.Equal(value[:n], []byte("sidecar")) {
+		t.Errorf("Extended attribute was not kept: %q %v", value[:n], err)
+	}
+}
+
+func TestCopyXattrsError(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.flac")
+	if err := os.WriteFile(fn, nil, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	if err := syscall.Setxattr(fn, "user.test", []byte("sidecar"), 0); err != nil {
+		t.Skipf("Extended attributes not supported: %s", err)
+	}
+	if err := copyXattrs(fn, filepath.Join(dir, "missing.flac")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the missing target to fail the copy, got %v", err)
+	}
+}
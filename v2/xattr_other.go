@@ -0,0 +1,9 @@
+//go:build !linux
+
+package flac
+
+// copyXattrs copies the extended attributes of the file at from to the file at to.
+// The standard library exposes extended attributes on Linux only, elsewhere nothing is copied.
+func copyXattrs(from, to string) error {
+	return nil
+}
@@ -0,0 +1,158 @@
+package flac
+
+import v2 "github.com/go-flac/go-flac/v2"
+
+// Block is a metadata block of a File
+type Block interface {
+	// BlockType returns the type of the block
+	BlockType() v2.BlockType
+	// MetaDataBlock encodes the block
+	MetaDataBlock() (*v2.MetaDataBlock, error)
+}
+
+// StreamInfo is the StreamInfo block, describing the audio stream
+type StreamInfo struct {
+	v2.StreamInfoBlock
+}
+
+// BlockType returns v2.StreamInfo
+func (c *StreamInfo) BlockType() v2.BlockType {
+	return v2.StreamInfo
+}
+
+// MetaDataBlock encodes the block after checking its values with Validate
+func (c *StreamInfo) MetaDataBlock() (*v2.MetaDataBlock, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	meta := c.Marshal()
+	return &meta, nil
+}
+
+// Application is an Application block, holding data of a registered application
+type Application struct {
+	v2.ApplicationBlock
+}
+
+// BlockType returns v2.Application
+func (c *Application) BlockType() v2.BlockType {
+	return v2.Application
+}
+
+// MetaDataBlock encodes the block
+func (c *Application) MetaDataBlock() (*v2.MetaDataBlock, error) {
+	meta := c.Marshal()
+	return &meta, nil
+}
+
+// SeekTable is the SeekTable block, listing seek points into the audio frames
+type SeekTable struct {
+	v2.SeekTableBlock
+}
+
+// BlockType returns v2.SeekTable
+func (c *SeekTable) BlockType() v2.BlockType {
+	return v2.SeekTable
+}
+
+// MetaDataBlock encodes the block
+func (c *SeekTable) MetaDataBlock() (*v2.MetaDataBlock, error) {
+	meta := c.Marshal()
+	return &meta, nil
+}
+
+// VorbisComment is the VorbisComment block, holding the tags of the File
+type VorbisComment struct {
+	v2.VorbisCommentBlock
+}
+
+// BlockType returns v2.VorbisComment
+func (c *VorbisComment) BlockType() v2.BlockType {
+	return v2.VorbisComment
+}
+
+// MetaDataBlock encodes the block
+func (c *VorbisComment) MetaDataBlock() (*v2.MetaDataBlock, error) {
+	meta := c.Marshal()
+	return &meta, nil
+}
+
+// Picture is a Picture block, holding an image such as the cover art
+type Picture struct {
+	v2.PictureBlock
+}
+
+// BlockType returns v2.Picture
+func (c *Picture) BlockType() v2.BlockType {
+	return v2.Picture
+}
+
+// MetaDataBlock encodes the block
+func (c *Picture) MetaDataBlock() (*v2.MetaDataBlock, error) {
+	meta := c.Marshal()
+	return &meta, nil
+}
+
+// Padding is a Padding block of Size zero bytes
+type Padding struct {
+	Size int
+}
+
+// BlockType returns v2.Padding
+func (c *Padding) BlockType() v2.BlockType {
+	return v2.Padding
+}
+
+// MetaDataBlock encodes the block without allocating its zeros
+func (c *Padding) MetaDataBlock() (*v2.MetaDataBlock, error) {
+	return v2.NewPadding(c.Size), nil
+}
+
+// Raw is a block kept as it was parsed, for the types without a typed form yet, for blocks that failed to decode and for blocks not loaded
+type Raw struct {
+	Meta *v2.MetaDataBlock
+}
+
+// BlockType returns the type of the parsed block
+func (c *Raw) BlockType() v2.BlockType {
+	return c.Meta.Type
+}
+
+// MetaDataBlock returns the parsed block
+func (c *Raw) MetaDataBlock() (*v2.MetaDataBlock, error) {
+	return c.Meta, nil
+}
+
+// typedBlock decodes a parsed block into its typed form, or Raw
+func typedBlock(meta *v2.MetaDataBlock) Block {
+	// blocks left in their source by ParseOptions.LazyBlocks or BlockTypes stay there
+	if !meta.Loaded() && meta.Type != v2.Padding {
+		return &Raw{Meta: meta}
+	}
+	switch meta.Type {
+	case v2.StreamInfo:
+		f := &v2.File{Meta: []*v2.MetaDataBlock{meta}}
+		if info, err := f.GetStreamInfo(); err == nil {
+			return &StreamInfo{*info}
+		}
+	case v2.Application:
+		if app, err := v2.ParseApplication(meta); err == nil {
+			return &Application{*app}
+		}
+	case v2.SeekTable:
+		if table, err := v2.ParseSeekTable(meta); err == nil {
+			return &SeekTable{*table}
+		}
+	case v2.VorbisComment:
+		if comment, err := v2.ParseVorbisComment(meta); err == nil {
+			return &VorbisComment{*comment}
+		}
+	case v2.Picture:
+		if picture, err := v2.ParsePicture(meta); err == nil {
+			return &Picture{*picture}
+		}
+	case v2.Padding:
+		return &Padding{Size: meta.Len()}
+	}
+	return &Raw{Meta: meta}
+}
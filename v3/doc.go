@@ -0,0 +1,10 @@
+// Package flac is the v3 API of go-flac, consolidating the options added to v2 into a coherent surface.
+//
+// Compared to v2:
+//   - entry points take a context.Context, checked on every read and write except by unsafe saves, and an options struct instead of variadic options;
+//     ParseOptions and SaveOptions document the few v2 options they leave out
+//   - File holds typed Blocks; blocks without a typed form yet are kept as Raw, byte-exact
+//   - Save is safe by default: it rewrites only the metadata when it fits in the file, and otherwise replaces the file atomically
+//
+// The implementation delegates to the v2 package, whose File is reachable through File.V2 for features without a v3 form yet.
+package flac
@@ -0,0 +1,255 @@
+package flac
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	v2 "github.com/go-flac/go-flac/v2"
+)
+
+// ParseOptions configures Parse and ParseFile. The zero value parses leniently, reading every block.
+//
+// v2.WithBlockHashes and v2.WithRewindableFrames have no field: MetaDataBlock.Hash computes hashes on demand,
+// and a v3 File is saved once, so its frames need not be rewound.
+type ParseOptions struct {
+	// Strict rejects streams the specification forbids, see v2.WithStrict
+	Strict bool
+	// ErrorCorpus receives the head of streams that fail to parse, see v2.WithErrorCorpus
+	ErrorCorpus v2.CorpusSink
+	// ErrorCorpusLimit is the size of the heads given to ErrorCorpus
+	ErrorCorpusLimit int
+	// LazyBlocks leaves the blocks other than StreamInfo in the file parsed by ParseFile until they are saved, see v2.WithLazyBlocks.
+	// Blocks that are not loaded are kept as Raw.
+	LazyBlocks bool
+	// BlockTypes, when not empty, restricts the blocks whose data is read to StreamInfo and the given types, see v2.WithBlockTypes.
+	// The other blocks are kept as Raw without their data, which ParseFile reads back from the file when saving and Parse cannot.
+	BlockTypes []v2.BlockType
+	// MaxBlocks is the number of metadata blocks beyond which parsing fails, see v2.WithMaxBlocks.
+	// Zero applies v2.DefaultMaxBlocks and a negative value removes the limit.
+	MaxBlocks int
+	// Partial makes a failed parse return the File of the blocks read before the failure along with the error, see v2.WithPartialResults
+	Partial bool
+	// SkipID3v2 accepts files starting with an ID3v2 tag, see v2.WithID3v2Skipping
+	SkipID3v2 bool
+	// Events receives the parse events and the later events of the File, see v2.WithEvents
+	Events v2.EventSink
+}
+
+func (c *ParseOptions) options() []v2.ParseOption {
+	var res []v2.ParseOption
+	if c == nil {
+		return res
+	}
+	if c.Strict {
+		res = append(res, v2.WithStrict())
+	}
+	if c.ErrorCorpus != nil {
+		res = append(res, v2.WithErrorCorpus(c.ErrorCorpus, c.ErrorCorpusLimit))
+	}
+	if c.LazyBlocks {
+		res = append(res, v2.WithLazyBlocks())
+	}
+	if len(c.BlockTypes) > 0 {
+		res = append(res, v2.WithBlockTypes(c.BlockTypes...))
+	}
+	if c.MaxBlocks != 0 {
+		res = append(res, v2.WithMaxBlocks(c.MaxBlocks))
+	}
+	if c.Partial {
+		res = append(res, v2.WithPartialResults())
+	}
+	if c.SkipID3v2 {
+		res = append(res, v2.WithID3v2Skipping())
+	}
+	if c.Events != nil {
+		res = append(res, v2.WithEvents(c.Events))
+	}
+	return res
+}
+
+// SaveOptions configures File.Save and File.SaveTo. The zero value saves safely.
+//
+// v2.WithHeaderBackup, v2.WithEncoderStamp and v2.WithBeforeMarshal have no field, as they edit the metadata behind Blocks;
+// save the File returned by File.V2 to use them.
+type SaveOptions struct {
+	// Unsafe truncates and rewrites the output instead of patching its metadata or replacing it atomically, like v2.File.Save.
+	// The context is then only checked before writing.
+	Unsafe bool
+	// Sync flushes the output to stable storage before Save returns, see v2.WithSync
+	Sync bool
+	// FileMode is the permissions of the files Save creates, 0666 when zero, see v2.WithFileMode
+	FileMode os.FileMode
+	// Padding sizes the padding of rewritten files, nil keeps the Padding blocks as they are
+	Padding v2.PaddingPolicy
+	// LockTimeout is the time to wait for the advisory lock of the output, see v2.WithLock; zero saves without locking
+	LockTimeout time.Duration
+	// Backup is the pattern of the backups of the output made before saving, see v2.WithBackup; empty saves without backup
+	Backup string
+	// BackupKeep is the number of backups kept
+	BackupKeep int
+	// HardLinks selects what to do with outputs that have several hard links
+	HardLinks v2.HardLinkPolicy
+	// Report receives the description of the save when not nil
+	Report *v2.SaveReport
+	// Audit receives the byte ranges written when not nil
+	Audit *v2.SaveAudit
+}
+
+func (c *SaveOptions) options() []v2.SaveOption {
+	if c == nil {
+		c = new(SaveOptions)
+	}
+	res := []v2.SaveOption{v2.WithHardLinks(c.HardLinks)}
+	if !c.Unsafe {
+		res = append(res, v2.WithInPlace(), v2.WithAtomic())
+	}
+	if c.Sync {
+		res = append(res, v2.WithSync())
+	}
+	if c.FileMode != 0 {
+		res = append(res, v2.WithFileMode(c.FileMode))
+	}
+	if c.Padding != nil {
+		res = append(res, v2.WithPadding(c.Padding))
+	}
+	if c.LockTimeout > 0 {
+		res = append(res, v2.WithLock(c.LockTimeout))
+	}
+	if c.Backup != "" {
+		res = append(res, v2.WithBackup(c.Backup, c.BackupKeep))
+	}
+	if c.Report != nil {
+		res = append(res, v2.WithReport(c.Report))
+	}
+	if c.Audit != nil {
+		res = append(res, v2.WithAudit(c.Audit))
+	}
+	return res
+}
+
+// File is a parsed FLAC stream
+type File struct {
+	// Blocks are the metadata blocks, StreamInfo first
+	Blocks []Block
+
+	file *v2.File
+}
+
+// newFile wraps a parsed v2 File
+func newFile(f *v2.File) *File {
+	res := &File{file: f, Blocks: make([]Block, len(f.Meta))}
+	for i, meta := range f.Meta {
+		res.Blocks[i] = typedBlock(meta)
+	}
+	return res
+}
+
+// Parse parses the FLAC stream r. The audio frames are left in r and read when the File is saved.
+// Once ctx is done, the parse fails with the error of ctx.
+func Parse(ctx context.Context, r io.Reader, opts *ParseOptions) (*File, error) {
+	return parsed(v2.ParseContext(ctx, r, opts.options()...))
+}
+
+// ParseFile parses the FLAC file at path. Close the File to release the file.
+// Once ctx is done, the parse fails with the error of ctx.
+func ParseFile(ctx context.Context, path string, opts *ParseOptions) (*File, error) {
+	return parsed(v2.ParseFileContext(ctx, path, opts.options()...))
+}
+
+// parsed wraps the result of a v2 parse, which is a partial File along with the error when ParseOptions.Partial is set
+func parsed(f *v2.File, err error) (*File, error) {
+	if f == nil {
+		return nil, err
+	}
+	return newFile(f), err
+}
+
+// StreamInfo returns the StreamInfo block
+func (c *File) StreamInfo() (*StreamInfo, error) {
+	if len(c.Blocks) > 0 {
+		if info, ok := c.Blocks[0].(*StreamInfo); ok {
+			return info, nil
+		}
+	}
+	return nil, v2.ErrorNoStreamInfo
+}
+
+// V2 returns the underlying v2 File with the metadata of Blocks, for features without a v3 form yet.
+// Changes made to its metadata are overwritten by the next call to V2, Save or SaveTo.
+func (c *File) V2() (*v2.File, error) {
+	meta := make([]*v2.MetaDataBlock, len(c.Blocks))
+	for i, block := range c.Blocks {
+		var err error
+		if meta[i], err = block.MetaDataBlock(); err != nil {
+			return nil, err
+		}
+	}
+	c.file.Meta = meta
+	return c.file, nil
+}
+
+// Save saves the File to path. By default the metadata of path is rewritten in place when the File was parsed from path and the
+// new metadata fits, and otherwise the file is replaced atomically. Like v2.File.Save, it consumes the audio frames.
+func (c *File) Save(ctx context.Context, path string, opts *SaveOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := c.V2()
+	if err != nil {
+		return err
+	}
+	// unsafe saves detect outputs that are their source through the frames, which must stay unwrapped
+	if (opts == nil || !opts.Unsafe) && f.Frames != nil {
+		f.Frames = &contextReader{ctx: ctx, r: f.Frames}
+	}
+	return f.Save(path, opts.options()...)
+}
+
+// SaveTo writes the File to w. Like v2.File.SaveTo, it consumes the audio frames.
+func (c *File) SaveTo(ctx context.Context, w io.Writer, opts *SaveOptions) error {
+	f, err := c.V2()
+	if err != nil {
+		return err
+	}
+	return f.SaveTo(&contextWriter{ctx: ctx, w: w}, opts.options()...)
+}
+
+// Close releases the file of a File returned by ParseFile
+func (c *File) Close() error {
+	return c.file.Close()
+}
+
+// contextReader fails reads once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+func (c *contextReader) Close() error {
+	if closer, ok := c.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// contextWriter fails writes once its context is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *contextWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
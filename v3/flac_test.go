@@ -0,0 +1,153 @@
+package flac
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	v2 "github.com/go-flac/go-flac/v2"
+)
+
+func testStream(t *testing.T, blocks ...Block) []byte {
+	f := &File{Blocks: blocks, file: &v2.File{Frames: bytes.NewReader([]byte{0xFF, 0xF8, 0x69, 0x08, 0x00, 0x00, 0x00})}}
+	var buf bytes.Buffer
+	if err := f.SaveTo(context.Background(), &buf, nil); err != nil {
+		t.Fatalf("Failed to write test stream: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseAndSave(t *testing.T) {
+	info := &StreamInfo{v2.StreamInfoBlock{BlockSizeMin: 4096, BlockSizeMax: 4096, SampleRate: 44100, ChannelCount: 2, BitDepth: 16, SampleCount: 4096, AudioMD5: make([]byte, 16)}}
+	app := &Application{v2.ApplicationBlock{ID: [4]byte{'t', 'e', 's', 't'}, Data: []byte{1, 2}}}
+	comment := &VorbisComment{v2.VorbisCommentBlock{Vendor: "test", Comments: []string{"TITLE=Title"}}}
+	picture := &Picture{v2.PictureBlock{PictureType: v2.PictureTypeFrontCover, MIME: "image/png", Data: []byte("\x89PNG")}}
+	table := &SeekTable{v2.SeekTableBlock{Points: []v2.SeekPoint{{SampleNumber: 0, Offset: 0, FrameSamples: 4096}}}}
+	data := testStream(t, info, table, app, comment, picture, &Padding{Size: 100})
+	fn := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+
+	ctx := context.Background()
+	f, err := ParseFile(ctx, fn, &ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if parsed, err := f.StreamInfo(); err != nil || parsed.SampleRate != 44100 || parsed.SampleCount != 4096 {
+		t.Errorf("Unexpected stream info %+v: %v", parsed, err)
+	}
+	if parsed, ok := f.Blocks[1].(*SeekTable); !ok || !parsed.Equal(&table.SeekTableBlock) {
+		t.Errorf("Unexpected SeekTable block %+v", f.Blocks[1])
+	}
+	if parsed, ok := f.Blocks[2].(*Application); !ok || parsed.ID != app.ID {
+		t.Errorf("Unexpected application block %+v", f.Blocks[2])
+	}
+	if parsed, ok := f.Blocks[3].(*VorbisComment); !ok || !reflect.DeepEqual(parsed.Get("title"), []string{"Title"}) {
+		t.Errorf("Unexpected VorbisComment block %+v", f.Blocks[3])
+	}
+	if parsed, ok := f.Blocks[4].(*Picture); !ok || !parsed.Equal(&picture.PictureBlock) {
+		t.Errorf("Unexpected Picture block %+v", f.Blocks[4])
+	}
+	if padding, ok := f.Blocks[5].(*Padding); !ok || padding.Size != 100 {
+		t.Errorf("Unexpected padding block %+v", f.Blocks[5])
+	}
+
+	// the grown application data fits in the padding, so only the metadata is rewritten
+	f.Blocks[2].(*Application).Data = make([]byte, 50)
+	var report v2.SaveReport
+	if err := f.Save(ctx, fn, &SaveOptions{Report: &report}); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	saved, _ := os.ReadFile(fn)
+	if report.Strategy != v2.SavePaddingPatch || len(saved) != len(data) || !bytes.HasSuffix(saved, data[len(data)-7:]) {
+		t.Errorf("Unexpected save %+v of %x", report, saved)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Parse(canceled, bytes.NewReader(data), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// some encoders write zero block sizes, which must not prevent saving the file back
+	info.BlockSizeMin, info.BlockSizeMax = 0, 0
+	if err := os.WriteFile(fn, testStream(t, info), 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+	if f, err = ParseFile(ctx, fn, nil); err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	defer f.Close()
+	if err := f.Save(ctx, fn, nil); err != nil {
+		t.Errorf("Failed to save a file with unknown block sizes: %s", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	info := &StreamInfo{v2.StreamInfoBlock{BlockSizeMin: 4096, BlockSizeMax: 4096, SampleRate: 44100, ChannelCount: 2, BitDepth: 16, SampleCount: 4096, AudioMD5: make([]byte, 16)}}
+	comment := &VorbisComment{v2.VorbisCommentBlock{Vendor: "test", Comments: []string{"TITLE=Title"}}}
+	picture := &Picture{v2.PictureBlock{PictureType: v2.PictureTypeFrontCover, MIME: "image/png", Data: []byte("\x89PNG")}}
+	data := testStream(t, info, comment, picture)
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "test.flac")
+	if err := os.WriteFile(fn, data, 0o644); err != nil {
+		t.Fatalf("Failed to write test file: %s", err)
+	}
+
+	ctx := context.Background()
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := ParseFile(canceled, fn, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	var events []v2.Event
+	f, err := ParseFile(ctx, fn, &ParseOptions{LazyBlocks: true, Events: func(e v2.Event) { events = append(events, e) }})
+	if err != nil {
+		t.Fatalf("Failed to parse file: %s", err)
+	}
+	if raw, ok := f.Blocks[2].(*Raw); !ok || raw.Meta.Loaded() {
+		t.Errorf("Lazy blocks should be kept as Raw, got %+v", f.Blocks[2])
+	}
+	if len(events) == 0 {
+		t.Error("Expected parse events")
+	}
+	out := filepath.Join(dir, "out.flac")
+	if err := f.Save(ctx, out, &SaveOptions{Sync: true, FileMode: 0o600}); err != nil {
+		t.Fatalf("Failed to save: %s", err)
+	}
+	f.Close()
+	if saved, err := os.ReadFile(out); err != nil || !bytes.Equal(saved, data) {
+		t.Errorf("Lazy blocks should be saved from the source: %v", err)
+	}
+	if stat, err := os.Stat(out); err != nil || runtime.GOOS != "windows" && stat.Mode().Perm()&0o077 != 0 {
+		t.Errorf("Unexpected permissions of the saved file: %v", err)
+	}
+
+	f, err = Parse(ctx, bytes.NewReader(data), &ParseOptions{BlockTypes: []v2.BlockType{v2.VorbisComment}})
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	if _, ok := f.Blocks[1].(*VorbisComment); !ok {
+		t.Errorf("Expected a VorbisComment block, got %+v", f.Blocks[1])
+	}
+	if _, ok := f.Blocks[2].(*Raw); !ok {
+		t.Errorf("Skipped blocks should be kept as Raw, got %+v", f.Blocks[2])
+	}
+
+	if _, err := Parse(ctx, bytes.NewReader(data), &ParseOptions{MaxBlocks: 2}); !errors.Is(err, v2.ErrorTooManyBlocks) {
+		t.Errorf("Expected ErrorTooManyBlocks, got %v", err)
+	}
+	if f, err := Parse(ctx, bytes.NewReader(data[:len(data)-20]), &ParseOptions{Partial: true}); err == nil || f == nil || len(f.Blocks) != 2 {
+		t.Errorf("Expected a partial File with an error, got %v", err)
+	}
+	if f, err := Parse(ctx, bytes.NewReader(append([]byte("ID3\x04\x00\x00\x00\x00\x00\x00"), data...)), &ParseOptions{SkipID3v2: true}); err != nil || len(f.Blocks) != 3 {
+		t.Errorf("Failed to parse after an ID3v2 tag: %v", err)
+	}
+}
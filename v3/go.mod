@@ -0,0 +1,10 @@
+module github.com/go-flac/go-flac/v3
+
+go 1.20
+
+require github.com/go-flac/go-flac/v2 v2.1.0
+
+require (
+	golang.org/x/sys v0.14.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)